@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/spf13/cobra"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+// artifactCmd creates a command to download a GitHub Actions artifact.
+func artifactCmd(ctx context.Context, f *flags, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{}
+	c := &cobra.Command{
+		Use:   "artifact [owner/repo]",
+		Short: "Download a GitHub Actions artifact",
+		Long: "Download the newest artifact of the workflow run, of the newest successful runs of the workflow,\n" +
+			"or of the repository, and extract its files into a directory named after it.\n" +
+			"--include and --exclude filter the files by globs like '*.xml' or 'reports/*'.\n" +
+			"Artifacts need a token in GH_TOKEN, even for public repositories.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := commandFormat(cmd, f, opts)
+			if err != nil {
+				return err
+			}
+			res, err := g.Artifact(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), format, res)
+		},
+	}
+	c.Flags().Int64Var(&opts.Run, "run", 0, "ID of the workflow run to download the artifact of")
+	c.Flags().StringVar(&opts.Workflow, "workflow", "", "workflow file, like build.yml, to download the artifact of its newest successful run")
+	c.Flags().StringVar(&opts.Branch, "branch", "", "branch of the workflow runs")
+	c.Flags().StringVar(&opts.Artifact, "name", "", "name of the artifact (default: the newest artifact)")
+	c.Flags().StringSliceVar(&opts.Include, "include", nil, "download only the files matching the globs")
+	c.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "skip the files matching the globs")
+	c.MarkFlagsMutuallyExclusive("run", "workflow")
+	c.MarkFlagsMutuallyExclusive("run", "branch")
+
+	return c
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+func TestArtifactCmd(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		g           gitty.Gitty
+		format      string
+		args        []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "success artifact",
+			g:        fakeNewGitty(),
+			args:     []string{"owner/repo", "--workflow", "build.yml", "--branch", "main", "--name", "coverage", "--include", "*.xml,*.html", "--exclude", "tmp/*"},
+			expected: (&gitty.DownloadResult{URLs: []string{"owner/repo"}, Refs: []string{"coverage"}}).String() + "\n",
+		},
+		{
+			name:     "success artifact ndjson",
+			g:        fakeNewGitty(),
+			format:   formatNDJSON,
+			args:     []string{"owner/repo", "--workflow", "build.yml", "--branch", "main", "--name", "coverage", "--include", "*.xml,*.html", "--exclude", "tmp/*"},
+			expected: `{"urls":["owner/repo"],"refs":["coverage"],"deleted":null,"total_files":0,"total_bytes":0,"duration_ns":0,"summary":null}` + "\n",
+		},
+		{
+			name:        "error mutually exclusive flags",
+			g:           fakeNewGitty(),
+			args:        []string{"owner/repo", "--run", "1", "--workflow", "build.yml"},
+			expectedErr: errors.New("if any flags in the group [run workflow] are set none of the others can be; [run workflow] were all set"),
+		},
+		{
+			name:        "error artifact",
+			g:           &mockError{},
+			args:        []string{"owner/repo", "--run", "1"},
+			expectedErr: errMockArtifact,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			c := artifactCmd(context.Background(), &flags{format: test.format}, test.g)
+			c.SetOut(&buf)
+			c.SetErr(io.Discard)
+			c.SilenceUsage = true
+			c.SetArgs(test.args)
+			err := c.Execute()
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"context"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+// catCmd creates a command to print GitHub files to stdout.
+func catCmd(ctx context.Context, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{Log: io.Discard}
+	c := &cobra.Command{
+		Use:   "cat [github file url or spec]...",
+		Short: "Print GitHub files to stdout",
+		Long: "Print the raw content of GitHub files to stdout in the given order.\n" +
+			"A line anchor like #L10 or #L10-L20 prints only those lines.",
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, url := range args {
+				if err := g.Cat(ctx, cmd.OutOrStdout(), url, opts); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	c.Flags().StringVar(&opts.Ref, "ref", "", "branch, tag, commit or version constraint (e.g., ^1.4) to read the files from")
+	c.Flags().StringVar(&opts.At, "at", "", "read the files as they were at the given time (e.g., 2024-01-31T00:00:00Z)")
+	c.Flags().StringVar(&opts.Path, "path", "", "path of the file to read, overriding the path of the urls")
+
+	return c
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+func TestCatCmd(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		g           gitty.Gitty
+		args        []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "success multiple files in order",
+			g:        fakeNewGitty(),
+			args:     []string{"first", "second", "--ref", "v1.0.0", "--at", "2024-01-31", "--path", "README.md"},
+			expected: "firstsecond",
+		},
+		{
+			name:        "error cat",
+			g:           &mockError{},
+			args:        []string{"first", "second"},
+			expected:    "",
+			expectedErr: errMockCat,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			c := catCmd(context.Background(), test.g)
+			c.SetOut(&buf)
+			c.SetErr(io.Discard)
+			c.SilenceUsage = true
+			c.SetArgs(test.args)
+			err := c.Execute()
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
@@ -4,12 +4,30 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// flags represents the flags for the root command.
+// flags represents the flags for the root command. The output format flags
+// are persistent, so the sub-commands share them.
 type flags struct {
-	set   string
-	auth  bool
-	check bool
-	unset bool
+	set         string
+	format      string
+	report      string
+	fromFile    string
+	archive     string
+	archiveFmt  string
+	ref         string
+	path        string
+	at          string
+	since       string
+	include     []string
+	exclude     []string
+	concurrency int
+	auth        bool
+	check       bool
+	unset       bool
+	json        bool
+	base        bool
+	merge       bool
+	prune       bool
+	prerelease  bool
 }
 
 // cmdFlags configures command flags for the root command.
@@ -18,4 +36,21 @@ func cmdFlags(c *cobra.Command, f *flags) {
 	c.Flags().BoolVarP(&f.auth, "auth", "a", false, "print authenticated username")
 	c.Flags().BoolVarP(&f.check, "check", "c", false, "check client status and remaining rate limit")
 	c.Flags().BoolVarP(&f.unset, "unset", "u", false, "unset github token from os environment variable")
+	c.PersistentFlags().BoolVar(&f.json, "json", false, "print results as json (same as --output-format=json)")
+	c.PersistentFlags().StringVar(&f.format, "output-format", formatText, "output format: text, json or ndjson")
+	c.Flags().StringVar(&f.report, "report", "", "write the download summary as json to the given file")
+	c.Flags().StringVarP(&f.fromFile, "from-file", "f", "", "read urls from the given file, one per line (use - for stdin)")
+	c.Flags().IntVar(&f.concurrency, "concurrency", 16, "maximum number of concurrent requests")
+	c.Flags().StringVar(&f.archive, "archive", "", "write downloads into a zip, tar.gz or tar.zst archive (use - for stdout)")
+	c.Flags().StringVar(&f.ref, "ref", "", "branch, tag, commit or version constraint (e.g., ^1.4) to download from, overriding the ref of the urls")
+	c.Flags().StringVar(&f.path, "path", "", "path to download, overriding the path of the urls (e.g., with git clone urls)")
+	c.Flags().BoolVar(&f.base, "base", false, "also download the base versions of the files changed by a pull request")
+	c.Flags().BoolVar(&f.merge, "merge", false, "download the files changed by a pull request at its merge ref (refs/pull/N/merge)")
+	c.Flags().StringVar(&f.at, "at", "", "download the path as it was at the given time (e.g., 2024-01-31T00:00:00Z or 2024-01-31)")
+	c.Flags().StringVar(&f.since, "since", "", "download only the files changed since the given ref or date (e.g., v1.0.0 or 2024-01-02)")
+	c.Flags().BoolVar(&f.prune, "prune", false, "delete the local files removed since the --since ref")
+	c.Flags().StringSliceVar(&f.include, "include", nil, "download only the files matching the globs (e.g., '*.md' or 'docs/*')")
+	c.Flags().StringSliceVar(&f.exclude, "exclude", nil, "skip the files matching the globs")
+	c.Flags().BoolVar(&f.prerelease, "prerelease", false, "let version constraints like ^1.4 pick prerelease tags")
+	c.Flags().StringVar(&f.archiveFmt, "archive-format", "", "archive format: zip, tar.gz or tar.zst (default: detected from the archive name, tar.gz for stdout)")
 }
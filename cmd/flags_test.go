@@ -22,4 +22,38 @@ func TestCmdFlags(t *testing.T) {
 	require.NoError(t, err)
 	_, err = c.Flags().GetBool("unset")
 	require.NoError(t, err)
+	_, err = c.PersistentFlags().GetBool("json")
+	require.NoError(t, err)
+	_, err = c.PersistentFlags().GetString("output-format")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("report")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("from-file")
+	require.NoError(t, err)
+	_, err = c.Flags().GetInt("concurrency")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("archive")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("archive-format")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("ref")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("path")
+	require.NoError(t, err)
+	_, err = c.Flags().GetBool("base")
+	require.NoError(t, err)
+	_, err = c.Flags().GetBool("merge")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("at")
+	require.NoError(t, err)
+	_, err = c.Flags().GetString("since")
+	require.NoError(t, err)
+	_, err = c.Flags().GetBool("prune")
+	require.NoError(t, err)
+	_, err = c.Flags().GetStringSlice("include")
+	require.NoError(t, err)
+	_, err = c.Flags().GetStringSlice("exclude")
+	require.NoError(t, err)
+	_, err = c.Flags().GetBool("prerelease")
+	require.NoError(t, err)
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+// installCmd creates a command to install the executable of a GitHub release.
+func installCmd(ctx context.Context, f *flags, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{}
+	var key string
+	c := &cobra.Command{
+		Use:   "install [owner/repo[@tag]]",
+		Short: "Install the executable of a GitHub release",
+		Long: "Install the executable of the latest release, or the release with the given tag, for the current platform.\n" +
+			"The tag may be a version constraint like @^1.4, which picks the highest matching tag.\n" +
+			"The asset is picked by the operating system and architecture in its name, or selected by --asset.\n" +
+			"Archives are extracted, the executable is placed into --bin-dir, and the install is recorded\n" +
+			"for 'gitty installed' and 'gitty upgrade'. Assets are verified like 'gitty release'.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := commandFormat(cmd, f, opts)
+			if err != nil {
+				return err
+			}
+			publicKey, err := readKey(key)
+			if err != nil {
+				return err
+			}
+			opts.PublicKey = publicKey
+			inst, err := g.Install(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), format, inst)
+		},
+	}
+	c.Flags().StringVar(&opts.BinDir, "bin-dir", "", "directory to place the executable into (default: ~/.local/bin)")
+	c.Flags().StringVar(&opts.Asset, "asset", "", "glob of the asset name to install (default: the asset of the current platform)")
+	c.Flags().StringVar(&opts.Verify, "verify", "auto", "verify the asset against the checksums of the release: auto, required or off")
+	c.Flags().StringVar(&key, "key", "", "public key, or a file holding it, to verify the signatures of the checksum files")
+	stateFileFlag(c, opts)
+
+	return c
+}
+
+// installedCmd creates a command to list the installs.
+func installedCmd(f *flags, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{}
+	c := &cobra.Command{
+		Use:   "installed",
+		Short: "List the executables installed from GitHub releases",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := commandFormat(cmd, f, opts)
+			if err != nil {
+				return err
+			}
+			res, err := g.Installed(opts)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), format, res)
+		},
+	}
+	stateFileFlag(c, opts)
+
+	return c
+}
+
+// upgradeCmd creates a command to upgrade the installs to the latest
+// releases.
+func upgradeCmd(ctx context.Context, f *flags, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{}
+	var key string
+	c := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade the installed executables to the latest GitHub releases",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			format, err := commandFormat(cmd, f, opts)
+			if err != nil {
+				return err
+			}
+			publicKey, err := readKey(key)
+			if err != nil {
+				return err
+			}
+			opts.PublicKey = publicKey
+			res, err := g.Upgrade(ctx, opts)
+			if res != nil {
+				if errRender := render(cmd.OutOrStdout(), format, res); errRender != nil {
+					return errors.Join(err, errRender)
+				}
+			}
+			return err
+		},
+	}
+	c.Flags().StringVar(&opts.Verify, "verify", "auto", "verify the assets against the checksums of the releases: auto, required or off")
+	c.Flags().StringVar(&key, "key", "", "public key, or a file holding it, to verify the signatures of the checksum files")
+	stateFileFlag(c, opts)
+
+	return c
+}
+
+// stateFileFlag adds the flag of the file which records the installs.
+func stateFileFlag(c *cobra.Command, opts *gitty.Options) {
+	c.Flags().StringVar(&opts.StateFile, "state-file", "", "file recording the installs (default: gitty/installed.json in the user config directory)")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+func TestInstallCmds(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		cmd         func(f *flags, g gitty.Gitty) *cobra.Command
+		format      string
+		g           gitty.Gitty
+		args        []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name: "success install",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return installCmd(context.Background(), f, g)
+			},
+			g:        fakeNewGitty(),
+			args:     []string{"owner/tool@v1.0.0", "--bin-dir", "bin", "--asset", "*linux*", "--state-file", "state.json"},
+			expected: "Installed owner/tool@v1.0.0 v1.0.0: " + filepath.Join("bin", "tool") + "\n",
+		},
+		{
+			name: "error install key",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return installCmd(context.Background(), f, g)
+			},
+			g:           fakeNewGitty(),
+			args:        []string{"owner/tool", "--key", "."},
+			expectedErr: &fs.PathError{Op: "read", Path: ".", Err: syscall.EISDIR},
+		},
+		{
+			name: "error install",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return installCmd(context.Background(), f, g)
+			},
+			g:           &mockError{},
+			args:        []string{"owner/tool"},
+			expectedErr: errMockInstall,
+		},
+		{
+			name:     "success installed",
+			cmd:      installedCmd,
+			g:        fakeNewGitty(),
+			args:     []string{"--state-file", "state.json"},
+			expected: "No installs\n",
+		},
+		{
+			name:     "success installed json",
+			cmd:      installedCmd,
+			g:        fakeNewGitty(),
+			format:   formatJSON,
+			args:     []string{"--state-file", "state.json"},
+			expected: "{\n  \"installs\": null\n}\n",
+		},
+		{
+			name:        "error installed format",
+			cmd:         installedCmd,
+			g:           fakeNewGitty(),
+			format:      "yaml",
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "error installed",
+			cmd:         installedCmd,
+			g:           &mockError{},
+			expectedErr: errMockInstall,
+		},
+		{
+			name: "success upgrade",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return upgradeCmd(context.Background(), f, g)
+			},
+			g:        fakeNewGitty(),
+			args:     []string{"--verify", "required"},
+			expected: "No installs\n",
+		},
+		{
+			name: "error upgrade key",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return upgradeCmd(context.Background(), f, g)
+			},
+			g:           fakeNewGitty(),
+			args:        []string{"--key", "."},
+			expectedErr: &fs.PathError{Op: "read", Path: ".", Err: syscall.EISDIR},
+		},
+		{
+			name: "error upgrade",
+			cmd: func(f *flags, g gitty.Gitty) *cobra.Command {
+				return upgradeCmd(context.Background(), f, g)
+			},
+			g:           &mockError{},
+			expected:    "No installs\n",
+			expectedErr: errMockUpgrade,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			c := test.cmd(&flags{format: test.format}, test.g)
+			c.SetOut(&buf)
+			c.SetErr(io.Discard)
+			c.SilenceUsage = true
+			c.SetArgs(test.args)
+			err := c.Execute()
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
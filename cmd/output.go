@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+// Output formats.
+const (
+	formatText   = "text"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+var ErrNotValidFormat = errors.New("output format must be one of text, json or ndjson")
+
+// outputFormat returns the selected output format.
+func (f *flags) outputFormat() (string, error) {
+	if f.json {
+		return formatJSON, nil
+	}
+
+	switch f.format {
+	case "", formatText:
+		return formatText, nil
+	case formatJSON, formatNDJSON:
+		return f.format, nil
+	default:
+		return "", ErrNotValidFormat
+	}
+}
+
+// downloadSummary represents the totals line of a ndjson download result,
+// which has all of the result but its files.
+type downloadSummary struct {
+	URLs       []string       `json:"urls"`
+	Refs       []string       `json:"refs"`
+	Commits    []string       `json:"commits,omitempty"`
+	Deleted    []string       `json:"deleted"`
+	TotalFiles int            `json:"total_files"`
+	TotalBytes int64          `json:"total_bytes"`
+	Duration   time.Duration  `json:"duration_ns"`
+	Summary    *gitty.Summary `json:"summary"`
+}
+
+// commandFormat returns the output format of a sub-command. The progress
+// messages of opts go to the output of the command, unless the results are
+// printed as json, which must stay machine-readable.
+func commandFormat(cmd *cobra.Command, f *flags, opts *gitty.Options) (string, error) {
+	format, err := f.outputFormat()
+	if err != nil {
+		return "", err
+	}
+	opts.Log = cmd.OutOrStdout()
+	if format != formatText {
+		opts.Log = io.Discard
+	}
+
+	return format, nil
+}
+
+// render writes the result to w in the given format.
+func render(w io.Writer, format string, v fmt.Stringer) error {
+	switch format {
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case formatNDJSON:
+		enc := json.NewEncoder(w)
+		res, ok := v.(*gitty.DownloadResult)
+		if !ok {
+			return enc.Encode(v)
+		}
+		// Each file is a record, followed by the totals.
+		for _, f := range res.Files {
+			if err := enc.Encode(f); err != nil {
+				return err
+			}
+		}
+		return enc.Encode(&downloadSummary{
+			URLs:       res.URLs,
+			Refs:       res.Refs,
+			Commits:    res.Commits,
+			Deleted:    res.Deleted,
+			TotalFiles: res.TotalFiles,
+			TotalBytes: res.TotalBytes,
+			Duration:   res.Duration,
+			Summary:    res.Summary,
+		})
+	default:
+		_, err := fmt.Fprintln(w, v)
+		return err
+	}
+}
+
+// writeReport writes the download result as json to the named file.
+func writeReport(name string, res *gitty.DownloadResult) error {
+	b, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, append(b, '\n'), 0o600)
+}
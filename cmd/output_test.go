@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+func TestOutputFormat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		flags       flags
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "default",
+			flags:    flags{},
+			expected: formatText,
+		},
+		{
+			name:     "text",
+			flags:    flags{format: formatText},
+			expected: formatText,
+		},
+		{
+			name:     "json flag",
+			flags:    flags{json: true, format: formatNDJSON},
+			expected: formatJSON,
+		},
+		{
+			name:     "ndjson",
+			flags:    flags{format: formatNDJSON},
+			expected: formatNDJSON,
+		},
+		{
+			name:        "invalid",
+			flags:       flags{format: "yaml"},
+			expected:    "",
+			expectedErr: ErrNotValidFormat,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			format, err := test.flags.outputFormat()
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	t.Parallel()
+	download := &gitty.DownloadResult{
+		URLs:    []string{"github.com/owner/repo/tree/main/dir"},
+		Refs:    []string{"main"},
+		Commits: []string{"c0ffee"},
+		Deleted: []string{"dir/old.txt"},
+		Files: []*gitty.File{
+			{LocalPath: "dir/a.txt", RemotePath: "dir/a.txt", Size: 1, SHA: "a", URL: "https://a"},
+			{LocalPath: "dir/b.txt", RemotePath: "dir/b.txt", Size: 2, SHA: "b", URL: "https://b"},
+		},
+		TotalFiles: 2,
+		TotalBytes: 3,
+		Duration:   time.Second,
+		Summary: &gitty.Summary{
+			APICalls:           2,
+			RateLimitRemaining: 58,
+			Skipped:            gitty.Skipped{Submodules: 1, Symlinks: 2, Filtered: 3},
+			Retries:            1,
+			Throughput:         3,
+		},
+	}
+	auth := &gitty.AuthResult{Login: "octocat", ID: 1}
+
+	tests := []struct {
+		name     string
+		format   string
+		v        fmt.Stringer
+		expected string
+	}{
+		{
+			name:     "text",
+			format:   formatText,
+			v:        auth,
+			expected: "Authenticated as @octocat \n",
+		},
+		{
+			name:     "json",
+			format:   formatJSON,
+			v:        auth,
+			expected: "{\n  \"login\": \"octocat\",\n  \"id\": 1\n}\n",
+		},
+		{
+			name:     "ndjson",
+			format:   formatNDJSON,
+			v:        auth,
+			expected: "{\"login\":\"octocat\",\"id\":1}\n",
+		},
+		{
+			name:   "ndjson download",
+			format: formatNDJSON,
+			v:      download,
+			expected: `{"local_path":"dir/a.txt","remote_path":"dir/a.txt","size":1,"sha":"a","url":"https://a","duration_ns":0}
+{"local_path":"dir/b.txt","remote_path":"dir/b.txt","size":2,"sha":"b","url":"https://b","duration_ns":0}
+{"urls":["github.com/owner/repo/tree/main/dir"],"refs":["main"],"commits":["c0ffee"],"deleted":["dir/old.txt"],"total_files":2,"total_bytes":3,"duration_ns":1000000000,"summary":{"directories":0,"api_calls":2,"rate_limit_remaining":58,"skipped":{"submodules":1,"symlinks":2,"filtered":3},"retries":1,"failures":0,"throughput_bytes_per_sec":3,"slowest":null}}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			err := render(&buf, test.format, test.v)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	res := &gitty.DownloadResult{
+		URLs:       []string{"github.com/owner/repo/tree/main/dir"},
+		TotalFiles: 1,
+		Summary:    &gitty.Summary{APICalls: 1, RateLimitRemaining: 59},
+	}
+
+	name := filepath.Join(dir, "report.json")
+	err := writeReport(name, res)
+	require.NoError(t, err)
+
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	actual := &gitty.DownloadResult{}
+	err = json.Unmarshal(b, actual)
+	require.NoError(t, err)
+	assert.Equal(t, res, actual)
+
+	err = writeReport(filepath.Join(dir, "missing", "report.json"), res)
+	require.Error(t, err)
+}
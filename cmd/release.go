@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+// pemPrefix starts the inline PEM encoded keys.
+const pemPrefix = "-----BEGIN"
+
+// releaseCmd creates a command to download the assets of a GitHub release.
+func releaseCmd(ctx context.Context, f *flags, g gitty.Gitty) *cobra.Command {
+	opts := &gitty.Options{}
+	var latest bool
+	var key string
+	c := &cobra.Command{
+		Use:   "release [owner/repo]",
+		Short: "Download GitHub release assets",
+		Long: "Download the assets of the latest release, the newest release including prereleases,\n" +
+			"or the release with the given tag. --asset selects the assets by a glob like '*linux_amd64*.tar.gz'.\n" +
+			"Assets are verified against the SHA256SUMS, checksums.txt or *.sha256 files of the release.\n" +
+			"--key also verifies the minisign (.minisig) or cosign-style (.sig) signatures of the checksum files.\n" +
+			"Private repositories need a token in GH_TOKEN.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := commandFormat(cmd, f, opts)
+			if err != nil {
+				return err
+			}
+			publicKey, err := readKey(key)
+			if err != nil {
+				return err
+			}
+			opts.PublicKey = publicKey
+			res, err := g.Release(ctx, args[0], opts)
+			if err != nil {
+				return err
+			}
+			return render(cmd.OutOrStdout(), format, res)
+		},
+	}
+	c.Flags().StringVar(&opts.Ref, "tag", "", "tag of the release to download, or a version constraint like ^1.4")
+	c.Flags().BoolVar(&latest, "latest", false, "download the latest release (default)")
+	c.Flags().BoolVar(&opts.Prerelease, "prerelease", false, "download the newest release, which may be a prerelease")
+	c.Flags().StringVar(&opts.Asset, "asset", "", "glob of the asset names to download (default: all assets)")
+	c.Flags().StringVar(&opts.Verify, "verify", "auto", "verify the assets against the checksums of the release: auto, required or off")
+	c.Flags().StringVar(&key, "key", "", "public key, or a file holding it, to verify the signatures of the checksum files")
+	c.MarkFlagsMutuallyExclusive("tag", "latest", "prerelease")
+
+	return c
+}
+
+// readKey returns the public key, which may be given as the name of a file
+// holding it. A missing file is an error if the key looks like a path, so
+// that a mistyped name is not taken for the key.
+func readKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, pemPrefix) {
+		return key, nil
+	}
+	b, err := os.ReadFile(key)
+	if errors.Is(err, fs.ErrNotExist) && !isKeyPath(key) {
+		return key, nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// isKeyPath reports whether the key looks like the name of a file. Inline
+// minisign keys are base64, which has no dots or backslashes, and start
+// with RW.
+func isKeyPath(key string) bool {
+	return strings.ContainsAny(key, `.\`) || strings.HasPrefix(key, "/") || strings.HasPrefix(key, "~")
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/worlpaker/gitty/gitty"
+)
+
+func TestReleaseCmd(t *testing.T) {
+	t.Parallel()
+	expectedJSON, err := json.MarshalIndent(&gitty.DownloadResult{URLs: []string{"owner/repo"}, Refs: []string{"v1.0.0"}}, "", "  ")
+	require.NoError(t, err)
+	tests := []struct {
+		name        string
+		g           gitty.Gitty
+		format      string
+		args        []string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "success release",
+			g:        fakeNewGitty(),
+			args:     []string{"owner/repo", "--tag", "v1.0.0", "--asset", "*linux*", "--verify", "required", "--key", "RWQkey"},
+			expected: (&gitty.DownloadResult{URLs: []string{"owner/repo"}, Refs: []string{"v1.0.0"}}).String() + "\n",
+		},
+		{
+			name:     "success release json",
+			g:        fakeNewGitty(),
+			format:   formatJSON,
+			args:     []string{"owner/repo", "--tag", "v1.0.0"},
+			expected: string(expectedJSON) + "\n",
+		},
+		{
+			name:        "error output format",
+			g:           fakeNewGitty(),
+			format:      "yaml",
+			args:        []string{"owner/repo"},
+			expectedErr: ErrNotValidFormat,
+		},
+		{
+			name:        "error mutually exclusive flags",
+			g:           fakeNewGitty(),
+			args:        []string{"owner/repo", "--tag", "v1.0.0", "--prerelease"},
+			expectedErr: errors.New("if any flags in the group [tag latest prerelease] are set none of the others can be; [prerelease tag] were all set"),
+		},
+		{
+			name:        "error key",
+			g:           fakeNewGitty(),
+			args:        []string{"owner/repo", "--key", "."},
+			expectedErr: &fs.PathError{Op: "read", Path: ".", Err: syscall.EISDIR},
+		},
+		{
+			name:        "error release",
+			g:           &mockError{},
+			args:        []string{"owner/repo", "--latest"},
+			expectedErr: errMockRelease,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			c := releaseCmd(context.Background(), &flags{format: test.format}, test.g)
+			c.SetOut(&buf)
+			c.SetErr(io.Discard)
+			c.SilenceUsage = true
+			c.SetArgs(test.args)
+			err := c.Execute()
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "key.pub")
+	err := os.WriteFile(name, []byte("RWQfile"), 0o600)
+	require.NoError(t, err)
+
+	missing := filepath.Join(t.TempDir(), "missing.pub")
+	pem := "-----BEGIN PUBLIC KEY-----\nMCowBQYDK2VwAyEA\n-----END PUBLIC KEY-----\n"
+
+	tests := []struct {
+		name        string
+		key         string
+		expected    string
+		expectedErr error
+	}{
+		{name: "empty", key: "", expected: ""},
+		{name: "inline key", key: "RWQinline", expected: "RWQinline"},
+		{name: "inline base64 key", key: "RWQ/in+line", expected: "RWQ/in+line"},
+		{name: "inline pem key", key: pem, expected: pem},
+		{name: "key file", key: name, expected: "RWQfile"},
+		{name: "error missing key file", key: missing, expectedErr: &fs.PathError{Op: "open", Path: missing, Err: syscall.ENOENT}},
+		{name: "error missing relative key file", key: "minisign.pub", expectedErr: &fs.PathError{Op: "open", Path: "minisign.pub", Err: syscall.ENOENT}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			key, err := readKey(test.key)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, key)
+		})
+	}
+}
@@ -1,20 +1,31 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/worlpaker/gitty/gitty"
 	"github.com/worlpaker/gitty/gitty/token"
 )
 
-// nArgs represents the maximum number of args.
+// nArgs represents the minimum number of urls to download.
 const nArgs = 1
 
 // subCommands adds sub-commands to the root command.
-func subCommands(c *cobra.Command) {
+func subCommands(ctx context.Context, c *cobra.Command, f *flags, g gitty.Gitty) {
 	c.AddCommand(versionCmd())
+	c.AddCommand(catCmd(ctx, g))
+	c.AddCommand(releaseCmd(ctx, f, g))
+	c.AddCommand(artifactCmd(ctx, f, g))
+	c.AddCommand(installCmd(ctx, f, g))
+	c.AddCommand(installedCmd(f, g))
+	c.AddCommand(upgradeCmd(ctx, f, g))
 }
 
 // cmdSettings configures settings for the root command.
@@ -28,21 +39,103 @@ func cmdSettings(c *cobra.Command) {
 // runRoot prepares and returns a function to execute the root command.
 func runRoot(ctx context.Context, f *flags, g gitty.Gitty) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
+		format, err := f.outputFormat()
+		if err != nil {
+			return err
+		}
+		out := cmd.OutOrStdout()
+
+		urls := args
+		if f.fromFile != "" {
+			listed, err := readURLs(cmd.InOrStdin(), f.fromFile)
+			if err != nil {
+				return err
+			}
+			urls = append(urls, listed...)
+		}
+
 		switch {
 		case f.auth:
-			return g.Auth(ctx)
+			res, err := g.Auth(ctx)
+			if err != nil {
+				return err
+			}
+			return render(out, format, res)
 		case f.check:
-			return g.Status(ctx)
+			res, err := g.Status(ctx)
+			if err != nil {
+				return err
+			}
+			return render(out, format, res)
 		case f.set != "":
 			return token.Set(f.set)
 		case f.unset:
 			return token.Unset()
-		case len(args) < nArgs:
+		case len(urls) < nArgs:
 			return cmd.Help()
 		default:
-			return g.Download(ctx, args[0])
+			opts := &gitty.Options{
+				Log:           out,
+				Ref:           f.ref,
+				Path:          f.path,
+				Base:          f.base,
+				Merge:         f.merge,
+				At:            f.at,
+				Since:         f.since,
+				Prune:         f.prune,
+				Include:       f.include,
+				Exclude:       f.exclude,
+				Prerelease:    f.prerelease,
+				Concurrency:   f.concurrency,
+				Archive:       f.archive,
+				ArchiveFormat: f.archiveFmt,
+				Stdout:        out,
+			}
+			if f.archive == "-" {
+				// Stdout receives the archive, so everything else goes to stderr.
+				out = cmd.ErrOrStderr()
+				opts.Log = out
+			}
+			if format != formatText {
+				// Keep the output machine-readable.
+				opts.Log = io.Discard
+			}
+			res, err := g.Download(ctx, urls, opts)
+			if f.report != "" && res != nil {
+				err = errors.Join(err, writeReport(f.report, res))
+			}
+			if err != nil {
+				return err
+			}
+			return render(out, format, res)
+		}
+	}
+}
+
+// readURLs reads the urls from the named file, or from stdin if the name
+// is "-". Blank lines and lines starting with # are ignored.
+func readURLs(stdin io.Reader, name string) ([]string, error) {
+	r := stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var urls []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
 		}
+		urls = append(urls, line)
 	}
+
+	return urls, scanner.Err()
 }
 
 // Execute executes the root command.
@@ -50,10 +143,10 @@ func Execute(ctx context.Context, version string) error {
 	g := gitty.New()
 	f := &flags{}
 	c := &cobra.Command{
-		Use:          "gitty [github url]",
+		Use:          "gitty [url or spec]...",
 		Short:        "Download GitHub File & Directory",
 		RunE:         runRoot(ctx, f, g),
-		Args:         cobra.MaximumNArgs(nArgs),
+		Args:         cobra.ArbitraryArgs,
 		Version:      version,
 		SilenceUsage: true,
 	}
@@ -61,7 +154,7 @@ func Execute(ctx context.Context, version string) error {
 	// Configurations for root.
 	cmdFlags(c, f)
 	cmdSettings(c)
-	subCommands(c)
+	subCommands(ctx, c, f, g)
 
 	return c.Execute()
 }
@@ -1,12 +1,18 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-github/v70/github"
 	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,25 +26,116 @@ func fakeNewGitty() gitty.Gitty {
 
 type mock struct{}
 
-func (m *mock) Status(_ context.Context) error {
-	return nil
+func (m *mock) Status(_ context.Context) (*gitty.StatusResult, error) {
+	return &gitty.StatusResult{RateLimits: &github.RateLimits{Core: &github.Rate{}}}, nil
 }
 
-func (m *mock) Download(_ context.Context, _ string) error {
-	return nil
+func (m *mock) Download(_ context.Context, urls []string, _ *gitty.Options) (*gitty.DownloadResult, error) {
+	return &gitty.DownloadResult{URLs: urls}, nil
 }
 
-func (m *mock) Auth(_ context.Context) error {
-	return nil
+func (m *mock) Auth(_ context.Context) (*gitty.AuthResult, error) {
+	return &gitty.AuthResult{}, nil
+}
+
+func (m *mock) Cat(_ context.Context, w io.Writer, url string, _ *gitty.Options) error {
+	_, err := io.WriteString(w, url)
+	return err
+}
+
+func (m *mock) Release(_ context.Context, spec string, opts *gitty.Options) (*gitty.DownloadResult, error) {
+	return &gitty.DownloadResult{URLs: []string{spec}, Refs: []string{opts.Ref}}, nil
+}
+
+func (m *mock) Artifact(_ context.Context, spec string, opts *gitty.Options) (*gitty.DownloadResult, error) {
+	if len(opts.Include) != 2 || len(opts.Exclude) != 1 {
+		return nil, errors.New("unexpected filters")
+	}
+	return &gitty.DownloadResult{URLs: []string{spec}, Refs: []string{opts.Artifact}}, nil
+}
+
+func (m *mock) Install(_ context.Context, spec string, opts *gitty.Options) (*gitty.Install, error) {
+	return &gitty.Install{Repo: spec, Tag: "v1.0.0", Pattern: opts.Asset, Path: filepath.Join(opts.BinDir, "tool")}, nil
+}
+
+func (m *mock) Installed(_ *gitty.Options) (*gitty.InstalledResult, error) {
+	return &gitty.InstalledResult{}, nil
+}
+
+func (m *mock) Upgrade(_ context.Context, _ *gitty.Options) (*gitty.UpgradeResult, error) {
+	return &gitty.UpgradeResult{}, nil
+}
+
+var (
+	errMockStatus   = errors.New("mock status error")
+	errMockAuth     = errors.New("mock auth error")
+	errMockDownload = errors.New("mock download error")
+	errMockCat      = errors.New("mock cat error")
+	errMockRelease  = errors.New("mock release error")
+	errMockArtifact = errors.New("mock artifact error")
+	errMockInstall  = errors.New("mock install error")
+	errMockUpgrade  = errors.New("mock upgrade error")
+)
+
+type mockError struct{}
+
+func (m *mockError) Status(_ context.Context) (*gitty.StatusResult, error) {
+	return nil, errMockStatus
+}
+
+func (m *mockError) Download(_ context.Context, _ []string, _ *gitty.Options) (*gitty.DownloadResult, error) {
+	return nil, errMockDownload
+}
+
+func (m *mockError) Auth(_ context.Context) (*gitty.AuthResult, error) {
+	return nil, errMockAuth
+}
+
+func (m *mockError) Cat(_ context.Context, _ io.Writer, _ string, _ *gitty.Options) error {
+	return errMockCat
+}
+
+func (m *mockError) Release(_ context.Context, _ string, _ *gitty.Options) (*gitty.DownloadResult, error) {
+	return nil, errMockRelease
+}
+
+func (m *mockError) Artifact(_ context.Context, _ string, _ *gitty.Options) (*gitty.DownloadResult, error) {
+	return nil, errMockArtifact
+}
+
+func (m *mockError) Install(_ context.Context, _ string, _ *gitty.Options) (*gitty.Install, error) {
+	return nil, errMockInstall
+}
+
+func (m *mockError) Installed(_ *gitty.Options) (*gitty.InstalledResult, error) {
+	return nil, errMockInstall
+}
+
+func (m *mockError) Upgrade(_ context.Context, _ *gitty.Options) (*gitty.UpgradeResult, error) {
+	return &gitty.UpgradeResult{}, errMockUpgrade
 }
 
 func TestSubCommands(t *testing.T) {
 	t.Parallel()
 	c := &cobra.Command{}
-	subCommands(c)
+	subCommands(context.Background(), c, &flags{}, fakeNewGitty())
 	assert.True(t, c.HasSubCommands())
 }
 
+func TestSubCommandsOutputFormat(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	c := &cobra.Command{}
+	f := &flags{}
+	cmdFlags(c, f)
+	subCommands(context.Background(), c, f, fakeNewGitty())
+	c.SetOut(&buf)
+	c.SetArgs([]string{"installed", "--json"})
+	err := c.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"installs\": null\n}\n", buf.String())
+}
+
 func TestCmdSettings(t *testing.T) {
 	t.Parallel()
 	c := &cobra.Command{}
@@ -61,6 +158,77 @@ func TestExecute(t *testing.T) {
 	require.NoError(t, err)
 }
 
+// urlsFile creates a file which lists urls.
+func urlsFile(t *testing.T) string {
+	t.Helper()
+	name := filepath.Join(t.TempDir(), "urls.txt")
+	err := os.WriteFile(name, []byte("arg1\n\n# comment\n  arg2  \n"), 0o600)
+	require.NoError(t, err)
+	return name
+}
+
+func TestReadURLs(t *testing.T) {
+	t.Parallel()
+	expected := []string{"arg1", "arg2"}
+
+	urls, err := readURLs(strings.NewReader(""), urlsFile(t))
+	require.NoError(t, err)
+	assert.Equal(t, expected, urls)
+
+	urls, err = readURLs(strings.NewReader("arg1\narg2"), "-")
+	require.NoError(t, err)
+	assert.Equal(t, expected, urls)
+}
+
+func TestRunRootErrors(t *testing.T) {
+	t.Parallel()
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	tests := []struct {
+		name     string
+		flags    flags
+		args     []string
+		expected error
+	}{
+		{
+			name:     "invalid output format",
+			flags:    flags{format: "xml"},
+			args:     []string{"arg1"},
+			expected: ErrNotValidFormat,
+		},
+		{
+			name:     "error from file",
+			flags:    flags{fromFile: missing},
+			expected: fs.ErrNotExist,
+		},
+		{
+			name:     "error download",
+			flags:    flags{},
+			args:     []string{"arg1"},
+			expected: errMockDownload,
+		},
+		{
+			name:     "error status",
+			flags:    flags{check: true},
+			expected: errMockStatus,
+		},
+		{
+			name:     "error auth",
+			flags:    flags{auth: true},
+			expected: errMockAuth,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c := &cobra.Command{}
+			runFunc := runRoot(context.Background(), &test.flags, &mockError{})
+			err := runFunc(c, test.args)
+			assert.ErrorIs(t, err, test.expected)
+		})
+	}
+}
+
 func TestRunRoot(t *testing.T) {
 	t.Parallel()
 	// Restore token.
@@ -103,12 +271,45 @@ func TestRunRoot(t *testing.T) {
 			flags: flags{},
 			args:  []string{"arg1"},
 		},
+		{
+			name:  "multiple urls",
+			flags: flags{},
+			args:  []string{"arg1", "arg2"},
+		},
+		{
+			name:  "urls from file",
+			flags: flags{fromFile: urlsFile(t)},
+		},
+		{
+			name:  "default case with json",
+			flags: flags{json: true},
+			args:  []string{"arg1"},
+		},
+		{
+			name:  "default case with archive to stdout",
+			flags: flags{archive: "-"},
+			args:  []string{"arg1"},
+		},
+		{
+			name:  "default case with report",
+			flags: flags{report: filepath.Join(t.TempDir(), "report.json")},
+			args:  []string{"arg1"},
+		},
+		{
+			name:  "check flag with ndjson",
+			flags: flags{check: true, format: formatNDJSON},
+		},
+		{
+			name:  "auth flag with json",
+			flags: flags{auth: true, format: formatJSON},
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			c := &cobra.Command{}
+			c.SetOut(io.Discard)
 			g := fakeNewGitty()
 			runFunc := runRoot(context.Background(), &test.flags, g)
 			err := runFunc(c, test.args)
@@ -0,0 +1,416 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Archive formats.
+const (
+	formatZip    = "zip"
+	formatTarGz  = "tar.gz"
+	formatTarZst = "tar.zst"
+)
+
+// modeExecutable represents the git mode of an executable file.
+const modeExecutable = "100755"
+
+var (
+	ErrNotValidArchive = errors.New("archive format must be zip, tar.gz or tar.zst")
+	ErrBadSize         = errors.New("downloaded size does not match")
+)
+
+// archiveTime represents the modification time of every archive entry, so
+// that archives are reproducible. Zip does not support earlier times.
+var archiveTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// archive writes the downloaded files into a zip, tar.gz or tar.zst archive.
+// The entries are written in path order with fixed modes and timestamps.
+// Once every source of the run has listed its files, the file which is next
+// in order is written straight from its download. Files that arrive early,
+// or whose position is unknown, are spooled to temporary files until their
+// turn or the close.
+type archive struct {
+	name    string
+	format  string
+	stdout  io.Writer
+	mu      sync.Mutex
+	sources int
+	listed  int
+	unknown bool
+	modes   map[string]string
+	order   []string
+	next    int
+	// streaming is set while the next entry is written from its download,
+	// without the lock.
+	streaming bool
+	pending   map[string]*spooled
+	file      *os.File
+	packer    io.WriteCloser
+	zip       *zip.Writer
+	tar       *tar.Writer
+	err       error
+}
+
+// spooled represents a file spooled to a temporary file.
+type spooled struct {
+	file *os.File
+	size int64
+}
+
+// archiveFormat returns the given format if set. Otherwise, it detects the
+// format from the archive name. Stdout defaults to tar.gz.
+func archiveFormat(name, format string) (string, error) {
+	switch format {
+	case formatZip, formatTarGz, formatTarZst:
+		return format, nil
+	case "":
+	default:
+		return "", ErrNotValidArchive
+	}
+
+	switch {
+	case name == "-":
+		return formatTarGz, nil
+	case strings.HasSuffix(name, ".zip"):
+		return formatZip, nil
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return formatTarGz, nil
+	case strings.HasSuffix(name, ".tar.zst"), strings.HasSuffix(name, ".tzst"):
+		return formatTarZst, nil
+	default:
+		return "", ErrNotValidArchive
+	}
+}
+
+// newArchive creates an archive with the given name, or "-" for stdout.
+func newArchive(name, format string, stdout io.Writer) (*archive, error) {
+	format, err := archiveFormat(name, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return &archive{
+		name:   name,
+		format: format,
+		stdout: stdout,
+	}, nil
+}
+
+// source registers a source of files, which lists them with expect.
+func (a *archive) source() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sources++
+}
+
+// expect records the entry names and git modes listed by a source. Nil
+// entries mean that the source can't list them upfront, so the entries
+// are written when the archive is closed.
+func (a *archive) expect(entries map[string]string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.listed++
+	if entries == nil {
+		a.unknown = true
+		return nil
+	}
+
+	if a.modes == nil {
+		a.modes = make(map[string]string, len(entries))
+	}
+	for name, mode := range entries {
+		a.modes[name] = mode
+		a.order = append(a.order, name)
+	}
+	sort.Strings(a.order)
+
+	return a.flush()
+}
+
+// save adds the file at its local path to the archive. The size of the
+// body is -1 if unknown. It returns the entry name and the number of bytes
+// written.
+func (a *archive) save(base, path string, body io.Reader, size int64) (string, int64, error) {
+	p, err := exactPath(base, path)
+	if err != nil {
+		return "", 0, err
+	}
+	name := filepath.ToSlash(p)
+
+	a.mu.Lock()
+	if a.ready() && a.next < len(a.order) && a.order[a.next] == name {
+		// The entries after it wait for it, so it is written without the
+		// lock.
+		a.streaming = true
+		mode := a.modes[name]
+		a.mu.Unlock()
+		n, err := a.write(name, mode, body, size)
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		a.streaming = false
+		if err != nil {
+			a.err = err
+			return "", 0, err
+		}
+		a.next++
+		return name, n, a.flush()
+	}
+	a.mu.Unlock()
+
+	s, err := spool(body)
+	if err != nil {
+		return "", 0, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*spooled)
+	}
+	if old, ok := a.pending[name]; ok {
+		if err := old.drop(); err != nil {
+			return "", 0, err
+		}
+	}
+	a.pending[name] = s
+	if err := a.flush(); err != nil {
+		return "", 0, err
+	}
+
+	return name, s.size, nil
+}
+
+// ready reports whether the order of the entries is known, which it is once
+// every source has listed its entries.
+func (a *archive) ready() bool {
+	return a.err == nil && !a.unknown && a.listed >= a.sources
+}
+
+// flush writes the spooled entries that are next in order.
+func (a *archive) flush() error {
+	if a.err != nil {
+		return a.err
+	}
+	if !a.ready() || a.streaming {
+		return nil
+	}
+
+	for ; a.next < len(a.order); a.next++ {
+		name := a.order[a.next]
+		s, ok := a.pending[name]
+		if !ok {
+			break
+		}
+		delete(a.pending, name)
+		if a.err = a.writeSpooled(name, a.modes[name], s); a.err != nil {
+			return a.err
+		}
+	}
+
+	return nil
+}
+
+// spool copies the body to a temporary file.
+func spool(body io.Reader) (*spooled, error) {
+	f, err := os.CreateTemp("", "gitty-*")
+	if err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &spooled{file: f, size: n}, nil
+}
+
+// drop closes and removes the spooled file.
+func (s *spooled) drop() error {
+	return errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
+
+// writeSpooled writes the spooled file as the entry, and drops it.
+func (a *archive) writeSpooled(name, mode string, s *spooled) error {
+	_, err := s.file.Seek(0, io.SeekStart)
+	if err == nil {
+		_, err = a.write(name, mode, s.file, s.size)
+	}
+	return errors.Join(err, s.drop())
+}
+
+// open creates the archive and its writers, if not created yet.
+func (a *archive) open() error {
+	if a.zip != nil || a.tar != nil {
+		return nil
+	}
+
+	w := a.stdout
+	if a.name != "-" {
+		f, err := os.OpenFile(a.name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+		if err != nil {
+			return err
+		}
+		a.file = f
+		w = f
+	}
+
+	switch a.format {
+	case formatZip:
+		a.zip = zip.NewWriter(w)
+		return nil
+	case formatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		a.packer = zw
+	default:
+		a.packer = gzip.NewWriter(w)
+	}
+	a.tar = tar.NewWriter(a.packer)
+
+	return nil
+}
+
+// write writes the entry from the body, whose size is -1 if unknown. Tar
+// headers hold the size, so bodies of unknown size are spooled first for
+// tar archives.
+func (a *archive) write(name, mode string, body io.Reader, size int64) (int64, error) {
+	if err := a.open(); err != nil {
+		return 0, err
+	}
+
+	if a.zip != nil {
+		hdr := &zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: archiveTime,
+		}
+		hdr.SetMode(fileMode(mode))
+
+		entry, err := a.zip.CreateHeader(hdr)
+		if err != nil {
+			return 0, err
+		}
+		return io.Copy(entry, body)
+	}
+
+	if size < 0 {
+		s, err := spool(body)
+		if err != nil {
+			return 0, err
+		}
+		return s.size, a.writeSpooled(name, mode, s)
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     int64(fileMode(mode)),
+		Size:     size,
+		ModTime:  archiveTime,
+		Format:   tar.FormatPAX,
+	}
+	if err := a.tar.WriteHeader(hdr); err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(a.tar, body)
+	if err == nil && n != size {
+		err = fmt.Errorf("%w: %s has %d bytes, want %d", ErrBadSize, name, n, size)
+	}
+	return n, err
+}
+
+// finish closes the writers of the archive, if created.
+func (a *archive) finish() error {
+	var err error
+	if a.zip != nil {
+		err = a.zip.Close()
+	}
+	if a.tar != nil {
+		err = errors.Join(err, a.tar.Close(), a.packer.Close())
+	}
+	if a.file != nil {
+		err = errors.Join(err, a.file.Close())
+	}
+	return err
+}
+
+// discard drops the pending entries and removes the partial archive.
+func (a *archive) discard() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var err error
+	for _, s := range a.pending {
+		err = errors.Join(err, s.drop())
+	}
+	a.pending = nil
+
+	// The writers can't be closed cleanly after a failed entry, which
+	// doesn't matter as the archive is removed.
+	if ferr := a.finish(); a.err == nil {
+		err = errors.Join(err, ferr)
+	}
+	if a.file != nil {
+		err = errors.Join(err, os.Remove(a.name))
+	}
+	return err
+}
+
+// close writes the remaining entries in path order, with the git modes of
+// the given files if they were not listed, and closes the archive.
+func (a *archive) close(files []*File) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	modes := make(map[string]string, len(files))
+	for _, f := range files {
+		modes[f.LocalPath] = f.Mode
+	}
+	for name, mode := range a.modes {
+		modes[name] = mode
+	}
+
+	names := make([]string, 0, len(a.pending))
+	for name := range a.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	err := a.err
+	if err == nil {
+		err = a.open()
+	}
+	for _, name := range names {
+		s := a.pending[name]
+		if err != nil {
+			err = errors.Join(err, s.drop())
+			continue
+		}
+		err = a.writeSpooled(name, modes[name], s)
+	}
+	a.pending = nil
+
+	return errors.Join(err, a.finish())
+}
+
+// fileMode returns the file mode of the given git mode.
+func fileMode(mode string) os.FileMode {
+	if mode == modeExecutable {
+		return 0o755
+	}
+	return 0o644
+}
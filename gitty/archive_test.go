@@ -0,0 +1,348 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchiveFormat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		archive     string
+		format      string
+		expected    string
+		expectedErr error
+	}{
+		{name: "zip", archive: "out.zip", expected: formatZip},
+		{name: "tar.gz", archive: "out.tar.gz", expected: formatTarGz},
+		{name: "tgz", archive: "out.tgz", expected: formatTarGz},
+		{name: "tar.zst", archive: "out.tar.zst", expected: formatTarZst},
+		{name: "tzst", archive: "out.tzst", expected: formatTarZst},
+		{name: "stdout", archive: "-", expected: formatTarGz},
+		{name: "stdout with format", archive: "-", format: formatZip, expected: formatZip},
+		{name: "format overrides name", archive: "out.zip", format: formatTarZst, expected: formatTarZst},
+		{name: "invalid name", archive: "out.rar", expectedErr: ErrNotValidArchive},
+		{name: "invalid format", archive: "out.zip", format: "rar", expectedErr: ErrNotValidArchive},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			format, err := archiveFormat(test.archive, test.format)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, format)
+		})
+	}
+}
+
+// stageFiles saves the files into the archive and returns their records.
+func stageFiles(t *testing.T, a *archive) []*File {
+	t.Helper()
+	contents := map[string]string{
+		"base/b.txt":    "second",
+		"base/a.txt":    "first",
+		"base/sub/x.sh": "#!/bin/sh",
+	}
+
+	files := make([]*File, 0, len(contents))
+	for path, content := range contents {
+		p, n, err := a.save("base", path, strings.NewReader(content), int64(len(content)))
+		require.NoError(t, err)
+		mode := ""
+		if strings.HasSuffix(path, ".sh") {
+			mode = modeExecutable
+		}
+		files = append(files, &File{LocalPath: p, Size: n, Mode: mode})
+	}
+	return files
+}
+
+// archiveEntry represents an entry read from an archive.
+type archiveEntry struct {
+	name    string
+	mode    os.FileMode
+	content string
+}
+
+func readTar(t *testing.T, r io.Reader) []archiveEntry {
+	t.Helper()
+	var entries []archiveEntry
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, archiveTime, hdr.ModTime.UTC())
+		b, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		entries = append(entries, archiveEntry{hdr.Name, os.FileMode(hdr.Mode), string(b)})
+	}
+	return entries
+}
+
+func readZip(t *testing.T, b []byte) []archiveEntry {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	require.NoError(t, err)
+
+	entries := make([]archiveEntry, 0, len(zr.File))
+	for _, f := range zr.File {
+		assert.Equal(t, archiveTime, f.Modified.UTC())
+		rc, err := f.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		rc.Close()
+		entries = append(entries, archiveEntry{f.Name, f.Mode().Perm(), string(content)})
+	}
+	return entries
+}
+
+func TestArchive(t *testing.T) {
+	t.Parallel()
+	expected := []archiveEntry{
+		{name: "base/a.txt", mode: 0o644, content: "first"},
+		{name: "base/b.txt", mode: 0o644, content: "second"},
+		{name: "base/sub/x.sh", mode: 0o755, content: "#!/bin/sh"},
+	}
+
+	tests := []struct {
+		name string
+		read func(t *testing.T, b []byte) []archiveEntry
+	}{
+		{
+			name: "out.zip",
+			read: readZip,
+		},
+		{
+			name: "out.tar.gz",
+			read: func(t *testing.T, b []byte) []archiveEntry {
+				t.Helper()
+				gr, err := gzip.NewReader(bytes.NewReader(b))
+				require.NoError(t, err)
+				return readTar(t, gr)
+			},
+		},
+		{
+			name: "out.tar.zst",
+			read: func(t *testing.T, b []byte) []archiveEntry {
+				t.Helper()
+				zr, err := zstd.NewReader(bytes.NewReader(b))
+				require.NoError(t, err)
+				defer zr.Close()
+				return readTar(t, zr)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			name := filepath.Join(t.TempDir(), test.name)
+
+			// Archives of the same files are identical.
+			var archives [][]byte
+			for range 2 {
+				a, err := newArchive(name, "", nil)
+				require.NoError(t, err)
+				err = a.close(stageFiles(t, a))
+				require.NoError(t, err)
+
+				b, err := os.ReadFile(name)
+				require.NoError(t, err)
+				archives = append(archives, b)
+			}
+			assert.Equal(t, archives[0], archives[1])
+			assert.Equal(t, expected, test.read(t, archives[0]))
+		})
+	}
+}
+
+func TestArchiveStdout(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	a, err := newArchive("-", "", &buf)
+	require.NoError(t, err)
+
+	err = a.close(stageFiles(t, a))
+	require.NoError(t, err)
+
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	assert.Len(t, readTar(t, gr), 3)
+}
+
+func TestArchiveErrors(t *testing.T) {
+	t.Parallel()
+	_, err := newArchive("out.rar", "", nil)
+	assert.Equal(t, ErrNotValidArchive, err)
+
+	// Nothing is saved.
+	a, err := newArchive("out.zip", "", nil)
+	require.NoError(t, err)
+	require.NoError(t, a.discard())
+
+	// The archive can't be created.
+	a, err = newArchive(filepath.Join(t.TempDir(), "missing", "out.zip"), "", nil)
+	require.NoError(t, err)
+	err = a.close(stageFiles(t, a))
+	require.Error(t, err)
+
+	// The listed file can't be written.
+	a, err = newArchive(filepath.Join(t.TempDir(), "missing", "out.tar.gz"), "", nil)
+	require.NoError(t, err)
+	require.NoError(t, a.expect(map[string]string{"base/a.txt": ""}))
+	_, _, err = a.save("base", "base/a.txt", strings.NewReader("first"), 5)
+	require.Error(t, err)
+	_, _, err = a.save("base", "base/b.txt", strings.NewReader("second"), 6)
+	require.Error(t, err)
+	require.NoError(t, a.discard())
+
+	// The file is outside of the base.
+	a, err = newArchive("out.zip", "", nil)
+	require.NoError(t, err)
+	_, _, err = a.save("/base", "path/file.txt", strings.NewReader(""), 0)
+	require.Error(t, err)
+	require.NoError(t, a.discard())
+}
+
+func TestArchiveStream(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "out.zip")
+	a, err := newArchive(name, "", nil)
+	require.NoError(t, err)
+	a.source()
+	a.source()
+
+	// The entries wait until every source lists its files.
+	require.NoError(t, a.expect(map[string]string{"base/b.txt": "", "base/sub/x.sh": modeExecutable}))
+	_, _, err = a.save("base", "base/b.txt", strings.NewReader("second"), 6)
+	require.NoError(t, err)
+	require.NoError(t, a.expect(map[string]string{"base/a.txt": ""}))
+	assert.Len(t, a.pending, 1)
+	assert.Nil(t, a.zip)
+
+	// The next entry is written straight from its body, without spooling.
+	_, _, err = a.save("base", "base/a.txt", &streamReader{t: t, a: a, r: strings.NewReader("first")}, 5)
+	require.NoError(t, err)
+	assert.Empty(t, a.pending)
+	_, err = os.Stat(name)
+	require.NoError(t, err)
+
+	// Extra entries are written when the archive is closed.
+	_, _, err = a.save("base", "base/c.txt", strings.NewReader("extra"), 5)
+	require.NoError(t, err)
+	_, _, err = a.save("base", "base/sub/x.sh", strings.NewReader("#!/bin/sh"), 9)
+	require.NoError(t, err)
+	assert.Len(t, a.pending, 1)
+	require.NoError(t, a.close(nil))
+
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, []archiveEntry{
+		{name: "base/a.txt", mode: 0o644, content: "first"},
+		{name: "base/b.txt", mode: 0o644, content: "second"},
+		{name: "base/sub/x.sh", mode: 0o755, content: "#!/bin/sh"},
+		{name: "base/c.txt", mode: 0o644, content: "extra"},
+	}, readZip(t, b))
+}
+
+// streamReader checks that the archive is written from it.
+type streamReader struct {
+	t *testing.T
+	a *archive
+	r io.Reader
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	assert.True(s.t, s.a.streaming)
+	assert.NotContains(s.t, s.a.pending, "base/a.txt")
+	return s.r.Read(p)
+}
+
+func TestArchiveSize(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		size        int64
+		expected    []archiveEntry
+		expectedErr error
+	}{
+		{
+			name:     "known size",
+			size:     5,
+			expected: []archiveEntry{{name: "base/a.txt", mode: 0o644, content: "first"}},
+		},
+		{
+			name:     "unknown size",
+			size:     -1,
+			expected: []archiveEntry{{name: "base/a.txt", mode: 0o644, content: "first"}},
+		},
+		{
+			name:        "short body",
+			size:        6,
+			expectedErr: ErrBadSize,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			a, err := newArchive("-", formatTarGz, &buf)
+			require.NoError(t, err)
+			require.NoError(t, a.expect(map[string]string{"base/a.txt": ""}))
+
+			_, _, err = a.save("base", "base/a.txt", strings.NewReader("first"), test.size)
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+				require.NoError(t, a.discard())
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, a.close(nil))
+
+			gr, err := gzip.NewReader(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, readTar(t, gr))
+		})
+	}
+}
+
+func TestArchiveDiscard(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "out.tar.zst")
+	a, err := newArchive(name, "", nil)
+	require.NoError(t, err)
+	require.NoError(t, a.expect(map[string]string{"base/a.txt": ""}))
+	_, _, err = a.save("base", "base/a.txt", strings.NewReader("first"), 5)
+	require.NoError(t, err)
+	_, err = os.Stat(name)
+	require.NoError(t, err)
+
+	// The partial archive is removed.
+	require.NoError(t, a.discard())
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileMode(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, os.FileMode(0o755), fileMode(modeExecutable))
+	assert.Equal(t, os.FileMode(0o644), fileMode("100644"))
+	assert.Equal(t, os.FileMode(0o644), fileMode(""))
+}
@@ -0,0 +1,243 @@
+package gitty
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// Page sizes of the workflow runs and artifacts listed to find the newest
+// artifact.
+const (
+	runsPerPage      = 10
+	artifactsPerPage = 100
+)
+
+// runSuccess represents the status of the successful workflow runs.
+const runSuccess = "success"
+
+var (
+	ErrNoArtifact = errors.New("no artifact found")
+	ErrUnsafePath = errors.New("path must stay inside the destination")
+)
+
+// resolveArtifact finds the newest artifact to download. It is picked from
+// the workflow run, the newest successful runs of the workflow, or the whole
+// repository, and narrowed down by name and branch. The reference is resolved
+// to the head SHA of its workflow run.
+func (g *GitHub) resolveArtifact(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if err := g.onlyGitHub("artifacts"); err != nil {
+		return err
+	}
+	artifact, err := g.findArtifact(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to resolve artifact: %w", err)
+	}
+	if artifact == nil {
+		return fmt.Errorf("%w: %s", ErrNoArtifact, g.artifactQuery())
+	}
+	g.artifact = artifact
+	g.Ref = &github.RepositoryContentGetOptions{Ref: artifact.GetWorkflowRun().GetHeadSHA()}
+
+	return nil
+}
+
+// findArtifact finds the newest artifact which matches the options, if any.
+func (g *GitHub) findArtifact(ctx context.Context) (*github.Artifact, error) {
+	opts := g.opts
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	switch {
+	case opts.Run != 0:
+		return g.runArtifact(ctx, opts.Run)
+	case opts.Workflow != "":
+		if err := g.engine.budget(); err != nil {
+			return nil, err
+		}
+		runs, resp, err := g.Client.ListWorkflowRunsByFileName(ctx, g.Owner, g.Repo, opts.Workflow, &github.ListWorkflowRunsOptions{
+			Branch:      opts.Branch,
+			Status:      runSuccess,
+			ListOptions: github.ListOptions{PerPage: runsPerPage},
+		})
+		g.engine.stats.call(resp)
+		if err != nil {
+			return nil, err
+		}
+		// Runs are listed newest first. Older runs may still have the
+		// artifact if the newest ones did not upload it.
+		for _, run := range runs.WorkflowRuns {
+			artifact, err := g.runArtifact(ctx, run.GetID())
+			if artifact != nil || err != nil {
+				return artifact, err
+			}
+		}
+		return nil, nil
+	default:
+		if err := g.engine.budget(); err != nil {
+			return nil, err
+		}
+		listOpts := &github.ListArtifactsOptions{ListOptions: github.ListOptions{PerPage: artifactsPerPage}}
+		if opts.Artifact != "" {
+			listOpts.Name = github.Ptr(opts.Artifact)
+		}
+		list, resp, err := g.Client.ListArtifacts(ctx, g.Owner, g.Repo, listOpts)
+		g.engine.stats.call(resp)
+		if err != nil {
+			return nil, err
+		}
+		return newestArtifact(list.Artifacts, opts.Artifact, opts.Branch), nil
+	}
+}
+
+// runArtifact finds the newest artifact of the workflow run, if any.
+func (g *GitHub) runArtifact(ctx context.Context, id int64) (*github.Artifact, error) {
+	if err := g.engine.budget(); err != nil {
+		return nil, err
+	}
+	list, resp, err := g.Client.ListWorkflowRunArtifacts(ctx, g.Owner, g.Repo, id, &github.ListOptions{PerPage: artifactsPerPage})
+	g.engine.stats.call(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	var name string
+	if g.opts != nil {
+		name = g.opts.Artifact
+	}
+	return newestArtifact(list.Artifacts, name, ""), nil
+}
+
+// artifactQuery returns the human-readable options which find the artifact.
+func (g *GitHub) artifactQuery() string {
+	q := g.Owner + "/" + g.Repo
+	if g.opts == nil {
+		return q
+	}
+	if g.opts.Artifact != "" {
+		q += fmt.Sprintf(" name %q", g.opts.Artifact)
+	}
+	switch {
+	case g.opts.Run != 0:
+		q += fmt.Sprintf(" run %d", g.opts.Run)
+	case g.opts.Workflow != "":
+		q += " workflow " + g.opts.Workflow
+	}
+	if g.opts.Branch != "" {
+		q += " branch " + g.opts.Branch
+	}
+	return q
+}
+
+// newestArtifact returns the newest unexpired artifact with the name and
+// of the branch, if they are set.
+func newestArtifact(artifacts []*github.Artifact, name, branch string) *github.Artifact {
+	var newest *github.Artifact
+	for _, a := range artifacts {
+		switch {
+		case a.GetExpired():
+		case name != "" && a.GetName() != name:
+		case branch != "" && a.GetWorkflowRun().GetHeadBranch() != branch:
+		case newest == nil || a.GetCreatedAt().After(newest.GetCreatedAt().Time):
+			newest = a
+		}
+	}
+	return newest
+}
+
+// collectArtifact downloads the zip archive of the artifact, and extracts
+// the files which pass the include and exclude globs.
+func (g *GitHub) collectArtifact(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	if err := g.fetchArtifact(ctx); err != nil {
+		g.engine.stats.fail()
+		errCh <- err
+	}
+}
+
+// fetchArtifact downloads the zip archive of the artifact into a temporary
+// file, which the zip reader needs, and saves its files. The archive is
+// rejected if a file would be saved outside the destination, since the
+// artifacts of pull requests from forks are not trusted.
+func (g *GitHub) fetchArtifact(ctx context.Context) error {
+	if err := g.engine.budget(); err != nil {
+		return err
+	}
+	if err := g.engine.acquire(ctx); err != nil {
+		return err
+	}
+	defer g.engine.release()
+
+	fmt.Fprintln(g.opts.log(), "Downloading:", g.artifact.GetName())
+	start := time.Now()
+	rc, err := g.Client.DownloadArtifact(ctx, g.Owner, g.Repo, g.artifact.GetID())
+	g.engine.stats.call(nil)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "gitty-artifact-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	n, err := io.Copy(tmp, rc)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(tmp, n)
+	if err != nil {
+		return err
+	}
+
+	for _, zf := range zr.File {
+		if !filepath.IsLocal(zf.Name) {
+			return fmt.Errorf("%w: %s", ErrUnsafePath, zf.Name)
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		if g.opts.filtered(zf.Name) {
+			g.engine.stats.skip(typeFiltered)
+			continue
+		}
+		if err := g.saveArtifactFile(zf, start); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// saveArtifactFile saves the file of the artifact archive.
+func (g *GitHub) saveArtifactFile(zf *zip.File, start time.Time) error {
+	rc, err := zf.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	f, err := g.store(g.artifact.GetArchiveDownloadURL(), zf.Name, rc, int64(zf.UncompressedSize64), start)
+	if err != nil {
+		return err
+	}
+	g.engine.stats.file(f)
+
+	return nil
+}
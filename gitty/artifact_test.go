@@ -0,0 +1,312 @@
+package gitty
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockNoArtifacts lists no workflow runs.
+type mockNoArtifacts struct {
+	mockSuccess
+}
+
+func (m *mockNoArtifacts) ListWorkflowRunsByFileName(_ context.Context, _, _, _ string, _ *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	return &github.WorkflowRuns{}, nil, nil
+}
+
+func TestResolveArtifact(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		client      mockClient
+		opts        *Options
+		expectedID  int64
+		expectedErr error
+	}{
+		{
+			name:       "newest artifact of the repository",
+			client:     &mockSuccess{},
+			opts:       &Options{},
+			expectedID: 21,
+		},
+		{
+			name:       "newest artifact of the repository by name",
+			client:     &mockSuccess{},
+			opts:       &Options{Artifact: "coverage"},
+			expectedID: 31,
+		},
+		{
+			name:       "newest artifact of the repository by name and branch",
+			client:     &mockSuccess{},
+			opts:       &Options{Artifact: "coverage", Branch: "main"},
+			expectedID: 11,
+		},
+		{
+			name:       "unexpired artifact of the run",
+			client:     &mockSuccess{},
+			opts:       &Options{Run: 1, Artifact: "coverage"},
+			expectedID: 11,
+		},
+		{
+			name:       "artifact of an older workflow run",
+			client:     &mockSuccess{},
+			opts:       &Options{Workflow: "build.yml", Branch: "main", Artifact: "coverage"},
+			expectedID: 11,
+		},
+		{
+			name:       "artifact of the newest workflow run",
+			client:     &mockSuccess{},
+			opts:       &Options{Workflow: "build.yml"},
+			expectedID: 21,
+		},
+		{
+			name:        "error no artifact of the run",
+			client:      &mockSuccess{},
+			opts:        &Options{Run: 2, Artifact: "coverage"},
+			expectedErr: fmt.Errorf("%w: %s", ErrNoArtifact, `owner/repo name "coverage" run 2`),
+		},
+		{
+			name:        "error no workflow runs",
+			client:      &mockNoArtifacts{},
+			opts:        &Options{Workflow: "build.yml", Branch: "main"},
+			expectedErr: fmt.Errorf("%w: %s", ErrNoArtifact, "owner/repo workflow build.yml branch main"),
+		},
+		{
+			name:        "error list artifacts",
+			client:      &mockError{},
+			opts:        &Options{},
+			expectedErr: fmt.Errorf("failed to resolve artifact: %w", errMockArtifact),
+		},
+		{
+			name:        "error list run artifacts",
+			client:      &mockError{},
+			opts:        &Options{Run: 1},
+			expectedErr: fmt.Errorf("failed to resolve artifact: %w", errMockArtifact),
+		},
+		{
+			name:        "error list workflow runs",
+			client:      &mockError{},
+			opts:        &Options{Workflow: "build.yml"},
+			expectedErr: fmt.Errorf("failed to resolve artifact: %w", errMockRuns),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: test.opts, engine: newEngine(0)}
+			err := g.extract("owner/repo")
+			require.NoError(t, err)
+
+			err = g.resolveArtifact(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, test.expectedID, g.artifact.GetID())
+			assert.Equal(t, fmt.Sprintf("sha%d", test.expectedID), g.ref())
+			assert.Equal(t, "repo-"+g.artifact.GetName(), g.dest())
+		})
+	}
+}
+
+func TestArtifact(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name             string
+		opts             *Options
+		expectedFiles    []string
+		expectedFiltered int
+	}{
+		{
+			name:          "all files",
+			opts:          &Options{},
+			expectedFiles: []string{"coverage.out", "html/index.html", "html/style.css"},
+		},
+		{
+			name:             "include",
+			opts:             &Options{Include: []string{"html/*"}},
+			expectedFiles:    []string{"html/index.html", "html/style.css"},
+			expectedFiltered: 1,
+		},
+		{
+			name:             "include and exclude",
+			opts:             &Options{Include: []string{"*.html", "*.out"}, Exclude: []string{"coverage.*"}},
+			expectedFiles:    []string{"html/index.html"},
+			expectedFiltered: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeRepo := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			fakeDest := fakeRepo + "-coverage"
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeDest)
+				require.NoError(t, err)
+			})
+
+			test.opts.Log = io.Discard
+			test.opts.Artifact = "coverage"
+			test.opts.Branch = "main"
+			g := fakeNew(fakeRepository(&mockSuccess{}))
+			res, err := g.Artifact(context.Background(), "owner/"+fakeRepo, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"sha11"}, res.Refs)
+			assert.Equal(t, 2, res.Summary.APICalls)
+			assert.Equal(t, test.expectedFiltered, res.Summary.Skipped.Filtered)
+
+			var names []string
+			for _, f := range res.Files {
+				names = append(names, f.RemotePath)
+				b, err := os.ReadFile(f.LocalPath)
+				require.NoError(t, err)
+				assert.Equal(t, f.RemotePath, string(b))
+				assert.Equal(t, filepath.Join(fakeDest, f.RemotePath), f.LocalPath)
+			}
+			assert.Equal(t, test.expectedFiles, names)
+		})
+	}
+}
+
+func TestArtifactError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		repo     Repository
+		spec     string
+		opts     *Options
+		expected error
+	}{
+		{
+			name:     "error extract",
+			repo:     fakeRepository(&mockSuccess{}),
+			spec:     "owner",
+			opts:     &Options{Log: io.Discard},
+			expected: formatError("missing owner or repository", compactFormat),
+		},
+		{
+			name:     "error archive",
+			repo:     fakeRepository(&mockSuccess{}),
+			spec:     "owner/repo",
+			opts:     &Options{Log: io.Discard, Archive: "out.rar"},
+			expected: ErrNotValidArchive,
+		},
+		{
+			name:     "error resolve",
+			repo:     fakeRepository(&mockError{}),
+			spec:     "owner/repo",
+			opts:     &Options{Log: io.Discard},
+			expected: fmt.Errorf("failed to resolve artifact: %w", errMockArtifact),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := fakeNew(test.repo).Artifact(context.Background(), test.spec, test.opts)
+			assert.Equal(t, test.expected, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+// mockArtifactError fails to download the artifact.
+type mockArtifactError struct {
+	mockSuccess
+}
+
+func (m *mockArtifactError) DownloadArtifact(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return nil, errMockArtifact
+}
+
+// mockArtifactSlip serves an artifact with a file outside the destination.
+type mockArtifactSlip struct {
+	mockSuccess
+}
+
+func (m *mockArtifactSlip) DownloadArtifact(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"coverage.out", "../../evil.txt"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(w, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func TestArtifactUnsafePath(t *testing.T) {
+	chdir(t)
+	g := fakeNew(fakeRepository(&mockArtifactSlip{}))
+	_, err := g.Artifact(context.Background(), "owner/repo", &Options{Log: io.Discard})
+	assert.Equal(t, fmt.Errorf("failed to download: %w", fmt.Errorf("%w: %s", ErrUnsafePath, "../../evil.txt")), err)
+
+	_, err = os.Stat(filepath.Join("..", "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestArtifactDownloadError(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(fakeRepository(&mockArtifactError{}))
+	res, err := g.Artifact(context.Background(), "owner/repo", &Options{Log: io.Discard})
+	assert.Equal(t, fmt.Errorf("failed to download: %w", errMockArtifact), err)
+	require.NotNil(t, res)
+	assert.Equal(t, 1, res.Summary.Failures)
+}
+
+func TestServiceDownloadArtifact(t *testing.T) {
+	t.Parallel()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	mux.HandleFunc("GET /repos/owner/repo/actions/artifacts/{id}/zip", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		http.Redirect(w, r, srv.URL+"/blob/"+r.PathValue("id"), http.StatusFound)
+	})
+	mux.HandleFunc("GET /blob/{id}", func(w http.ResponseWriter, r *http.Request) {
+		// The signed URL is downloaded without the token.
+		assert.Empty(t, r.Header.Get("Authorization"))
+		if r.PathValue("id") != "1" {
+			http.NotFound(w, r)
+			return
+		}
+		_, err := w.Write([]byte("zip"))
+		assert.NoError(t, err)
+	})
+	c := github.NewClient(nil).WithAuthToken("token")
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+	s := &service{client: c}
+
+	rc, err := s.DownloadArtifact(context.Background(), "owner", "repo", 1)
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "zip", string(b))
+
+	_, err = s.DownloadArtifact(context.Background(), "owner", "repo", 2)
+	assert.Equal(t, fmt.Errorf("%w: %s", ErrBadStatus, "404 Not Found"), err)
+}
@@ -0,0 +1,390 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// bitbucketBase is the base URL of the API of bitbucket.org, which is on
+// its own host.
+const bitbucketBase = "https://api.bitbucket.org"
+
+// Paths of the REST APIs of bitbucket.org and of a Bitbucket Server.
+const (
+	bitbucketAPI = "/2.0/"
+	serverAPI    = "/rest/api/1.0/"
+)
+
+// Number of items listed per request, which are the maximums of the APIs.
+const (
+	bitbucketPerPage = 100
+	serverPerPage    = 1000
+)
+
+// bitbucketDepth represents the depth of the recursive source listing of
+// bitbucket.org, which lists only the top directory by default.
+const bitbucketDepth = 100
+
+// Types and attributes of the entries of the source listing of
+// bitbucket.org.
+const (
+	bitbucketDir        = "commit_directory"
+	bitbucketFile       = "commit_file"
+	bitbucketLink       = "link"
+	bitbucketExecutable = "executable"
+	bitbucketSubrepo    = "subrepository"
+)
+
+// bitbucketProvider represents bitbucket.org, which is used through its
+// REST API. The owner of a repository is its workspace.
+type bitbucketProvider struct {
+	rest
+	name string
+}
+
+// Ensure bitbucketProvider implements the provider interface.
+var _ provider = (*bitbucketProvider)(nil)
+
+// newBitbucket creates the provider of bitbucket.org, whose API is at the
+// base URL, authenticated with the token of the host, if any.
+func newBitbucket(name, base string) *bitbucketProvider {
+	return &bitbucketProvider{rest: bitbucketREST(name, base, bitbucketAPI), name: name}
+}
+
+func (p *bitbucketProvider) host() string {
+	return p.name
+}
+
+func (p *bitbucketProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	var r struct {
+		MainBranch struct {
+			Name string `json:"name"`
+		} `json:"mainbranch"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo), nil, &r)
+	if err != nil {
+		return "", resp, err
+	}
+	return r.MainBranch.Name, resp, nil
+}
+
+// refs lists the branches or the tags whose names contain the given name.
+// The names are matched again later.
+func (p *bitbucketProvider) refs(ctx context.Context, owner, repo, kind, name string, page cursor) ([]string, *reply, error) {
+	endpoint := p.repo(owner, repo) + "/refs/branches"
+	if kind == "tags" {
+		endpoint = p.repo(owner, repo) + "/refs/tags"
+	}
+	query := bitbucketQuery()
+	if name != "" {
+		query.Set("q", "name ~ "+strconv.Quote(name))
+	}
+
+	var refs struct {
+		Values []struct {
+			Name string `json:"name"`
+		} `json:"values"`
+		Next string `json:"next"`
+	}
+	resp, err := p.list(ctx, endpoint, query, page, &refs)
+	if err != nil {
+		return nil, resp, err
+	}
+	resp.next = cursor(refs.Next)
+	names := make([]string, 0, len(refs.Values))
+	for _, ref := range refs.Values {
+		names = append(names, ref.Name)
+	}
+
+	return names, resp, nil
+}
+
+func (p *bitbucketProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	var commit struct {
+		Hash string `json:"hash"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/commit/"+url.PathEscape(ref), nil, &commit)
+	if err != nil {
+		return "", resp, err
+	}
+	return commit.Hash, resp, nil
+}
+
+// tree lists a page of the recursive source listing. The listing has no
+// blob SHAs, so the entries have none.
+func (p *bitbucketProvider) tree(ctx context.Context, owner, repo, ref string, page cursor) (*fileTree, *reply, error) {
+	query := bitbucketQuery()
+	query.Set("max_depth", strconv.Itoa(bitbucketDepth))
+
+	var src struct {
+		Values []struct {
+			Type       string   `json:"type"`
+			Path       string   `json:"path"`
+			Attributes []string `json:"attributes"`
+		} `json:"values"`
+		Next string `json:"next"`
+	}
+	resp, err := p.list(ctx, p.src(owner, repo, ref, ""), query, page, &src)
+	if err != nil {
+		return nil, resp, err
+	}
+	resp.next = cursor(src.Next)
+
+	tree := &fileTree{}
+	for _, e := range src.Values {
+		if err := localPath(e.Path); err != nil {
+			return nil, resp, err
+		}
+		entry := &treeEntry{path: e.Path, typ: entryBlob}
+		switch {
+		case e.Type == bitbucketDir:
+			entry.typ = entryTree
+		case slices.Contains(e.Attributes, bitbucketSubrepo):
+			entry.typ = entryCommit
+		case slices.Contains(e.Attributes, bitbucketLink):
+			entry.mode = modeSymlink
+		case slices.Contains(e.Attributes, bitbucketExecutable):
+			entry.mode = modeExecutable
+		}
+		tree.entries = append(tree.entries, entry)
+	}
+
+	return tree, resp, nil
+}
+
+// file gets the metadata of the path, which is a file or a directory.
+func (p *bitbucketProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	var meta struct {
+		Type string `json:"type"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.src(owner, repo, ref, path), url.Values{"format": {"meta"}}, &meta)
+	if err != nil {
+		return "", resp, err
+	}
+	if meta.Type != bitbucketFile {
+		return "", resp, ErrNotFile
+	}
+	return p.rawURL(owner, repo, ref, path), resp, nil
+}
+
+// rawURL returns the URL of the source endpoint of the API, which returns
+// the raw content of files.
+func (p *bitbucketProvider) rawURL(owner, repo, ref, path string) string {
+	return p.base + bitbucketAPI + p.src(owner, repo, ref, path)
+}
+
+func (p *bitbucketProvider) get(ctx context.Context, url string) (*http.Response, error) {
+	return p.raw(ctx, url)
+}
+
+// list calls the endpoint with the query for the first page of a list. The
+// next pages are listed with the next link of the previous page, as is,
+// since its page is opaque and it has the query already.
+func (p *bitbucketProvider) list(ctx context.Context, endpoint string, query url.Values, page cursor, v any) (*reply, error) {
+	if page != "" {
+		return p.follow(ctx, string(page), v)
+	}
+	return p.call(ctx, http.MethodGet, endpoint, query, v)
+}
+
+// repo returns the endpoint of the repository.
+func (p *bitbucketProvider) repo(owner, repo string) string {
+	return "repositories/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
+
+// src returns the source endpoint of the path at the reference. The
+// directories end with a slash.
+func (p *bitbucketProvider) src(owner, repo, ref, path string) string {
+	return p.repo(owner, repo) + "/src/" + url.PathEscape(ref) + "/" + pathEscape(path)
+}
+
+// serverProvider represents a Bitbucket Server or Data Center instance,
+// which is used through its REST API. The owner of a repository is the key
+// of its project, or the name of its user after a tilde.
+type serverProvider struct {
+	rest
+	name string
+}
+
+// Ensure serverProvider implements the provider interface.
+var _ provider = (*serverProvider)(nil)
+
+// newServer creates the provider of the Bitbucket Server at the base URL,
+// authenticated with the token of the host, if any.
+func newServer(name, base string) *serverProvider {
+	return &serverProvider{rest: bitbucketREST(name, base, serverAPI), name: name}
+}
+
+func (p *serverProvider) host() string {
+	return p.name
+}
+
+func (p *serverProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	var branch struct {
+		DisplayID string `json:"displayId"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/default-branch", nil, &branch)
+	if err != nil {
+		return "", resp, err
+	}
+	return branch.DisplayID, resp, nil
+}
+
+// refs lists the branches or the tags whose names contain the given name.
+// The names are matched again later. The page is the start of the list.
+func (p *serverProvider) refs(ctx context.Context, owner, repo, kind, name string, page cursor) ([]string, *reply, error) {
+	endpoint := p.repo(owner, repo) + "/branches"
+	if kind == "tags" {
+		endpoint = p.repo(owner, repo) + "/tags"
+	}
+	query := serverQuery(page)
+	if name != "" {
+		query.Set("filterText", name)
+	}
+
+	var refs struct {
+		Values []struct {
+			DisplayID string `json:"displayId"`
+		} `json:"values"`
+		serverPage
+	}
+	resp, err := p.call(ctx, http.MethodGet, endpoint, query, &refs)
+	if err != nil {
+		return nil, resp, err
+	}
+	resp.next = refs.next()
+	names := make([]string, 0, len(refs.Values))
+	for _, ref := range refs.Values {
+		names = append(names, ref.DisplayID)
+	}
+
+	return names, resp, nil
+}
+
+// commitSHA lists the last commit of the reference, which may be a branch,
+// a tag, or a commit SHA.
+func (p *serverProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	var commits struct {
+		Values []struct {
+			ID string `json:"id"`
+		} `json:"values"`
+	}
+	query := url.Values{"until": {ref}, "limit": {"1"}}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/commits", query, &commits)
+	if err != nil {
+		return "", resp, err
+	}
+	if len(commits.Values) == 0 {
+		return "", resp, fmt.Errorf("%w: %s", ErrNoRef, ref)
+	}
+	return commits.Values[0].ID, resp, nil
+}
+
+// tree lists a page of the paths of all the files. The listing has no
+// directories, modes, or blob SHAs, so symbolic links are listed as files.
+// The page is the start of the list.
+func (p *serverProvider) tree(ctx context.Context, owner, repo, ref string, page cursor) (*fileTree, *reply, error) {
+	query := serverQuery(page)
+	query.Set("at", ref)
+
+	var files struct {
+		Values []string `json:"values"`
+		serverPage
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/files", query, &files)
+	if err != nil {
+		return nil, resp, err
+	}
+	resp.next = files.next()
+
+	tree := &fileTree{}
+	for _, path := range files.Values {
+		if err := localPath(path); err != nil {
+			return nil, resp, err
+		}
+		tree.entries = append(tree.entries, &treeEntry{path: path, typ: entryBlob})
+	}
+
+	return tree, resp, nil
+}
+
+// file gets the type of the path, which is a file or a directory.
+func (p *serverProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	var browse struct {
+		Type string `json:"type"`
+	}
+	query := url.Values{"at": {ref}, "type": {"true"}}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/browse/"+pathEscape(path), query, &browse)
+	if err != nil {
+		return "", resp, err
+	}
+	if browse.Type != "FILE" {
+		return "", resp, ErrNotFile
+	}
+	return p.rawURL(owner, repo, ref, path), resp, nil
+}
+
+// rawURL returns the URL of the raw file of the web interface, which
+// accepts the token.
+func (p *serverProvider) rawURL(owner, repo, ref, path string) string {
+	return fmt.Sprintf("%s/%s/raw/%s?at=%s", p.base, p.repo(owner, repo), pathEscape(path), url.QueryEscape(ref))
+}
+
+func (p *serverProvider) get(ctx context.Context, url string) (*http.Response, error) {
+	return p.raw(ctx, url)
+}
+
+// repo returns the endpoint of the repository.
+func (p *serverProvider) repo(owner, repo string) string {
+	return "projects/" + url.PathEscape(owner) + "/repos/" + url.PathEscape(repo)
+}
+
+// serverPage represents the paging of the lists of a Bitbucket Server.
+type serverPage struct {
+	IsLastPage    bool `json:"isLastPage"`
+	NextPageStart int  `json:"nextPageStart"`
+}
+
+// next returns the start of the next page, or none if it is the last page.
+func (s serverPage) next() cursor {
+	if s.IsLastPage {
+		return ""
+	}
+	return cursor(strconv.Itoa(s.NextPageStart))
+}
+
+// bitbucketREST returns the API of the Bitbucket host at the base URL,
+// authenticated with the token of the host, if any.
+func bitbucketREST(name, base, api string) rest {
+	prefix, tok := credentials(token.ForBitbucket(name))
+	return rest{
+		base:   base,
+		api:    api,
+		header: authorizationHeader,
+		prefix: prefix,
+		token:  tok,
+		client: http.DefaultClient,
+	}
+}
+
+// bitbucketQuery returns the query of the first page of a list of
+// bitbucket.org.
+func bitbucketQuery() url.Values {
+	return url.Values{"pagelen": {strconv.Itoa(bitbucketPerPage)}}
+}
+
+// serverQuery returns the query of the page of a list of a Bitbucket
+// Server, which starts at the page, or at 0 if it is empty.
+func serverQuery(page cursor) url.Values {
+	start := string(page)
+	if start == "" {
+		start = "0"
+	}
+	return url.Values{"limit": {strconv.Itoa(serverPerPage)}, "start": {start}}
+}
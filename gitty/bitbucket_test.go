@@ -0,0 +1,398 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bitbucketServer returns a stand-in of the API of bitbucket.org, which
+// serves the workspace/repo repository. Its main and feature/x branches
+// have the docs directory, whose source listing is in two pages. Its next
+// links are absolute, with opaque pages. The requests must have the
+// authorization.
+func bitbucketServer(t *testing.T, auth string) *httptest.Server {
+	t.Helper()
+	shas := map[string]string{"main": strings.Repeat("c", 40), "feature/x": strings.Repeat("d", 40)}
+	repo := "/2.0/repositories/workspace/repo"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+repo, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"mainbranch":{"name":"main"}}`)
+	})
+	mux.HandleFunc("GET "+repo+"/refs/branches", func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		contains := strings.Trim(strings.TrimPrefix(r.URL.Query().Get("q"), "name ~ "), `"`)
+		for _, name := range []string{"main", "feature/x"} {
+			if strings.Contains(name, contains) {
+				names = append(names, fmt.Sprintf(`{"name":%q}`, name))
+			}
+		}
+		fmt.Fprintf(w, `{"values":[%s]}`, strings.Join(names, ","))
+	})
+	mux.HandleFunc("GET "+repo+"/refs/tags", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			fmt.Fprintf(w, `{"values":[{"name":"v0.9.0"}],"next":"http://%s%s?pagelen=100&page=2"}`, r.Host, r.URL.Path)
+			return
+		}
+		fmt.Fprint(w, `{"values":[{"name":"v1.0.0"}]}`)
+	})
+	mux.HandleFunc("GET "+repo+"/commit/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		sha, ok := shas[r.PathValue("ref")]
+		if !ok {
+			http.Error(w, `{"type":"error"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"hash":%q}`, sha)
+	})
+	mux.HandleFunc("GET "+repo+"/src/{ref}/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		path := r.PathValue("path")
+		switch {
+		case r.URL.Query().Get("format") == "meta" && path == "docs":
+			fmt.Fprint(w, `{"type":"commit_directory","path":"docs"}`)
+		case r.URL.Query().Get("format") == "meta" && path == "docs/a.md":
+			fmt.Fprint(w, `{"type":"commit_file","path":"docs/a.md"}`)
+		case path == "docs/a.md":
+			fmt.Fprintf(w, "# A at %s", r.PathValue("ref"))
+		case path != "":
+			http.Error(w, `{"type":"error"}`, http.StatusNotFound)
+		case r.PathValue("ref") == "hostile":
+			fmt.Fprint(w, `{"values":[{"type":"commit_file","path":"docs/../../x","attributes":[]}]}`)
+		case r.URL.Query().Get("max_depth") == "":
+			http.Error(w, `{"type":"error","error":{"message":"not recursive"}}`, http.StatusBadRequest)
+		case r.URL.Query().Get("page") == "":
+			fmt.Fprintf(w, `{"values":[{"type":"commit_directory","path":"docs"},{"type":"commit_file","path":"docs/a.md","attributes":[]}],"next":"http://%s%s?max_depth=100&page=dG9rZW4%%3D"}`, r.Host, r.URL.Path)
+		case r.URL.Query().Get("page") != "dG9rZW4=":
+			http.Error(w, `{"type":"error","error":{"message":"bad page"}}`, http.StatusBadRequest)
+		default:
+			fmt.Fprint(w, `{"values":[{"type":"commit_file","path":"docs/link","attributes":["link"]},{"type":"commit_file","path":"run.sh","attributes":["executable"]},{"type":"commit_file","path":"lib","attributes":["subrepository"]}]}`)
+		}
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(authorizationHeader) != auth {
+			http.Error(w, `{"type":"error"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// dataCenterServer returns a stand-in of a Bitbucket Server, which serves
+// the repo repository of the PROJ project. Its main and feature/x branches
+// and its v1.0.0 tag have the docs directory, whose files are listed in two
+// pages. The requests must have the authorization.
+func dataCenterServer(t *testing.T, auth string) *httptest.Server {
+	t.Helper()
+	shas := map[string]string{"main": strings.Repeat("c", 40), "feature/x": strings.Repeat("d", 40), "v1.0.0": strings.Repeat("e", 40)}
+	repo := "/projects/PROJ/repos/repo"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/default-branch", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"id":"refs/heads/main","displayId":"main"}`)
+	})
+	refs := func(names ...string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var matched []string
+			for _, name := range names {
+				if strings.Contains(name, r.URL.Query().Get("filterText")) {
+					matched = append(matched, fmt.Sprintf(`{"displayId":%q}`, name))
+				}
+			}
+			fmt.Fprintf(w, `{"values":[%s],"isLastPage":true}`, strings.Join(matched, ","))
+		}
+	}
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/branches", refs("main", "feature/x"))
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/tags", refs("v1.0.0"))
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/commits", func(w http.ResponseWriter, r *http.Request) {
+		sha, ok := shas[r.URL.Query().Get("until")]
+		if !ok {
+			fmt.Fprint(w, `{"values":[],"isLastPage":true}`)
+			return
+		}
+		fmt.Fprintf(w, `{"values":[{"id":%q}],"isLastPage":true}`, sha)
+	})
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/files", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("at") == "hostile" {
+			fmt.Fprint(w, `{"values":["docs/../../x"],"isLastPage":true}`)
+			return
+		}
+		if r.URL.Query().Get("start") == "0" {
+			fmt.Fprint(w, `{"values":["docs/a.md","README.md"],"isLastPage":false,"nextPageStart":2}`)
+			return
+		}
+		fmt.Fprint(w, `{"values":["src/main.go"],"isLastPage":true}`)
+	})
+	mux.HandleFunc("GET /rest/api/1.0"+repo+"/browse/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("path") {
+		case "docs":
+			fmt.Fprint(w, `{"type":"DIRECTORY"}`)
+		case "docs/a.md":
+			fmt.Fprint(w, `{"type":"FILE"}`)
+		default:
+			http.Error(w, `{"errors":[]}`, http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET "+repo+"/raw/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("path") != "docs/a.md" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "# A at %s", r.URL.Query().Get("at"))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(authorizationHeader) != auth {
+			http.Error(w, `{"errors":[]}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// basicAuth returns the Authorization header of the username and the app
+// password.
+func basicAuth(tok string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(tok))
+}
+
+func TestBitbucketProvider(t *testing.T) {
+	t.Parallel()
+	srv := bitbucketServer(t, basicAuth("user:secret"))
+	p := newBitbucket(hostBitbucket, srv.URL)
+	p.prefix, p.token = credentials("user:secret")
+	ctx := context.Background()
+	assert.Equal(t, hostBitbucket, p.host())
+
+	branch, _, err := p.defaultBranch(ctx, "workspace", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	names, _, err := p.refs(ctx, "workspace", "repo", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, resp, err := p.refs(ctx, "workspace", "repo", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v0.9.0"}, names)
+	assert.Equal(t, cursor(srv.URL+"/2.0/repositories/workspace/repo/refs/tags?pagelen=100&page=2"), resp.next)
+	names, resp, err = p.refs(ctx, "workspace", "repo", "tags", "", resp.next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+	assert.Empty(t, resp.next)
+
+	sha, _, err := p.commitSHA(ctx, "workspace", "repo", "feature/x")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("d", 40), sha)
+
+	tree, resp, err := p.tree(ctx, "workspace", "repo", sha, "")
+	require.NoError(t, err)
+	assert.Equal(t, cursor(srv.URL+"/2.0/repositories/workspace/repo/src/"+sha+"/?max_depth=100&page=dG9rZW4%3D"), resp.next)
+	require.Len(t, tree.entries, 2)
+	assert.Equal(t, entryTree, tree.entries[0].typ)
+	assert.Equal(t, "docs/a.md", tree.entries[1].path)
+	assert.Equal(t, entryBlob, tree.entries[1].typ)
+
+	tree, resp, err = p.tree(ctx, "workspace", "repo", sha, resp.next)
+	require.NoError(t, err)
+	assert.Empty(t, resp.next)
+	require.Len(t, tree.entries, 3)
+	assert.Equal(t, modeSymlink, tree.entries[0].mode)
+	assert.Equal(t, modeExecutable, tree.entries[1].mode)
+	assert.Equal(t, entryCommit, tree.entries[2].typ)
+
+	url, _, err := p.file(ctx, "workspace", "repo", sha, "docs/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/2.0/repositories/workspace/repo/src/"+sha+"/docs/a.md", url)
+	raw, err := p.get(ctx, url)
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+sha, string(body))
+
+	_, _, err = p.file(ctx, "workspace", "repo", sha, "docs")
+	assert.Equal(t, ErrNotFile, err)
+}
+
+func TestBitbucketProviderError(t *testing.T) {
+	t.Parallel()
+	srv := bitbucketServer(t, "Bearer secret")
+	ctx := context.Background()
+
+	p := newBitbucket(hostBitbucket, srv.URL)
+	p.prefix, p.token = credentials("user:secret")
+	_, _, err := p.defaultBranch(ctx, "workspace", "repo")
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized repositories/workspace/repo", ErrBadStatus), err)
+
+	p.prefix, p.token = credentials("secret")
+	_, _, err = p.commitSHA(ctx, "workspace", "repo", "missing")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.file(ctx, "workspace", "repo", "main", "missing.md")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.refs(ctx, "workspace", "other", "heads", "main", "")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.tree(ctx, "workspace", "repo", "main", cursor(srv.URL+"/2.0/repositories/workspace/repo/src/main/?page=bad"))
+	assert.Equal(t, fmt.Errorf("%w: 400 Bad Request repositories/workspace/repo/src/main/?page=bad", ErrBadStatus), err)
+	// The tree entries can't be saved outside of the download directory.
+	_, _, err = p.tree(ctx, "workspace", "repo", "hostile", "")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotLocalPath, "docs/../../x"), err)
+	// The token isn't sent to the next links of other hosts.
+	_, _, err = p.tree(ctx, "workspace", "repo", "main", "https://example.com/2.0/src?page=2")
+	assert.Equal(t, fmt.Errorf("%w: https://example.com/2.0/src?page=2", ErrBadLink), err)
+
+	p.base = "http://[::1"
+	_, _, err = p.tree(ctx, "workspace", "repo", "main", "")
+	assert.Error(t, err)
+	_, err = p.get(ctx, p.rawURL("workspace", "repo", "main", "a.md"))
+	assert.Error(t, err)
+}
+
+func TestServerProvider(t *testing.T) {
+	t.Parallel()
+	srv := dataCenterServer(t, "Bearer secret")
+	p := newServer("stash.example.com", srv.URL)
+	p.prefix, p.token = credentials("secret")
+	ctx := context.Background()
+	assert.Equal(t, "stash.example.com", p.host())
+
+	branch, _, err := p.defaultBranch(ctx, "PROJ", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	names, _, err := p.refs(ctx, "PROJ", "repo", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, _, err = p.refs(ctx, "PROJ", "repo", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+
+	sha, _, err := p.commitSHA(ctx, "PROJ", "repo", "feature/x")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("d", 40), sha)
+
+	tree, resp, err := p.tree(ctx, "PROJ", "repo", sha, "")
+	require.NoError(t, err)
+	assert.Equal(t, cursor("2"), resp.next)
+	require.Len(t, tree.entries, 2)
+	assert.Equal(t, "docs/a.md", tree.entries[0].path)
+	assert.Equal(t, entryBlob, tree.entries[0].typ)
+
+	tree, resp, err = p.tree(ctx, "PROJ", "repo", sha, "2")
+	require.NoError(t, err)
+	assert.Empty(t, resp.next)
+	assert.Equal(t, "src/main.go", tree.entries[0].path)
+
+	url, _, err := p.file(ctx, "PROJ", "repo", sha, "docs/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/projects/PROJ/repos/repo/raw/docs/a.md?at="+sha, url)
+	raw, err := p.get(ctx, url)
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+sha, string(body))
+
+	_, _, err = p.file(ctx, "PROJ", "repo", sha, "docs")
+	assert.Equal(t, ErrNotFile, err)
+}
+
+func TestServerProviderError(t *testing.T) {
+	t.Parallel()
+	srv := dataCenterServer(t, "Bearer secret")
+	ctx := context.Background()
+
+	p := newServer("stash.example.com", srv.URL)
+	_, _, err := p.defaultBranch(ctx, "PROJ", "repo")
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized projects/PROJ/repos/repo/default-branch", ErrBadStatus), err)
+	_, _, err = p.refs(ctx, "PROJ", "repo", "heads", "main", "")
+	assert.ErrorIs(t, err, ErrBadStatus)
+
+	p.prefix, p.token = credentials("secret")
+	_, _, err = p.commitSHA(ctx, "PROJ", "repo", "missing")
+	assert.Equal(t, fmt.Errorf("%w: missing", ErrNoRef), err)
+	_, _, err = p.file(ctx, "PROJ", "repo", "main", "missing.md")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.tree(ctx, "PROJ", "repo", "hostile", "")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotLocalPath, "docs/../../x"), err)
+
+	p.base = "http://[::1"
+	_, _, err = p.tree(ctx, "PROJ", "repo", "main", "")
+	assert.Error(t, err)
+	_, err = p.get(ctx, p.rawURL("PROJ", "repo", "main", "a.md"))
+	assert.Error(t, err)
+}
+
+// setServer configures the stand-in as a Bitbucket Server, and returns its
+// name.
+func setServer(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	name := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv(tokenVar("BITBUCKET_TOKEN", name), "secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(bitbucketHostsKey, srv.URL)
+	return name
+}
+
+func TestDownloadBitbucketServer(t *testing.T) {
+	srv := dataCenterServer(t, "Bearer secret")
+	name := setServer(t, srv)
+
+	tests := []struct {
+		name        string
+		url         string
+		ref         string
+		path        string
+		expectedSHA string
+		expectedRef string
+	}{
+		{name: "default branch", url: srv.URL + "/projects/PROJ/repos/repo", path: "docs", expectedSHA: strings.Repeat("c", 40), expectedRef: "main"},
+		{name: "qualified ref", url: name + "/projects/PROJ/repos/repo/browse/docs?at=refs%2Fheads%2Ffeature%2Fx", expectedSHA: strings.Repeat("d", 40), expectedRef: "feature/x"},
+		{name: "version constraint", url: srv.URL + "/scm/PROJ/repo.git", ref: "^1.0", path: "docs", expectedSHA: strings.Repeat("e", 40), expectedRef: "v1.0.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := New()
+			opts := &Options{Log: io.Discard, Ref: test.ref, Path: test.path, Archive: filepath.Join(t.TempDir(), "docs.zip")}
+
+			res, err := g.Download(context.Background(), []string{test.url}, opts)
+			require.NoError(t, err)
+			require.Len(t, res.Files, 1)
+			assert.Equal(t, "docs/a.md", res.Files[0].LocalPath)
+			assert.Equal(t, srv.URL+"/projects/PROJ/repos/repo/raw/docs/a.md?at="+test.expectedSHA, res.Files[0].URL)
+			assert.Equal(t, []string{test.expectedRef}, res.Refs)
+			assert.Equal(t, []string{test.expectedSHA}, res.Commits)
+		})
+	}
+}
+
+func TestCatBitbucketServer(t *testing.T) {
+	srv := dataCenterServer(t, "Bearer secret")
+	setServer(t, srv)
+
+	var buf bytes.Buffer
+	err := New().Cat(context.Background(), &buf, srv.URL+"/projects/PROJ/repos/repo/browse/docs/a.md?at=refs/heads/main", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+strings.Repeat("c", 40), buf.String())
+
+	err = New().Cat(context.Background(), io.Discard, srv.URL+"/projects/PROJ/repos/repo/browse/docs", nil)
+	assert.Equal(t, ErrNotFile, err)
+
+	_, err = New().Release(context.Background(), srv.URL+"/projects/PROJ/repos/repo", &Options{Log: io.Discard})
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
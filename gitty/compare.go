@@ -0,0 +1,279 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// compareFileLimit represents the number of files a comparison lists at
+// most over all its pages. Larger comparisons are diffed from the trees.
+const compareFileLimit = 300
+
+var (
+	ErrNoCommit       = errors.New("no commit found before the date")
+	ErrNotValidDate   = errors.New("not valid date")
+	ErrTooManyChanges = errors.New("too many changed files to compare")
+)
+
+// parseDate parses a date like 2006-01-02 or 2006-01-02T15:04:05Z07:00.
+func parseDate(s string) (time.Time, bool) {
+	for _, layout := range []string{time.DateOnly, time.RFC3339} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// resolveSince resolves the date to download the changes since, if any, to
+// the last commit of the reference before it. References are used as is.
+func (g *GitHub) resolveSince(ctx context.Context) error {
+	if g.since == "" {
+		return nil
+	}
+	h, err := g.history("since")
+	if err != nil {
+		return err
+	}
+	t, ok := parseDate(g.since)
+	if !ok {
+		return nil
+	}
+
+	sha, err := g.commitAt(ctx, h, t, "")
+	if err != nil {
+		return fmt.Errorf("failed to resolve since: %w", err)
+	}
+	g.since = sha
+
+	return nil
+}
+
+// resolveAt resolves the reference to the last commit of the reference at
+// or before opts.At, if set, which changed the path.
+func (g *GitHub) resolveAt(ctx context.Context) error {
+	if g.opts == nil || g.opts.At == "" {
+		return nil
+	}
+	h, err := g.history("at")
+	if err != nil {
+		return err
+	}
+	t, ok := parseDate(g.opts.At)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNotValidDate, g.opts.At)
+	}
+
+	sha, err := g.commitAt(ctx, h, t, g.Path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve at: %w", err)
+	}
+	g.name = g.ref()
+	g.Ref = &github.RepositoryContentGetOptions{Ref: sha}
+
+	return nil
+}
+
+// commitAt returns the SHA of the last commit of the reference at the time.
+// The commits are narrowed down to the ones which changed the path, if any.
+func (g *GitHub) commitAt(ctx context.Context, h historian, t time.Time, path string) (string, error) {
+	if err := g.engine.budget(); err != nil {
+		return "", err
+	}
+	sha, resp, err := h.commitAt(ctx, g.Owner, g.Repo, g.ref(), path, t)
+	g.engine.stats.reply(resp)
+	if err != nil {
+		return "", err
+	}
+
+	return sha, nil
+}
+
+// change represents a file changed between two references. A renamed file
+// has its previous path.
+type change struct {
+	path     string
+	previous string
+	status   string
+	sha      string
+}
+
+// collectChanges downloads the files under the path which were added or
+// modified between the since reference and the reference. It records the
+// files under the path which were removed, or renamed away, as deleted, and
+// removes them from the disk if opts.Prune is set.
+func (g *GitHub) collectChanges(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	files, err := g.changes(ctx)
+	if err != nil {
+		errCh <- err
+		return
+	}
+
+	changed := make(map[string]string)
+	for _, f := range files {
+		switch f.status {
+		case statusUnchanged, statusRemoved:
+		default:
+			if under(f.path, g.Path) && !g.opts.filtered(f.path) {
+				changed[f.path] = ""
+			}
+		}
+	}
+	if err := g.expect(changed); err != nil {
+		errCh <- err
+		return
+	}
+
+	for _, f := range files {
+		if f.previous != "" && under(f.previous, g.Path) {
+			if err := g.delete(f.previous); err != nil {
+				errCh <- err
+				return
+			}
+		}
+		if !under(f.path, g.Path) {
+			continue
+		}
+
+		switch f.status {
+		case statusUnchanged:
+		case statusRemoved:
+			if err := g.delete(f.path); err != nil {
+				errCh <- err
+				return
+			}
+		default:
+			wg.Add(1)
+			go g.fetchAt(ctx, wg, errCh, g.ref(), f.path, f.sha)
+		}
+	}
+}
+
+// changes lists the files changed between the since reference and the
+// reference. GitHub compares them, unless the comparison may have more files
+// than it lists, and the other hosts diff their trees.
+func (g *GitHub) changes(ctx context.Context) ([]*change, error) {
+	if g.provider != nil {
+		return g.diffTrees(ctx)
+	}
+
+	compared, err := g.compareFiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(compared) >= compareFileLimit {
+		return g.diffTrees(ctx)
+	}
+
+	files := make([]*change, 0, len(compared))
+	for _, f := range compared {
+		files = append(files, &change{
+			path:     f.GetFilename(),
+			previous: f.GetPreviousFilename(),
+			status:   f.GetStatus(),
+			sha:      f.GetSHA(),
+		})
+	}
+
+	return files, nil
+}
+
+// compareFiles lists the files of the comparison between the since
+// reference and the reference, page by page.
+func (g *GitHub) compareFiles(ctx context.Context) ([]*github.CommitFile, error) {
+	var files []*github.CommitFile
+	opts := &github.ListOptions{PerPage: filesPerPage}
+	for {
+		if err := g.engine.budget(); err != nil {
+			return nil, err
+		}
+		cmp, resp, err := g.Client.CompareCommits(ctx, g.Owner, g.Repo, g.since, g.ref(), opts)
+		g.engine.stats.call(resp)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, cmp.Files...)
+		if resp == nil || resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// diffTrees lists the files whose blobs differ between the trees of the
+// since reference and the reference, in path order. The trees don't record
+// renames, so renamed files are listed as removed and added.
+func (g *GitHub) diffTrees(ctx context.Context) ([]*change, error) {
+	base, err := g.engine.tree(ctx, g.source(), g.Owner, g.Repo, g.since)
+	if err != nil {
+		return nil, err
+	}
+	head, err := g.engine.tree(ctx, g.source(), g.Owner, g.Repo, g.ref())
+	if err != nil {
+		return nil, err
+	}
+	if base.truncated || head.truncated {
+		return nil, fmt.Errorf("%w: %s...%s has a truncated tree", ErrTooManyChanges, g.since, g.ref())
+	}
+
+	blobs := make(map[string]string)
+	for _, entry := range base.entries {
+		if entry.typ == entryBlob {
+			blobs[entry.path] = entry.sha
+		}
+	}
+
+	var files []*change
+	for _, entry := range head.entries {
+		if entry.typ != entryBlob {
+			continue
+		}
+		sha, ok := blobs[entry.path]
+		delete(blobs, entry.path)
+		switch {
+		case !ok:
+			files = append(files, &change{path: entry.path, status: statusAdded, sha: entry.sha})
+		case sha != entry.sha:
+			files = append(files, &change{path: entry.path, status: statusModified, sha: entry.sha})
+		}
+	}
+	for path := range blobs {
+		files = append(files, &change{path: path, status: statusRemoved})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].path < files[j].path
+	})
+
+	return files, nil
+}
+
+// delete records the file at the remote path as deleted. It removes the
+// file from the disk if opts.Prune is set, unless the downloads are written
+// into an archive.
+func (g *GitHub) delete(path string) error {
+	p, err := exactPath(g.target(path))
+	if err != nil {
+		return err
+	}
+	g.engine.stats.delete(p)
+
+	if g.opts == nil || !g.opts.Prune || g.engine.archive != nil {
+		return nil
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	fmt.Fprintln(g.opts.log(), "Deleting:", p)
+
+	return nil
+}
@@ -0,0 +1,357 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		s        string
+		expected time.Time
+		ok       bool
+	}{
+		{name: "date", s: "2024-01-02", expected: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "time", s: "2024-01-02T03:04:05Z", expected: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ok: true},
+		{name: "ref", s: "v1.0.0", ok: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			actual, ok := parseDate(test.s)
+			assert.Equal(t, test.ok, ok)
+			assert.True(t, test.expected.Equal(actual))
+		})
+	}
+}
+
+// mockNoCommits lists no commits.
+type mockNoCommits struct {
+	mockSuccess
+}
+
+func (m *mockNoCommits) ListCommits(_ context.Context, _, _ string, _ *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return nil, nil, nil
+}
+
+func TestResolveSince(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		client      mockClient
+		url         string
+		since       string
+		expected    string
+		expectedRef string
+		expectedErr error
+	}{
+		{
+			name:        "compare url",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/compare/v1.0...feature/x",
+			expected:    "v1.0",
+			expectedRef: "feature/x",
+		},
+		{
+			name:        "ref",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			since:       "v1.0",
+			expected:    "v1.0",
+			expectedRef: "main",
+		},
+		{
+			name:        "date",
+			client:      &mockSuccess{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			since:       "2024-01-02",
+			expected:    "datesha",
+			expectedRef: "main",
+		},
+		{
+			name:        "error commits",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			since:       "2024-01-02",
+			expectedErr: fmt.Errorf("failed to resolve since: %w", errMockCommits),
+		},
+		{
+			name:        "error no commit",
+			client:      &mockNoCommits{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			since:       "2024-01-02",
+			expectedErr: fmt.Errorf("failed to resolve since: %w", fmt.Errorf("%w: %s", ErrNoCommit, "2024-01-02T00:00:00Z")),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: &Options{Since: test.since}, engine: newEngine(0)}
+			err := g.extract(test.url)
+			require.NoError(t, err)
+
+			err = g.resolve(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, test.expected, g.since)
+				assert.Equal(t, test.expectedRef, g.refName())
+			}
+		})
+	}
+}
+
+// mockCommitsAt lists a commit whose SHA records the listing options.
+type mockCommitsAt struct {
+	mockSuccess
+}
+
+func (m *mockCommitsAt) ListCommits(_ context.Context, _, _ string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	sha := commitData(fmt.Sprintf("%s@%s:%s", opts.SHA, opts.Until.Format(time.RFC3339), opts.Path))
+	return []*github.RepositoryCommit{{SHA: ptr(sha)}}, nil, nil
+}
+
+func TestResolveAt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		client       mockClient
+		url          string
+		at           string
+		expectedRef  string
+		expectedName string
+		expectedErr  error
+	}{
+		{
+			name:         "no time",
+			client:       &mockError{},
+			url:          "github.com/owner/repo/tree/main/dir",
+			expectedRef:  commitData("main"),
+			expectedName: "main",
+		},
+		{
+			name:         "time",
+			client:       &mockCommitsAt{},
+			url:          "github.com/owner/repo/tree/main/dir/sub",
+			at:           "2024-01-31T00:00:00Z",
+			expectedRef:  commitData("main@2024-01-31T00:00:00Z:dir/sub"),
+			expectedName: "main",
+		},
+		{
+			name:         "date",
+			client:       &mockCommitsAt{},
+			url:          "github.com/owner/repo/blob/v1.0/README.md",
+			at:           "2024-01-31",
+			expectedRef:  commitData("v1.0@2024-01-31T00:00:00Z:README.md"),
+			expectedName: "v1.0",
+		},
+		{
+			name:        "error not valid date",
+			client:      &mockCommitsAt{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			at:          "yesterday",
+			expectedErr: fmt.Errorf("%w: %q", ErrNotValidDate, "yesterday"),
+		},
+		{
+			name:        "error commits",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			at:          "2024-01-31",
+			expectedErr: fmt.Errorf("failed to resolve at: %w", errMockCommits),
+		},
+		{
+			name:        "error no commit",
+			client:      &mockNoCommits{},
+			url:         "github.com/owner/repo/tree/main/dir",
+			at:          "2024-01-31T12:00:00+02:00",
+			expectedErr: fmt.Errorf("failed to resolve at: %w", fmt.Errorf("%w: %s", ErrNoCommit, "2024-01-31T12:00:00+02:00")),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: &Options{At: test.at}, engine: newEngine(0)}
+			err := g.extract(test.url)
+			require.NoError(t, err)
+
+			err = g.resolve(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, test.expectedRef, g.ref())
+				assert.Equal(t, test.expectedName, g.refName())
+			}
+		})
+	}
+}
+
+func TestCollectChanges(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		prune bool
+	}{
+		{name: "report deleted", prune: false},
+		{name: "prune deleted", prune: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeBase)
+				require.NoError(t, err)
+			})
+			ctx := context.WithValue(context.Background(), filesKey, []*github.CommitFile{
+				{Filename: ptr(fakeBase + "/added.go"), Status: ptr(statusAdded)},
+				{Filename: ptr(fakeBase + "/modified.go"), Status: ptr("modified")},
+				{Filename: ptr(fakeBase + "/removed.go"), Status: ptr(statusRemoved)},
+				{Filename: ptr(fakeBase + "/renamed.go"), PreviousFilename: ptr(fakeBase + "/old.go"), Status: ptr("renamed")},
+				{Filename: ptr(fakeBase + "/unchanged.go"), Status: ptr(statusUnchanged)},
+				{Filename: ptr("other/modified.go"), Status: ptr("modified")},
+			})
+
+			// The removed file exists from a previous download.
+			removed := filepath.Join(fakeBase, "removed.go")
+			err := os.MkdirAll(fakeBase, os.ModePerm)
+			require.NoError(t, err)
+			err = os.WriteFile(removed, nil, 0o600)
+			require.NoError(t, err)
+
+			g := &GitHub{Client: &mockSuccess{}, opts: &Options{Since: "v1.0", Prune: test.prune, Log: io.Discard}, engine: newEngine(0)}
+			err = g.extract("github.com/owner/repo/tree/main/" + fakeBase)
+			require.NoError(t, err)
+			err = g.download(ctx)
+			require.NoError(t, err)
+
+			res := g.result()
+			var files []string
+			for _, f := range res.Files {
+				files = append(files, f.RemotePath)
+			}
+			sort.Strings(files)
+			sort.Strings(res.Deleted)
+			assert.Equal(t, []string{fakeBase + "/added.go", fakeBase + "/modified.go", fakeBase + "/renamed.go"}, files)
+			assert.Equal(t, []string{filepath.Join(fakeBase, "old.go"), removed}, res.Deleted)
+
+			_, err = os.Stat(removed)
+			assert.Equal(t, test.prune, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestCollectChangesError(t *testing.T) {
+	t.Parallel()
+	g := &GitHub{Client: &mockError{}, since: "v1.0", engine: newEngine(0)}
+	err := g.download(context.Background())
+	assert.Equal(t, fmt.Errorf("failed to download: %w", errMockCompare), err)
+
+	// The comparison lists as many files as it can, and the trees are
+	// truncated.
+	ctx := context.WithValue(context.Background(), filesKey, largeComparison())
+	g = &GitHub{Client: &mockSuccess{}, since: "v1.0", Ref: &github.RepositoryContentGetOptions{Ref: "main"}, engine: newEngine(0)}
+	err = g.download(ctx)
+	expectedErr := fmt.Errorf("%w: v1.0...main has a truncated tree", ErrTooManyChanges)
+	assert.Equal(t, fmt.Errorf("failed to download: %w", expectedErr), err)
+	assert.Empty(t, g.result().Files)
+}
+
+// largeComparison returns as many files as a comparison lists at most.
+func largeComparison() []*github.CommitFile {
+	files := make([]*github.CommitFile, compareFileLimit)
+	for i := range files {
+		files[i] = &github.CommitFile{Filename: ptr(fmt.Sprintf("file%d.go", i)), Status: ptr(statusAdded)}
+	}
+	return files
+}
+
+// mockPagedCompare lists the files of the comparison in two pages, or
+// returns the large comparison, and lists the tree of each reference.
+type mockPagedCompare struct {
+	mockSuccess
+	large bool
+	calls atomic.Int32
+}
+
+func (m *mockPagedCompare) CompareCommits(_ context.Context, _, _, _, _ string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	m.calls.Add(1)
+	switch {
+	case m.large:
+		return &github.CommitsComparison{Files: largeComparison()}, &github.Response{}, nil
+	case opts.Page == 0:
+		return &github.CommitsComparison{Files: []*github.CommitFile{{Filename: ptr("a.go"), Status: ptr(statusAdded)}}}, &github.Response{NextPage: 2}, nil
+	}
+	return &github.CommitsComparison{Files: []*github.CommitFile{{Filename: ptr("b.go"), Status: ptr(statusRemoved)}}}, &github.Response{}, nil
+}
+
+func (m *mockPagedCompare) GetTree(_ context.Context, _, _, sha string, _ bool) (*github.Tree, *github.Response, error) {
+	entries := []*github.TreeEntry{{Type: ptr(entryBlob), Path: ptr("a.go"), SHA: ptr("sha1")}}
+	if sha == "main" {
+		entries = []*github.TreeEntry{
+			{Type: ptr(entryBlob), Path: ptr("a.go"), SHA: ptr("sha2")},
+			{Type: ptr(entryBlob), Path: ptr("b.go"), SHA: ptr("sha3")},
+		}
+	}
+	return &github.Tree{Entries: entries, Truncated: ptr(false)}, &github.Response{}, nil
+}
+
+func TestChanges(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		large         bool
+		expected      []*change
+		expectedCalls int32
+	}{
+		{
+			name: "pages",
+			expected: []*change{
+				{path: "a.go", status: statusAdded},
+				{path: "b.go", status: statusRemoved},
+			},
+			expectedCalls: 2,
+		},
+		{
+			name:  "tree diff",
+			large: true,
+			expected: []*change{
+				{path: "a.go", status: statusModified, sha: "sha2"},
+				{path: "b.go", status: statusAdded, sha: "sha3"},
+			},
+			expectedCalls: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c := &mockPagedCompare{large: test.large}
+			g := &GitHub{Client: c, since: "v1.0", Ref: &github.RepositoryContentGetOptions{Ref: "main"}, engine: newEngine(0)}
+			files, err := g.changes(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, files)
+			assert.Equal(t, test.expectedCalls, c.calls.Load())
+		})
+	}
+
+	g := &GitHub{Client: &mockPagedCompare{}, since: "v1.0", engine: newEngine(0)}
+	g.engine.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err := g.changes(context.Background())
+	assert.Equal(t, ErrRateLimited, err)
+}
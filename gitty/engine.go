@@ -0,0 +1,322 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+)
+
+// defaultConcurrency represents the default number of concurrent requests.
+const defaultConcurrency = 16
+
+var (
+	ErrRateLimited = errors.New("rate limit exceeded")
+	ErrOverlap     = errors.New("destination paths overlap")
+)
+
+// refsPerPage represents the number of references listed per request.
+const refsPerPage = 100
+
+// engine schedules the downloads of a run. The downloads share the
+// concurrency limit, the rate limit budget, the tree listings, the reference
+// lookups, and the stats.
+type engine struct {
+	sem      chan struct{}
+	stats    stats
+	archive  *archive
+	mu       sync.Mutex
+	trees    map[string]*listing
+	refs     map[string]*lookup
+	branches map[string]*lookup
+	commits  map[string]*lookup
+	tags     map[string]*lookup
+}
+
+// listing represents a tree listing of a repository reference. It is
+// fetched only once, unless the fetch is interrupted.
+type listing struct {
+	once sync.Once
+	tree *fileTree
+	err  error
+}
+
+// lookup represents the reference names of a repository, such as the
+// branches and tags that start with a name, all the tags, the default
+// branch, or the commit SHA of a reference. It is fetched only once, unless
+// the fetch is interrupted.
+type lookup struct {
+	once  sync.Once
+	names []string
+	err   error
+}
+
+// forget removes the cached entry of the key if its fetch was canceled or
+// timed out, so that the next download fetches it again instead of failing
+// with the context error of another download.
+func forget[T comparable](mu *sync.Mutex, cache map[string]T, key string, entry T, err error) {
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if cache[key] == entry {
+		delete(cache, key)
+	}
+}
+
+// newEngine creates a new engine with the given concurrency limit.
+func newEngine(concurrency int) *engine {
+	if concurrency < 1 {
+		concurrency = defaultConcurrency
+	}
+	e := &engine{
+		sem:      make(chan struct{}, concurrency),
+		trees:    make(map[string]*listing),
+		refs:     make(map[string]*lookup),
+		branches: make(map[string]*lookup),
+		commits:  make(map[string]*lookup),
+		tags:     make(map[string]*lookup),
+	}
+	e.stats.reset()
+	return e
+}
+
+// save writes the file into the archive, if any. Otherwise, it saves the
+// file on the disk. The size of the body is -1 if unknown.
+func (e *engine) save(base, path string, body io.Reader, size int64) (string, int64, error) {
+	if e.archive != nil {
+		return e.archive.save(base, path, body, size)
+	}
+	return saveFile(base, path, body)
+}
+
+// acquire blocks until a request slot is free or ctx is done.
+func (e *engine) acquire(ctx context.Context) error {
+	select {
+	case e.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a request slot.
+func (e *engine) release() {
+	<-e.sem
+}
+
+// budget returns ErrRateLimited if the rate limit is exhausted.
+func (e *engine) budget() error {
+	if e.stats.exhausted() {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// tree returns the recursive tree listing of the repository reference,
+// page by page. The listing is fetched once per run and shared between the
+// downloads.
+func (e *engine) tree(ctx context.Context, p provider, owner, repo, ref string) (*fileTree, error) {
+	key := fmt.Sprintf("%s/%s/%s@%s", p.host(), owner, repo, ref)
+	e.mu.Lock()
+	l, ok := e.trees[key]
+	if !ok {
+		l = &listing{}
+		e.trees[key] = l
+	}
+	e.mu.Unlock()
+
+	l.once.Do(func() {
+		l.tree, l.err = e.listTree(ctx, p, owner, repo, ref)
+	})
+	forget(&e.mu, e.trees, key, l, l.err)
+
+	return l.tree, l.err
+}
+
+// listTree lists the pages of the recursive tree of the repository
+// reference, and merges them.
+func (e *engine) listTree(ctx context.Context, p provider, owner, repo, ref string) (*fileTree, error) {
+	if err := e.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer e.release()
+
+	var tree *fileTree
+	var page cursor
+	for {
+		if err := e.budget(); err != nil {
+			return nil, err
+		}
+		t, resp, err := p.tree(ctx, owner, repo, ref, page)
+		e.stats.reply(resp)
+		if err != nil {
+			return nil, err
+		}
+		if tree == nil {
+			tree = t
+		} else {
+			tree.entries = append(tree.entries, t.entries...)
+		}
+		if resp == nil || resp.next == "" {
+			return tree, nil
+		}
+		page = resp.next
+	}
+}
+
+// matchingRefs returns the branch and tag names of the repository that
+// start with the given name. The lookups are fetched once per repository
+// and name, and shared between the downloads.
+func (e *engine) matchingRefs(ctx context.Context, p provider, owner, repo, name string) ([]string, error) {
+	key := fmt.Sprintf("%s/%s/%s:%s", p.host(), owner, repo, name)
+	e.mu.Lock()
+	l, ok := e.refs[key]
+	if !ok {
+		l = &lookup{}
+		e.refs[key] = l
+	}
+	e.mu.Unlock()
+
+	l.once.Do(func() {
+		for _, kind := range []string{"heads", "tags"} {
+			names, err := e.listRefs(ctx, p, owner, repo, kind, name)
+			if err != nil {
+				l.err = err
+				return
+			}
+			l.names = append(l.names, names...)
+		}
+	})
+	forget(&e.mu, e.refs, key, l, l.err)
+
+	return l.names, l.err
+}
+
+// listTags returns the tag names of the repository. They are fetched once
+// per repository and shared between the downloads.
+func (e *engine) listTags(ctx context.Context, p provider, owner, repo string) ([]string, error) {
+	key := fmt.Sprintf("%s/%s/%s", p.host(), owner, repo)
+	e.mu.Lock()
+	l, ok := e.tags[key]
+	if !ok {
+		l = &lookup{}
+		e.tags[key] = l
+	}
+	e.mu.Unlock()
+
+	l.once.Do(func() {
+		l.names, l.err = e.listRefs(ctx, p, owner, repo, "tags", "")
+	})
+	forget(&e.mu, e.tags, key, l, l.err)
+
+	return l.names, l.err
+}
+
+// listRefs lists the names of the references of the kind, heads or tags,
+// that start with the given name, page by page. All of them are listed if
+// the name is empty.
+func (e *engine) listRefs(ctx context.Context, p provider, owner, repo, kind, name string) ([]string, error) {
+	var names []string
+	var page cursor
+	for {
+		if err := e.budget(); err != nil {
+			return nil, err
+		}
+		refs, resp, err := p.refs(ctx, owner, repo, kind, name, page)
+		e.stats.reply(resp)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, refs...)
+		if resp == nil || resp.next == "" {
+			return names, nil
+		}
+		page = resp.next
+	}
+}
+
+// defaultBranch returns the default branch of the repository. It is fetched
+// once per repository and shared between the downloads.
+func (e *engine) defaultBranch(ctx context.Context, p provider, owner, repo string) (string, error) {
+	key := fmt.Sprintf("%s/%s/%s", p.host(), owner, repo)
+	e.mu.Lock()
+	l, ok := e.branches[key]
+	if !ok {
+		l = &lookup{}
+		e.branches[key] = l
+	}
+	e.mu.Unlock()
+
+	l.once.Do(func() {
+		if l.err = e.budget(); l.err != nil {
+			return
+		}
+		branch, resp, err := p.defaultBranch(ctx, owner, repo)
+		e.stats.reply(resp)
+		if err != nil {
+			l.err = err
+			return
+		}
+		l.names = []string{branch}
+	})
+	forget(&e.mu, e.branches, key, l, l.err)
+	if l.err != nil {
+		return "", l.err
+	}
+
+	return l.names[0], nil
+}
+
+// commitSHA returns the commit SHA of the repository reference. It is
+// fetched once per reference and shared between the downloads, so they
+// download the same commit.
+func (e *engine) commitSHA(ctx context.Context, p provider, owner, repo, ref string) (string, error) {
+	key := fmt.Sprintf("%s/%s/%s@%s", p.host(), owner, repo, ref)
+	e.mu.Lock()
+	l, ok := e.commits[key]
+	if !ok {
+		l = &lookup{}
+		e.commits[key] = l
+	}
+	e.mu.Unlock()
+
+	l.once.Do(func() {
+		if l.err = e.budget(); l.err != nil {
+			return
+		}
+		sha, resp, err := p.commitSHA(ctx, owner, repo, ref)
+		e.stats.reply(resp)
+		if err != nil {
+			l.err = err
+			return
+		}
+		l.names = []string{sha}
+	})
+	forget(&e.mu, e.commits, key, l, l.err)
+	if l.err != nil {
+		return "", l.err
+	}
+
+	return l.names[0], nil
+}
+
+// overlaps returns ErrOverlap if any destination path is equal to or
+// inside another one.
+func overlaps(dests []string) error {
+	for i, a := range dests {
+		for _, b := range dests[i+1:] {
+			a, b := path.Clean(a), path.Clean(b)
+			if a == b || a == "." || b == "." ||
+				strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/") {
+				return fmt.Errorf("%w: %s and %s", ErrOverlap, a, b)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,283 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEngine(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, defaultConcurrency, cap(newEngine(0).sem))
+	assert.Equal(t, 3, cap(newEngine(3).sem))
+	assert.Equal(t, -1, newEngine(1).stats.result().Summary.RateLimitRemaining)
+}
+
+func TestEngineAcquire(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	err := e.acquire(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = e.acquire(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	e.release()
+	err = e.acquire(context.Background())
+	require.NoError(t, err)
+}
+
+func TestEngineBudget(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	require.NoError(t, e.budget())
+
+	e.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	assert.Equal(t, ErrRateLimited, e.budget())
+
+	_, err := e.tree(context.Background(), githubOf(&mockSuccess{}), "owner", "repo", "main")
+	assert.Equal(t, ErrRateLimited, err)
+}
+
+// mockCountTree counts the tree requests.
+type mockCountTree struct {
+	mockSuccess
+	calls atomic.Int32
+}
+
+func (m *mockCountTree) GetTree(ctx context.Context, owner, repo, sha string, recursive bool) (*github.Tree, *github.Response, error) {
+	m.calls.Add(1)
+	return m.mockSuccess.GetTree(ctx, owner, repo, sha, recursive)
+}
+
+func TestEngineTree(t *testing.T) {
+	t.Parallel()
+	e := newEngine(2)
+	c := &mockCountTree{}
+
+	wg := &sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tree, err := e.tree(context.Background(), githubOf(c), "owner", "repo", "main")
+			assert.NoError(t, err)
+			assert.NotNil(t, tree)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), c.calls.Load())
+
+	_, err := e.tree(context.Background(), githubOf(c), "owner", "repo", "dev")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), c.calls.Load())
+	assert.Equal(t, 2, e.stats.result().Summary.APICalls)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	e.sem <- struct{}{}
+	e.sem <- struct{}{}
+	_, err = e.tree(ctx, githubOf(c), "owner", "repo", "canceled")
+	assert.Equal(t, context.Canceled, err)
+
+	// The canceled listing is fetched again.
+	<-e.sem
+	<-e.sem
+	_, err = e.tree(context.Background(), githubOf(c), "owner", "repo", "canceled")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), c.calls.Load())
+}
+
+func TestForget(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		err      error
+		replaced bool
+		expected bool
+	}{
+		{name: "no error", expected: true},
+		{name: "error", err: errMockTree, expected: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("wrapped: %w", context.DeadlineExceeded)},
+		{name: "replaced", err: context.Canceled, replaced: true, expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var mu sync.Mutex
+			l := &lookup{}
+			cache := map[string]*lookup{"key": l}
+			if test.replaced {
+				cache["key"] = &lookup{}
+			}
+
+			forget(&mu, cache, "key", l, test.err)
+			_, ok := cache["key"]
+			assert.Equal(t, test.expected, ok)
+		})
+	}
+}
+
+// mockPagedRefs lists the references in two pages and counts the requests.
+type mockPagedRefs struct {
+	mockSuccess
+	calls atomic.Int32
+}
+
+func (m *mockPagedRefs) ListMatchingRefs(_ context.Context, _, _ string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error) {
+	m.calls.Add(1)
+	switch {
+	case opts.Ref == "tags" && opts.Page == 0:
+		return []*github.Reference{{Ref: ptr("refs/tags/v1.0.0")}}, &github.Response{NextPage: 2}, nil
+	case opts.Ref == "tags":
+		return []*github.Reference{{Ref: ptr("refs/tags/v1.1.0")}}, &github.Response{}, nil
+	case opts.Ref != "heads/feature":
+		return nil, &github.Response{}, nil
+	case opts.Page == 0:
+		return []*github.Reference{{Ref: ptr("refs/heads/feature")}}, &github.Response{NextPage: 2}, nil
+	}
+	return []*github.Reference{{Ref: ptr("refs/heads/feature/new-api")}}, &github.Response{}, nil
+}
+
+func TestEngineMatchingRefs(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	c := &mockPagedRefs{}
+
+	for range 2 {
+		names, err := e.matchingRefs(context.Background(), githubOf(c), "owner", "repo", "feature")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"feature", "feature/new-api"}, names)
+	}
+	// Two pages of branches and a page of tags.
+	assert.Equal(t, int32(3), c.calls.Load())
+
+	_, err := e.matchingRefs(context.Background(), githubOf(c), "other", "repo", "feature")
+	require.NoError(t, err)
+	assert.Equal(t, int32(6), c.calls.Load())
+
+	e.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err = e.matchingRefs(context.Background(), githubOf(c), "owner", "repo", "main")
+	assert.Equal(t, ErrRateLimited, err)
+}
+
+// mockCountRepo counts the repository requests.
+type mockCountRepo struct {
+	mockSuccess
+	calls atomic.Int32
+}
+
+func (m *mockCountRepo) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	m.calls.Add(1)
+	return m.mockSuccess.GetRepository(ctx, owner, repo)
+}
+
+func TestEngineListTags(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	c := &mockPagedRefs{}
+
+	for range 2 {
+		names, err := e.listTags(context.Background(), githubOf(c), "owner", "repo")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, names)
+	}
+	// Two pages of tags, listed once.
+	assert.Equal(t, int32(2), c.calls.Load())
+
+	e.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err := e.listTags(context.Background(), githubOf(c), "other", "repo")
+	assert.Equal(t, ErrRateLimited, err)
+}
+
+func TestEngineDefaultBranch(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	c := &mockCountRepo{}
+
+	for range 2 {
+		branch, err := e.defaultBranch(context.Background(), githubOf(c), "owner", "repo")
+		require.NoError(t, err)
+		assert.Equal(t, "main", branch)
+	}
+	assert.Equal(t, int32(1), c.calls.Load())
+
+	_, err := e.defaultBranch(context.Background(), githubOf(&mockError{}), "other", "repo")
+	assert.Equal(t, errMockRepo, err)
+
+	e.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err = e.defaultBranch(context.Background(), githubOf(c), "another", "repo")
+	assert.Equal(t, ErrRateLimited, err)
+}
+
+func (m *mockCountRepo) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error) {
+	m.calls.Add(1)
+	return m.mockSuccess.GetCommitSHA1(ctx, owner, repo, ref, lastSHA)
+}
+
+func TestEngineCommitSHA(t *testing.T) {
+	t.Parallel()
+	e := newEngine(1)
+	c := &mockCountRepo{}
+
+	for range 2 {
+		sha, err := e.commitSHA(context.Background(), githubOf(c), "owner", "repo", "main")
+		require.NoError(t, err)
+		assert.Equal(t, commitData("main"), sha)
+	}
+	assert.Equal(t, int32(1), c.calls.Load())
+
+	_, err := e.commitSHA(context.Background(), githubOf(&mockError{}), "owner", "repo", testCommitFail)
+	assert.Equal(t, errMockCommit, err)
+
+	e.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err = e.commitSHA(context.Background(), githubOf(c), "owner", "repo", "dev")
+	assert.Equal(t, ErrRateLimited, err)
+}
+
+func TestOverlaps(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		dests    []string
+		expected error
+	}{
+		{
+			name:     "no overlap",
+			dests:    []string{"docs", "docs-v2", "src/docs"},
+			expected: nil,
+		},
+		{
+			name:     "same path",
+			dests:    []string{"docs", "src", "docs/"},
+			expected: fmt.Errorf("%w: %s and %s", ErrOverlap, "docs", "docs"),
+		},
+		{
+			name:     "nested path",
+			dests:    []string{"docs-v2", "docs/api", "docs"},
+			expected: fmt.Errorf("%w: %s and %s", ErrOverlap, "docs/api", "docs"),
+		},
+		{
+			name:     "current directory",
+			dests:    []string{"docs", "."},
+			expected: fmt.Errorf("%w: %s and %s", ErrOverlap, "docs", "."),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, overlaps(test.dests))
+		})
+	}
+}
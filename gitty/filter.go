@@ -0,0 +1,64 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrNotValidFilter = errors.New("not valid filter")
+
+// validateFilters reports whether the include and exclude globs are valid.
+func (o *Options) validateFilters() error {
+	if o == nil {
+		return nil
+	}
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%w: %q", ErrNotValidFilter, pattern)
+		}
+	}
+	return nil
+}
+
+// filtered reports whether the file at the slash-separated path is filtered
+// out by the include and exclude globs.
+func (o *Options) filtered(p string) bool {
+	if o == nil {
+		return false
+	}
+	if len(o.Include) > 0 && !matchAny(o.Include, p) {
+		return true
+	}
+	return matchAny(o.Exclude, p)
+}
+
+// filter drops the files of the tree entries which are filtered out by the
+// include and exclude globs, and counts them as skipped.
+func (g *GitHub) filter(entries []*treeEntry) []*treeEntry {
+	kept := make([]*treeEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entry.typ == entryBlob && entry.mode != modeSymlink && g.opts.filtered(entry.path) {
+			g.engine.stats.skip(typeFiltered)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// matchAny reports whether the path matches any of the globs. A glob
+// without a slash matches the base name, otherwise the whole path.
+func matchAny(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		name := p
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(p)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package gitty
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsFiltered(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     *Options
+		path     string
+		expected bool
+	}{
+		{name: "nil options", opts: nil, path: "a.txt", expected: false},
+		{name: "no filters", opts: &Options{}, path: "a.txt", expected: false},
+		{name: "included base name", opts: &Options{Include: []string{"*.xml"}}, path: "reports/a.xml", expected: false},
+		{name: "not included", opts: &Options{Include: []string{"*.xml"}}, path: "reports/a.txt", expected: true},
+		{name: "included path", opts: &Options{Include: []string{"reports/*"}}, path: "reports/a.txt", expected: false},
+		{name: "path glob needs the whole path", opts: &Options{Include: []string{"reports/*"}}, path: "reports/sub/a.txt", expected: true},
+		{name: "excluded", opts: &Options{Exclude: []string{"*.log"}}, path: "logs/a.log", expected: true},
+		{name: "included and excluded", opts: &Options{Include: []string{"*"}, Exclude: []string{"a.*"}}, path: "a.txt", expected: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.opts.filtered(test.path))
+		})
+	}
+}
+
+func TestOptionsValidateFilters(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		opts     *Options
+		expected error
+	}{
+		{name: "nil options", opts: nil},
+		{name: "valid", opts: &Options{Include: []string{"*.xml"}, Exclude: []string{"tmp/*"}}},
+		{name: "invalid include", opts: &Options{Include: []string{"[a"}}, expected: fmt.Errorf("%w: %q", ErrNotValidFilter, "[a")},
+		{name: "invalid exclude", opts: &Options{Exclude: []string{"\\"}}, expected: fmt.Errorf("%w: %q", ErrNotValidFilter, "\\")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.opts.validateFilters())
+		})
+	}
+}
+
+func TestDownloadFiltered(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	ctx := context.WithValue(context.Background(), treeKey, treeData(fakeBase, fakeBase+"/a.txt", fakeBase+"/b.go"))
+	url := "https://github.com/owner/repo/tree/branch/" + fakeBase
+
+	var buf bytes.Buffer
+	g := fakeNew(fakeRepository(&mockSuccess{}))
+	opts := &Options{Log: io.Discard, Archive: "-", Stdout: &buf, Exclude: []string{"*.go"}}
+	res, err := g.Download(ctx, []string{url}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.TotalFiles)
+	assert.Equal(t, 1, res.Summary.Skipped.Filtered)
+
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	entries := readTar(t, gr)
+	require.Len(t, entries, 1)
+	assert.Equal(t, fakeBase+"/a.txt", entries[0].name)
+}
+
+func TestFetchFiltered(t *testing.T) {
+	t.Parallel()
+	// The filtered file is not requested, so the failing client is not
+	// called.
+	g := &GitHub{Client: &mockError{}, opts: &Options{Include: []string{"docs/*"}}, engine: newEngine(0)}
+	err := g.fetch(context.Background(), "https://example.com/a.go", "src/a.go", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, g.engine.stats.result().Summary.Skipped.Filtered)
+
+	err = g.fetch(context.Background(), "https://example.com/a.md", "docs/a.md", "", "")
+	assert.Equal(t, errMockGet, err)
+}
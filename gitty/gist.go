@@ -0,0 +1,139 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// gistAnchorPrefix is the prefix of the anchors of gist files.
+const gistAnchorPrefix = "file-"
+
+// resolveGist gets the gist, at its revision if the URL has one, and keeps
+// its files matching the URL fragment.
+func (g *GitHub) resolveGist(ctx context.Context) error {
+	if err := g.engine.budget(); err != nil {
+		return err
+	}
+
+	var gist *github.Gist
+	var resp *github.Response
+	var err error
+	if g.ref() == "" {
+		gist, resp, err = g.Client.GetGist(ctx, g.gist)
+	} else {
+		gist, resp, err = g.Client.GetGistRevision(ctx, g.gist, g.ref())
+	}
+	g.engine.stats.call(resp)
+	if err != nil {
+		return fmt.Errorf("failed to resolve gist: %w", err)
+	}
+
+	g.gistFiles = matchGistFiles(gist.Files, g.file)
+	if len(g.gistFiles) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, g.file)
+	}
+	return nil
+}
+
+// collectGist saves the files of the gist concurrently. Truncated files are
+// downloaded from their raw URLs.
+func (g *GitHub) collectGist(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	for _, f := range g.gistFiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !complete(f) {
+				if err := g.fetch(ctx, f.GetRawURL(), f.GetFilename(), "", ""); err != nil {
+					errCh <- err
+				}
+				return
+			}
+			if err := g.saveGistFile(f); err != nil {
+				g.engine.stats.fail()
+				errCh <- err
+			}
+		}()
+	}
+}
+
+// saveGistFile saves the content of the gist file, which the gist response
+// holds in full.
+func (g *GitHub) saveGistFile(f github.GistFile) error {
+	fmt.Fprintln(g.opts.log(), "Downloading:", f.GetFilename())
+	content := f.GetContent()
+	file, err := g.store(f.GetRawURL(), f.GetFilename(), strings.NewReader(content), int64(len(content)), time.Now())
+	if err != nil {
+		return err
+	}
+	g.engine.stats.file(file)
+
+	return nil
+}
+
+// catGist writes the content of the gist file to w. The gist must have
+// a single file, or the URL must point to one.
+func (g *GitHub) catGist(ctx context.Context, w io.Writer, lines lineRange) error {
+	if len(g.gistFiles) != 1 {
+		return ErrNotFile
+	}
+
+	f := g.gistFiles[0]
+	if complete(f) {
+		return copyLines(w, strings.NewReader(f.GetContent()), lines)
+	}
+
+	raw, err := g.get(ctx, f.GetRawURL())
+	if err != nil {
+		return err
+	}
+	defer raw.Body.Close()
+
+	return copyLines(w, raw.Body, lines)
+}
+
+// complete reports whether the gist response holds the full content of
+// the file. The API truncates the content of large files.
+func complete(f github.GistFile) bool {
+	return f.Content != nil && len(f.GetContent()) == f.GetSize()
+}
+
+// matchGistFiles returns the gist files sorted by name. If anchor is set,
+// it returns only the file with the anchor, or with the name.
+func matchGistFiles(files map[github.GistFilename]github.GistFile, anchor string) []github.GistFile {
+	var matched []github.GistFile
+	for name, f := range files {
+		if f.Filename == nil {
+			f.Filename = github.Ptr(string(name))
+		}
+		if anchor == "" || anchor == gistAnchor(f.GetFilename()) || anchor == f.GetFilename() {
+			matched = append(matched, f)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].GetFilename() < matched[j].GetFilename()
+	})
+
+	return matched
+}
+
+// gistAnchor returns the anchor of the gist file on its web page, like
+// file-hello_world-go for hello_world.go.
+func gistAnchor(name string) string {
+	return gistAnchorPrefix + strings.Map(func(r rune) rune {
+		r = unicode.ToLower(r)
+		if r == '_' || r == '-' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '-'
+	}, name)
+}
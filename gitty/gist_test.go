@@ -0,0 +1,195 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGistAnchor(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		file     string
+		expected string
+	}{
+		{name: "extension", file: "hello_world.go", expected: "file-hello_world-go"},
+		{name: "upper case", file: "README.md", expected: "file-readme-md"},
+		{name: "spaces", file: "my notes.txt", expected: "file-my-notes-txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, gistAnchor(test.file))
+		})
+	}
+}
+
+func TestMatchGistFiles(t *testing.T) {
+	t.Parallel()
+	files := gistData().Files
+	tests := []struct {
+		name     string
+		anchor   string
+		expected []string
+	}{
+		{name: "all", anchor: "", expected: []string{"hello_world.go", "large.txt"}},
+		{name: "anchor", anchor: "file-large-txt", expected: []string{"large.txt"}},
+		{name: "name", anchor: "hello_world.go", expected: []string{"hello_world.go"}},
+		{name: "missing", anchor: "file-missing-go", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var names []string
+			for _, f := range matchGistFiles(files, test.anchor) {
+				names = append(names, f.GetFilename())
+			}
+			assert.Equal(t, test.expected, names)
+		})
+	}
+}
+
+func TestResolveGist(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		client        mockClient
+		url           string
+		expectedFiles int
+		expectedErr   error
+	}{
+		{
+			name:          "gist",
+			client:        &mockSuccess{},
+			url:           "https://gist.github.com/user/abc123",
+			expectedFiles: 2,
+		},
+		{
+			name:          "revision and file",
+			client:        &mockSuccess{},
+			url:           "https://gist.github.com/user/abc123/def456#file-large-txt",
+			expectedFiles: 1,
+		},
+		{
+			name:        "error file not found",
+			client:      &mockSuccess{},
+			url:         "https://gist.github.com/user/abc123#file-missing-go",
+			expectedErr: fmt.Errorf("%w: %s", ErrNotFound, "file-missing-go"),
+		},
+		{
+			name:        "error gist",
+			client:      &mockError{},
+			url:         "https://gist.github.com/user/abc123",
+			expectedErr: fmt.Errorf("failed to resolve gist: %w", errMockGist),
+		},
+		{
+			name:        "error revision",
+			client:      &mockError{},
+			url:         "https://gist.github.com/user/abc123/def456",
+			expectedErr: fmt.Errorf("failed to resolve gist: %w", errMockGist),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, engine: newEngine(0)}
+			err := g.extract(test.url)
+			require.NoError(t, err)
+
+			err = g.resolve(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			assert.Len(t, g.gistFiles, test.expectedFiles)
+			assert.Equal(t, "abc123", g.dest())
+		})
+	}
+}
+
+func TestDownloadGist(t *testing.T) {
+	t.Parallel()
+	fakeGist := fmt.Sprintf("%s%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeGist)
+		require.NoError(t, err)
+	})
+
+	g := fakeNew(fakeRepository(&mockSuccess{}))
+	res, err := g.Download(context.Background(), []string{"https://gist.github.com/user/" + fakeGist}, &Options{Log: io.Discard})
+	require.NoError(t, err)
+	require.Len(t, res.Files, 2)
+	assert.Equal(t, 1, res.Summary.APICalls)
+
+	// The truncated file is downloaded from its raw URL.
+	assert.Equal(t, filepath.Join(fakeGist, "hello_world.go"), res.Files[0].LocalPath)
+	assert.Equal(t, filepath.Join(fakeGist, "large.txt"), res.Files[1].LocalPath)
+	for _, f := range res.Files {
+		b, err := os.ReadFile(f.LocalPath)
+		require.NoError(t, err)
+		assert.Equal(t, "test data", string(b))
+	}
+
+	g = fakeNew(fakeRepository(&mockError{}))
+	_, err = g.Download(context.Background(), []string{"https://gist.github.com/user/" + fakeGist + "#file-large-txt"}, &Options{Log: io.Discard})
+	assert.Equal(t, fmt.Errorf("failed to resolve gist: %w", errMockGist), err)
+}
+
+func TestCatGist(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		client      mockClient
+		files       []github.GistFile
+		lines       lineRange
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "complete file",
+			client:   &mockError{},
+			files:    []github.GistFile{{Content: ptr("1\n2\n3"), Size: ptr(5)}},
+			lines:    lineRange{start: 2, end: 2},
+			expected: "2\n",
+		},
+		{
+			name:     "truncated file",
+			client:   &mockSuccess{},
+			files:    []github.GistFile{{Content: ptr("1"), Size: ptr(5), RawURL: ptr("https://gist.githubusercontent.com/raw/a.txt")}},
+			expected: "test data",
+		},
+		{
+			name:        "error raw file",
+			client:      &mockError{},
+			files:       []github.GistFile{{Size: ptr(5), RawURL: ptr("https://gist.githubusercontent.com/raw/a.txt")}},
+			expectedErr: errMockGet,
+		},
+		{
+			name:        "error several files",
+			client:      &mockSuccess{},
+			files:       []github.GistFile{{}, {}},
+			expectedErr: ErrNotFile,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			g := &GitHub{Client: test.client, gist: "abc123", gistFiles: test.files, engine: newEngine(0)}
+			err := g.cat(context.Background(), &buf, test.lines)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package gitty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// giteaBase is the base URL of codeberg.org.
+const giteaBase = "https://codeberg.org"
+
+// giteaAPI is the path of the REST API of a Gitea or Forgejo instance.
+const giteaAPI = "/api/v1/"
+
+// giteaTreePerPage represents the number of tree entries listed per
+// request, which is the default maximum of the Gitea API.
+const giteaTreePerPage = 1000
+
+// Token header of the Gitea API, whose value is the token after the prefix.
+const (
+	giteaTokenHeader = "Authorization"
+	giteaTokenPrefix = "token "
+)
+
+var ErrNoRef = errors.New("reference not found")
+
+// giteaProvider represents codeberg.org or a Gitea or Forgejo instance,
+// which is used through its REST API. Its API is close to the one of
+// GitHub: the repositories are named owner/repo, and their trees are
+// listed recursively, page by page. The trees are listed with the git trees
+// endpoint, which lists the whole tree in a few requests, rather than with
+// the contents endpoint, which lists one directory per request and is used
+// for single files only. Its releases and its commits listed by time serve
+// releases, --at and --since.
+type giteaProvider struct {
+	rest
+	name string
+}
+
+// Ensure giteaProvider implements the provider, the historian and the
+// releaser interfaces.
+var (
+	_ provider  = (*giteaProvider)(nil)
+	_ historian = (*giteaProvider)(nil)
+	_ releaser  = (*giteaProvider)(nil)
+)
+
+// giteaRelease represents a release of the Gitea API.
+type giteaRelease struct {
+	TagName string `json:"tag_name"`
+	Draft   bool   `json:"draft"`
+	Assets  []struct {
+		ID                 int64  `json:"id"`
+		Name               string `json:"name"`
+		BrowserDownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// newGitea creates the provider of the Gitea instance at the base URL,
+// authenticated with the token of the host, if any.
+func newGitea(name, base string) *giteaProvider {
+	return &giteaProvider{
+		rest: rest{
+			base:   base,
+			api:    giteaAPI,
+			header: giteaTokenHeader,
+			prefix: giteaTokenPrefix,
+			token:  token.ForGitea(name),
+			client: http.DefaultClient,
+		},
+		name: name,
+	}
+}
+
+func (p *giteaProvider) host() string {
+	return p.name
+}
+
+func (p *giteaProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	var r struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo), nil, &r)
+	if err != nil {
+		return "", resp, err
+	}
+	return r.DefaultBranch, resp, nil
+}
+
+// refs lists the references which start with the given name, like the
+// matching refs of GitHub. They are not paged. The API responds with
+// 404 Not Found if none matches.
+func (p *giteaProvider) refs(ctx context.Context, owner, repo, kind, name string, _ cursor) ([]string, *reply, error) {
+	endpoint := p.repo(owner, repo) + "/git/refs/" + kind
+	if name != "" {
+		endpoint += "/" + pathEscape(name)
+	}
+
+	var refs []struct {
+		Ref string `json:"ref"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, endpoint, nil, &refs)
+	if resp != nil && resp.status == http.StatusNotFound {
+		return nil, resp, nil
+	}
+	if err != nil {
+		return nil, resp, err
+	}
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, strings.TrimPrefix(ref.Ref, "refs/"+kind+"/"))
+	}
+
+	return names, resp, nil
+}
+
+// commitSHA lists the last commit of the reference, which may be
+// a branch, a tag, or a commit SHA.
+func (p *giteaProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	commit, resp, err := p.lastCommit(ctx, owner, repo, url.Values{"sha": {ref}})
+	if err != nil {
+		return "", resp, err
+	}
+	if commit == nil {
+		return "", resp, fmt.Errorf("%w: %s", ErrNoRef, ref)
+	}
+	return commit.SHA, resp, nil
+}
+
+// commitAt lists the last commit of the reference until the time. Servers
+// which don't filter the commits by time list a later commit, which is
+// not supported.
+func (p *giteaProvider) commitAt(ctx context.Context, owner, repo, ref, path string, t time.Time) (string, *reply, error) {
+	query := url.Values{"sha": {ref}, "until": {t.Format(time.RFC3339)}}
+	if path != "" {
+		query.Set("path", path)
+	}
+	commit, resp, err := p.lastCommit(ctx, owner, repo, query)
+	if err != nil {
+		return "", resp, err
+	}
+	if commit == nil {
+		return "", resp, fmt.Errorf("%w: %s", ErrNoCommit, t.Format(time.RFC3339))
+	}
+	if commit.Commit.Committer.Date.After(t) {
+		return "", resp, fmt.Errorf("%w: until on %s", ErrNotSupported, p.name)
+	}
+	return commit.SHA, resp, nil
+}
+
+// giteaCommit represents a commit listed by the Gitea API.
+type giteaCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+	} `json:"commit"`
+}
+
+// lastCommit lists the last commit with the query, without its stats and
+// files. It returns no commit if none matches.
+func (p *giteaProvider) lastCommit(ctx context.Context, owner, repo string, query url.Values) (*giteaCommit, *reply, error) {
+	query.Set("limit", "1")
+	query.Set("stat", "false")
+	query.Set("verification", "false")
+	query.Set("files", "false")
+
+	var commits []*giteaCommit
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/commits", query, &commits)
+	if err != nil || len(commits) == 0 {
+		return nil, resp, err
+	}
+	return commits[0], resp, nil
+}
+
+// tree lists a page of the recursive tree. The page is truncated if there
+// are more pages. The cursor is the page number.
+func (p *giteaProvider) tree(ctx context.Context, owner, repo, ref string, page cursor) (*fileTree, *reply, error) {
+	query := url.Values{
+		"recursive": {"true"},
+		"per_page":  {strconv.Itoa(giteaTreePerPage)},
+	}
+	if page != "" {
+		query.Set("page", string(page))
+	}
+
+	var t struct {
+		Entries []struct {
+			Path string `json:"path"`
+			Mode string `json:"mode"`
+			Type string `json:"type"`
+			SHA  string `json:"sha"`
+		} `json:"tree"`
+		Truncated bool `json:"truncated"`
+		Page      int  `json:"page"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.repo(owner, repo)+"/git/trees/"+url.PathEscape(ref), query, &t)
+	if err != nil {
+		return nil, resp, err
+	}
+	if t.Truncated {
+		resp.next = cursor(strconv.Itoa(max(t.Page, 1) + 1))
+	}
+
+	tree := &fileTree{}
+	for _, e := range t.Entries {
+		if err := localPath(e.Path); err != nil {
+			return nil, resp, err
+		}
+		tree.entries = append(tree.entries, &treeEntry{path: e.Path, mode: e.Mode, typ: e.Type, sha: e.SHA})
+	}
+
+	return tree, resp, nil
+}
+
+// file gets the contents of the path, which are a list if it is
+// a directory.
+func (p *giteaProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	var raw json.RawMessage
+	endpoint := p.repo(owner, repo) + "/contents/" + pathEscape(path)
+	resp, err := p.call(ctx, http.MethodGet, endpoint, url.Values{"ref": {ref}}, &raw)
+	if err != nil {
+		return "", resp, err
+	}
+
+	var content struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &content); err != nil || content.Type != "file" {
+		return "", resp, ErrNotFile
+	}
+	return p.rawURL(owner, repo, ref, path), resp, nil
+}
+
+// rawURL returns the URL of the raw endpoint of the API, which accepts
+// the token.
+func (p *giteaProvider) rawURL(owner, repo, ref, path string) string {
+	return fmt.Sprintf("%s%s%s/raw/%s?ref=%s", p.base, giteaAPI, p.repo(owner, repo), pathEscape(path), url.QueryEscape(ref))
+}
+
+func (p *giteaProvider) get(ctx context.Context, url string) (*http.Response, error) {
+	return p.raw(ctx, url)
+}
+
+// release gets the release by its tag, the latest release, or the newest
+// release which is not a draft.
+func (p *giteaProvider) release(ctx context.Context, owner, repo, tag string, prerelease bool) (*repoRelease, *reply, error) {
+	endpoint := p.repo(owner, repo) + "/releases"
+	var rel giteaRelease
+	var resp *reply
+	var err error
+	switch {
+	case tag != "":
+		resp, err = p.call(ctx, http.MethodGet, endpoint+"/tags/"+url.PathEscape(tag), nil, &rel)
+	case prerelease:
+		var rels []giteaRelease
+		query := url.Values{"limit": {strconv.Itoa(releasesPerPage)}}
+		resp, err = p.call(ctx, http.MethodGet, endpoint, query, &rels)
+		// Releases are listed newest first.
+		i := slices.IndexFunc(rels, func(r giteaRelease) bool { return !r.Draft })
+		if err == nil && i < 0 {
+			err = ErrNoRelease
+		}
+		if i >= 0 {
+			rel = rels[i]
+		}
+	default:
+		resp, err = p.call(ctx, http.MethodGet, endpoint+"/latest", nil, &rel)
+	}
+	if err != nil {
+		return nil, resp, err
+	}
+
+	r := &repoRelease{tag: rel.TagName}
+	for _, a := range rel.Assets {
+		r.assets = append(r.assets, &releaseAsset{id: a.ID, name: a.Name, url: a.BrowserDownloadURL})
+	}
+
+	return r, resp, nil
+}
+
+// asset downloads the asset from its download URL. The token is sent only
+// to the host itself.
+func (p *giteaProvider) asset(ctx context.Context, _, _ string, a *releaseAsset) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	var hr *http.Response
+	if strings.HasPrefix(a.url, p.base+"/") {
+		hr, err = p.do(req)
+	} else {
+		hr, err = p.client.Do(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if hr.StatusCode != http.StatusOK {
+		hr.Body.Close()
+		return nil, fmt.Errorf("%w: %s %s", ErrBadStatus, hr.Status, a.name)
+	}
+
+	return hr.Body, nil
+}
+
+// repo returns the endpoint of the repository.
+func (p *giteaProvider) repo(owner, repo string) string {
+	return "repos/" + url.PathEscape(owner) + "/" + url.PathEscape(repo)
+}
@@ -0,0 +1,350 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// giteaServer returns a stand-in of a Gitea instance, which serves the
+// owner/repo repository. Its main and feature/x branches and its v1.0.0
+// tag have the docs directory, whose tree is listed in two pages. Its
+// commits are dated 2024-01-01, and its v1.0.0 release has one asset.
+// The requests must have the token.
+func giteaServer(t *testing.T, tok string) *httptest.Server {
+	t.Helper()
+	shas := map[string]string{"main": strings.Repeat("c", 40), "feature/x": strings.Repeat("d", 40), "v1.0.0": strings.Repeat("e", 40)}
+	refs := []string{"refs/heads/main", "refs/heads/feature/x", "refs/tags/v1.0.0"}
+	repo := "/api/v1/repos/owner/repo"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+repo, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"default_branch":"main"}`)
+	})
+	mux.HandleFunc("GET "+repo+"/git/refs/{ref...}", func(w http.ResponseWriter, r *http.Request) {
+		var matched []string
+		for _, ref := range refs {
+			if strings.HasPrefix(ref, "refs/"+r.PathValue("ref")) {
+				matched = append(matched, fmt.Sprintf(`{"ref":%q}`, ref))
+			}
+		}
+		if len(matched) == 0 {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(matched, ","))
+	})
+	mux.HandleFunc("GET "+repo+"/commits", func(w http.ResponseWriter, r *http.Request) {
+		sha, ok := shas[r.URL.Query().Get("sha")]
+		if until := r.URL.Query().Get("until"); until != "" && until < "2024-01-01" {
+			ok = false
+		}
+		if !ok {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		fmt.Fprintf(w, `[{"sha":%q,"commit":{"committer":{"date":"2024-01-01T00:00:00Z"}}}]`, sha)
+	})
+	mux.HandleFunc("GET "+repo+"/git/trees/{sha}", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("recursive") != "true" {
+			http.Error(w, `{"message":"not recursive"}`, http.StatusBadRequest)
+			return
+		}
+		if r.PathValue("sha") == "hostile" {
+			fmt.Fprint(w, `{"tree":[{"path":"docs/../../x","type":"blob","mode":"100644","sha":"x1"}],"truncated":false,"page":1}`)
+			return
+		}
+		if page := r.URL.Query().Get("page"); page == "" || page == "1" {
+			// The tag has an older docs/a.md.
+			blob := "a1"
+			if sha := r.PathValue("sha"); sha == "v1.0.0" || sha == shas["v1.0.0"] {
+				blob = "a0"
+			}
+			fmt.Fprintf(w, `{"tree":[{"path":"docs","type":"tree","mode":"040000","sha":"t1"},{"path":"docs/a.md","type":"blob","mode":"100644","sha":%q}],"truncated":true,"page":1}`, blob)
+			return
+		}
+		fmt.Fprint(w, `{"tree":[{"path":"docs/link","type":"blob","mode":"120000","sha":"l1"},{"path":"README.md","type":"blob","mode":"100644","sha":"b2"}],"truncated":false,"page":2}`)
+	})
+	mux.HandleFunc("GET "+repo+"/contents/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("path") {
+		case "docs":
+			fmt.Fprint(w, `[{"type":"file","path":"docs/a.md"}]`)
+		case "docs/a.md":
+			fmt.Fprint(w, `{"type":"file","path":"docs/a.md"}`)
+		default:
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("GET "+repo+"/raw/{path...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("path") != "docs/a.md" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "# A at %s", r.URL.Query().Get("ref"))
+	})
+
+	release := `{"tag_name":"v1.0.0","draft":false,"assets":[{"id":7,"name":"tool.tar.gz","browser_download_url":"%s/owner/repo/releases/download/v1.0.0/tool.tar.gz"}]}`
+	mux.HandleFunc("GET "+repo+"/releases", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"tag_name":"v1.1.0-rc.1","draft":true},%s]`, fmt.Sprintf(release, "http://"+r.Host))
+	})
+	mux.HandleFunc("GET "+repo+"/releases/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, release, "http://"+r.Host)
+	})
+	mux.HandleFunc("GET "+repo+"/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("tag") != "v1.0.0" {
+			http.Error(w, `{"message":"Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, release, "http://"+r.Host)
+	})
+	mux.HandleFunc("GET /owner/repo/releases/download/v1.0.0/tool.tar.gz", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "tool")
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(giteaTokenHeader) != giteaTokenPrefix+tok {
+			http.Error(w, `{"message":"token is required"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGiteaProvider(t *testing.T) {
+	t.Parallel()
+	srv := giteaServer(t, "secret")
+	p := newGitea("git.example.com", srv.URL)
+	p.token = "secret"
+	ctx := context.Background()
+	assert.Equal(t, "git.example.com", p.host())
+
+	branch, _, err := p.defaultBranch(ctx, "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	names, _, err := p.refs(ctx, "owner", "repo", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, _, err = p.refs(ctx, "owner", "repo", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+
+	names, _, err = p.refs(ctx, "owner", "repo", "heads", "missing", "")
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	sha, _, err := p.commitSHA(ctx, "owner", "repo", "feature/x")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("d", 40), sha)
+
+	tree, resp, err := p.tree(ctx, "owner", "repo", sha, "")
+	require.NoError(t, err)
+	assert.Equal(t, cursor("2"), resp.next)
+	assert.False(t, tree.truncated)
+	require.Len(t, tree.entries, 2)
+	assert.Equal(t, "docs/a.md", tree.entries[1].path)
+	assert.Equal(t, "a1", tree.entries[1].sha)
+
+	tree, resp, err = p.tree(ctx, "owner", "repo", sha, "2")
+	require.NoError(t, err)
+	assert.Empty(t, resp.next)
+	assert.Equal(t, modeSymlink, tree.entries[0].mode)
+
+	url, _, err := p.file(ctx, "owner", "repo", sha, "docs/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/api/v1/repos/owner/repo/raw/docs/a.md?ref="+sha, url)
+	raw, err := p.get(ctx, url)
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+sha, string(body))
+
+	_, _, err = p.file(ctx, "owner", "repo", sha, "docs")
+	assert.Equal(t, ErrNotFile, err)
+
+	at := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	sha, _, err = p.commitAt(ctx, "owner", "repo", "main", "docs", at)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("c", 40), sha)
+
+	for _, opts := range []struct {
+		tag        string
+		prerelease bool
+	}{{}, {tag: "v1.0.0"}, {prerelease: true}} {
+		rel, _, err := p.release(ctx, "owner", "repo", opts.tag, opts.prerelease)
+		require.NoError(t, err)
+		assert.Equal(t, "v1.0.0", rel.tag)
+		require.Len(t, rel.assets, 1)
+		assert.Equal(t, &releaseAsset{id: 7, name: "tool.tar.gz", url: srv.URL + "/owner/repo/releases/download/v1.0.0/tool.tar.gz"}, rel.assets[0])
+	}
+
+	rel, _, err := p.release(ctx, "owner", "repo", "", false)
+	require.NoError(t, err)
+	asset, err := p.asset(ctx, "owner", "repo", rel.assets[0])
+	require.NoError(t, err)
+	defer asset.Close()
+	body, err = io.ReadAll(asset)
+	require.NoError(t, err)
+	assert.Equal(t, "tool", string(body))
+}
+
+func TestGiteaProviderError(t *testing.T) {
+	t.Parallel()
+	srv := giteaServer(t, "secret")
+	ctx := context.Background()
+
+	p := newGitea("git.example.com", srv.URL)
+	_, _, err := p.defaultBranch(ctx, "owner", "repo")
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized repos/owner/repo", ErrBadStatus), err)
+	_, _, err = p.refs(ctx, "owner", "repo", "heads", "main", "")
+	assert.ErrorIs(t, err, ErrBadStatus)
+
+	p.token = "secret"
+	_, _, err = p.commitSHA(ctx, "owner", "repo", "missing")
+	assert.Equal(t, fmt.Errorf("%w: missing", ErrNoRef), err)
+	_, _, err = p.file(ctx, "owner", "repo", "main", "missing.md")
+	assert.ErrorIs(t, err, ErrBadStatus)
+
+	_, _, err = p.commitAt(ctx, "owner", "repo", "main", "", time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, fmt.Errorf("%w: 2023-01-01T00:00:00Z", ErrNoCommit), err)
+	_, _, err = p.release(ctx, "owner", "repo", "v2.0.0", false)
+	assert.ErrorIs(t, err, ErrBadStatus)
+	// The tree entries can't be saved outside of the download directory.
+	_, _, err = p.tree(ctx, "owner", "repo", "hostile", "")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotLocalPath, "docs/../../x"), err)
+	_, err = p.asset(ctx, "owner", "repo", &releaseAsset{name: "missing", url: srv.URL + "/owner/repo/releases/download/v1.0.0/missing"})
+	assert.Equal(t, fmt.Errorf("%w: 404 Not Found missing", ErrBadStatus), err)
+
+	// The token isn't sent to other hosts, which reject the request here.
+	other := newGitea("other.example.com", "http://other.example.com")
+	other.client = srv.Client()
+	other.token = "secret"
+	_, err = other.asset(ctx, "owner", "repo", &releaseAsset{name: "tool.tar.gz", url: srv.URL + "/owner/repo/releases/download/v1.0.0/tool.tar.gz"})
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized tool.tar.gz", ErrBadStatus), err)
+
+	p.base = "http://[::1"
+	_, _, err = p.tree(ctx, "owner", "repo", "main", "")
+	assert.Error(t, err)
+	_, err = p.get(ctx, p.rawURL("owner", "repo", "main", "a.md"))
+	assert.Error(t, err)
+}
+
+// setGitea configures the stand-in as a Gitea host, and returns its name.
+func setGitea(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	name := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv(tokenVar("GITEA_TOKEN", name), "secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(giteaHostsKey, srv.URL)
+	return name
+}
+
+func TestDownloadGitea(t *testing.T) {
+	srv := giteaServer(t, "secret")
+	name := setGitea(t, srv)
+
+	tests := []struct {
+		name         string
+		url          string
+		ref          string
+		path         string
+		expectedSHA  string
+		expectedRef  string
+		expectedBlob string
+	}{
+		{name: "default branch", url: srv.URL + "/owner/repo", path: "docs", expectedSHA: strings.Repeat("c", 40), expectedRef: "main", expectedBlob: "a1"},
+		{name: "ref with slashes", url: name + "/owner/repo/src/branch/feature/x/docs", expectedSHA: strings.Repeat("d", 40), expectedRef: "feature/x", expectedBlob: "a1"},
+		{name: "version constraint", url: srv.URL + "/owner/repo", ref: "^1.0", path: "docs", expectedSHA: strings.Repeat("e", 40), expectedRef: "v1.0.0", expectedBlob: "a0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := New()
+			opts := &Options{Log: io.Discard, Ref: test.ref, Path: test.path, Archive: filepath.Join(t.TempDir(), "docs.zip")}
+
+			res, err := g.Download(context.Background(), []string{test.url}, opts)
+			require.NoError(t, err)
+			require.Len(t, res.Files, 1)
+			assert.Equal(t, "docs/a.md", res.Files[0].LocalPath)
+			assert.Equal(t, test.expectedBlob, res.Files[0].SHA)
+			assert.Equal(t, srv.URL+"/api/v1/repos/owner/repo/raw/docs/a.md?ref="+test.expectedSHA, res.Files[0].URL)
+			assert.Equal(t, []string{test.expectedRef}, res.Refs)
+			assert.Equal(t, []string{test.expectedSHA}, res.Commits)
+			assert.Equal(t, 1, res.Summary.Skipped.Symlinks)
+		})
+	}
+}
+
+func TestDownloadGiteaHistory(t *testing.T) {
+	srv := giteaServer(t, "secret")
+	setGitea(t, srv)
+
+	// Only docs/a.md differs between v1.0.0 and main.
+	opts := &Options{Log: io.Discard, Since: "v1.0.0", Archive: filepath.Join(t.TempDir(), "docs.zip")}
+	res, err := New().Download(context.Background(), []string{srv.URL + "/owner/repo/src/branch/main/docs"}, opts)
+	require.NoError(t, err)
+	require.Len(t, res.Files, 1)
+	assert.Equal(t, "docs/a.md", res.Files[0].LocalPath)
+	assert.Equal(t, "a1", res.Files[0].SHA)
+
+	opts = &Options{Log: io.Discard, At: "2024-01-02", Archive: filepath.Join(t.TempDir(), "docs.zip")}
+	res, err = New().Download(context.Background(), []string{srv.URL + "/owner/repo/src/branch/main/docs"}, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{strings.Repeat("c", 40)}, res.Commits)
+
+	opts = &Options{Log: io.Discard, At: "2023-01-01"}
+	_, err = New().Download(context.Background(), []string{srv.URL + "/owner/repo/src/branch/main/docs"}, opts)
+	assert.ErrorIs(t, err, ErrNoCommit)
+}
+
+func TestGiteaProviderUntil(t *testing.T) {
+	t.Parallel()
+	// The server ignores until, and lists a later commit.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintf(w, `[{"sha":%q,"commit":{"committer":{"date":"2024-06-01T00:00:00Z"}}}]`, strings.Repeat("c", 40))
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newGitea("git.example.com", srv.URL)
+	_, _, err := p.commitAt(context.Background(), "owner", "repo", "main", "", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, fmt.Errorf("%w: until on git.example.com", ErrNotSupported), err)
+}
+
+func TestReleaseGitea(t *testing.T) {
+	srv := giteaServer(t, "secret")
+	setGitea(t, srv)
+
+	archive := filepath.Join(t.TempDir(), "tool.zip")
+	res, err := New().Release(context.Background(), srv.URL+"/owner/repo", &Options{Log: io.Discard, Archive: archive})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, res.Refs)
+	require.Len(t, res.Files, 1)
+	assert.Equal(t, "tool.tar.gz", filepath.Base(res.Files[0].LocalPath))
+}
+
+func TestCatGitea(t *testing.T) {
+	srv := giteaServer(t, "secret")
+	setGitea(t, srv)
+
+	var buf bytes.Buffer
+	err := New().Cat(context.Background(), &buf, srv.URL+"/owner/repo/src/branch/main/docs/a.md", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+strings.Repeat("c", 40), buf.String())
+
+	err = New().Cat(context.Background(), io.Discard, srv.URL+"/owner/repo/src/branch/main/docs", nil)
+	assert.Equal(t, ErrNotFile, err)
+}
@@ -2,6 +2,8 @@ package gitty
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 
 	"github.com/google/go-github/v70/github"
@@ -15,6 +17,41 @@ type GitHub struct {
 	Repo   string
 	Ref    *github.RepositoryContentGetOptions
 	Path   string
+
+	// host is the host of the URL, or "" for the default host. The client
+	// of the host is picked from hosts, if set. provider is the provider
+	// of the host, or nil for the GitHub hosts, which use the client.
+	host     string
+	hosts    *hosts
+	provider provider
+
+	// refPath is the reference and the path of the URL, which are
+	// ambiguous if the reference contains slashes.
+	refPath string
+	// name is the reference which Ref was pinned from to its commit SHA.
+	name string
+	// pull is the number of the pull request, if the URL points to one.
+	// Its files are saved on the given side, and baseSHA is its base.
+	pull    int
+	side    string
+	baseSHA string
+	// since is the reference or the date to download the changes since.
+	since string
+	// gist is the ID of the gist, if the URL points to one. Its files are
+	// narrowed down to the file of the URL fragment, if any.
+	gist      string
+	file      string
+	gistFiles []github.GistFile
+	// assets are the release assets to download, if the run downloads
+	// a release. They are verified against sums in the verify mode.
+	assets []*releaseAsset
+	sums   map[string]string
+	verify string
+	// artifact is the workflow artifact to download, if the run downloads
+	// one.
+	artifact *github.Artifact
+	opts     *Options
+	engine   *engine
 }
 
 // service represents a GitHub client that interacts with the GitHub API.
@@ -37,9 +74,28 @@ func newClient() *github.Client {
 // [go-github]: https://github.com/google/go-github
 type Client interface {
 	Get(url string) (resp *http.Response, err error)
+	Do(req *http.Request) (*http.Response, error)
 	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (fileContent *github.RepositoryContent, directoryContent []*github.RepositoryContent, resp *github.Response, err error)
 	RateLimit(ctx context.Context) (*github.RateLimits, *github.Response, error)
 	GetUser(ctx context.Context, user string) (*github.User, *github.Response, error)
+	GetTree(ctx context.Context, owner string, repo string, sha string, recursive bool) (*github.Tree, *github.Response, error)
+	ListMatchingRefs(ctx context.Context, owner, repo string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error)
+	GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error)
+	GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	GetPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
+	ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error)
+	CompareCommits(ctx context.Context, owner, repo string, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error)
+	ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+	GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error)
+	GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error)
+	GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error)
+	GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, *github.Response, error)
+	ListReleases(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error)
+	DownloadReleaseAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error)
+	ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error)
+	ListWorkflowRunArtifacts(ctx context.Context, owner, repo string, runID int64, opts *github.ListOptions) (*github.ArtifactList, *github.Response, error)
+	ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error)
+	DownloadArtifact(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error)
 }
 
 // Ensure service implements the Client interface.
@@ -67,6 +123,13 @@ func (s *service) Get(url string) (resp *http.Response, err error) {
 	return s.client.Client().Get(url)
 }
 
+// Do sends the HTTP request with the HTTP client of the GitHub client, and
+// returns the response. It follows the redirects like Get, and stops when
+// the context of the request is done.
+func (s *service) Do(req *http.Request) (*http.Response, error) {
+	return s.client.Client().Do(req)
+}
+
 // GetContents can return either the metadata and content of a single file
 // (when path references a file) or the metadata of all the files and/or
 // subdirectories of a directory (when path references a directory). To make it
@@ -105,3 +168,197 @@ func (s *service) RateLimit(ctx context.Context) (*github.RateLimits, *github.Re
 func (s *service) GetUser(ctx context.Context, user string) (*github.User, *github.Response, error) {
 	return s.client.Users.Get(ctx, user)
 }
+
+// GetTree fetches the Tree object for a given sha hash from a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/git/trees#get-a-tree
+//
+//meta:operation GET /repos/{owner}/{repo}/git/trees/{tree_sha}
+func (s *service) GetTree(ctx context.Context, owner string, repo string, sha string, recursive bool) (*github.Tree, *github.Response, error) {
+	return s.client.Git.GetTree(ctx, owner, repo, sha, recursive)
+}
+
+// ListMatchingRefs lists references in a repository that match a supplied ref.
+// Use an empty ref to list all references.
+//
+// GitHub API docs: https://docs.github.com/rest/git/refs#list-matching-references
+//
+//meta:operation GET /repos/{owner}/{repo}/git/matching-refs/{ref}
+func (s *service) ListMatchingRefs(ctx context.Context, owner, repo string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error) {
+	return s.client.Git.ListMatchingRefs(ctx, owner, repo, opts)
+}
+
+// GetCommitSHA1 gets the SHA-1 of a commit reference. If a last-known SHA1 is
+// supplied and no new commits have occurred, a 304 Unmodified response is returned.
+//
+// GitHub API docs: https://docs.github.com/rest/commits/commits#get-a-commit
+//
+//meta:operation GET /repos/{owner}/{repo}/commits/{ref}
+func (s *service) GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error) {
+	return s.client.Repositories.GetCommitSHA1(ctx, owner, repo, ref, lastSHA)
+}
+
+// GetRepository fetches a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/repos/repos#get-a-repository
+//
+//meta:operation GET /repos/{owner}/{repo}
+func (s *service) GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error) {
+	return s.client.Repositories.Get(ctx, owner, repo)
+}
+
+// GetPullRequest gets a single pull request.
+//
+// GitHub API docs: https://docs.github.com/rest/pulls/pulls#get-a-pull-request
+//
+//meta:operation GET /repos/{owner}/{repo}/pulls/{pull_number}
+func (s *service) GetPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	return s.client.PullRequests.Get(ctx, owner, repo, number)
+}
+
+// ListPullRequestFiles lists the files in a pull request.
+//
+// GitHub API docs: https://docs.github.com/rest/pulls/pulls#list-pull-requests-files
+//
+//meta:operation GET /repos/{owner}/{repo}/pulls/{pull_number}/files
+func (s *service) ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return s.client.PullRequests.ListFiles(ctx, owner, repo, number, opts)
+}
+
+// CompareCommits compares a range of commits with each other.
+//
+// GitHub API docs: https://docs.github.com/rest/commits/commits#compare-two-commits
+//
+//meta:operation GET /repos/{owner}/{repo}/compare/{basehead}
+func (s *service) CompareCommits(ctx context.Context, owner, repo string, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	return s.client.Repositories.CompareCommits(ctx, owner, repo, base, head, opts)
+}
+
+// ListCommits lists the commits of a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/commits/commits#list-commits
+//
+//meta:operation GET /repos/{owner}/{repo}/commits
+func (s *service) ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return s.client.Repositories.ListCommits(ctx, owner, repo, opts)
+}
+
+// GetGist gets a single gist.
+//
+// GitHub API docs: https://docs.github.com/rest/gists/gists#get-a-gist
+//
+//meta:operation GET /gists/{gist_id}
+func (s *service) GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error) {
+	return s.client.Gists.Get(ctx, id)
+}
+
+// GetGistRevision gets a specific revision of a gist.
+//
+// GitHub API docs: https://docs.github.com/rest/gists/gists#get-a-gist-revision
+//
+//meta:operation GET /gists/{gist_id}/{sha}
+func (s *service) GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error) {
+	return s.client.Gists.GetRevision(ctx, id, sha)
+}
+
+// GetLatestRelease fetches the latest published release for the repository.
+//
+// GitHub API docs: https://docs.github.com/rest/releases/releases#get-the-latest-release
+//
+//meta:operation GET /repos/{owner}/{repo}/releases/latest
+func (s *service) GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error) {
+	return s.client.Repositories.GetLatestRelease(ctx, owner, repo)
+}
+
+// GetReleaseByTag fetches a release with the specified tag.
+//
+// GitHub API docs: https://docs.github.com/rest/releases/releases#get-a-release-by-tag-name
+//
+//meta:operation GET /repos/{owner}/{repo}/releases/tags/{tag}
+func (s *service) GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, *github.Response, error) {
+	return s.client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+}
+
+// ListReleases lists the releases for a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/releases/releases#list-releases
+//
+//meta:operation GET /repos/{owner}/{repo}/releases
+func (s *service) ListReleases(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error) {
+	return s.client.Repositories.ListReleases(ctx, owner, repo, opts)
+}
+
+// DownloadReleaseAsset downloads a release asset. The API serves the asset,
+// or redirects to a signed URL of it, which is downloaded without the token.
+//
+// GitHub API docs: https://docs.github.com/rest/releases/assets#get-a-release-asset
+//
+//meta:operation GET /repos/{owner}/{repo}/releases/assets/{asset_id}
+func (s *service) DownloadReleaseAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	rc, u, err := s.client.Repositories.DownloadReleaseAsset(ctx, owner, repo, id, nil)
+	if err != nil || rc != nil {
+		return rc, err
+	}
+	return getSigned(ctx, u)
+}
+
+// ListWorkflowRunsByFileName lists all workflow runs by workflow file name.
+//
+// GitHub API docs: https://docs.github.com/rest/actions/workflow-runs#list-workflow-runs-for-a-workflow
+//
+//meta:operation GET /repos/{owner}/{repo}/actions/workflows/{workflow_id}/runs
+func (s *service) ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	return s.client.Actions.ListWorkflowRunsByFileName(ctx, owner, repo, workflowFileName, opts)
+}
+
+// ListWorkflowRunArtifacts lists all artifacts that belong to a workflow run.
+//
+// GitHub API docs: https://docs.github.com/rest/actions/artifacts#list-workflow-run-artifacts
+//
+//meta:operation GET /repos/{owner}/{repo}/actions/runs/{run_id}/artifacts
+func (s *service) ListWorkflowRunArtifacts(ctx context.Context, owner, repo string, runID int64, opts *github.ListOptions) (*github.ArtifactList, *github.Response, error) {
+	return s.client.Actions.ListWorkflowRunArtifacts(ctx, owner, repo, runID, opts)
+}
+
+// ListArtifacts lists all artifacts that belong to a repository.
+//
+// GitHub API docs: https://docs.github.com/rest/actions/artifacts#list-artifacts-for-a-repository
+//
+//meta:operation GET /repos/{owner}/{repo}/actions/artifacts
+func (s *service) ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	return s.client.Actions.ListArtifacts(ctx, owner, repo, opts)
+}
+
+// DownloadArtifact downloads the zip archive of an artifact. The API
+// redirects to a signed URL of the archive, which is downloaded without
+// the token.
+//
+// GitHub API docs: https://docs.github.com/rest/actions/artifacts#download-an-artifact
+//
+//meta:operation GET /repos/{owner}/{repo}/actions/artifacts/{artifact_id}/{archive_format}
+func (s *service) DownloadArtifact(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error) {
+	u, _, err := s.client.Actions.DownloadArtifact(ctx, owner, repo, id, 1)
+	if err != nil {
+		return nil, err
+	}
+	return getSigned(ctx, u.String())
+}
+
+// getSigned downloads the signed URL which the API redirected to. It uses
+// a client without the token, which must not be sent to the storage host.
+func getSigned(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrBadStatus, resp.Status)
+	}
+
+	return resp.Body, nil
+}
@@ -3,8 +3,12 @@ package gitty
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"testing"
 
@@ -110,3 +114,179 @@ func TestGetUser(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode)
 }
+
+func TestListMatchingRefs(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	opts := &github.ReferenceListOptions{
+		Ref: "heads/main",
+	}
+	_, resp, err := s.ListMatchingRefs(context.Background(), "owner", "repo", opts)
+	// The mock body is an object, not a list of references.
+	var typeErr *json.UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetCommitSHA1(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetCommitSHA1(context.Background(), "owner", "repo", "main", "")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetRepository(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetRepository(context.Background(), "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetPullRequest(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetPullRequest(context.Background(), "owner", "repo", 1)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListPullRequestFiles(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListPullRequestFiles(context.Background(), "owner", "repo", 1, nil)
+	// The mock body is an object, not a list of files.
+	var typeErr *json.UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestCompareCommits(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.CompareCommits(context.Background(), "owner", "repo", "v1.0", "v1.1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListCommits(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListCommits(context.Background(), "owner", "repo", nil)
+	// The mock body is an object, not a list of commits.
+	var typeErr *json.UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetGist(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetGist(context.Background(), "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetGistRevision(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetGistRevision(context.Background(), "abc123", "def456")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetLatestRelease(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetLatestRelease(context.Background(), "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestGetReleaseByTag(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.GetReleaseByTag(context.Background(), "owner", "repo", "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListReleases(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListReleases(context.Background(), "owner", "repo", nil)
+	// The mock body is an object, not a list of releases.
+	var typeErr *json.UnmarshalTypeError
+	require.ErrorAs(t, err, &typeErr)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDownloadReleaseAsset(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	rc, err := s.DownloadReleaseAsset(context.Background(), "owner", "repo", 1)
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":"test"}`, string(b))
+}
+
+func TestDownloadReleaseAssetRedirect(t *testing.T) {
+	t.Parallel()
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The storage host must not receive the token.
+		assert.Empty(t, r.Header.Get("Authorization"))
+		fmt.Fprint(w, "asset")
+	}))
+	t.Cleanup(storage.Close)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		http.Redirect(w, r, storage.URL+"/asset?signature=abc", http.StatusFound)
+	}))
+	t.Cleanup(api.Close)
+
+	c := github.NewClient(nil).WithAuthToken("secret")
+	c.BaseURL, _ = url.Parse(api.URL + "/")
+	s := &service{client: c}
+
+	rc, err := s.DownloadReleaseAsset(context.Background(), "owner", "repo", 1)
+	require.NoError(t, err)
+	defer rc.Close()
+	b, err := io.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, "asset", string(b))
+}
+
+func TestListWorkflowRunsByFileName(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListWorkflowRunsByFileName(context.Background(), "owner", "repo", "build.yml", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListWorkflowRunArtifacts(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListWorkflowRunArtifacts(context.Background(), "owner", "repo", 1, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestListArtifacts(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	_, resp, err := s.ListArtifacts(context.Background(), "owner", "repo", nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestDownloadArtifact(t *testing.T) {
+	t.Parallel()
+	s := setup()
+	// The API redirects to the archive, and the mock does not.
+	_, err := s.DownloadArtifact(context.Background(), "owner", "repo", 1)
+	assert.ErrorContains(t, err, "unexpected status code")
+}
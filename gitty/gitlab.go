@@ -0,0 +1,171 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// gitlabBase is the base URL of gitlab.com.
+const gitlabBase = "https://gitlab.com"
+
+// gitlabAPI is the path of the REST API of a GitLab instance.
+const gitlabAPI = "/api/v4/"
+
+// gitlabPerPage represents the number of items listed per request, which
+// is the maximum of the GitLab API.
+const gitlabPerPage = 100
+
+// gitlabTokenHeader is the header of the tokens of the GitLab API.
+const gitlabTokenHeader = "PRIVATE-TOKEN"
+
+// gitlabProvider represents gitlab.com or a self-managed GitLab instance,
+// which is used through its REST API. Projects are named by their full
+// path: the owner is the group, with its subgroups, and the repository is
+// the project.
+type gitlabProvider struct {
+	rest
+	name string
+}
+
+// Ensure gitlabProvider implements the provider interface.
+var _ provider = (*gitlabProvider)(nil)
+
+// newGitLab creates the provider of the GitLab instance at the base URL,
+// authenticated with the token of the host, if any.
+func newGitLab(name, base string) *gitlabProvider {
+	return &gitlabProvider{
+		rest: rest{
+			base:   base,
+			api:    gitlabAPI,
+			header: gitlabTokenHeader,
+			token:  token.ForGitLab(name),
+			client: http.DefaultClient,
+		},
+		name: name,
+	}
+}
+
+func (p *gitlabProvider) host() string {
+	return p.name
+}
+
+func (p *gitlabProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	var project struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.project(owner, repo), nil, &project)
+	if err != nil {
+		return "", resp, err
+	}
+	return project.DefaultBranch, resp, nil
+}
+
+// refs lists the branches or the tags whose names start with the given
+// name. The search of GitLab is not case-sensitive, so the names are
+// matched again later.
+func (p *gitlabProvider) refs(ctx context.Context, owner, repo, kind, name string, page cursor) ([]string, *reply, error) {
+	endpoint := p.project(owner, repo) + "/repository/branches"
+	if kind == "tags" {
+		endpoint = p.project(owner, repo) + "/repository/tags"
+	}
+	query := pageQuery(page)
+	if name != "" {
+		query.Set("search", "^"+name)
+	}
+
+	var refs []struct {
+		Name string `json:"name"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, endpoint, query, &refs)
+	if err != nil {
+		return nil, resp, err
+	}
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, ref.Name)
+	}
+
+	return names, resp, nil
+}
+
+func (p *gitlabProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	var commit struct {
+		ID string `json:"id"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.project(owner, repo)+"/repository/commits/"+url.PathEscape(ref), nil, &commit)
+	if err != nil {
+		return "", resp, err
+	}
+	return commit.ID, resp, nil
+}
+
+// tree lists a page of the recursive repository tree. Its entries have
+// the same types and modes as the ones of GitHub.
+func (p *gitlabProvider) tree(ctx context.Context, owner, repo, ref string, page cursor) (*fileTree, *reply, error) {
+	query := pageQuery(page)
+	query.Set("ref", ref)
+	query.Set("recursive", "true")
+
+	var entries []struct {
+		ID   string `json:"id"`
+		Type string `json:"type"`
+		Path string `json:"path"`
+		Mode string `json:"mode"`
+	}
+	resp, err := p.call(ctx, http.MethodGet, p.project(owner, repo)+"/repository/tree", query, &entries)
+	if err != nil {
+		return nil, resp, err
+	}
+	tree := &fileTree{}
+	for _, e := range entries {
+		if err := localPath(e.Path); err != nil {
+			return nil, resp, err
+		}
+		tree.entries = append(tree.entries, &treeEntry{path: e.Path, mode: e.Mode, typ: e.Type, sha: e.ID})
+	}
+
+	return tree, resp, nil
+}
+
+// rawURL returns the URL of the raw file endpoint of the API, since the
+// raw web URLs don't accept tokens.
+func (p *gitlabProvider) rawURL(owner, repo, ref, path string) string {
+	return fmt.Sprintf("%s%s%s/repository/files/%s/raw?ref=%s",
+		p.base, gitlabAPI, p.project(owner, repo), url.PathEscape(path), url.QueryEscape(ref))
+}
+
+func (p *gitlabProvider) get(ctx context.Context, url string) (*http.Response, error) {
+	return p.raw(ctx, url)
+}
+
+// file checks that the path is a file with a HEAD request to the file
+// endpoint of the API, which doesn't return its content.
+func (p *gitlabProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	endpoint := p.project(owner, repo) + "/repository/files/" + url.PathEscape(path)
+	resp, err := p.call(ctx, http.MethodHead, endpoint, url.Values{"ref": {ref}}, nil)
+	if err != nil {
+		return "", resp, err
+	}
+	return p.rawURL(owner, repo, ref, path), resp, nil
+}
+
+// project returns the endpoint of the project, which is named by its
+// URL-encoded full path.
+func (p *gitlabProvider) project(owner, repo string) string {
+	return "projects/" + url.PathEscape(owner+"/"+repo)
+}
+
+// pageQuery returns the query of the page of a list, which is the first
+// page if it is empty. The cursor is the page number.
+func pageQuery(page cursor) url.Values {
+	query := url.Values{"per_page": {strconv.Itoa(gitlabPerPage)}}
+	if page != "" {
+		query.Set("page", string(page))
+	}
+	return query
+}
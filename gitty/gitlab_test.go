@@ -0,0 +1,241 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitlabServer returns a stand-in of a GitLab instance, which serves the
+// group/sub/project project. Its main and feature/x branches have the
+// docs directory, whose tree is listed in two pages. The requests must
+// have the token.
+func gitlabServer(t *testing.T, tok string) *httptest.Server {
+	t.Helper()
+	shas := map[string]string{"main": strings.Repeat("c", 40), "feature/x": strings.Repeat("d", 40)}
+	project := "/api/v4/projects/{id}"
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+project, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"default_branch":"main"}`)
+	})
+	mux.HandleFunc("GET "+project+"/repository/branches", func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for _, name := range []string{"main", "feature/x"} {
+			if strings.HasPrefix("^"+name, r.URL.Query().Get("search")) {
+				names = append(names, fmt.Sprintf(`{"name":%q}`, name))
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(names, ","))
+	})
+	mux.HandleFunc("GET "+project+"/repository/tags", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `[{"name":"v1.0.0"}]`)
+	})
+	mux.HandleFunc("GET "+project+"/repository/commits/{ref}", func(w http.ResponseWriter, r *http.Request) {
+		sha, ok := shas[r.PathValue("ref")]
+		if !ok {
+			http.Error(w, `{"message":"404 Commit Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q}`, sha)
+	})
+	mux.HandleFunc("GET "+project+"/repository/tree", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("recursive") != "true" {
+			http.Error(w, `{"message":"not recursive"}`, http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("ref") == "hostile" {
+			fmt.Fprint(w, `[{"id":"x1","type":"blob","path":"docs/../../x","mode":"100644"}]`)
+			return
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set(nextPageHeader, "2")
+			fmt.Fprint(w, `[{"id":"t1","type":"tree","path":"docs","mode":"040000"},{"id":"a1","type":"blob","path":"docs/a.md","mode":"100644"}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":"l1","type":"blob","path":"docs/link","mode":"120000"},{"id":"b2","type":"blob","path":"README.md","mode":"100644"}]`)
+	})
+	mux.HandleFunc("GET "+project+"/repository/files/{path}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("path") != "docs/a.md" {
+			http.Error(w, `{"message":"404 File Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"file_path":"docs/a.md"}`)
+	})
+	mux.HandleFunc("GET "+project+"/repository/files/{path}/raw", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("path") != "docs/a.md" {
+			http.Error(w, `{"message":"404 File Not Found"}`, http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "# A at %s", r.URL.Query().Get("ref"))
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(gitlabTokenHeader) != tok {
+			http.Error(w, `{"message":"401 Unauthorized"}`, http.StatusUnauthorized)
+			return
+		}
+		if !strings.Contains(r.URL.EscapedPath(), "/projects/group%2Fsub%2Fproject") {
+			http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set(limitHeader, "2000")
+		w.Header().Set(remainingHeader, "1999")
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGitLabProvider(t *testing.T) {
+	t.Parallel()
+	srv := gitlabServer(t, "secret")
+	p := newGitLab("gitlab.example.com", srv.URL)
+	p.token = "secret"
+	ctx := context.Background()
+	assert.Equal(t, "gitlab.example.com", p.host())
+
+	branch, resp, err := p.defaultBranch(ctx, "group/sub", "project")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+	assert.Equal(t, 2000, resp.limit)
+	assert.Equal(t, 1999, resp.remaining)
+
+	names, _, err := p.refs(ctx, "group/sub", "project", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, _, err = p.refs(ctx, "group/sub", "project", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+
+	sha, _, err := p.commitSHA(ctx, "group/sub", "project", "feature/x")
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("d", 40), sha)
+
+	tree, resp, err := p.tree(ctx, "group/sub", "project", sha, "")
+	require.NoError(t, err)
+	assert.Equal(t, cursor("2"), resp.next)
+	require.Len(t, tree.entries, 2)
+	assert.Equal(t, "docs/a.md", tree.entries[1].path)
+	assert.Equal(t, entryBlob, tree.entries[1].typ)
+	assert.Equal(t, "a1", tree.entries[1].sha)
+
+	tree, resp, err = p.tree(ctx, "group/sub", "project", sha, "2")
+	require.NoError(t, err)
+	assert.Empty(t, resp.next)
+	assert.Equal(t, modeSymlink, tree.entries[0].mode)
+
+	url, _, err := p.file(ctx, "group/sub", "project", sha, "docs/a.md")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/api/v4/projects/group%2Fsub%2Fproject/repository/files/docs%2Fa.md/raw?ref="+sha, url)
+	raw, err := p.get(ctx, url)
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+sha, string(body))
+}
+
+func TestGitLabProviderError(t *testing.T) {
+	t.Parallel()
+	srv := gitlabServer(t, "secret")
+	ctx := context.Background()
+
+	p := newGitLab("gitlab.example.com", srv.URL)
+	p.token = "wrong"
+	_, _, err := p.defaultBranch(ctx, "group/sub", "project")
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized projects/group%%2Fsub%%2Fproject", ErrBadStatus), err)
+
+	p.token = "secret"
+	_, _, err = p.defaultBranch(ctx, "group", "other")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.commitSHA(ctx, "group/sub", "project", "missing")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, _, err = p.file(ctx, "group/sub", "project", "main", "docs")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	// The tree entries can't be saved outside of the download directory.
+	_, _, err = p.tree(ctx, "group/sub", "project", "hostile", "")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotLocalPath, "docs/../../x"), err)
+
+	p.base = "http://[::1"
+	_, _, err = p.tree(ctx, "group/sub", "project", "main", "")
+	assert.Error(t, err)
+	_, err = p.get(ctx, p.rawURL("group/sub", "project", "main", "a.md"))
+	assert.Error(t, err)
+}
+
+func TestDownloadGitLab(t *testing.T) {
+	srv := gitlabServer(t, "secret")
+	name := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv(tokenVar("GITLAB_TOKEN", name), "secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitlabHostsKey, srv.URL)
+
+	tests := []struct {
+		name        string
+		url         string
+		path        string
+		expectedSHA string
+		expectedRef string
+	}{
+		{name: "default branch", url: srv.URL + "/group/sub/project", path: "docs", expectedSHA: strings.Repeat("c", 40), expectedRef: "main"},
+		{name: "ref with slashes", url: name + "/group/sub/project/-/tree/feature/x/docs", expectedSHA: strings.Repeat("d", 40), expectedRef: "feature/x"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := New()
+			opts := &Options{Log: io.Discard, Path: test.path, Archive: filepath.Join(t.TempDir(), "docs.zip")}
+
+			res, err := g.Download(context.Background(), []string{test.url}, opts)
+			require.NoError(t, err)
+			require.Len(t, res.Files, 1)
+			assert.Equal(t, "docs/a.md", res.Files[0].LocalPath)
+			assert.Equal(t, "a1", res.Files[0].SHA)
+			assert.Equal(t, srv.URL+"/api/v4/projects/group%2Fsub%2Fproject/repository/files/docs%2Fa.md/raw?ref="+test.expectedSHA, res.Files[0].URL)
+			assert.Equal(t, []string{test.expectedRef}, res.Refs)
+			assert.Equal(t, []string{test.expectedSHA}, res.Commits)
+			assert.Equal(t, 1, res.Summary.Skipped.Symlinks)
+			assert.Equal(t, 1999, res.Summary.RateLimitRemaining)
+		})
+	}
+}
+
+func TestCatGitLab(t *testing.T) {
+	srv := gitlabServer(t, "secret")
+	t.Setenv(tokenVar("GITLAB_TOKEN", strings.TrimPrefix(srv.URL, "http://")), "secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitlabHostsKey, srv.URL)
+
+	var buf bytes.Buffer
+	err := New().Cat(context.Background(), &buf, srv.URL+"/group/sub/project/-/blob/main/docs/a.md", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# A at "+strings.Repeat("c", 40), buf.String())
+}
+
+func TestGitLabNotSupported(t *testing.T) {
+	srv := gitlabServer(t, "secret")
+	t.Setenv(tokenVar("GITLAB_TOKEN", strings.TrimPrefix(srv.URL, "http://")), "secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitlabHostsKey, srv.URL)
+	host := strings.TrimPrefix(srv.URL, "http://")
+	url := srv.URL + "/group/sub/project"
+
+	_, err := New().Release(context.Background(), url, &Options{Log: io.Discard})
+	assert.Equal(t, fmt.Errorf("%w: releases on %s", ErrNotSupported, host), err)
+
+	_, err = New().Download(context.Background(), []string{url}, &Options{Log: io.Discard, At: "2024-01-01"})
+	assert.Equal(t, fmt.Errorf("%w: at on %s", ErrNotSupported, host), err)
+}
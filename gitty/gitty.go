@@ -3,6 +3,9 @@ package gitty
 import (
 	"context"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -11,51 +14,395 @@ type Git struct {
 	repo Repository
 }
 
+// Options represents the download options.
+type Options struct {
+	// Log receives the progress messages. Defaults to os.Stdout.
+	Log io.Writer
+	// Ref overrides the reference of the URL, if set. Like the reference of
+	// a URL, it may be a version constraint, like ^1.4, ~2.0.3 or
+	// latest-stable, which picks the highest tag that satisfies it.
+	Ref string
+	// Path overrides the path of the URL, if set. It is useful with
+	// clone URLs, which have no path.
+	Path string
+	// Base also downloads the base versions of the files changed by a pull
+	// request, next to their head versions.
+	Base bool
+	// Merge downloads the files changed by a pull request at its merge ref,
+	// refs/pull/N/merge, instead of its head.
+	Merge bool
+	// At downloads the path as it was at the given time, like
+	// 2024-01-31T00:00:00Z or 2024-01-31. The reference is resolved to its
+	// last commit at or before the time which changed the path.
+	At string
+	// Since downloads only the files added or modified since the given ref,
+	// or date like 2006-01-02, and reports the deleted files.
+	Since string
+	// Prune removes the local files which were deleted since the given ref.
+	Prune bool
+	// Asset selects the release assets whose names match the glob, like
+	// *linux_amd64*.tar.gz. All assets are downloaded if it is empty.
+	Asset string
+	// Prerelease picks the newest release, which may be a prerelease,
+	// instead of the latest release. Ref picks the release by its tag. It
+	// also lets the version constraints, except latest-stable, pick
+	// prerelease tags.
+	Prerelease bool
+	// Verify sets how the release assets are verified against the
+	// checksum files of the release: auto verifies the assets which have
+	// checksums, required also fails if an asset has none, and off skips
+	// the verification. Defaults to auto.
+	Verify string
+	// PublicKey verifies the signatures of the checksum files, if set, and
+	// makes the verification required unless it is off. It is a minisign
+	// public key, or a PEM encoded ECDSA or Ed25519 key which signs like
+	// cosign.
+	PublicKey string
+	// BinDir is the directory where the executables are installed. Defaults
+	// to ~/.local/bin.
+	BinDir string
+	// StateFile records the installs. Defaults to gitty/installed.json in
+	// the user config directory.
+	StateFile string
+	// Run picks the artifact from the workflow run with the given ID.
+	Run int64
+	// Workflow picks the artifact from the newest successful run of the
+	// workflow file, like build.yml.
+	Workflow string
+	// Branch narrows the workflow runs down to the branch.
+	Branch string
+	// Artifact picks the artifact by its name. The newest artifact is
+	// picked if it is empty.
+	Artifact string
+	// Include keeps only the files which match any of the globs, by their
+	// path in the repository or in the artifact. A glob without a slash
+	// matches the base name of the file, otherwise its whole path.
+	Include []string
+	// Exclude skips the files which match any of the globs, like Include.
+	Exclude []string
+	// Concurrency limits the number of concurrent requests of a run.
+	// Defaults to 16.
+	Concurrency int
+	// Archive writes the downloads into the named zip, tar.gz or tar.zst
+	// archive instead of the disk. "-" writes the archive to Stdout.
+	Archive string
+	// ArchiveFormat overrides the archive format detected from its name.
+	ArchiveFormat string
+	// Stdout receives the archive if Archive is "-". Defaults to os.Stdout.
+	Stdout io.Writer
+}
+
+// log returns the writer for progress messages.
+func (o *Options) log() io.Writer {
+	if o == nil || o.Log == nil {
+		return os.Stdout
+	}
+	return o.Log
+}
+
+// concurrency returns the concurrency limit.
+func (o *Options) concurrency() int {
+	if o == nil {
+		return 0
+	}
+	return o.Concurrency
+}
+
+// stdout returns the writer for the archive on stdout.
+func (o *Options) stdout() io.Writer {
+	if o == nil || o.Stdout == nil {
+		return os.Stdout
+	}
+	return o.Stdout
+}
+
 // Gitty defines methods for interacting with cmd.
 type Gitty interface {
-	Status(ctx context.Context) error
-	Auth(ctx context.Context) error
-	Download(ctx context.Context, url string) error
+	Status(ctx context.Context) (*StatusResult, error)
+	Auth(ctx context.Context) (*AuthResult, error)
+	Download(ctx context.Context, urls []string, opts *Options) (*DownloadResult, error)
+	Cat(ctx context.Context, w io.Writer, url string, opts *Options) error
+	Release(ctx context.Context, spec string, opts *Options) (*DownloadResult, error)
+	Artifact(ctx context.Context, spec string, opts *Options) (*DownloadResult, error)
+	Install(ctx context.Context, spec string, opts *Options) (*Install, error)
+	Installed(opts *Options) (*InstalledResult, error)
+	Upgrade(ctx context.Context, opts *Options) (*UpgradeResult, error)
 }
 
 // Ensure Git implements the Gitty interface.
 var _ Gitty = (*Git)(nil)
 
-// New creates a new Gitty.
+// New creates a new Gitty. The GitHub Enterprise Server hosts are
+// configured by the GH_HOST and GH_ENTERPRISE_HOSTS environment variables,
+// the self-managed GitLab instances by GITLAB_HOSTS, the Gitea and Forgejo
+// instances by GITEA_HOSTS, the Bitbucket Server instances by
+// BITBUCKET_HOSTS, and the plain git servers by GIT_HOSTS.
 func New() Gitty {
-	client := newClient()
-	r := repository(client)
+	r := repository(newHosts())
 	return &Git{
 		repo: r,
 	}
 }
 
 // Status reports the status of the client.
-func (g *Git) Status(ctx context.Context) error {
+func (g *Git) Status(ctx context.Context) (*StatusResult, error) {
 	return g.repo.status(ctx)
 }
 
-// Auth reports the authenticated username.
-func (g *Git) Auth(ctx context.Context) error {
+// Auth reports the authenticated user.
+func (g *Git) Auth(ctx context.Context) (*AuthResult, error) {
 	return g.repo.auth(ctx)
 }
 
-// Download downloads the contents from the given URL. It extracts the URL,
-// collects the contents, and downloads files concurrently.
-func (g *Git) Download(ctx context.Context, url string) error {
-	fmt.Println("Downloading:", url)
+// Download downloads the contents from the given URLs. It extracts the URLs,
+// resolves their references, collects the contents, and downloads files
+// concurrently. The downloads share the concurrency limit, the rate limit
+// budget, and the tree listings.
+// The result summarizes the run, and it is returned even if a download fails.
+func (g *Git) Download(ctx context.Context, urls []string, opts *Options) (*DownloadResult, error) {
+	if err := g.repo.configure(opts); err != nil {
+		return nil, err
+	}
+
+	repos := make([]Repository, 0, len(urls))
+	dests := make([]string, 0, len(urls))
+	for _, url := range urls {
+		r := g.repo.fork()
+		if err := r.extract(url); err != nil {
+			return nil, err
+		}
+		if err := r.resolve(ctx); err != nil {
+			return nil, err
+		}
+		repos = append(repos, r)
+		dests = append(dests, r.dest())
+	}
+
+	// Overlapping downloads would overwrite each other.
+	if err := overlaps(dests); err != nil {
+		return nil, err
+	}
+
 	start := time.Now()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	if err := g.repo.extract(url); err != nil {
+	refs := make([]string, 0, len(repos))
+	commits := make([]string, 0, len(repos))
+	errCh := make(chan error, len(repos))
+	for i, r := range repos {
+		refs = append(refs, r.refName())
+		commits = append(commits, r.ref())
+		fmt.Fprintf(opts.log(), "Downloading: %s (ref: %s, commit: %s)\n", urls[i], r.refName(), r.ref())
+		go func() {
+			errCh <- r.download(ctx)
+		}()
+	}
+
+	// The first error cancels the other downloads.
+	var err error
+	for range repos {
+		if errDownload := <-errCh; errDownload != nil && err == nil {
+			err = errDownload
+			cancel()
+		}
+	}
+
+	res := g.repo.result()
+	res.URLs = urls
+	res.Refs = refs
+	res.Commits = commits
+	if errClose := g.repo.close(res.Files, err == nil); err == nil {
+		err = errClose
+	}
+	res.summarize(time.Since(start))
+
+	return res, err
+}
+
+// Cat writes the raw content of the file at the given URL to w. A line
+// range like #L10-L20 in the URL limits the output to those lines.
+func (g *Git) Cat(ctx context.Context, w io.Writer, url string, opts *Options) error {
+	if err := g.repo.configure(opts); err != nil {
 		return err
 	}
 
-	if err := g.repo.download(ctx); err != nil {
+	url, lines, err := cutLines(url)
+	if err != nil {
 		return err
 	}
 
-	fmt.Println("Download Completed")
-	fmt.Println(time.Since(start))
+	if err := g.repo.extract(url); err != nil {
+		return err
+	}
+	if err := g.repo.resolve(ctx); err != nil {
+		return err
+	}
+
+	return g.repo.cat(ctx, w, lines)
+}
+
+// Release downloads the assets of a release of the repository, like
+// owner/repo. It picks the latest release, the newest one if
+// opts.Prerelease is set, or the one tagged opts.Ref. Only the assets
+// matching opts.Asset are downloaded, if it is set. The result is returned
+// even if a download fails.
+func (g *Git) Release(ctx context.Context, spec string, opts *Options) (*DownloadResult, error) {
+	if err := g.repo.configure(opts); err != nil {
+		return nil, err
+	}
+	if err := g.repo.extract(spec); err != nil {
+		return nil, err
+	}
+	if err := g.repo.resolveRelease(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	fmt.Fprintf(opts.log(), "Downloading: %s (tag: %s)\n", spec, g.repo.ref())
+	err := g.repo.download(ctx)
+
+	res := g.repo.result()
+	res.URLs = []string{spec}
+	res.Refs = []string{g.repo.ref()}
+	if errClose := g.repo.close(res.Files, err == nil); err == nil {
+		err = errClose
+	}
+	res.summarize(time.Since(start))
+
+	return res, err
+}
+
+// Artifact downloads the newest GitHub Actions artifact of the repository,
+// like owner/repo, and extracts its files. The artifact is picked from
+// opts.Run, the newest successful runs of opts.Workflow, or the whole
+// repository, by opts.Artifact and opts.Branch. Its files are filtered by
+// opts.Include and opts.Exclude.
+func (g *Git) Artifact(ctx context.Context, spec string, opts *Options) (*DownloadResult, error) {
+	if err := g.repo.configure(opts); err != nil {
+		return nil, err
+	}
+	if err := g.repo.extract(spec); err != nil {
+		return nil, err
+	}
+	if err := g.repo.resolveArtifact(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	fmt.Fprintf(opts.log(), "Downloading: %s (commit: %s)\n", spec, g.repo.ref())
+	err := g.repo.download(ctx)
+
+	res := g.repo.result()
+	res.URLs = []string{spec}
+	res.Refs = []string{g.repo.ref()}
+	if errClose := g.repo.close(res.Files, err == nil); err == nil {
+		err = errClose
+	}
+	res.summarize(time.Since(start))
+
+	return res, err
+}
+
+// Install installs the executable of a release of the repository, like
+// owner/repo or owner/repo@v1.2.3, for the current platform. It picks the
+// release like Release, and the asset which names the platform, or matches
+// opts.Asset if it is set. The install is recorded in opts.StateFile.
+func (g *Git) Install(ctx context.Context, spec string, opts *Options) (*Install, error) {
+	name, err := opts.stateFile()
+	if err != nil {
+		return nil, err
+	}
+	s, err := readState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	inst, err := g.install(ctx, spec, opts)
+	if err != nil {
+		return nil, err
+	}
+	s.record(inst)
+
+	return inst, writeState(name, s)
+}
+
+// Installed lists the installs recorded in opts.StateFile.
+func (g *Git) Installed(opts *Options) (*InstalledResult, error) {
+	name, err := opts.stateFile()
+	if err != nil {
+		return nil, err
+	}
+	s, err := readState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &InstalledResult{Installs: s.Installs}, nil
+}
+
+// Upgrade upgrades the installs recorded in opts.StateFile to the latest
+// releases. The executables are replaced in place, and the installs which
+// are already at the latest release are left as is.
+func (g *Git) Upgrade(ctx context.Context, opts *Options) (*UpgradeResult, error) {
+	name, err := opts.stateFile()
+	if err != nil {
+		return nil, err
+	}
+	s, err := readState(name)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &UpgradeResult{}
+	installs := append([]*Install{}, s.Installs...)
+	for _, prev := range installs {
+		o := Options{}
+		if opts != nil {
+			o = *opts
+		}
+		o.Ref, o.Prerelease = "", false
+		o.Asset = prev.Pattern
+		o.BinDir = filepath.Dir(prev.Path)
+		if err := g.repo.configure(&o); err != nil {
+			return res, err
+		}
+		if err := g.repo.extract(prev.spec()); err != nil {
+			return res, err
+		}
+		if err := g.repo.resolveRelease(ctx); err != nil {
+			return res, err
+		}
+		if g.repo.ref() == prev.Tag {
+			res.UpToDate = append(res.UpToDate, prev)
+			continue
+		}
+
+		inst, err := g.repo.install(ctx)
+		if err != nil {
+			return res, err
+		}
+		s.record(inst)
+		if err := writeState(name, s); err != nil {
+			return res, err
+		}
+		res.Upgraded = append(res.Upgraded, &Upgrade{From: prev.Tag, Install: inst})
+	}
+
+	return res, nil
+}
+
+// install installs the executable of the release of the repository.
+func (g *Git) install(ctx context.Context, spec string, opts *Options) (*Install, error) {
+	if err := g.repo.configure(opts); err != nil {
+		return nil, err
+	}
+	if err := g.repo.extract(spec); err != nil {
+		return nil, err
+	}
+	if err := g.repo.resolveRelease(ctx); err != nil {
+		return nil, err
+	}
 
-	return nil
+	return g.repo.install(ctx)
 }
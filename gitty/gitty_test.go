@@ -1,12 +1,18 @@
 package gitty
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"testing"
 
 	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -44,60 +50,118 @@ func TestStatus(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			g := fakeNew(test.repo)
-			err := g.Status(context.Background())
+			_, err := g.Status(context.Background())
 			assert.Equal(t, test.expected, err)
 		})
 	}
 }
 
 func TestDownload(t *testing.T) {
-	t.Parallel()
+	chdir(t)
 
 	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
 	fakeFirstPath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
 	fakeSecondPath := fmt.Sprintf("%s/%s_%d.txt", fakeBase, gofakeit.LoremIpsumWord(), gofakeit.Int())
-	t.Cleanup(func() {
-		err := os.RemoveAll(fakeBase)
-		require.NoError(t, err)
-	})
+	fakeTreeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	fakeOtherBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	fakeRepo := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
 	ctxfakePath := func() context.Context {
 		return context.WithValue(context.Background(), pathKey, contentsData(fakeFirstPath, fakeSecondPath))
 	}
+	ctxfakeTree := func() context.Context {
+		entries := append(
+			treeData(fakeTreeBase, fakeTreeBase+"/a.txt", fakeTreeBase+"/b.txt"),
+			&github.TreeEntry{Type: ptr(entryBlob), Path: ptr(fakeOtherBase + "/c.txt")},
+		)
+		return context.WithValue(context.Background(), treeKey, entries)
+	}
 
 	tests := []struct {
-		name     string
-		repo     Repository
-		ctx      context.Context
-		url      string
-		expected error
+		name          string
+		repo          Repository
+		ctx           context.Context
+		urls          []string
+		expectedFiles int
+		expectedCalls int
+		expected      error
 	}{
 		{
-			name:     "success download",
-			repo:     fakeRepository(&mockSuccess{}),
-			ctx:      ctxfakePath(),
-			url:      "https://github.com/owner/repo/tree/branch/directory",
-			expected: nil,
+			name:          "success download",
+			repo:          fakeRepository(&mockSuccess{}),
+			ctx:           ctxfakePath(),
+			urls:          []string{"https://github.com/owner/repo/tree/branch/directory"},
+			expectedFiles: 4,
+			expectedCalls: 6,
+			expected:      nil,
+		},
+		{
+			name: "success download multiple urls from the same tree",
+			repo: fakeRepository(&mockSuccess{}),
+			ctx:  ctxfakeTree(),
+			urls: []string{
+				"https://github.com/owner/repo/tree/branch/" + fakeTreeBase,
+				"https://github.com/owner/repo/blob/branch/" + fakeOtherBase + "/c.txt",
+			},
+			expectedFiles: 3,
+			expectedCalls: 4,
+			expected:      nil,
+		},
+		{
+			name:          "success download repository",
+			repo:          fakeRepository(&mockSuccess{}),
+			ctx:           ctxfakeTree(),
+			urls:          []string{"https://github.com/owner/" + fakeRepo},
+			expectedFiles: 4,
+			expectedCalls: 3,
+			expected:      nil,
 		},
 		{
 			name:     "error extract",
 			repo:     fakeRepository(&mockSuccess{}),
 			ctx:      context.Background(),
-			url:      gofakeit.URL(),
-			expected: ErrNotValidURL,
+			urls:     []string{"https://git.example.com/owner/repo"},
+			expected: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
 		},
 		{
-			name:     "error content",
-			repo:     fakeRepository(&mockError{}),
-			ctx:      context.Background(),
-			url:      "https://github.com/owner/repo/tree/branch/directory",
-			expected: fmt.Errorf("failed to download: %w", errMockContents),
+			name: "error overlap",
+			repo: fakeRepository(&mockSuccess{}),
+			ctx:  context.Background(),
+			urls: []string{
+				"https://github.com/owner/repo/tree/branch/docs",
+				"https://github.com/other/repo/tree/branch/dir/docs",
+			},
+			expected: fmt.Errorf("%w: %s and %s", ErrOverlap, "docs", "docs"),
 		},
 		{
-			name:     "error download file",
+			name:     "error resolve",
 			repo:     fakeRepository(&mockError{}),
 			ctx:      context.Background(),
-			url:      "https://github.com/owner/repo/tree/branch/" + testDownloadFail,
-			expected: fmt.Errorf("failed to download: %w", ErrInvalidPathURL),
+			urls:     []string{"https://github.com/owner/repo/tree/" + testRefsFail + "/directory"},
+			expected: fmt.Errorf("failed to resolve ref: %w", errMockRefs),
+		},
+		{
+			name:          "error tree",
+			repo:          fakeRepository(&mockError{}),
+			ctx:           context.Background(),
+			urls:          []string{"https://github.com/owner/repo/tree/" + testTreeFail + "/directory"},
+			expectedCalls: 4,
+			expected:      fmt.Errorf("failed to download: %w", errMockTree),
+		},
+		{
+			name:          "error content",
+			repo:          fakeRepository(&mockError{}),
+			ctx:           context.Background(),
+			urls:          []string{"https://github.com/owner/repo/tree/branch/directory"},
+			expectedCalls: 5,
+			expected:      fmt.Errorf("failed to download: %w", errMockContents),
+		},
+		{
+			name:          "error download file",
+			repo:          fakeRepository(&mockError{}),
+			ctx:           context.Background(),
+			urls:          []string{"https://github.com/owner/repo/tree/branch/" + testDownloadFail},
+			expectedCalls: 5,
+			expected:      fmt.Errorf("failed to download: %w", ErrInvalidPathURL),
 		},
 	}
 
@@ -105,12 +169,41 @@ func TestDownload(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			g := fakeNew(test.repo)
-			err := g.Download(test.ctx, test.url)
+			res, err := g.Download(test.ctx, test.urls, &Options{Log: io.Discard})
 			assert.Equal(t, test.expected, err)
+			if errors.Is(err, ErrNotValidURL) || errors.Is(err, ErrOverlap) || errors.Is(err, errMockRefs) {
+				assert.Nil(t, res)
+				return
+			}
+			// The result is reported even if the download fails.
+			require.NotNil(t, res.Summary)
+			assert.Equal(t, test.urls, res.URLs)
+			assert.Equal(t, test.expectedFiles, res.TotalFiles)
+			assert.Equal(t, test.expectedCalls, res.Summary.APICalls)
+			// Each reference is recorded with the commit it was pinned to.
+			require.Len(t, res.Commits, len(res.Refs))
+			for i, ref := range res.Refs {
+				assert.Equal(t, commitData(ref), res.Commits[i])
+			}
+			assert.True(t, sort.SliceIsSorted(res.Files, func(i, j int) bool {
+				return res.Files[i].RemotePath < res.Files[j].RemotePath
+			}))
 		})
 	}
 }
 
+func TestOptionsLog(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	var nilOpts *Options
+
+	assert.Equal(t, os.Stdout, nilOpts.log())
+	assert.Equal(t, os.Stdout, (&Options{}).log())
+	assert.Equal(t, &buf, (&Options{Log: &buf}).log())
+	assert.Equal(t, 0, nilOpts.concurrency())
+	assert.Equal(t, 4, (&Options{Concurrency: 4}).concurrency())
+}
+
 func TestAuth(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -134,8 +227,91 @@ func TestAuth(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			g := fakeNew(test.repo)
-			err := g.Auth(context.Background())
+			_, err := g.Auth(context.Background())
 			assert.Equal(t, test.expected, err)
 		})
 	}
 }
+
+func TestCat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		repo        Repository
+		url         string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "success cat",
+			repo:     fakeRepository(&mockSuccess{}),
+			url:      "https://github.com/owner/repo/blob/branch/" + testFileOnly + "#L1",
+			expected: "test data",
+		},
+		{
+			name:     "success cat spec",
+			repo:     fakeRepository(&mockSuccess{}),
+			url:      "owner/repo/" + testFileOnly + "#branch",
+			expected: "test data",
+		},
+		{
+			name:        "error lines",
+			repo:        fakeRepository(&mockSuccess{}),
+			url:         "https://github.com/owner/repo/blob/branch/" + testFileOnly + "#L",
+			expectedErr: ErrNotValidLines,
+		},
+		{
+			name:        "error extract",
+			repo:        fakeRepository(&mockSuccess{}),
+			url:         "https://git.example.com/owner/repo",
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
+		},
+		{
+			name:        "error resolve",
+			repo:        fakeRepository(&mockError{}),
+			url:         "https://github.com/owner/repo/blob/" + testRefsFail + "/file.go",
+			expectedErr: fmt.Errorf("failed to resolve ref: %w", errMockRefs),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			g := fakeNew(test.repo)
+			err := g.Cat(context.Background(), &buf, test.url, &Options{Log: io.Discard})
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestDownloadArchive(t *testing.T) {
+	t.Parallel()
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	ctx := context.WithValue(context.Background(), treeKey, treeData(fakeBase, fakeBase+"/a.txt", fakeBase+"/b.txt"))
+	url := "https://github.com/owner/repo/tree/branch/" + fakeBase
+
+	var buf bytes.Buffer
+	g := fakeNew(fakeRepository(&mockSuccess{}))
+	res, err := g.Download(ctx, []string{url}, &Options{Log: io.Discard, Archive: "-", Stdout: &buf})
+	require.NoError(t, err)
+	assert.Equal(t, 2, res.TotalFiles)
+
+	// Nothing is saved on the disk.
+	_, err = os.Stat(fakeBase)
+	assert.True(t, os.IsNotExist(err))
+
+	gr, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	entries := readTar(t, gr)
+	require.Len(t, entries, 2)
+	assert.Equal(t, fakeBase+"/a.txt", entries[0].name)
+	assert.Equal(t, "test data", entries[0].content)
+
+	_, err = g.Download(ctx, []string{url}, &Options{Archive: "out.rar"})
+	assert.Equal(t, ErrNotValidArchive, err)
+
+	err = g.Cat(ctx, io.Discard, url, &Options{Archive: "out.rar"})
+	assert.Equal(t, ErrNotValidArchive, err)
+}
@@ -1,67 +1,183 @@
 package gitty
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
-const (
-	hPrefix = "https://github.com/"
-	prefix  = "github.com/"
-)
+const rawPrefix = "https://raw.githubusercontent.com/"
 
 var (
-	ErrNotValidURL    = errors.New("url must starts with https://github.com/ or github.com/")
-	ErrNotValidFormat = errors.New("url format must be https://github.com/owner/repo/tree/branch/directory")
+	ErrNotValidLines = errors.New("line range must be #L10 or #L10-L20")
+	ErrNotLocalPath  = errors.New("path outside the repository")
 )
 
-// getGitHubRepo parses and extracts the repository path from a GitHub URL.
-func getGitHubRepo(url string) (string, error) {
-	prefixes := []string{hPrefix, prefix}
-	for _, pref := range prefixes {
-		if path, ok := strings.CutPrefix(url, pref); ok {
-			return validate(path)
+// lineRange represents a range of lines, starting from 1. The zero value
+// represents all lines.
+type lineRange struct {
+	start int
+	end   int
+}
+
+// cutLines cuts the fragment from the URL if it is a line range like #L10
+// or #L10-L20. Other fragments, like the ref of a compact spec, are kept.
+func cutLines(s string) (string, lineRange, error) {
+	url, fragment, found := strings.Cut(s, "#")
+	if !found || !strings.HasPrefix(fragment, "L") {
+		return s, lineRange{}, nil
+	}
+
+	first, last, isRange := strings.Cut(fragment, "-")
+	start, err := strconv.Atoi(strings.TrimPrefix(first, "L"))
+	if err != nil || start < 1 {
+		return "", lineRange{}, ErrNotValidLines
+	}
+	if !isRange {
+		return url, lineRange{start: start, end: start}, nil
+	}
+
+	end, err := strconv.Atoi(strings.TrimPrefix(last, "L"))
+	if err != nil || end < start {
+		return "", lineRange{}, ErrNotValidLines
+	}
+
+	return url, lineRange{start: start, end: end}, nil
+}
+
+// copyLines copies the given range of lines from src to dst. It copies
+// everything if the range is the zero value.
+func copyLines(dst io.Writer, src io.Reader, lines lineRange) error {
+	if lines == (lineRange{}) {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+
+	r := bufio.NewReader(src)
+	for n := 1; n <= lines.end; n++ {
+		line, err := r.ReadString('\n')
+		if n >= lines.start {
+			if _, errWrite := io.WriteString(dst, line); errWrite != nil {
+				return errWrite
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rawURL returns the raw download URL of the file at the given reference,
+// under the prefix of the raw URLs of the host.
+func rawURL(prefix, owner, repo, ref, path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return prefix + owner + "/" + repo + "/" + ref + "/" + strings.Join(segments, "/")
+}
+
+// isSHA reports whether s is a full commit SHA.
+func isSHA(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// longestRef splits s into the longest of the references that s starts
+// with, and the path after it. A reference must end at a path segment.
+// It returns an empty reference if none of them match.
+func longestRef(s string, refs []string) (string, string) {
+	var ref string
+	for _, r := range refs {
+		if (s == r || strings.HasPrefix(s, r+"/")) && len(r) > len(ref) {
+			ref = r
 		}
 	}
-	return "", ErrNotValidURL
+	if ref == "" {
+		return "", ""
+	}
+	return ref, strings.TrimPrefix(s[len(ref):], "/")
 }
 
-// validate checks if the URL has a valid format.
-func validate(s string) (string, error) {
-	// Valid format example is: https://github.com/owner/repo/tree/branch/directory
-	// After the domain, the expected format is: owner/repo/tree/branch/directory
-	if strings.Count(s, "/") < 4 {
-		return "", ErrNotValidFormat
+// matchEntries returns the tree entries at or under the given path.
+// An empty path matches every entry.
+func matchEntries(entries []*treeEntry, path string) []*treeEntry {
+	var matched []*treeEntry
+	for _, entry := range entries {
+		if under(entry.path, path) {
+			matched = append(matched, entry)
+		}
 	}
-	return s, nil
+	return matched
 }
 
-// saveFile saves the content of the file at the specified path.
-func saveFile(base, path string, body io.Reader) error {
+// under reports whether p is at or under the given path. An empty path
+// contains every path.
+func under(p, path string) bool {
+	return path == "" || p == path || strings.HasPrefix(p, path+"/")
+}
+
+// saveFile saves the content of the file at the specified path. It returns
+// the local path and the number of bytes written.
+func saveFile(base, path string, body io.Reader) (string, int64, error) {
 	p, err := exactPath(base, path)
 	if err != nil {
-		return err
+		return "", 0, err
 	}
-	fmt.Println("Saving:", p)
 
-	if errMkdir := os.MkdirAll(filepath.Dir(p), os.ModePerm); errMkdir != nil {
-		return errMkdir
+	n, err := writeFile(p, body)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return p, n, nil
+}
+
+// writeFile writes the body to the named file, creating its directories.
+func writeFile(name string, body io.Reader) (int64, error) {
+	if errMkdir := os.MkdirAll(filepath.Dir(name), os.ModePerm); errMkdir != nil {
+		return 0, errMkdir
 	}
 
-	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	defer f.Close()
 
-	if _, err := io.Copy(f, body); err != nil {
-		return err
-	}
+	return io.Copy(f, body)
+}
 
+// localPath returns ErrNotLocalPath unless the path of a tree entry, which
+// the host lists, is a clean path under the repository root. Other paths,
+// like docs/../../x, would be saved outside of the download directory.
+func localPath(p string) error {
+	for _, segment := range strings.Split(p, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("%w: %q", ErrNotLocalPath, p)
+		}
+	}
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return fmt.Errorf("%w: %q", ErrNotLocalPath, p)
+	}
 	return nil
 }
 
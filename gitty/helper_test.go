@@ -18,118 +18,6 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetGitHubRepo(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name        string
-		url         string
-		expected    string
-		expectedErr error
-	}{
-		{
-			name:        "valid url with https://github.com/",
-			url:         "https://github.com/owner/repo/tree/branch/directory",
-			expected:    "owner/repo/tree/branch/directory",
-			expectedErr: nil,
-		},
-		{
-			name:        "valid url with github.com/",
-			url:         "github.com/owner/repo/tree/branch/directory1/directory2",
-			expected:    "owner/repo/tree/branch/directory1/directory2",
-			expectedErr: nil,
-		},
-		{
-			name:        "valid file url with github.com/",
-			url:         "github.com/owner/repo/tree/branch/directory1/directory2/file.txt",
-			expected:    "owner/repo/tree/branch/directory1/directory2/file.txt",
-			expectedErr: nil,
-		},
-		{
-			name:        "invalid https url",
-			url:         "https://gitlab.com/owner/repo/tree/branch/directory",
-			expected:    "",
-			expectedErr: ErrNotValidURL,
-		},
-		{
-			name:        "invalid url",
-			url:         "gitlab.com/owner/repo/tree/branch/directory",
-			expected:    "",
-			expectedErr: ErrNotValidURL,
-		},
-		{
-			name:        "invalid https url format",
-			url:         "https://github.com/owner/repo",
-			expected:    "",
-			expectedErr: ErrNotValidFormat,
-		},
-		{
-			name:        "invalid url format",
-			url:         "github.com/owner/repo",
-			expected:    "",
-			expectedErr: ErrNotValidFormat,
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
-			repo, err := getGitHubRepo(test.url)
-			assert.Equal(t, test.expected, repo)
-			assert.Equal(t, test.expectedErr, err)
-		})
-	}
-}
-
-func TestValidate(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name        string
-		input       string
-		expected    string
-		expectedErr error
-	}{
-		{
-			name:        "valid format 1",
-			input:       "owner/repo/tree/branch/directory",
-			expected:    "owner/repo/tree/branch/directory",
-			expectedErr: nil,
-		},
-		{
-			name:        "valid format 2",
-			input:       "owner/repo/tree/branch/directory1/directory2",
-			expected:    "owner/repo/tree/branch/directory1/directory2",
-			expectedErr: nil,
-		},
-		{
-			name:        "valid format 3",
-			input:       "owner/repo/tree/branch/directory1/directory2/file.txt",
-			expected:    "owner/repo/tree/branch/directory1/directory2/file.txt",
-			expectedErr: nil,
-		},
-		{
-			name:        "invalid format 1",
-			input:       "owner/repo/directory",
-			expected:    "",
-			expectedErr: ErrNotValidFormat,
-		},
-		{
-			name:        "invalid format 2",
-			input:       "owner/repo.git",
-			expected:    "",
-			expectedErr: ErrNotValidFormat,
-		},
-	}
-
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			t.Parallel()
-			path, err := validate(test.input)
-			assert.Equal(t, test.expected, path)
-			assert.Equal(t, test.expectedErr, err)
-		})
-	}
-}
-
 type errReader int
 
 var errMockReadAll = errors.New("mock readall body error")
@@ -209,8 +97,12 @@ func TestSaveFile(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := saveFile(test.base, test.path, test.body)
+			p, n, err := saveFile(test.base, test.path, test.body)
 			assert.Equal(t, test.expected, err)
+			if err == nil {
+				assert.Equal(t, filepath.Join(filepath.Base(test.base), filepath.Base(test.path)), p)
+				assert.Equal(t, int64(len("test data")), n)
+			}
 		})
 	}
 }
@@ -263,3 +155,256 @@ func TestExactPath(t *testing.T) {
 		})
 	}
 }
+
+func TestCutLines(t *testing.T) {
+	t.Parallel()
+	url := "github.com/owner/repo/blob/main/file.go"
+	tests := []struct {
+		name        string
+		url         string
+		expectedURL string
+		expected    lineRange
+		expectedErr error
+	}{
+		{
+			name:        "without fragment",
+			url:         url,
+			expectedURL: url,
+			expected:    lineRange{},
+		},
+		{
+			name:        "other fragment",
+			url:         url + "#readme",
+			expectedURL: url + "#readme",
+			expected:    lineRange{},
+		},
+		{
+			name:        "single line",
+			url:         url + "#L10",
+			expectedURL: url,
+			expected:    lineRange{start: 10, end: 10},
+		},
+		{
+			name:        "line range",
+			url:         url + "#L10-L20",
+			expectedURL: url,
+			expected:    lineRange{start: 10, end: 20},
+		},
+		{
+			name:        "invalid start",
+			url:         url + "#Lx",
+			expectedErr: ErrNotValidLines,
+		},
+		{
+			name:        "zero start",
+			url:         url + "#L0",
+			expectedErr: ErrNotValidLines,
+		},
+		{
+			name:        "invalid end",
+			url:         url + "#L10-Lx",
+			expectedErr: ErrNotValidLines,
+		},
+		{
+			name:        "end before start",
+			url:         url + "#L20-L10",
+			expectedErr: ErrNotValidLines,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			u, lines, err := cutLines(test.url)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expectedURL, u)
+			assert.Equal(t, test.expected, lines)
+		})
+	}
+}
+
+type errWriter int
+
+var errMockWrite = errors.New("mock write error")
+
+func (errWriter) Write(_ []byte) (n int, err error) {
+	return 0, errMockWrite
+}
+
+func TestCopyLines(t *testing.T) {
+	t.Parallel()
+	src := "1\n2\n3\n4\n5"
+	tests := []struct {
+		name        string
+		src         io.Reader
+		dst         io.Writer
+		lines       lineRange
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "all lines",
+			src:      strings.NewReader(src),
+			lines:    lineRange{},
+			expected: src,
+		},
+		{
+			name:     "single line",
+			src:      strings.NewReader(src),
+			lines:    lineRange{start: 2, end: 2},
+			expected: "2\n",
+		},
+		{
+			name:     "line range",
+			src:      strings.NewReader(src),
+			lines:    lineRange{start: 2, end: 4},
+			expected: "2\n3\n4\n",
+		},
+		{
+			name:     "line range past the end",
+			src:      strings.NewReader(src),
+			lines:    lineRange{start: 4, end: 10},
+			expected: "4\n5",
+		},
+		{
+			name:        "error reading",
+			src:         errReader(0),
+			lines:       lineRange{start: 1, end: 2},
+			expected:    "",
+			expectedErr: errMockReadAll,
+		},
+		{
+			name:        "error writing",
+			src:         strings.NewReader(src),
+			dst:         errWriter(0),
+			lines:       lineRange{start: 1, end: 2},
+			expectedErr: errMockWrite,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			var buf bytes.Buffer
+			dst := test.dst
+			if dst == nil {
+				dst = &buf
+			}
+			err := copyLines(dst, test.src, test.lines)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
+func TestRawURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "plain path",
+			path:     "dir/file.txt",
+			expected: "https://raw.githubusercontent.com/owner/repo/main/dir/file.txt",
+		},
+		{
+			name:     "escaped path",
+			path:     "my dir/file #1.txt",
+			expected: "https://raw.githubusercontent.com/owner/repo/main/my%20dir/file%20%231.txt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, rawURL(rawPrefix, "owner", "repo", "main", test.path))
+		})
+	}
+}
+
+func TestIsSHA(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		s        string
+		expected bool
+	}{
+		{name: "full sha", s: "0123456789abcdef0123456789abcdef01234567", expected: true},
+		{name: "short sha", s: "0123456", expected: false},
+		{name: "upper case", s: "0123456789ABCDEF0123456789ABCDEF01234567", expected: false},
+		{name: "branch", s: "main", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, isSHA(test.s))
+		})
+	}
+}
+
+func TestLongestRef(t *testing.T) {
+	t.Parallel()
+	refs := []string{"feature", "feature/new-api", "feature/new"}
+
+	tests := []struct {
+		name         string
+		s            string
+		expectedRef  string
+		expectedPath string
+	}{
+		{name: "longest", s: "feature/new-api/docs", expectedRef: "feature/new-api", expectedPath: "docs"},
+		{name: "exact", s: "feature/new-api", expectedRef: "feature/new-api", expectedPath: ""},
+		{name: "segment", s: "feature/new-apis/docs", expectedRef: "feature", expectedPath: "new-apis/docs"},
+		{name: "missing", s: "main/docs", expectedRef: "", expectedPath: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			ref, path := longestRef(test.s, refs)
+			assert.Equal(t, test.expectedRef, ref)
+			assert.Equal(t, test.expectedPath, path)
+		})
+	}
+}
+
+func TestLocalPath(t *testing.T) {
+	t.Parallel()
+	for _, p := range []string{"a.md", "docs/a.md", "docs/.github/b..md"} {
+		require.NoError(t, localPath(p), p)
+	}
+	for _, p := range []string{"", ".", "..", "docs/../../x", "docs/../a.md", "/etc/passwd", "docs//a.md", "docs/", "./a.md"} {
+		assert.Equal(t, fmt.Errorf("%w: %q", ErrNotLocalPath, p), localPath(p), p)
+	}
+}
+
+func TestMatchEntries(t *testing.T) {
+	t.Parallel()
+	entries := []*treeEntry{
+		{path: "docs"},
+		{path: "docs/a.md"},
+		{path: "docs2/b.md"},
+		{path: "main.go"},
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected []*treeEntry
+	}{
+		{name: "all", path: "", expected: entries},
+		{name: "directory", path: "docs", expected: entries[:2]},
+		{name: "file", path: "main.go", expected: entries[3:]},
+		{name: "missing", path: "doc", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, matchEntries(entries, test.path))
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// Environment variables which configure the hosts.
+const (
+	// hostKey names the default host, which serves the compact specs and
+	// the requests without a URL, like GH_HOST of the GitHub CLI. Defaults
+	// to github.com.
+	hostKey = "GH_HOST"
+	// hostsKey lists more GitHub Enterprise Server hosts, separated by
+	// commas.
+	hostsKey = "GH_ENTERPRISE_HOSTS"
+	// gitlabHostsKey lists the self-managed GitLab instances, separated by
+	// commas. gitlab.com is always known.
+	gitlabHostsKey = "GITLAB_HOSTS"
+	// giteaHostsKey lists the Gitea and Forgejo instances, separated by
+	// commas. codeberg.org is always known.
+	giteaHostsKey = "GITEA_HOSTS"
+	// bitbucketHostsKey lists the Bitbucket Server instances, separated by
+	// commas. bitbucket.org is always known.
+	bitbucketHostsKey = "BITBUCKET_HOSTS"
+	// gitHostsKey lists the plain git servers, which are used through the
+	// git protocol over smart HTTP, separated by commas.
+	gitHostsKey = "GIT_HOSTS"
+)
+
+// Paths of the endpoints of a GitHub Enterprise Server.
+const (
+	enterpriseAPI    = "/api/v3/"
+	enterpriseUpload = "/api/uploads/"
+	enterpriseRaw    = "/raw/"
+)
+
+var ErrNotValidHost = errors.New("host must be a name like ghe.example.com, or a base url like https://ghe.example.com")
+
+// enterprise represents a GitHub Enterprise Server host.
+type enterprise struct {
+	// base is the base URL of the host, like https://ghe.example.com.
+	base   string
+	client Client
+}
+
+// hosts represents the known hosts: github.com, the GitHub Enterprise
+// Server hosts keyed by their names, like ghe.example.com, the GitLab
+// instances, like gitlab.com, the Gitea instances, like codeberg.org, the
+// Bitbucket hosts, like bitbucket.org, and the plain git servers.
+type hosts struct {
+	// def is the name of the default host.
+	def        string
+	github     Client
+	enterprise map[string]*enterprise
+	gitlab     map[string]*gitlabProvider
+	gitea      map[string]*giteaProvider
+	bitbucket  map[string]provider
+	git        map[string]*smartProvider
+	// err is the error of the configuration, if any. It is returned by
+	// the requests, since New can't return it.
+	err error
+}
+
+// newHosts creates the clients of github.com and of the GitHub Enterprise
+// Server hosts configured by GH_HOST and GH_ENTERPRISE_HOSTS, and the
+// providers of gitlab.com, codeberg.org, bitbucket.org, and of the GitLab,
+// Gitea and Bitbucket Server instances and the git servers configured by
+// GITLAB_HOSTS, GITEA_HOSTS, BITBUCKET_HOSTS and GIT_HOSTS. Each host is
+// authenticated with its own token, if any.
+func newHosts() *hosts {
+	h := &hosts{
+		def:        hostGitHub,
+		github:     &service{client: newClient()},
+		enterprise: make(map[string]*enterprise),
+		gitlab:     map[string]*gitlabProvider{hostGitLab: newGitLab(hostGitLab, gitlabBase)},
+		gitea:      map[string]*giteaProvider{hostCodeberg: newGitea(hostCodeberg, giteaBase)},
+		bitbucket:  map[string]provider{hostBitbucket: newBitbucket(hostBitbucket, bitbucketBase)},
+		git:        make(map[string]*smartProvider),
+	}
+
+	if def := strings.TrimSpace(os.Getenv(hostKey)); def != "" {
+		name, err := h.add(def)
+		if err != nil {
+			h.err = err
+			return h
+		}
+		h.def = name
+	}
+	h.err = addEach(os.Getenv(hostsKey), func(name, base string) error {
+		_, err := h.addEnterprise(name, base)
+		return err
+	})
+	if h.err == nil {
+		h.err = addEach(os.Getenv(gitlabHostsKey), func(name, base string) error {
+			h.gitlab[name] = newGitLab(name, base)
+			return nil
+		})
+	}
+	if h.err == nil {
+		h.err = addEach(os.Getenv(giteaHostsKey), func(name, base string) error {
+			h.gitea[name] = newGitea(name, base)
+			return nil
+		})
+	}
+	if h.err == nil {
+		h.err = addEach(os.Getenv(bitbucketHostsKey), func(name, base string) error {
+			if name != hostBitbucket {
+				h.bitbucket[name] = newServer(name, base)
+			}
+			return nil
+		})
+	}
+	if h.err == nil {
+		h.err = addEach(os.Getenv(gitHostsKey), func(name, base string) error {
+			h.git[name] = newSmart(name, base)
+			return nil
+		})
+	}
+
+	return h
+}
+
+// addEach parses the hosts of the list, separated by commas, and adds
+// them by their names and base URLs.
+func addEach(list string, add func(name, base string) error) error {
+	for _, s := range strings.Split(list, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		name, base, err := parseHost(s)
+		if err != nil {
+			return err
+		}
+		if err := add(name, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the GitHub Enterprise Server host, given by its name or base
+// URL, and returns its name.
+func (h *hosts) add(s string) (string, error) {
+	name, base, err := parseHost(s)
+	if err != nil {
+		return "", err
+	}
+	return h.addEnterprise(name, base)
+}
+
+// addEnterprise adds the GitHub Enterprise Server host, and returns its
+// name. github.com is always known.
+func (h *hosts) addEnterprise(name, base string) (string, error) {
+	if name == hostGitHub || name == hostWWW {
+		return hostGitHub, nil
+	}
+
+	c, err := newEnterpriseClient(base, token.ForHost(name))
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrNotValidHost, base)
+	}
+	h.enterprise[name] = &enterprise{base: base, client: &service{client: c}}
+
+	return name, nil
+}
+
+// parseHost parses the name and the base URL of a host, given by its name,
+// like ghe.example.com, or its base URL. The scheme defaults to https.
+func parseHost(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") || strings.Trim(u.Path, "/") != "" {
+		return "", "", fmt.Errorf("%w: %q", ErrNotValidHost, s)
+	}
+	name := strings.ToLower(u.Host)
+
+	return name, u.Scheme + "://" + name, nil
+}
+
+// names returns the names of the GitHub Enterprise Server hosts.
+func (h *hosts) names() []string {
+	if h == nil {
+		return nil
+	}
+	names := make([]string, 0, len(h.enterprise))
+	for name := range h.enterprise {
+		names = append(names, name)
+	}
+	return names
+}
+
+// known returns the names of the GitHub Enterprise Server hosts, of the
+// GitLab, Gitea and Bitbucket instances, and of the git servers.
+func (h *hosts) known() []string {
+	return slices.Concat(h.names(), h.gitlabNames(), h.giteaNames(), h.bitbucketNames(), h.gitNames())
+}
+
+// gitlabNames returns the names of the GitLab instances. gitlab.com is always
+// known.
+func (h *hosts) gitlabNames() []string {
+	if h == nil {
+		return []string{hostGitLab}
+	}
+	names := make([]string, 0, len(h.gitlab))
+	for name := range h.gitlab {
+		names = append(names, name)
+	}
+	return names
+}
+
+// giteaNames returns the names of the Gitea instances. codeberg.org is always
+// known.
+func (h *hosts) giteaNames() []string {
+	if h == nil {
+		return []string{hostCodeberg}
+	}
+	names := make([]string, 0, len(h.gitea))
+	for name := range h.gitea {
+		names = append(names, name)
+	}
+	return names
+}
+
+// bitbucketNames returns the names of the Bitbucket hosts. bitbucket.org is
+// always known.
+func (h *hosts) bitbucketNames() []string {
+	if h == nil {
+		return []string{hostBitbucket}
+	}
+	names := make([]string, 0, len(h.bitbucket))
+	for name := range h.bitbucket {
+		names = append(names, name)
+	}
+	return names
+}
+
+// gitNames returns the names of the git servers.
+func (h *hosts) gitNames() []string {
+	if h == nil {
+		return nil
+	}
+	names := make([]string, 0, len(h.git))
+	for name := range h.git {
+		names = append(names, name)
+	}
+	return names
+}
+
+// provider returns the provider of the host, or nil for the GitHub hosts,
+// which use their client.
+func (h *hosts) provider(name string) provider {
+	if h == nil {
+		switch name {
+		case hostGitLab:
+			return newGitLab(hostGitLab, gitlabBase)
+		case hostCodeberg:
+			return newGitea(hostCodeberg, giteaBase)
+		case hostBitbucket:
+			return newBitbucket(hostBitbucket, bitbucketBase)
+		}
+		return nil
+	}
+	if p, ok := h.gitlab[name]; ok {
+		return p
+	}
+	if p, ok := h.gitea[name]; ok {
+		return p
+	}
+	if p, ok := h.bitbucket[name]; ok {
+		return p
+	}
+	if p, ok := h.git[name]; ok {
+		return p
+	}
+	return nil
+}
+
+// client returns the client of the host. The default host is used if the
+// name is empty.
+func (h *hosts) client(name string) Client {
+	if e, ok := h.enterprise[h.name(name)]; ok {
+		return e.client
+	}
+	return h.github
+}
+
+// raw returns the prefix of the raw download URLs of the host.
+func (h *hosts) raw(name string) string {
+	if e, ok := h.enterprise[h.name(name)]; ok {
+		return e.base + enterpriseRaw
+	}
+	return rawPrefix
+}
+
+// token returns the token of the host.
+func (h *hosts) token(name string) string {
+	return token.ForHost(h.name(name))
+}
+
+// name returns the name of the host, or of the default host if it is
+// empty.
+func (h *hosts) name(name string) string {
+	if name == "" {
+		return h.def
+	}
+	return name
+}
+
+// newEnterpriseClient creates a new client of the GitHub Enterprise Server
+// at the base URL, authenticated with the token if it is set.
+func newEnterpriseClient(base, tok string) (*github.Client, error) {
+	c, err := github.NewClient(nil).WithEnterpriseURLs(base+enterpriseAPI, base+enterpriseUpload)
+	if err != nil {
+		return nil, err
+	}
+	if tok == "" {
+		return c, nil
+	}
+
+	return c.WithAuthToken(tok), nil
+}
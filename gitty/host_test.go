@@ -0,0 +1,251 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenVar returns the name of the environment variable of the token of the
+// host, which starts with the prefix, as the token package names it.
+func tokenVar(prefix, host string) string {
+	return prefix + "_" + strings.ToUpper(strings.Map(func(r rune) rune {
+		if ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, host))
+}
+
+func TestParseHost(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		s            string
+		expectedName string
+		expectedBase string
+		expectedErr  error
+	}{
+		{name: "name", s: "ghe.example.com", expectedName: "ghe.example.com", expectedBase: "https://ghe.example.com"},
+		{name: "base url", s: " https://GHE.example.com/ ", expectedName: "ghe.example.com", expectedBase: "https://ghe.example.com"},
+		{name: "http with port", s: "http://127.0.0.1:8080", expectedName: "127.0.0.1:8080", expectedBase: "http://127.0.0.1:8080"},
+		{name: "error path", s: "https://ghe.example.com/api/v3", expectedErr: fmt.Errorf("%w: %q", ErrNotValidHost, "https://ghe.example.com/api/v3")},
+		{name: "error scheme", s: "ftp://ghe.example.com", expectedErr: fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://ghe.example.com")},
+		{name: "error empty", s: "https://", expectedErr: fmt.Errorf("%w: %q", ErrNotValidHost, "https://")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			name, base, err := parseHost(test.s)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expectedName, name)
+			assert.Equal(t, test.expectedBase, base)
+		})
+	}
+}
+
+func TestNewHosts(t *testing.T) {
+	tests := []struct {
+		name          string
+		host          string
+		hosts         string
+		expectedDef   string
+		expectedNames []string
+		expectedErr   error
+	}{
+		{
+			name:        "github.com",
+			expectedDef: hostGitHub,
+		},
+		{
+			name:          "default enterprise host",
+			host:          "ghe.example.com",
+			hosts:         "https://other.example.com, ,github.com",
+			expectedDef:   "ghe.example.com",
+			expectedNames: []string{"ghe.example.com", "other.example.com"},
+		},
+		{
+			name:          "default github.com",
+			host:          "github.com",
+			hosts:         "ghe.example.com",
+			expectedDef:   hostGitHub,
+			expectedNames: []string{"ghe.example.com"},
+		},
+		{
+			name:        "error host",
+			host:        "ghe.example.com/path",
+			expectedDef: hostGitHub,
+			expectedErr: fmt.Errorf("%w: %q", ErrNotValidHost, "https://ghe.example.com/path"),
+		},
+		{
+			name:        "error hosts",
+			hosts:       "ftp://ghe.example.com",
+			expectedDef: hostGitHub,
+			expectedErr: fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://ghe.example.com"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(hostKey, test.host)
+			t.Setenv(hostsKey, test.hosts)
+			h := newHosts()
+			assert.Equal(t, test.expectedErr, h.err)
+			assert.Equal(t, test.expectedDef, h.def)
+			assert.ElementsMatch(t, test.expectedNames, h.names())
+		})
+	}
+}
+
+func TestNewHostsProviders(t *testing.T) {
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitlabHostsKey, "https://gitlab.example.com, ,gitlab.com")
+	t.Setenv(giteaHostsKey, "http://forgejo.example.com:3000")
+	t.Setenv(bitbucketHostsKey, "stash.example.com,bitbucket.org")
+	t.Setenv(tokenVar("GITLAB_TOKEN", "gitlab.example.com"), "self-managed")
+	h := newHosts()
+	require.NoError(t, h.err)
+	assert.ElementsMatch(t, []string{hostGitLab, "gitlab.example.com"}, h.gitlabNames())
+	assert.ElementsMatch(t, []string{hostCodeberg, "forgejo.example.com:3000"}, h.giteaNames())
+	assert.Empty(t, h.names())
+
+	g, ok := h.provider("forgejo.example.com:3000").(*giteaProvider)
+	require.True(t, ok)
+	assert.Equal(t, "http://forgejo.example.com:3000", g.base)
+
+	assert.ElementsMatch(t, []string{hostBitbucket, "stash.example.com"}, h.bitbucketNames())
+	assert.IsType(t, &bitbucketProvider{}, h.provider(hostBitbucket))
+	assert.IsType(t, &serverProvider{}, h.provider("stash.example.com"))
+
+	p, ok := h.provider("gitlab.example.com").(*gitlabProvider)
+	require.True(t, ok)
+	assert.Equal(t, "https://gitlab.example.com", p.base)
+	assert.Equal(t, "self-managed", p.token)
+	assert.Nil(t, h.provider(hostGitHub))
+
+	t.Setenv(gitlabHostsKey, "ftp://gitlab.example.com")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://gitlab.example.com"), newHosts().err)
+
+	t.Setenv(gitlabHostsKey, "")
+	t.Setenv(giteaHostsKey, "ftp://forgejo.example.com")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://forgejo.example.com"), newHosts().err)
+
+	t.Setenv(giteaHostsKey, "")
+	t.Setenv(bitbucketHostsKey, "ftp://stash.example.com")
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://stash.example.com"), newHosts().err)
+}
+
+func TestHosts(t *testing.T) {
+	t.Setenv(tokenVar("GH_TOKEN", "ghe.example.com"), "ghe")
+	t.Setenv("GH_TOKEN", "github")
+	t.Setenv(hostKey, "ghe.example.com")
+	t.Setenv(hostsKey, "")
+	h := newHosts()
+	require.NoError(t, h.err)
+
+	assert.Same(t, h.enterprise["ghe.example.com"].client, h.client(""))
+	assert.Same(t, h.github, h.client(hostGitHub))
+	assert.Equal(t, "https://ghe.example.com/raw/", h.raw(""))
+	assert.Equal(t, rawPrefix, h.raw(hostGitHub))
+	assert.Equal(t, "ghe", h.token(""))
+	assert.Equal(t, "github", h.token(hostGitHub))
+
+	c, ok := h.client("").(*service)
+	require.True(t, ok)
+	assert.Equal(t, "https://ghe.example.com/api/v3/", c.client.BaseURL.String())
+	assert.Equal(t, "https://ghe.example.com/api/uploads/", c.client.UploadURL.String())
+}
+
+// enterpriseServer returns a stand-in of a GitHub Enterprise Server, which
+// serves the docs directory of owner/repo at the main branch. The requests
+// must have the token.
+func enterpriseServer(t *testing.T, tok string) *httptest.Server {
+	t.Helper()
+	sha := strings.Repeat("c", 40)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v3/repos/owner/repo", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"default_branch":"main"}`)
+	})
+	mux.HandleFunc("GET /api/v3/repos/owner/repo/git/matching-refs/{ref...}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("ref") == "heads/main" {
+			fmt.Fprint(w, `[{"ref":"refs/heads/main"}]`)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("GET /api/v3/repos/owner/repo/commits/main", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, sha)
+	})
+	mux.HandleFunc("GET /api/v3/repos/owner/repo/git/trees/"+sha, func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, `{"tree":[{"path":"docs","type":"tree"},{"path":"docs/a.md","type":"blob","sha":"a1","mode":"100644"},{"path":"README.md","type":"blob","sha":"b2","mode":"100644"}]}`)
+	})
+	mux.HandleFunc("GET /raw/owner/repo/"+sha+"/docs/a.md", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "# A")
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+tok {
+			http.Error(w, `{"message":"Bad credentials"}`, http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDownloadEnterprise(t *testing.T) {
+	srv := enterpriseServer(t, "ghe")
+	name := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv(tokenVar("GH_TOKEN", name), "ghe")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, srv.URL)
+
+	tests := []struct {
+		name string
+		host string
+		url  string
+	}{
+		{name: "url", url: srv.URL + "/owner/repo/tree/main/docs"},
+		{name: "url without scheme", url: name + "/owner/repo/tree/main/docs"},
+		{name: "compact spec on default host", host: srv.URL, url: "owner/repo/docs"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(hostKey, test.host)
+			g := New()
+			opts := &Options{Log: io.Discard, Archive: filepath.Join(t.TempDir(), "docs.zip")}
+
+			res, err := g.Download(context.Background(), []string{test.url}, opts)
+			require.NoError(t, err)
+			require.Len(t, res.Files, 1)
+			assert.Equal(t, "docs/a.md", res.Files[0].LocalPath)
+			assert.Equal(t, srv.URL+"/raw/owner/repo/"+strings.Repeat("c", 40)+"/docs/a.md", res.Files[0].URL)
+			assert.Equal(t, []string{"main"}, res.Refs)
+		})
+	}
+}
+
+func TestEnterpriseHostsError(t *testing.T) {
+	t.Setenv(hostKey, "ftp://ghe.example.com")
+	g := New()
+	expected := fmt.Errorf("%w: %q", ErrNotValidHost, "ftp://ghe.example.com")
+
+	_, err := g.Download(context.Background(), []string{"owner/repo"}, nil)
+	assert.Equal(t, expected, err)
+	_, err = g.Status(context.Background())
+	assert.Equal(t, expected, err)
+	_, err = g.Auth(context.Background())
+	assert.Equal(t, expected, err)
+}
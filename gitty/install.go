@@ -0,0 +1,177 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// stateDir represents the directory of the state file in the user config
+// directory.
+const stateDir = "gitty"
+
+// stateName represents the name of the state file.
+const stateName = "installed.json"
+
+// state represents the installs recorded in the state file.
+type state struct {
+	Installs []*Install `json:"installs"`
+}
+
+// binDir returns the directory where the executables are installed.
+func (o *Options) binDir() (string, error) {
+	if o != nil && o.BinDir != "" {
+		return o.BinDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "bin"), nil
+}
+
+// stateFile returns the name of the state file.
+func (o *Options) stateFile() (string, error) {
+	if o != nil && o.StateFile != "" {
+		return o.StateFile, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, stateDir, stateName), nil
+}
+
+// install downloads the release asset for the current platform, verifies
+// it, and extracts its executable into the bin directory.
+func (g *GitHub) install(ctx context.Context) (*Install, error) {
+	dir, err := g.opts.binDir()
+	if err != nil {
+		return nil, err
+	}
+	var pattern string
+	if g.opts != nil {
+		pattern = g.opts.Asset
+	}
+	// An asset selected by the pattern is installed as is.
+	asset := g.assets[0]
+	if pattern == "" || len(g.assets) > 1 {
+		asset, err = pickAsset(g.assets, runtime.GOOS, runtime.GOARCH)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, downloadLimit*time.Second)
+	defer cancel()
+
+	fmt.Fprintln(g.opts.log(), "Downloading:", asset.name)
+	rc, err := g.openAsset(ctx, asset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install: %w", err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "gitty-install-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(tmp, io.TeeReader(rc, h)); err != nil {
+		return nil, fmt.Errorf("failed to install: %w", err)
+	}
+	if err := g.verifyAsset(asset.name, hex.EncodeToString(h.Sum(nil))); err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	p, err := unpack(tmp, assetFormat(asset.name), g.Repo, runtime.GOOS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to install: %w", err)
+	}
+	fmt.Fprintln(g.opts.log(), "Installing:", p)
+
+	return &Install{
+		Host:        g.source().host(),
+		Repo:        g.Owner + "/" + g.Repo,
+		Tag:         g.ref(),
+		Asset:       asset.name,
+		Pattern:     pattern,
+		Path:        p,
+		InstalledAt: time.Now().UTC(),
+	}, nil
+}
+
+// readState reads the installs from the state file. A missing state file
+// has no installs.
+func readState(name string) (*state, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return &state{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("failed to read state: %w", err)
+	}
+
+	return s, nil
+}
+
+// writeState writes the installs, sorted by repository, to the state file.
+func writeState(name string, s *state) error {
+	sort.Slice(s.Installs, func(i, j int) bool {
+		if s.Installs[i].Repo != s.Installs[j].Repo {
+			return s.Installs[i].Repo < s.Installs[j].Repo
+		}
+		return s.Installs[i].Host < s.Installs[j].Host
+	})
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := writeFile(name, bytes.NewReader(append(b, '\n'))); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// record records the install in the state. It replaces the previous install
+// of the repository, if any.
+func (s *state) record(inst *Install) {
+	for i, prev := range s.Installs {
+		if prev.Host == inst.Host && prev.Repo == inst.Repo {
+			s.Installs[i] = inst
+			return
+		}
+	}
+	s.Installs = append(s.Installs, inst)
+}
+
+// spec returns the repository of the install, on its host if it was
+// recorded, so that it is upgraded from the host it was installed from.
+func (i *Install) spec() string {
+	if i.Host == "" {
+		return i.Repo
+	}
+	return i.Host + "/" + i.Repo
+}
@@ -0,0 +1,257 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// platformAsset returns the name of a release asset for the current platform.
+func platformAsset() string {
+	return fmt.Sprintf("tool_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+}
+
+// toolName returns the name of the executable of tool on the current
+// platform.
+func toolName() string {
+	if runtime.GOOS == "windows" {
+		return "tool" + exeSuffix
+	}
+	return "tool"
+}
+
+// toolArchive returns a tar.gz archive with the executable of tool.
+func toolArchive(t *testing.T) []byte {
+	t.Helper()
+	return compress(t, tarData(t, []entry{
+		{name: "README.md", mode: 0o644},
+		{name: toolName(), mode: 0o755},
+	}), gzipWriter)
+}
+
+// installOptions returns the options which install into t.TempDir.
+func installOptions(t *testing.T) *Options {
+	t.Helper()
+	dir := t.TempDir()
+	return &Options{
+		BinDir:    filepath.Join(dir, "bin"),
+		StateFile: filepath.Join(dir, "state", stateName),
+		Log:       io.Discard,
+	}
+}
+
+func TestInstall(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name          string
+		spec          string
+		asset         string
+		assets        map[string][]byte
+		expectedTag   string
+		expectedAsset string
+		expectedErr   error
+	}{
+		{
+			name:          "latest",
+			spec:          "owner/tool",
+			assets:        map[string][]byte{platformAsset(): toolArchive(t), "tool_plan9_mips.tar.gz": {}},
+			expectedTag:   "v1.0.0",
+			expectedAsset: platformAsset(),
+		},
+		{
+			name:          "tag",
+			spec:          "owner/tool@v0.9.0",
+			assets:        map[string][]byte{platformAsset(): toolArchive(t)},
+			expectedTag:   "v0.9.0",
+			expectedAsset: platformAsset(),
+		},
+		{
+			name:          "asset pattern",
+			spec:          "owner/tool",
+			asset:         "tool-static.tgz",
+			assets:        map[string][]byte{"tool-static.tgz": toolArchive(t), platformAsset(): {}},
+			expectedTag:   "v1.0.0",
+			expectedAsset: "tool-static.tgz",
+		},
+		{
+			name:        "error no platform asset",
+			spec:        "owner/tool",
+			assets:      map[string][]byte{"tool_plan9_mips.tar.gz": {}},
+			expectedErr: fmt.Errorf("%w: %s/%s", ErrNoPlatformAsset, runtime.GOOS, runtime.GOARCH),
+		},
+		{
+			name:        "error no executable",
+			spec:        "owner/tool",
+			assets:      map[string][]byte{platformAsset(): compress(t, tarData(t, []entry{{name: "README.md", mode: 0o644}}), gzipWriter)},
+			expectedErr: fmt.Errorf("failed to install: %w", fmt.Errorf("%w: want %s", ErrNoExecutable, toolName())),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &Git{repo: &GitHub{Client: &service{client: releaseServer(t, test.assets)}}}
+			opts := installOptions(t)
+			opts.Asset = test.asset
+
+			inst, err := g.Install(context.Background(), test.spec, opts)
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				_, errStat := os.Stat(opts.StateFile)
+				assert.True(t, os.IsNotExist(errStat))
+				return
+			}
+			assert.Equal(t, hostGitHub, inst.Host)
+			assert.Equal(t, "owner/tool", inst.Repo)
+			assert.Equal(t, test.expectedTag, inst.Tag)
+			assert.Equal(t, test.expectedAsset, inst.Asset)
+			assert.Equal(t, test.asset, inst.Pattern)
+			assert.Equal(t, filepath.Join(opts.BinDir, toolName()), inst.Path)
+
+			res, err := g.Installed(opts)
+			require.NoError(t, err)
+			require.Len(t, res.Installs, 1)
+			assert.Equal(t, inst.Path, res.Installs[0].Path)
+			assert.True(t, inst.InstalledAt.Equal(res.Installs[0].InstalledAt))
+		})
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Parallel()
+	g := &Git{repo: &GitHub{Client: &service{client: releaseServer(t, map[string][]byte{platformAsset(): toolArchive(t)})}}}
+	opts := installOptions(t)
+
+	_, err := g.Install(context.Background(), "owner/tool@v0.9.0", opts)
+	require.NoError(t, err)
+
+	res, err := g.Upgrade(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, res.Upgraded, 1)
+	assert.Empty(t, res.UpToDate)
+	assert.Equal(t, "v0.9.0", res.Upgraded[0].From)
+	assert.Equal(t, "v1.0.0", res.Upgraded[0].Tag)
+
+	installed, err := g.Installed(opts)
+	require.NoError(t, err)
+	require.Len(t, installed.Installs, 1)
+	assert.Equal(t, "v1.0.0", installed.Installs[0].Tag)
+
+	res, err = g.Upgrade(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, res.Upgraded)
+	require.Len(t, res.UpToDate, 1)
+	assert.Equal(t, "v1.0.0", res.UpToDate[0].Tag)
+}
+
+func TestUpgradeEnterprise(t *testing.T) {
+	t.Parallel()
+	// github.com has no asset of the platform, so upgrading from it fails.
+	h := &hosts{
+		def:    hostGitHub,
+		github: &service{client: releaseServer(t, map[string][]byte{"tool_plan9_mips.tar.gz": {}})},
+		enterprise: map[string]*enterprise{
+			"ghe.example.com": {
+				base:   "https://ghe.example.com",
+				client: &service{client: releaseServer(t, map[string][]byte{platformAsset(): toolArchive(t)})},
+			},
+		},
+	}
+	g := &Git{repo: repository(h)}
+	opts := installOptions(t)
+	opts.Ref = "v0.9.0"
+
+	inst, err := g.Install(context.Background(), "ghe.example.com/owner/tool", opts)
+	require.NoError(t, err)
+	assert.Equal(t, "ghe.example.com", inst.Host)
+	assert.Equal(t, "v0.9.0", inst.Tag)
+
+	res, err := g.Upgrade(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, res.Upgraded, 1)
+	assert.Equal(t, "ghe.example.com", res.Upgraded[0].Host)
+	assert.Equal(t, "v1.0.0", res.Upgraded[0].Tag)
+}
+
+func TestUpgradeError(t *testing.T) {
+	t.Parallel()
+	opts := installOptions(t)
+	err := writeState(opts.StateFile, &state{Installs: []*Install{{Repo: "owner/tool", Tag: "v0.9.0", Path: filepath.Join(opts.BinDir, "tool")}}})
+	require.NoError(t, err)
+
+	g := &Git{repo: &GitHub{Client: &service{client: releaseServer(t, map[string][]byte{"tool_plan9_mips.tar.gz": {}})}}}
+	_, err = g.Upgrade(context.Background(), opts)
+	assert.Equal(t, fmt.Errorf("%w: %s/%s", ErrNoPlatformAsset, runtime.GOOS, runtime.GOARCH), err)
+}
+
+func TestOptionsBinDir(t *testing.T) {
+	t.Parallel()
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	dir, err := (*Options)(nil).binDir()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".local", "bin"), dir)
+
+	dir, err = (&Options{BinDir: "bin"}).binDir()
+	require.NoError(t, err)
+	assert.Equal(t, "bin", dir)
+}
+
+func TestOptionsStateFile(t *testing.T) {
+	t.Parallel()
+	config, err := os.UserConfigDir()
+	require.NoError(t, err)
+
+	name, err := (*Options)(nil).stateFile()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(config, stateDir, stateName), name)
+
+	name, err = (&Options{StateFile: "state.json"}).stateFile()
+	require.NoError(t, err)
+	assert.Equal(t, "state.json", name)
+}
+
+func TestReadState(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	s, err := readState(filepath.Join(dir, "missing.json"))
+	require.NoError(t, err)
+	assert.Empty(t, s.Installs)
+
+	name := filepath.Join(dir, "bad.json")
+	err = os.WriteFile(name, []byte("{"), 0o600)
+	require.NoError(t, err)
+	_, err = readState(name)
+	assert.ErrorContains(t, err, "failed to read state: ")
+}
+
+func TestStateRecord(t *testing.T) {
+	t.Parallel()
+	s := &state{}
+	s.record(&Install{Repo: "owner/b", Tag: "v1"})
+	s.record(&Install{Repo: "owner/a", Tag: "v1"})
+	s.record(&Install{Repo: "owner/b", Tag: "v2"})
+	s.record(&Install{Host: "ghe.example.com", Repo: "owner/b", Tag: "v3"})
+
+	name := filepath.Join(t.TempDir(), stateName)
+	err := writeState(name, s)
+	require.NoError(t, err)
+
+	got, err := readState(name)
+	require.NoError(t, err)
+	require.Len(t, got.Installs, 3)
+	assert.Equal(t, "owner/a", got.Installs[0].Repo)
+	assert.Equal(t, "owner/b", got.Installs[1].Repo)
+	assert.Equal(t, "v2", got.Installs[1].Tag)
+	assert.Equal(t, "ghe.example.com", got.Installs[2].Host)
+	assert.Equal(t, "v3", got.Installs[2].Tag)
+}
@@ -0,0 +1,448 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Lengths of the special packets of the git protocol, which have no
+// payload.
+const (
+	pktFlush = iota
+	pktDelim
+	pktEnd
+)
+
+// pktMax is the maximum length of a packet, with its 4 bytes of length.
+const pktMax = 65520
+
+// Sideband channels of the packfile section of a fetch response.
+const (
+	bandData     = 1
+	bandProgress = 2
+	bandError    = 3
+)
+
+// Types of the objects of a packfile.
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+// packSignature starts every packfile.
+const packSignature = "PACK"
+
+// packEntryMin is the length of the smallest entry of a packfile: a byte of
+// type and size, and the 8 bytes of the zlib stream of no data.
+const packEntryMin = 9
+
+// deltaCopyMax is the number of bytes a copy instruction of a delta copies
+// at most, since its size has 3 bytes.
+const deltaCopyMax = 0xffffff
+
+// Modes of the tree entries of git.
+const (
+	modeDir       = "40000"
+	modeSubmodule = "160000"
+)
+
+var (
+	ErrBadPacket = errors.New("malformed git packet")
+	ErrBadPack   = errors.New("malformed git packfile")
+)
+
+// objectNames are the names of the object types, which are hashed with their
+// content.
+var objectNames = map[int]string{
+	objCommit: "commit",
+	objTree:   "tree",
+	objBlob:   "blob",
+	objTag:    "tag",
+}
+
+// object represents an object of a packfile.
+type object struct {
+	kind int
+	data []byte
+}
+
+// pktWriter writes the packets of a git protocol request.
+type pktWriter struct {
+	bytes.Buffer
+}
+
+// line writes a packet of the text, which ends with a newline.
+func (w *pktWriter) line(format string, args ...any) {
+	s := fmt.Sprintf(format, args...) + "\n"
+	fmt.Fprintf(w, "%04x%s", len(s)+4, s)
+}
+
+// special writes a packet without payload, like a flush.
+func (w *pktWriter) special(kind int) {
+	fmt.Fprintf(w, "%04x", kind)
+}
+
+// readPkt reads a packet. It returns its payload, or the kind of the special
+// packet, like a flush, with no payload.
+func readPkt(r io.Reader) ([]byte, int, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, 0, err
+	}
+	n, err := strconv.ParseUint(string(head[:]), 16, 16)
+	if err != nil || n == 3 || n > pktMax {
+		return nil, 0, fmt.Errorf("%w: length %q", ErrBadPacket, head)
+	}
+	if n <= pktEnd {
+		return nil, int(n), nil
+	}
+
+	payload := make([]byte, n-4)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrBadPacket, err)
+	}
+	if s, ok := strings.CutPrefix(string(payload), "ERR "); ok {
+		return nil, 0, fmt.Errorf("%w: %s", ErrBadStatus, strings.TrimSpace(s))
+	}
+	return payload, 0, nil
+}
+
+// readLines reads the text packets until a flush, or until a delimiter or
+// a response end. It returns the lines without their newlines.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	for {
+		payload, _, err := readPkt(r)
+		if err != nil {
+			return nil, err
+		}
+		if payload == nil {
+			return lines, nil
+		}
+		lines = append(lines, strings.TrimSuffix(string(payload), "\n"))
+	}
+}
+
+// sideband reads the data of the packets of the packfile section. The
+// progress messages are dropped, and the error messages are returned.
+type sideband struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (s *sideband) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		payload, _, err := readPkt(s.r)
+		if err != nil {
+			return 0, err
+		}
+		if payload == nil {
+			return 0, io.EOF
+		}
+		switch payload[0] {
+		case bandData:
+			s.buf = payload[1:]
+		case bandProgress:
+		case bandError:
+			return 0, fmt.Errorf("%w: %s", ErrBadStatus, strings.TrimSpace(string(payload[1:])))
+		default:
+			return 0, fmt.Errorf("%w: unknown band %d", ErrBadPacket, payload[0])
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// counter counts the bytes read from the packfile, since the deltas refer
+// to their bases by offset. It is a byte reader, so zlib doesn't read past
+// the end of the compressed data.
+type counter struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *counter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *counter) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
+
+// packed represents an object as it is in the packfile. Deltas have the
+// offset or the name of their base.
+type packed struct {
+	kind       int
+	data       []byte
+	baseOffset int64
+	baseName   string
+}
+
+// readPack reads the objects of the packfile, keyed by their names. The
+// deltas are resolved against their bases, which must be in the packfile.
+// The packfile is read whole first, so that its count of objects can be
+// checked against its length.
+func readPack(r io.Reader) (map[string]*object, error) {
+	pack, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+	}
+	c := &counter{r: bufio.NewReader(bytes.NewReader(pack))}
+	var header [12]byte
+	if _, err := io.ReadFull(c, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+	}
+	if string(header[:4]) != packSignature {
+		return nil, fmt.Errorf("%w: missing signature", ErrBadPack)
+	}
+	count := binary.BigEndian.Uint32(header[8:])
+	if uint64(count)*packEntryMin > uint64(len(pack)-len(header)) {
+		return nil, fmt.Errorf("%w: %d objects in %d bytes", ErrBadPack, count, len(pack))
+	}
+
+	entries := make(map[int64]*packed, count)
+	offsets := make([]int64, 0, count)
+	for range count {
+		offset := c.n
+		p, err := readEntry(c, offset)
+		if err != nil {
+			return nil, err
+		}
+		entries[offset] = p
+		offsets = append(offsets, offset)
+	}
+
+	return resolveDeltas(entries, offsets)
+}
+
+// readEntry reads the entry of the packfile at the offset.
+func readEntry(c *counter, offset int64) (*packed, error) {
+	b, err := c.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+	}
+	p := &packed{kind: int(b>>4) & 7}
+	size := int64(b & 0x0f)
+	for shift := 4; b&0x80 != 0; shift += 7 {
+		if b, err = c.ReadByte(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+		}
+		size |= int64(b&0x7f) << shift
+	}
+
+	switch p.kind {
+	case objCommit, objTree, objBlob, objTag:
+	case objOfsDelta:
+		// The offset is big-endian, and each continuation adds one first.
+		b, err := c.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+		}
+		distance := int64(b & 0x7f)
+		for b&0x80 != 0 {
+			if b, err = c.ReadByte(); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+			}
+			distance = (distance+1)<<7 | int64(b&0x7f)
+		}
+		p.baseOffset = offset - distance
+	case objRefDelta:
+		var name [sha1.Size]byte
+		if _, err := io.ReadFull(c, name[:]); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+		}
+		p.baseName = hex.EncodeToString(name[:])
+	default:
+		return nil, fmt.Errorf("%w: unknown object type %d", ErrBadPack, p.kind)
+	}
+
+	z, err := zlib.NewReader(c)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+	}
+	defer z.Close()
+	if p.data, err = io.ReadAll(z); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBadPack, err)
+	}
+	if int64(len(p.data)) != size {
+		return nil, fmt.Errorf("%w: object at %d has %d bytes, want %d", ErrBadPack, offset, len(p.data), size)
+	}
+
+	return p, nil
+}
+
+// resolveDeltas applies the deltas to their bases, and names the objects. A delta
+// may be the base of another delta, so they are resolved until none is
+// left.
+func resolveDeltas(entries map[int64]*packed, offsets []int64) (map[string]*object, error) {
+	objects := make(map[string]*object, len(offsets))
+	resolved := make(map[int64]*object, len(offsets))
+	pending := offsets
+	for len(pending) > 0 {
+		var next []int64
+		for _, offset := range pending {
+			p := entries[offset]
+			o := &object{kind: p.kind, data: p.data}
+			if p.kind == objOfsDelta || p.kind == objRefDelta {
+				base := resolved[p.baseOffset]
+				if p.kind == objRefDelta {
+					base = objects[p.baseName]
+				}
+				if base == nil {
+					next = append(next, offset)
+					continue
+				}
+				data, err := applyDelta(base.data, p.data)
+				if err != nil {
+					return nil, err
+				}
+				o = &object{kind: base.kind, data: data}
+			}
+			resolved[offset] = o
+			objects[objectName(o)] = o
+		}
+		if len(next) == len(pending) {
+			return nil, fmt.Errorf("%w: %d deltas without base", ErrBadPack, len(next))
+		}
+		pending = next
+	}
+
+	return objects, nil
+}
+
+// objectName returns the SHA-1 name of the object.
+func objectName(o *object) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "%s %d\x00", objectNames[o.kind], len(o.data))
+	h.Write(o.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// applyDelta applies the delta to the base. The delta starts with the sizes
+// of the base and of the result, followed by the instructions, which copy
+// a part of the base or insert their own data.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	baseSize, err := binary.ReadUvarint(r)
+	if err != nil || baseSize != uint64(len(base)) {
+		return nil, fmt.Errorf("%w: delta base size", ErrBadPack)
+	}
+	size, err := binary.ReadUvarint(r)
+	// Each instruction has a byte at least, and writes deltaCopyMax bytes at
+	// most.
+	if err != nil || size > uint64(r.Len())*deltaCopyMax {
+		return nil, fmt.Errorf("%w: delta size", ErrBadPack)
+	}
+
+	// The copies may repeat the base, so the result grows past the length
+	// of the inputs if it needs to.
+	out := make([]byte, 0, min(size, uint64(len(base)+len(delta))))
+	for {
+		op, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		switch {
+		case op&0x80 != 0:
+			var offset, n uint64
+			for i := range 7 {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				b, err := r.ReadByte()
+				if err != nil {
+					return nil, fmt.Errorf("%w: delta copy", ErrBadPack)
+				}
+				if i < 4 {
+					offset |= uint64(b) << (8 * i)
+				} else {
+					n |= uint64(b) << (8 * (i - 4))
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if offset+n > uint64(len(base)) {
+				return nil, fmt.Errorf("%w: delta copy out of the base", ErrBadPack)
+			}
+			out = append(out, base[offset:offset+n]...)
+		case op != 0:
+			data := make([]byte, op)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("%w: delta insert", ErrBadPack)
+			}
+			out = append(out, data...)
+		default:
+			return nil, fmt.Errorf("%w: delta instruction 0", ErrBadPack)
+		}
+	}
+	if uint64(len(out)) != size {
+		return nil, fmt.Errorf("%w: delta result has %d bytes, want %d", ErrBadPack, len(out), size)
+	}
+
+	return out, nil
+}
+
+// treeItem represents an entry of a tree object.
+type treeItem struct {
+	mode string
+	name string
+	sha  string
+}
+
+// parseTree parses the entries of the tree object. Each one is its mode
+// and its name, separated by a space, followed by a zero byte and the
+// binary name of its object. Names which aren't a single path segment are
+// rejected.
+func parseTree(data []byte) ([]treeItem, error) {
+	var items []treeItem
+	for len(data) > 0 {
+		mode, rest, ok := bytes.Cut(data, []byte{' '})
+		if !ok {
+			return nil, fmt.Errorf("%w: tree entry mode", ErrBadPack)
+		}
+		name, rest, ok := bytes.Cut(rest, []byte{0})
+		if !ok || len(rest) < sha1.Size {
+			return nil, fmt.Errorf("%w: tree entry name", ErrBadPack)
+		}
+		// The names are joined into the paths of the files, so they must be
+		// single path segments.
+		if len(name) == 0 || string(name) == "." || string(name) == ".." || bytes.ContainsRune(name, '/') {
+			return nil, fmt.Errorf("%w: tree entry name %q", ErrBadPack, name)
+		}
+		items = append(items, treeItem{mode: string(mode), name: string(name), sha: hex.EncodeToString(rest[:sha1.Size])})
+		data = rest[sha1.Size:]
+	}
+	return items, nil
+}
+
+// commitTree returns the name of the tree of the commit object, which is
+// on its first line.
+func commitTree(data []byte) (string, error) {
+	line, _, _ := bytes.Cut(data, []byte{'\n'})
+	tree, ok := bytes.CutPrefix(line, []byte("tree "))
+	if !ok {
+		return "", fmt.Errorf("%w: commit without tree", ErrBadPack)
+	}
+	return string(tree), nil
+}
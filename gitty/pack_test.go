@@ -0,0 +1,204 @@
+package gitty
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPktWriter(t *testing.T) {
+	t.Parallel()
+	var w pktWriter
+	w.line("command=%s", "ls-refs")
+	w.special(pktDelim)
+	w.line("peel")
+	w.special(pktFlush)
+	assert.Equal(t, "0014command=ls-refs\n00010009peel\n0000", w.String())
+}
+
+func TestReadPkt(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		input           string
+		expectedPayload []byte
+		expectedKind    int
+		expectedErr     error
+	}{
+		{name: "line", input: "0009peel\n", expectedPayload: []byte("peel\n")},
+		{name: "flush", input: "0000", expectedKind: pktFlush},
+		{name: "delimiter", input: "0001", expectedKind: pktDelim},
+		{name: "response end", input: "0002", expectedKind: pktEnd},
+		{name: "server error", input: "000dERR nope\n", expectedErr: fmt.Errorf("%w: nope", ErrBadStatus)},
+		{name: "bad length", input: "zzzz", expectedErr: fmt.Errorf("%w: length %q", ErrBadPacket, [4]byte{'z', 'z', 'z', 'z'})},
+		{name: "reserved length", input: "0003", expectedErr: fmt.Errorf("%w: length %q", ErrBadPacket, [4]byte{'0', '0', '0', '3'})},
+		{name: "empty", input: "", expectedErr: io.EOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			payload, kind, err := readPkt(strings.NewReader(test.input))
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expectedPayload, payload)
+			assert.Equal(t, test.expectedKind, kind)
+		})
+	}
+}
+
+func TestReadLines(t *testing.T) {
+	t.Parallel()
+	lines, err := readLines(strings.NewReader("000eversion 2\n000cls-refs\n0000"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"version 2", "ls-refs"}, lines)
+
+	_, err = readLines(strings.NewReader("000eversion 2\n"))
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestSideband(t *testing.T) {
+	t.Parallel()
+	var w pktWriter
+	w.line("\x01PA")
+	w.line("\x02counting objects")
+	w.line("\x01CK")
+	w.special(pktFlush)
+
+	b, err := io.ReadAll(&sideband{r: &w})
+	require.NoError(t, err)
+	assert.Equal(t, "PA\nCK\n", string(b))
+
+	w.Reset()
+	w.line("\x03out of memory")
+	_, err = io.ReadAll(&sideband{r: &w})
+	assert.ErrorIs(t, err, ErrBadStatus)
+}
+
+func TestReadPack(t *testing.T) {
+	t.Parallel()
+	_, err := readPack(strings.NewReader("KCAP\x00\x00\x00\x02\x00\x00\x00\x00"))
+	assert.Equal(t, fmt.Errorf("%w: missing signature", ErrBadPack), err)
+	_, err = readPack(strings.NewReader("PACK"))
+	assert.ErrorIs(t, err, ErrBadPack)
+	_, err = readPack(strings.NewReader("PACK\x00\x00\x00\x02\xff\xff\xff\xff"))
+	assert.Equal(t, fmt.Errorf("%w: 4294967295 objects in 12 bytes", ErrBadPack), err)
+	_, err = readPack(&sideband{r: strings.NewReader("0005\x03")})
+	assert.ErrorIs(t, err, ErrBadStatus)
+
+	objects, err := readPack(strings.NewReader("PACK\x00\x00\x00\x02\x00\x00\x00\x00"))
+	require.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestObjectName(t *testing.T) {
+	t.Parallel()
+	// The name of the blob given by git hash-object.
+	assert.Equal(t, "ce013625030ba8dba906f756967f9e9ca394464a", objectName(&object{kind: objBlob, data: []byte("hello\n")}))
+}
+
+func TestApplyDelta(t *testing.T) {
+	t.Parallel()
+	base := []byte("hello, world")
+	tests := []struct {
+		name        string
+		delta       []byte
+		expected    []byte
+		expectedErr error
+	}{
+		{
+			name:     "copy and insert",
+			delta:    []byte{12, 11, 0x91, 0, 5, 6, '!', ' ', 'g', 'i', 't', 'y'},
+			expected: []byte("hello! gity"),
+		},
+		{
+			name:     "copy with offset",
+			delta:    []byte{12, 5, 0x91, 7, 5},
+			expected: []byte("world"),
+		},
+		{
+			name:        "wrong base size",
+			delta:       []byte{3, 1, 1, 'a'},
+			expectedErr: fmt.Errorf("%w: delta base size", ErrBadPack),
+		},
+		{
+			name:        "copy out of the base",
+			delta:       []byte{12, 5, 0x91, 10, 5},
+			expectedErr: fmt.Errorf("%w: delta copy out of the base", ErrBadPack),
+		},
+		{
+			name:        "short insert",
+			delta:       []byte{12, 3, 3, 'a'},
+			expectedErr: fmt.Errorf("%w: delta insert", ErrBadPack),
+		},
+		{
+			name:        "instruction 0",
+			delta:       []byte{12, 0, 0},
+			expectedErr: fmt.Errorf("%w: delta instruction 0", ErrBadPack),
+		},
+		{
+			name:        "size out of range",
+			delta:       []byte{12, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 1, 'a'},
+			expectedErr: fmt.Errorf("%w: delta size", ErrBadPack),
+		},
+		{
+			name:        "size over the instructions",
+			delta:       []byte{12, 0x80, 0x80, 0x80, 0x80, 0x01, 0x91, 0, 0xff},
+			expectedErr: fmt.Errorf("%w: delta size", ErrBadPack),
+		},
+		{
+			name:        "wrong result size",
+			delta:       []byte{12, 2, 1, 'a'},
+			expectedErr: fmt.Errorf("%w: delta result has 1 bytes, want 2", ErrBadPack),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			out, err := applyDelta(base, test.delta)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, out)
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	t.Parallel()
+	sha := bytes.Repeat([]byte{0xab}, 20)
+	var data []byte
+	data = append(append(append(data, "100644 a.md\x00"...), sha...), "40000 docs\x00"...)
+	data = append(data, sha...)
+
+	items, err := parseTree(data)
+	require.NoError(t, err)
+	assert.Equal(t, []treeItem{
+		{mode: "100644", name: "a.md", sha: hex.EncodeToString(sha)},
+		{mode: modeDir, name: "docs", sha: hex.EncodeToString(sha)},
+	}, items)
+
+	_, err = parseTree([]byte("100644"))
+	assert.Equal(t, fmt.Errorf("%w: tree entry mode", ErrBadPack), err)
+	_, err = parseTree([]byte("100644 a.md\x00abc"))
+	assert.Equal(t, fmt.Errorf("%w: tree entry name", ErrBadPack), err)
+
+	for _, name := range []string{"", ".", "..", "docs/a.md", "../a.md"} {
+		_, err = parseTree(append([]byte("100644 "+name+"\x00"), sha...))
+		assert.Equal(t, fmt.Errorf("%w: tree entry name %q", ErrBadPack, name), err)
+	}
+}
+
+func TestCommitTree(t *testing.T) {
+	t.Parallel()
+	tree, err := commitTree([]byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\nauthor gitty\n"))
+	require.NoError(t, err)
+	assert.Equal(t, "4b825dc642cb6eb9a060e54bf8d69288fbee4904", tree)
+
+	_, err = commitTree([]byte("author gitty\n"))
+	assert.Equal(t, fmt.Errorf("%w: commit without tree", ErrBadPack), err)
+}
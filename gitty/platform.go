@@ -0,0 +1,149 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Formats of the installable release assets.
+const (
+	formatTar    = "tar"
+	formatTarBz2 = "tar.bz2"
+	formatGz     = "gz"
+	formatBinary = "binary"
+)
+
+var ErrNoPlatformAsset = errors.New("no release asset matches the platform")
+
+// osAliases represents the names of the operating systems in asset names.
+var osAliases = map[string][]string{
+	"darwin":  {"darwin", "macos", "mac", "osx", "apple"},
+	"linux":   {"linux"},
+	"windows": {"windows", "win", "win64", "win32"},
+	"freebsd": {"freebsd"},
+}
+
+// archAliases represents the names of the architectures in asset names.
+// Names like x86_64 are normalized before the names are split.
+var archAliases = map[string][]string{
+	"amd64": {"amd64", "x64", "64bit"},
+	"arm64": {"arm64"},
+	"386":   {"386", "i386", "i686", "x86", "32bit"},
+	"arm":   {"arm", "armv6", "armv7", "armhf"},
+}
+
+// universalArches represents the names of macOS binaries which run on every
+// architecture.
+var universalArches = []string{"universal", "all"}
+
+// formatSuffixes maps the suffixes of the installable assets to their formats.
+// Longer suffixes come first.
+var formatSuffixes = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", formatTarGz},
+	{".tgz", formatTarGz},
+	{".tar.zst", formatTarZst},
+	{".tar.bz2", formatTarBz2},
+	{".tbz", formatTarBz2},
+	{".tar", formatTar},
+	{".zip", formatZip},
+	{".gz", formatGz},
+	{".exe", formatBinary},
+}
+
+// skippedSuffixes represents the suffixes of the assets which are not
+// installable, like packages, metadata, and unsupported archives.
+var skippedSuffixes = []string{
+	".deb", ".rpm", ".apk", ".msi", ".pkg", ".dmg", ".snap", ".flatpak", ".appimage",
+	".xz", ".7z", ".bz2", ".zst", ".txt", ".json", ".sbom", ".pem", ".asc", ".sig", ".minisig", ".sha256", ".md",
+}
+
+// assetFormat returns the format of the installable release asset, or ""
+// if it is not installable. Names without a known suffix are binaries.
+func assetFormat(name string) string {
+	lower := strings.ToLower(name)
+	for _, s := range formatSuffixes {
+		if strings.HasSuffix(lower, s.suffix) {
+			return s.format
+		}
+	}
+	for _, s := range skippedSuffixes {
+		if strings.HasSuffix(lower, s) {
+			return ""
+		}
+	}
+	if isChecksumFile(name) {
+		return ""
+	}
+	return formatBinary
+}
+
+// pickAsset picks the installable release asset for the platform. The asset
+// name must name the operating system. Assets which also name the
+// architecture are preferred over the ones which name none, or a universal
+// one. Among equals, the first one in name order is picked.
+func pickAsset(assets []*releaseAsset, goos, goarch string) (*releaseAsset, error) {
+	var best *releaseAsset
+	bestScore := 0
+	for _, asset := range assets {
+		if assetFormat(asset.name) == "" {
+			continue
+		}
+		score := platformScore(asset.name, goos, goarch)
+		if score > bestScore || (score == bestScore && score > 0 && asset.name < best.name) {
+			best, bestScore = asset, score
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("%w: %s/%s", ErrNoPlatformAsset, goos, goarch)
+	}
+
+	return best, nil
+}
+
+// platformScore scores how well the asset name matches the platform. It is
+// 0 if the name names another operating system or architecture.
+func platformScore(name, goos, goarch string) int {
+	tokens := nameTokens(name)
+	if !containsAny(tokens, osAliases[goos]) {
+		return 0
+	}
+
+	switch {
+	case containsAny(tokens, archAliases[goarch]):
+		return 3
+	case goos == "darwin" && containsAny(tokens, universalArches):
+		return 2
+	}
+	for arch, aliases := range archAliases {
+		if arch != goarch && containsAny(tokens, aliases) {
+			return 0
+		}
+	}
+	return 1
+}
+
+// nameTokens returns the lowercase words of the asset name. Architecture
+// names with separators, like x86_64 and aarch64, are normalized first.
+func nameTokens(name string) []string {
+	lower := strings.ToLower(name)
+	lower = strings.NewReplacer("x86_64", "amd64", "x86-64", "amd64", "aarch64", "arm64").Replace(lower)
+	return strings.FieldsFunc(lower, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsAny reports whether the tokens contain any of the names.
+func containsAny(tokens, names []string) bool {
+	for _, name := range names {
+		if slices.Contains(tokens, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package gitty
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssetFormat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		asset    string
+		expected string
+	}{
+		{name: "tar.gz", asset: "tool_linux_amd64.tar.gz", expected: formatTarGz},
+		{name: "tgz", asset: "tool-linux-x86_64.tgz", expected: formatTarGz},
+		{name: "tar.zst", asset: "tool_linux_amd64.tar.zst", expected: formatTarZst},
+		{name: "tar.bz2", asset: "tool_linux_amd64.tar.bz2", expected: formatTarBz2},
+		{name: "zip", asset: "tool_windows_amd64.ZIP", expected: formatZip},
+		{name: "gz", asset: "tool_linux_amd64.gz", expected: formatGz},
+		{name: "exe", asset: "tool_windows_amd64.exe", expected: formatBinary},
+		{name: "binary with version", asset: "tool_1.2.3_linux_amd64", expected: formatBinary},
+		{name: "package", asset: "tool_linux_amd64.deb", expected: ""},
+		{name: "xz", asset: "tool_linux_amd64.tar.xz", expected: ""},
+		{name: "checksums", asset: "SHA256SUMS", expected: ""},
+		{name: "signature", asset: "tool_linux_amd64.tar.gz.sig", expected: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, assetFormat(test.asset))
+		})
+	}
+}
+
+func TestPickAsset(t *testing.T) {
+	t.Parallel()
+	assets := func(names ...string) []*releaseAsset {
+		var assets []*releaseAsset
+		for _, name := range names {
+			assets = append(assets, &releaseAsset{name: name})
+		}
+		return assets
+	}
+	goreleaser := assets(
+		"checksums.txt",
+		"tool_1.0.0_darwin_all.tar.gz",
+		"tool_1.0.0_linux_amd64.deb",
+		"tool_1.0.0_linux_amd64.tar.gz",
+		"tool_1.0.0_linux_arm64.tar.gz",
+		"tool_1.0.0_linux_armv7.tar.gz",
+		"tool_1.0.0_windows_amd64.zip",
+	)
+	rust := assets(
+		"tool-x86_64-unknown-linux-musl.tar.gz",
+		"tool-aarch64-apple-darwin.tar.gz",
+		"tool-x86_64-apple-darwin.tar.gz",
+		"tool-x86_64-pc-windows-msvc.zip",
+	)
+
+	tests := []struct {
+		name        string
+		assets      []*releaseAsset
+		goos        string
+		goarch      string
+		expected    string
+		expectedErr error
+	}{
+		{name: "goreleaser linux amd64", assets: goreleaser, goos: "linux", goarch: "amd64", expected: "tool_1.0.0_linux_amd64.tar.gz"},
+		{name: "goreleaser linux arm64", assets: goreleaser, goos: "linux", goarch: "arm64", expected: "tool_1.0.0_linux_arm64.tar.gz"},
+		{name: "goreleaser linux arm", assets: goreleaser, goos: "linux", goarch: "arm", expected: "tool_1.0.0_linux_armv7.tar.gz"},
+		{name: "goreleaser darwin", assets: goreleaser, goos: "darwin", goarch: "arm64", expected: "tool_1.0.0_darwin_all.tar.gz"},
+		{name: "goreleaser windows", assets: goreleaser, goos: "windows", goarch: "amd64", expected: "tool_1.0.0_windows_amd64.zip"},
+		{name: "rust linux amd64", assets: rust, goos: "linux", goarch: "amd64", expected: "tool-x86_64-unknown-linux-musl.tar.gz"},
+		{name: "rust darwin arm64", assets: rust, goos: "darwin", goarch: "arm64", expected: "tool-aarch64-apple-darwin.tar.gz"},
+		{name: "rust windows", assets: rust, goos: "windows", goarch: "amd64", expected: "tool-x86_64-pc-windows-msvc.zip"},
+		{name: "universal darwin", assets: assets("tool_darwin_universal.zip", "tool_linux_amd64.zip"), goos: "darwin", goarch: "arm64", expected: "tool_darwin_universal.zip"},
+		{name: "without arch", assets: assets("tool-linux", "tool-linux-arm64"), goos: "linux", goarch: "amd64", expected: "tool-linux"},
+		{
+			name:        "error no platform asset",
+			assets:      rust,
+			goos:        "linux",
+			goarch:      "arm64",
+			expectedErr: fmt.Errorf("%w: %s/%s", ErrNoPlatformAsset, "linux", "arm64"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			asset, err := pickAsset(test.assets, test.goos, test.goarch)
+			assert.Equal(t, test.expectedErr, err)
+			if test.expectedErr != nil {
+				assert.Nil(t, asset)
+				return
+			}
+			assert.Equal(t, test.expected, asset.name)
+		})
+	}
+}
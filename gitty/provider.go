@@ -0,0 +1,343 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+var ErrNotSupported = errors.New("not supported by the host")
+
+// provider defines the methods of a git host which downloads need: ref
+// resolution, tree listing, and raw file fetching. The engine caches the
+// lookups and counts the API calls. Their replies report the rate limit
+// and the next page, if any.
+type provider interface {
+	// host returns the name of the host, which keys the cached lookups.
+	host() string
+	// defaultBranch returns the default branch of the repository.
+	defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error)
+	// refs lists a page of the names of the references of the kind, heads
+	// or tags, that start with the given name. All of them are listed if
+	// the name is empty.
+	refs(ctx context.Context, owner, repo, kind, name string, page cursor) ([]string, *reply, error)
+	// commitSHA returns the commit SHA of the reference.
+	commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error)
+	// tree lists a page of the recursive tree of the reference.
+	tree(ctx context.Context, owner, repo, ref string, page cursor) (*fileTree, *reply, error)
+	// file returns the raw download URL of the file at the reference. It
+	// returns ErrNotFile if the path is not a file.
+	file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error)
+	// rawURL returns the raw download URL of the file at the reference.
+	rawURL(owner, repo, ref, path string) string
+	// get issues a GET to the raw download URL.
+	get(ctx context.Context, url string) (*http.Response, error)
+}
+
+// historian is a provider which lists the commits of a reference by time,
+// which --at and --since need.
+type historian interface {
+	// commitAt returns the SHA of the last commit of the reference at or
+	// before the time, which changed the path if it is set. It returns
+	// ErrNoCommit if there is none.
+	commitAt(ctx context.Context, owner, repo, ref, path string, t time.Time) (string, *reply, error)
+}
+
+// releaser is a provider which has releases.
+type releaser interface {
+	// release gets the release tagged with the tag, if set. Otherwise, it
+	// gets the latest release, or the newest published one, which may be
+	// a prerelease, if prerelease is set.
+	release(ctx context.Context, owner, repo, tag string, prerelease bool) (*repoRelease, *reply, error)
+	// asset downloads the content of the release asset.
+	asset(ctx context.Context, owner, repo string, a *releaseAsset) (io.ReadCloser, error)
+}
+
+// prefetcher is a provider which gets the blobs of the tree entries in one
+// request, before their files are downloaded one by one with get.
+type prefetcher interface {
+	// prefetch gets the blobs of the entries, which get serves then.
+	prefetch(ctx context.Context, owner, repo string, entries []*treeEntry) (*reply, error)
+}
+
+// repoRelease represents a release, with the tag and the assets of it.
+type repoRelease struct {
+	tag    string
+	assets []*releaseAsset
+}
+
+// releaseAsset represents an asset of a release. Its URL is the download
+// URL which the files record.
+type releaseAsset struct {
+	id   int64
+	name string
+	url  string
+}
+
+// cursor represents the position of a page of a list. Only the provider
+// which returned it knows what it is, like a page number, the start of the
+// list, or the URL of the page. The empty cursor is the first page.
+type cursor string
+
+// reply represents the response of the host to a call of a provider. It
+// reports the HTTP status, the rate limit, if the host has one, and the
+// cursor of the next page, which is empty after the last page.
+type reply struct {
+	status    int
+	limit     int
+	remaining int
+	next      cursor
+}
+
+// fileTree represents the recursive tree of a reference. It is truncated
+// if the host can't list all of it.
+type fileTree struct {
+	entries   []*treeEntry
+	truncated bool
+}
+
+// treeEntry represents an entry of a tree, with the types and the modes of
+// git. Hosts which don't list the modes or the SHAs leave them empty.
+type treeEntry struct {
+	path string
+	mode string
+	typ  string
+	sha  string
+}
+
+// githubProvider represents github.com or a GitHub Enterprise Server,
+// which is used through its client.
+type githubProvider struct {
+	name   string
+	client Client
+	// raw is the prefix of the raw download URLs of the host.
+	raw string
+}
+
+// Ensure githubProvider implements the provider, the historian and the
+// releaser interfaces.
+var (
+	_ provider  = (*githubProvider)(nil)
+	_ historian = (*githubProvider)(nil)
+	_ releaser  = (*githubProvider)(nil)
+)
+
+func (p *githubProvider) host() string {
+	return p.name
+}
+
+func (p *githubProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	r, resp, err := p.client.GetRepository(ctx, owner, repo)
+	if err != nil {
+		return "", githubReply(resp), err
+	}
+	return r.GetDefaultBranch(), githubReply(resp), nil
+}
+
+// refs lists a page of the matching refs. The cursor is the page number.
+func (p *githubProvider) refs(ctx context.Context, owner, repo, kind, name string, page cursor) ([]string, *reply, error) {
+	number, _ := strconv.Atoi(string(page))
+	opts := &github.ReferenceListOptions{
+		Ref:         kind,
+		ListOptions: github.ListOptions{Page: number, PerPage: refsPerPage},
+	}
+	if name != "" {
+		opts.Ref += "/" + name
+	}
+
+	refs, resp, err := p.client.ListMatchingRefs(ctx, owner, repo, opts)
+	if err != nil {
+		return nil, githubReply(resp), err
+	}
+	names := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		names = append(names, strings.TrimPrefix(ref.GetRef(), "refs/"+kind+"/"))
+	}
+
+	return names, githubReply(resp), nil
+}
+
+func (p *githubProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	sha, resp, err := p.client.GetCommitSHA1(ctx, owner, repo, ref, "")
+	return sha, githubReply(resp), err
+}
+
+// tree gets the whole recursive tree, which GitHub doesn't page. It is
+// truncated if it is too large.
+func (p *githubProvider) tree(ctx context.Context, owner, repo, ref string, _ cursor) (*fileTree, *reply, error) {
+	t, resp, err := p.client.GetTree(ctx, owner, repo, ref, true)
+	if err != nil {
+		return nil, githubReply(resp), err
+	}
+	tree := &fileTree{truncated: t.GetTruncated()}
+	for _, e := range t.Entries {
+		if err := localPath(e.GetPath()); err != nil {
+			return nil, githubReply(resp), err
+		}
+		tree.entries = append(tree.entries, &treeEntry{
+			path: e.GetPath(),
+			mode: e.GetMode(),
+			typ:  e.GetType(),
+			sha:  e.GetSHA(),
+		})
+	}
+
+	return tree, githubReply(resp), nil
+}
+
+// file gets the contents of the path, which link to its raw download URL.
+func (p *githubProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	fileContent, _, resp, err := p.client.GetContents(ctx, owner, repo, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return "", githubReply(resp), err
+	}
+	if fileContent == nil {
+		return "", githubReply(resp), ErrNotFile
+	}
+	return fileContent.GetDownloadURL(), githubReply(resp), nil
+}
+
+func (p *githubProvider) rawURL(owner, repo, ref, path string) string {
+	return rawURL(p.raw, owner, repo, ref, path)
+}
+
+// get gets the URL with the client, whose Get has no context, so that the
+// exported Client interface is kept.
+func (p *githubProvider) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.client.Do(req)
+}
+
+// commitAt lists the last commit of the reference until the time.
+func (p *githubProvider) commitAt(ctx context.Context, owner, repo, ref, path string, t time.Time) (string, *reply, error) {
+	opts := &github.CommitsListOptions{
+		SHA:         ref,
+		Path:        path,
+		Until:       t,
+		ListOptions: github.ListOptions{PerPage: 1},
+	}
+	commits, resp, err := p.client.ListCommits(ctx, owner, repo, opts)
+	if err != nil {
+		return "", githubReply(resp), err
+	}
+	if len(commits) == 0 {
+		return "", githubReply(resp), fmt.Errorf("%w: %s", ErrNoCommit, t.Format(time.RFC3339))
+	}
+
+	return commits[0].GetSHA(), githubReply(resp), nil
+}
+
+func (p *githubProvider) release(ctx context.Context, owner, repo, tag string, prerelease bool) (*repoRelease, *reply, error) {
+	var rel *github.RepositoryRelease
+	var resp *github.Response
+	var err error
+	switch {
+	case tag != "":
+		rel, resp, err = p.client.GetReleaseByTag(ctx, owner, repo, tag)
+	case prerelease:
+		var rels []*github.RepositoryRelease
+		rels, resp, err = p.client.ListReleases(ctx, owner, repo, &github.ListOptions{PerPage: releasesPerPage})
+		// Releases are listed newest first, and drafts have no tag yet.
+		i := slices.IndexFunc(rels, func(r *github.RepositoryRelease) bool { return !r.GetDraft() })
+		if err == nil && i < 0 {
+			err = ErrNoRelease
+		}
+		if i >= 0 {
+			rel = rels[i]
+		}
+	default:
+		rel, resp, err = p.client.GetLatestRelease(ctx, owner, repo)
+	}
+	if err != nil {
+		return nil, githubReply(resp), err
+	}
+
+	r := &repoRelease{tag: rel.GetTagName()}
+	for _, a := range rel.Assets {
+		r.assets = append(r.assets, &releaseAsset{id: a.GetID(), name: a.GetName(), url: a.GetBrowserDownloadURL()})
+	}
+
+	return r, githubReply(resp), nil
+}
+
+// asset downloads the asset through the API, which redirects to its
+// content.
+func (p *githubProvider) asset(ctx context.Context, owner, repo string, a *releaseAsset) (io.ReadCloser, error) {
+	return p.client.DownloadReleaseAsset(ctx, owner, repo, a.id)
+}
+
+// githubReply returns the reply of the response of the GitHub client. The
+// cursor of the next page is its number.
+func githubReply(resp *github.Response) *reply {
+	if resp == nil {
+		return nil
+	}
+	r := &reply{limit: resp.Rate.Limit, remaining: resp.Rate.Remaining}
+	if resp.Response != nil {
+		r.status = resp.StatusCode
+	}
+	if resp.NextPage != 0 {
+		r.next = cursor(strconv.Itoa(resp.NextPage))
+	}
+	return r
+}
+
+// source returns the provider of the host of the repository. The GitHub
+// hosts use the client of the repository.
+func (g *GitHub) source() provider {
+	if g.provider != nil {
+		return g.provider
+	}
+	p := &githubProvider{name: hostGitHub, client: g.Client, raw: rawPrefix}
+	if g.hosts != nil {
+		p.name = g.hosts.name(g.host)
+		p.raw = g.hosts.raw(g.host)
+	}
+	return p
+}
+
+// onlyGitHub returns ErrNotSupported if the host of the repository is not
+// a GitHub host, which the feature needs.
+func (g *GitHub) onlyGitHub(feature string) error {
+	if g.provider == nil {
+		return nil
+	}
+	return g.notSupported(feature)
+}
+
+// history returns the provider of the host of the repository if it lists
+// the commits by time. Otherwise, it returns ErrNotSupported for the feature.
+func (g *GitHub) history(feature string) (historian, error) {
+	h, ok := g.source().(historian)
+	if !ok {
+		return nil, g.notSupported(feature)
+	}
+	return h, nil
+}
+
+// releases returns the provider of the host of the repository if it has
+// releases. Otherwise, it returns ErrNotSupported.
+func (g *GitHub) releases() (releaser, error) {
+	r, ok := g.source().(releaser)
+	if !ok {
+		return nil, g.notSupported("releases")
+	}
+	return r, nil
+}
+
+// notSupported returns ErrNotSupported for the feature on the host of the
+// repository.
+func (g *GitHub) notSupported(feature string) error {
+	return fmt.Errorf("%w: %s on %s", ErrNotSupported, feature, g.source().host())
+}
@@ -0,0 +1,195 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// githubOf returns the provider of github.com which uses the client.
+func githubOf(c Client) *githubProvider {
+	return &githubProvider{name: hostGitHub, client: c, raw: rawPrefix}
+}
+
+func TestGitHubProvider(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), refsKey, []string{
+		"refs/heads/main",
+		"refs/heads/feature/x",
+		"refs/tags/v1.0.0",
+	})
+	p := githubOf(&mockSuccess{})
+	assert.Equal(t, hostGitHub, p.host())
+
+	branch, _, err := p.defaultBranch(ctx, "owner", "repo")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	names, _, err := p.refs(ctx, "owner", "repo", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, _, err = p.refs(ctx, "owner", "repo", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+
+	sha, _, err := p.commitSHA(ctx, "owner", "repo", "main")
+	require.NoError(t, err)
+	assert.Equal(t, commitData("main"), sha)
+
+	tree, _, err := p.tree(ctx, "owner", "repo", "main", "")
+	require.NoError(t, err)
+	assert.True(t, tree.truncated)
+
+	url, _, err := p.file(ctx, "owner", "repo", "main", testFileOnly)
+	require.NoError(t, err)
+	assert.NotEmpty(t, url)
+	_, _, err = p.file(ctx, "owner", "repo", "main", "dir")
+	assert.Equal(t, ErrNotFile, err)
+
+	assert.Equal(t, rawPrefix+"owner/repo/main/dir/a%20b.txt", p.rawURL("owner", "repo", "main", "dir/a b.txt"))
+
+	resp, err := p.get(ctx, p.rawURL("owner", "repo", "main", "file.txt"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "test data", string(body))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = p.get(canceled, p.rawURL("owner", "repo", "main", "file.txt"))
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGitHubProviderError(t *testing.T) {
+	t.Parallel()
+	p := githubOf(&mockError{})
+
+	_, _, err := p.defaultBranch(context.Background(), "owner", "repo")
+	assert.Equal(t, errMockRepo, err)
+
+	_, _, err = p.refs(context.Background(), "owner", "repo", "heads", testRefsFail, "")
+	assert.Error(t, err)
+
+	_, _, err = p.file(context.Background(), "owner", "repo", "main", "dir")
+	assert.Equal(t, errMockContents, err)
+}
+
+func TestGitHubReply(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		resp     *github.Response
+		expected *reply
+	}{
+		{name: "no response", resp: nil, expected: nil},
+		{
+			name:     "last page",
+			resp:     &github.Response{Response: &http.Response{StatusCode: http.StatusOK}, Rate: github.Rate{Limit: 60, Remaining: 59}},
+			expected: &reply{status: http.StatusOK, limit: 60, remaining: 59},
+		},
+		{
+			name:     "next page",
+			resp:     &github.Response{NextPage: 3},
+			expected: &reply{next: "3"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, githubReply(test.resp))
+		})
+	}
+}
+
+func TestSource(t *testing.T) {
+	t.Setenv(hostKey, "ghe.example.com")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitlabHostsKey, "gitlab.example.com")
+	t.Setenv(giteaHostsKey, "")
+	h := newHosts()
+	require.NoError(t, h.err)
+
+	tests := []struct {
+		name        string
+		g           *GitHub
+		url         string
+		expectedRaw string
+	}{
+		{
+			name:        "github.com without hosts",
+			g:           &GitHub{Client: &mockSuccess{}},
+			url:         "owner/repo",
+			expectedRaw: rawPrefix + "owner/repo/main/a.txt",
+		},
+		{
+			name:        "default enterprise host",
+			g:           &GitHub{hosts: h},
+			url:         "owner/repo",
+			expectedRaw: "https://ghe.example.com/raw/owner/repo/main/a.txt",
+		},
+		{
+			name:        "gitlab.com without hosts",
+			g:           &GitHub{Client: &mockSuccess{}},
+			url:         "gitlab.com/group/sub/project",
+			expectedRaw: "https://gitlab.com/api/v4/projects/group%2Fsub%2Fproject/repository/files/a.txt/raw?ref=main",
+		},
+		{
+			name:        "codeberg.org without hosts",
+			g:           &GitHub{Client: &mockSuccess{}},
+			url:         "codeberg.org/owner/repo",
+			expectedRaw: "https://codeberg.org/api/v1/repos/owner/repo/raw/a.txt?ref=main",
+		},
+		{
+			name:        "bitbucket.org without hosts",
+			g:           &GitHub{Client: &mockSuccess{}},
+			url:         "bitbucket.org/workspace/repo",
+			expectedRaw: "https://api.bitbucket.org/2.0/repositories/workspace/repo/src/main/a.txt",
+		},
+		{
+			name:        "self-managed gitlab",
+			g:           &GitHub{hosts: h},
+			url:         "gitlab.example.com/group/project",
+			expectedRaw: "https://gitlab.example.com/api/v4/projects/group%2Fproject/repository/files/a.txt/raw?ref=main",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.NoError(t, test.g.extract(test.url))
+			assert.Equal(t, test.expectedRaw, test.g.rawURL("main", "a.txt"))
+		})
+	}
+}
+
+func TestOnlyGitHub(t *testing.T) {
+	t.Parallel()
+	g := &GitHub{}
+	require.NoError(t, g.onlyGitHub("artifacts"))
+
+	g.provider = newGitea(hostCodeberg, giteaBase)
+	assert.Equal(t, fmt.Errorf("%w: artifacts on %s", ErrNotSupported, hostCodeberg), g.onlyGitHub("artifacts"))
+}
+
+func TestHistoryReleases(t *testing.T) {
+	t.Parallel()
+	g := &GitHub{provider: newGitea(hostCodeberg, giteaBase)}
+	_, err := g.history("at")
+	require.NoError(t, err)
+	_, err = g.releases()
+	require.NoError(t, err)
+
+	g.provider = newGitLab(hostGitLab, gitlabBase)
+	_, err = g.history("at")
+	assert.Equal(t, fmt.Errorf("%w: at on %s", ErrNotSupported, hostGitLab), err)
+	_, err = g.releases()
+	assert.Equal(t, fmt.Errorf("%w: releases on %s", ErrNotSupported, hostGitLab), err)
+}
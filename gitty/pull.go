@@ -0,0 +1,119 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// filesPerPage represents the number of pull request files listed per request.
+const filesPerPage = 100
+
+// Sides of a pull request, which are saved side by side.
+const (
+	sideHead = "head"
+	sideBase = "base"
+)
+
+// Statuses of the files changed by a pull request or a comparison.
+const (
+	statusAdded     = "added"
+	statusModified  = "modified"
+	statusRemoved   = "removed"
+	statusUnchanged = "unchanged"
+)
+
+// resolvePull resolves the reference of the pull request to its head SHA,
+// or to its merge ref if opts.Merge is set. It also records its base SHA.
+func (g *GitHub) resolvePull(ctx context.Context) error {
+	if err := g.engine.budget(); err != nil {
+		return err
+	}
+	pr, resp, err := g.Client.GetPullRequest(ctx, g.Owner, g.Repo, g.pull)
+	g.engine.stats.call(resp)
+	if err != nil {
+		return fmt.Errorf("failed to resolve ref: %w", err)
+	}
+
+	ref := pr.GetHead().GetSHA()
+	if g.opts != nil && g.opts.Merge {
+		ref = fmt.Sprintf("refs/pull/%d/merge", g.pull)
+	}
+	g.Ref = &github.RepositoryContentGetOptions{Ref: ref}
+	g.baseSHA = pr.GetBase().GetSHA()
+
+	return nil
+}
+
+// collectPull downloads the files changed by the pull request concurrently.
+// Removed files are not downloaded. If opts.Base is set, it also downloads
+// the base versions of the files, which are not added, side by side.
+func (g *GitHub) collectPull(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	files, err := g.pullFiles(ctx)
+	if err != nil {
+		errCh <- err
+		return
+	}
+
+	withBase := g.opts != nil && g.opts.Base
+	head, base := g, g
+	if withBase {
+		head, base = g.onSide(sideHead), g.onSide(sideBase)
+	}
+
+	for _, f := range files {
+		if f.GetStatus() != statusRemoved {
+			wg.Add(1)
+			go head.fetchAt(ctx, wg, errCh, head.ref(), f.GetFilename(), f.GetSHA())
+		}
+		if withBase && f.GetStatus() != statusAdded {
+			// Renamed files have their previous name in the base.
+			name := f.GetPreviousFilename()
+			if name == "" {
+				name = f.GetFilename()
+			}
+			wg.Add(1)
+			go base.fetchAt(ctx, wg, errCh, g.baseSHA, name, "")
+		}
+	}
+}
+
+// pullFiles lists the files changed by the pull request.
+func (g *GitHub) pullFiles(ctx context.Context) ([]*github.CommitFile, error) {
+	var files []*github.CommitFile
+	opts := &github.ListOptions{PerPage: filesPerPage}
+	for {
+		if err := g.engine.budget(); err != nil {
+			return nil, err
+		}
+		page, resp, err := g.Client.ListPullRequestFiles(ctx, g.Owner, g.Repo, g.pull, opts)
+		g.engine.stats.call(resp)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, page...)
+		if resp == nil || resp.NextPage == 0 {
+			return files, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// onSide returns a copy of g which saves the files on the given side.
+func (g *GitHub) onSide(side string) *GitHub {
+	s := *g
+	s.side = side
+	return &s
+}
+
+// fetchAt downloads the file at the given reference.
+func (g *GitHub) fetchAt(ctx context.Context, wg *sync.WaitGroup, errCh chan error, ref, path, sha string) {
+	defer wg.Done()
+	if err := g.fetch(ctx, g.rawURL(ref, path), path, sha, ""); err != nil {
+		errCh <- err
+	}
+}
@@ -0,0 +1,150 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolvePull(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		client       mockClient
+		opts         *Options
+		expectedRef  string
+		expectedName string
+		expectedErr  error
+	}{
+		{
+			name:         "head",
+			client:       &mockSuccess{},
+			expectedRef:  testHeadSHA,
+			expectedName: testHeadSHA,
+		},
+		{
+			name:         "merge",
+			client:       &mockSuccess{},
+			opts:         &Options{Merge: true},
+			expectedRef:  commitData("refs/pull/123/merge"),
+			expectedName: "refs/pull/123/merge",
+		},
+		{
+			name:        "error pull request",
+			client:      &mockError{},
+			expectedErr: fmt.Errorf("failed to resolve ref: %w", errMockPull),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: test.opts, engine: newEngine(0)}
+			err := g.extract("github.com/owner/repo/pull/123/files")
+			require.NoError(t, err)
+
+			err = g.resolve(context.Background())
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, test.expectedRef, g.ref())
+				assert.Equal(t, test.expectedName, g.refName())
+				assert.Equal(t, testBaseSHA, g.baseSHA)
+				assert.Equal(t, "repo-pull-123", g.dest())
+			}
+		})
+	}
+}
+
+func TestCollectPull(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		base     bool
+		expected []string
+	}{
+		{
+			name:     "head",
+			expected: []string{"added.go", "dir/modified.go", "renamed.go"},
+		},
+		{
+			name: "head and base",
+			base: true,
+			expected: []string{
+				"base/dir/modified.go", "base/old.go", "base/removed.go",
+				"head/added.go", "head/dir/modified.go", "head/renamed.go",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			repo := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			g := &GitHub{Client: &mockSuccess{}, opts: &Options{Base: test.base}, engine: newEngine(0)}
+			err := g.extract("github.com/owner/" + repo + "/pull/1")
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				err := os.RemoveAll(g.dest())
+				require.NoError(t, err)
+			})
+			err = g.resolve(context.Background())
+			require.NoError(t, err)
+
+			err = g.download(context.Background())
+			require.NoError(t, err)
+
+			res := g.result()
+			var actual []string
+			for _, f := range res.Files {
+				rel, err := filepath.Rel(g.dest(), f.LocalPath)
+				require.NoError(t, err)
+				actual = append(actual, filepath.ToSlash(rel))
+			}
+			sort.Strings(actual)
+			assert.Equal(t, test.expected, actual)
+			// The pull request and its files.
+			assert.Equal(t, 2, res.Summary.APICalls)
+		})
+	}
+}
+
+// mockPagedFiles lists the pull request files in two pages.
+type mockPagedFiles struct {
+	mockSuccess
+	calls atomic.Int32
+}
+
+func (m *mockPagedFiles) ListPullRequestFiles(_ context.Context, _, _ string, _ int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	m.calls.Add(1)
+	if opts.Page == 0 {
+		return []*github.CommitFile{{Filename: ptr("a.go")}}, &github.Response{NextPage: 2}, nil
+	}
+	return []*github.CommitFile{{Filename: ptr("b.go")}}, &github.Response{}, nil
+}
+
+func TestPullFiles(t *testing.T) {
+	t.Parallel()
+	c := &mockPagedFiles{}
+	g := &GitHub{Client: c, pull: 1, engine: newEngine(0)}
+	files, err := g.pullFiles(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Equal(t, int32(2), c.calls.Load())
+
+	g = &GitHub{Client: &mockError{}, pull: 1, engine: newEngine(0)}
+	_, err = g.pullFiles(context.Background())
+	assert.Equal(t, errMockPull, err)
+
+	g.engine.stats.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 0}})
+	_, err = g.pullFiles(context.Background())
+	assert.Equal(t, ErrRateLimited, err)
+}
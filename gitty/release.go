@@ -0,0 +1,159 @@
+package gitty
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// releasesPerPage represents the number of releases listed to find the
+// newest one.
+const releasesPerPage = 10
+
+var (
+	ErrNoRelease = errors.New("no release found")
+	ErrNoAsset   = errors.New("no release asset matches")
+)
+
+// resolveRelease finds the release and its assets to download, and loads
+// their checksums. The reference is resolved to the tag of the release. A
+// version constraint, like ^1.4, picks the highest tag which satisfies it.
+func (g *GitHub) resolveRelease(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	r, err := g.releases()
+	if err != nil {
+		return err
+	}
+	if isConstraint(g.ref()) {
+		if err := g.resolveVersion(ctx); err != nil {
+			return err
+		}
+	}
+	rel, err := g.findRelease(ctx, r)
+	if err != nil {
+		return fmt.Errorf("failed to resolve release: %w", err)
+	}
+	g.Ref = &github.RepositoryContentGetOptions{Ref: rel.tag}
+
+	var pattern string
+	if g.opts != nil {
+		pattern = g.opts.Asset
+	}
+	assets, err := matchAssets(rel.assets, pattern)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return fmt.Errorf("%w: %q in %s", ErrNoAsset, pattern, rel.tag)
+	}
+	g.assets = assets
+
+	return g.loadChecksums(ctx, rel.assets)
+}
+
+// findRelease gets the release tagged with the reference, if any. Otherwise,
+// it gets the latest release, or the newest published one, which may be a
+// prerelease, if opts.Prerelease is set.
+func (g *GitHub) findRelease(ctx context.Context, r releaser) (*repoRelease, error) {
+	if err := g.engine.budget(); err != nil {
+		return nil, err
+	}
+	prerelease := g.opts != nil && g.opts.Prerelease
+	rel, resp, err := r.release(ctx, g.Owner, g.Repo, g.ref(), prerelease)
+	g.engine.stats.reply(resp)
+
+	return rel, err
+}
+
+// collectRelease downloads the release assets concurrently.
+func (g *GitHub) collectRelease(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	for _, asset := range g.assets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.fetchAsset(ctx, asset); err != nil {
+				g.engine.stats.fail()
+				errCh <- err
+			}
+		}()
+	}
+}
+
+// fetchAsset downloads the release asset, and verifies its checksum. The
+// asset is removed if the verification fails.
+func (g *GitHub) fetchAsset(ctx context.Context, asset *releaseAsset) error {
+	if err := g.engine.budget(); err != nil {
+		return err
+	}
+	if err := g.engine.acquire(ctx); err != nil {
+		return err
+	}
+	defer g.engine.release()
+
+	fmt.Fprintln(g.opts.log(), "Downloading:", asset.name)
+	start := time.Now()
+	rc, err := g.openAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	f, err := g.store(asset.url, asset.name, io.TeeReader(rc, h), -1, start)
+	if err != nil {
+		return err
+	}
+	f.SHA256 = hex.EncodeToString(h.Sum(nil))
+	if err := g.verifyAsset(asset.name, f.SHA256); err != nil {
+		g.discard(f)
+		return err
+	}
+	g.engine.stats.file(f)
+
+	return nil
+}
+
+// openAsset downloads the content of the release asset from the host.
+func (g *GitHub) openAsset(ctx context.Context, asset *releaseAsset) (io.ReadCloser, error) {
+	r, err := g.releases()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := r.asset(ctx, g.Owner, g.Repo, asset)
+	g.engine.stats.reply(nil)
+
+	return rc, err
+}
+
+// matchAssets returns the assets whose names match the glob pattern. It
+// returns all assets if the pattern is empty.
+func matchAssets(assets []*releaseAsset, pattern string) ([]*releaseAsset, error) {
+	if pattern == "" {
+		return assets, nil
+	}
+
+	var matched []*releaseAsset
+	for _, asset := range assets {
+		ok, err := path.Match(pattern, asset.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to match assets: %w", err)
+		}
+		if ok {
+			matched = append(matched, asset)
+		}
+	}
+
+	return matched, nil
+}
@@ -0,0 +1,235 @@
+package gitty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mockNoReleases lists only drafts.
+type mockNoReleases struct {
+	mockSuccess
+}
+
+func (m *mockNoReleases) ListReleases(_ context.Context, _, _ string, _ *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error) {
+	return []*github.RepositoryRelease{{Draft: ptr(true)}}, nil, nil
+}
+
+// mockAssetError fails to download the release assets.
+type mockAssetError struct {
+	mockSuccess
+}
+
+func (m *mockAssetError) DownloadReleaseAsset(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return nil, errMockAsset
+}
+
+func TestResolveRelease(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), refsKey, []string{
+		"refs/tags/v0.8.0",
+		"refs/tags/v0.9.0",
+		"refs/tags/v1.0.0",
+	})
+	tests := []struct {
+		name          string
+		client        mockClient
+		spec          string
+		opts          *Options
+		expectedTag   string
+		expectedFiles []string
+		expectedErr   error
+	}{
+		{
+			name:          "latest release",
+			client:        &mockSuccess{},
+			spec:          "owner/repo",
+			opts:          &Options{},
+			expectedTag:   "v1.0.0",
+			expectedFiles: []string{"gitty_linux_amd64.tar.gz", "gitty_darwin_arm64.tar.gz", "checksums.txt"},
+		},
+		{
+			name:          "release by tag",
+			client:        &mockSuccess{},
+			spec:          "owner/repo",
+			opts:          &Options{Ref: "v0.9.0", Asset: "*linux_amd64*.tar.gz"},
+			expectedTag:   "v0.9.0",
+			expectedFiles: []string{"gitty_linux_amd64.tar.gz"},
+		},
+		{
+			name:          "release by spec tag",
+			client:        &mockSuccess{},
+			spec:          "owner/repo@v0.8.0",
+			opts:          &Options{Asset: "*.txt"},
+			expectedTag:   "v0.8.0",
+			expectedFiles: []string{"checksums.txt"},
+		},
+		{
+			name:          "release by version constraint",
+			client:        &mockSuccess{},
+			spec:          "owner/repo@~0.8",
+			opts:          &Options{Asset: "*.txt"},
+			expectedTag:   "v0.8.0",
+			expectedFiles: []string{"checksums.txt"},
+		},
+		{
+			name:          "newest prerelease",
+			client:        &mockSuccess{},
+			spec:          "owner/repo",
+			opts:          &Options{Prerelease: true, Asset: "*darwin*"},
+			expectedTag:   "v1.1.0-rc.1",
+			expectedFiles: []string{"gitty_darwin_arm64.tar.gz"},
+		},
+		{
+			name:        "error no release",
+			client:      &mockNoReleases{},
+			spec:        "owner/repo",
+			opts:        &Options{Prerelease: true},
+			expectedErr: fmt.Errorf("failed to resolve release: %w", ErrNoRelease),
+		},
+		{
+			name:        "error no asset",
+			client:      &mockSuccess{},
+			spec:        "owner/repo",
+			opts:        &Options{Asset: "*windows*"},
+			expectedErr: fmt.Errorf("%w: %q in %s", ErrNoAsset, "*windows*", "v1.0.0"),
+		},
+		{
+			name:        "error pattern",
+			client:      &mockSuccess{},
+			spec:        "owner/repo",
+			opts:        &Options{Asset: "["},
+			expectedErr: fmt.Errorf("failed to match assets: %w", path.ErrBadPattern),
+		},
+		{
+			name:        "error version constraint",
+			client:      &mockSuccess{},
+			spec:        "owner/repo@^2",
+			opts:        &Options{},
+			expectedErr: fmt.Errorf("%w: %s", ErrNoVersion, "^2"),
+		},
+		{
+			name:        "error latest release",
+			client:      &mockError{},
+			spec:        "owner/repo",
+			opts:        &Options{},
+			expectedErr: fmt.Errorf("failed to resolve release: %w", errMockRelease),
+		},
+		{
+			name:        "error release by tag",
+			client:      &mockError{},
+			spec:        "owner/repo",
+			opts:        &Options{Ref: "v0.9.0"},
+			expectedErr: fmt.Errorf("failed to resolve release: %w", errMockRelease),
+		},
+		{
+			name:        "error list releases",
+			client:      &mockError{},
+			spec:        "owner/repo",
+			opts:        &Options{Prerelease: true},
+			expectedErr: fmt.Errorf("failed to resolve release: %w", errMockRelease),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: test.opts, engine: newEngine(0)}
+			err := g.extract(test.spec)
+			require.NoError(t, err)
+
+			err = g.resolveRelease(ctx)
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, asset := range g.assets {
+				names = append(names, asset.name)
+			}
+			assert.Equal(t, test.expectedFiles, names)
+			assert.Equal(t, test.expectedTag, g.ref())
+			assert.Equal(t, "repo-"+test.expectedTag, g.dest())
+		})
+	}
+}
+
+func TestRelease(t *testing.T) {
+	t.Parallel()
+	fakeRepo := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	fakeDest := fakeRepo + "-v1.0.0"
+	t.Cleanup(func() {
+		err := os.RemoveAll(fakeDest)
+		require.NoError(t, err)
+	})
+
+	g := fakeNew(fakeRepository(&mockSuccess{}))
+	res, err := g.Release(context.Background(), "owner/"+fakeRepo, &Options{Log: io.Discard, Asset: "*.tar.gz"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, res.Refs)
+	// The checksums are read before the assets are downloaded.
+	assert.Equal(t, 4, res.Summary.APICalls)
+	require.Len(t, res.Files, 2)
+	assert.Equal(t, filepath.Join(fakeDest, "gitty_darwin_arm64.tar.gz"), res.Files[0].LocalPath)
+	b, err := os.ReadFile(res.Files[1].LocalPath)
+	require.NoError(t, err)
+	assert.Equal(t, "test data", string(b))
+
+	tests := []struct {
+		name     string
+		repo     Repository
+		spec     string
+		opts     *Options
+		expected error
+	}{
+		{
+			name:     "error extract",
+			repo:     fakeRepository(&mockSuccess{}),
+			spec:     "owner",
+			opts:     &Options{Log: io.Discard},
+			expected: formatError("missing owner or repository", compactFormat),
+		},
+		{
+			name:     "error archive",
+			repo:     fakeRepository(&mockSuccess{}),
+			spec:     "owner/repo",
+			opts:     &Options{Log: io.Discard, Archive: "out.rar"},
+			expected: ErrNotValidArchive,
+		},
+		{
+			name:     "error resolve",
+			repo:     fakeRepository(&mockError{}),
+			spec:     "owner/repo",
+			opts:     &Options{Log: io.Discard},
+			expected: fmt.Errorf("failed to resolve release: %w", errMockRelease),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			res, err := fakeNew(test.repo).Release(context.Background(), test.spec, test.opts)
+			assert.Equal(t, test.expected, err)
+			assert.Nil(t, res)
+		})
+	}
+}
+
+func TestReleaseDownloadError(t *testing.T) {
+	t.Parallel()
+	g := fakeNew(fakeRepository(&mockAssetError{}))
+	res, err := g.Release(context.Background(), "owner/repo", &Options{Log: io.Discard, Verify: verifyOff})
+	assert.Equal(t, fmt.Errorf("failed to download: %w", errMockAsset), err)
+	require.NotNil(t, res)
+	assert.Equal(t, []string{"owner/repo"}, res.URLs)
+	assert.Positive(t, res.Summary.Failures)
+}
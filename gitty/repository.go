@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
@@ -18,57 +21,305 @@ const (
 	// downloadLimit represents the number of seconds limited per download request.
 	// If it takes more than downloadLimit seconds, it returns ErrTookTooLong.
 	downloadLimit = 60
+	// maxRetries represents the number of retries for a failed file download.
+	maxRetries = 2
+	// retryDelay represents the base delay between retries.
+	retryDelay = 100 * time.Millisecond
 )
 
 var (
 	ErrTookTooLong    = errors.New("took more than 60 seconds to download contents")
 	ErrInvalidPathURL = errors.New("invalid url or path")
+	ErrBadStatus      = errors.New("unexpected response status")
+	ErrNotFile        = errors.New("url must point to a file")
+	ErrNotFound       = errors.New("path not found")
+)
+
+// Tree entry attributes.
+const (
+	entryBlob   = "blob"
+	entryTree   = "tree"
+	entryCommit = "commit"
+	modeSymlink = "120000"
 )
 
 // Repository defines methods for interacting with GitHub.
 type Repository interface {
+	configure(opts *Options) error
+	fork() Repository
 	extract(url string) error
+	resolve(ctx context.Context) error
+	resolveRelease(ctx context.Context) error
+	resolveArtifact(ctx context.Context) error
+	install(ctx context.Context) (*Install, error)
+	ref() string
+	refName() string
+	dest() string
 	download(ctx context.Context) error
+	result() *DownloadResult
+	close(files []*File, ok bool) error
+	collect(ctx context.Context, wg *sync.WaitGroup, errCh chan error)
 	contents(ctx context.Context, wg *sync.WaitGroup, path string, errCh chan error)
-	getFile(url, path string) error
-	status(ctx context.Context) error
-	auth(ctx context.Context) error
+	getFile(ctx context.Context, url, path string) (*File, error)
+	cat(ctx context.Context, w io.Writer, lines lineRange) error
+	status(ctx context.Context) (*StatusResult, error)
+	auth(ctx context.Context) (*AuthResult, error)
 }
 
 // Ensure GitHub implements the Repository interface.
 var _ Repository = (*GitHub)(nil)
 
-// repository creates a GitHub repository with default values.
-func repository(c *github.Client) Repository {
+// repository creates a GitHub repository with default values, which uses
+// the client of the default host.
+func repository(h *hosts) Repository {
 	return &GitHub{
-		Client: &service{
-			client: c,
-		},
-		Owner: "",
-		Repo:  "",
-		Ref:   nil,
-		Path:  "",
+		Client: h.client(""),
+		Owner:  "",
+		Repo:   "",
+		Ref:    nil,
+		Path:   "",
+		hosts:  h,
 	}
 }
 
-// extract parses a GitHub URL and extracts the owner, repository name, reference,
-// and path from it. It sets these values in the GitHub struct.
+// configure sets the options and starts a new run. It fails if the hosts
+// are not valid.
+func (g *GitHub) configure(opts *Options) error {
+	if g.hosts != nil && g.hosts.err != nil {
+		return g.hosts.err
+	}
+	if err := opts.validateFilters(); err != nil {
+		return err
+	}
+	g.opts = opts
+	g.engine = newEngine(opts.concurrency())
+	if opts == nil || opts.Archive == "" {
+		return nil
+	}
+
+	a, err := newArchive(opts.Archive, opts.ArchiveFormat, opts.stdout())
+	if err != nil {
+		return err
+	}
+	g.engine.archive = a
+
+	return nil
+}
+
+// fork creates a repository which shares the client, the hosts, the
+// options, and the run with g. It is a source of files of the archive, if
+// any.
+func (g *GitHub) fork() Repository {
+	if g.engine != nil && g.engine.archive != nil {
+		g.engine.archive.source()
+	}
+	return &GitHub{
+		Client: g.Client,
+		hosts:  g.hosts,
+		opts:   g.opts,
+		engine: g.engine,
+	}
+}
+
+// extract parses a GitHub URL or a compact spec and extracts the owner,
+// repository name, reference, and path from it. It sets these values in
+// the GitHub struct, and picks the client or the provider of the host of
+// the URL.
 func (g *GitHub) extract(url string) error {
-	s, err := getGitHubRepo(url)
+	s, err := parseSpec(url, g.hosts)
 	if err != nil {
 		return err
 	}
 
-	sep := "/"
-	strs := strings.Split(s, sep)
-	g.Owner = strs[0]
-	g.Repo = strs[1]
-	g.Ref = &github.RepositoryContentGetOptions{Ref: strs[3]}
-	g.Path = strings.Join(strs[4:], sep)
+	g.host = s.host
+	g.provider = g.hosts.provider(s.host)
+	if g.hosts != nil && g.provider == nil {
+		g.Client = g.hosts.client(s.host)
+	}
+	g.Owner = s.owner
+	g.Repo = s.repo
+	g.Ref = nil
+	g.Path = s.path
+	g.refPath = s.refPath
+	g.name = ""
+	g.pull = s.pull
+	g.since = s.since
+	g.gist = s.gist
+	g.file = s.file
+	// The reference is looked up later if the URL has none.
+	if s.ref != "" {
+		g.Ref = &github.RepositoryContentGetOptions{Ref: s.ref}
+	}
+	if ref, path, _ := strings.Cut(s.refPath, "/"); ref != "" {
+		g.Ref = &github.RepositoryContentGetOptions{Ref: ref}
+		g.Path = path
+	}
+	if g.opts != nil && g.opts.Ref != "" {
+		g.Ref = &github.RepositoryContentGetOptions{Ref: g.opts.Ref}
+	}
+	if g.opts != nil && g.opts.Path != "" {
+		g.Path = strings.Trim(g.opts.Path, "/")
+	}
+	if g.opts != nil && g.opts.Since != "" {
+		g.since = g.opts.Since
+	}
+
+	return nil
+}
+
+// resolve resolves the reference of the URL, at the time of opts.At if set,
+// and pins it to its commit SHA. It resolves the reference to download the
+// changes since, if any. Gists are resolved to their files.
+func (g *GitHub) resolve(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if g.gist != "" {
+		return g.resolveGist(ctx)
+	}
+	if err := g.resolveRef(ctx); err != nil {
+		return err
+	}
+	if err := g.resolveAt(ctx); err != nil {
+		return err
+	}
+	if err := g.pin(ctx); err != nil {
+		return err
+	}
+	return g.resolveSince(ctx)
+}
+
+// pin pins the reference to its commit SHA, so every listing and file of
+// the download comes from the same commit, even if the branch moves during
+// the download. Commit SHAs are used as is.
+func (g *GitHub) pin(ctx context.Context) error {
+	ref := g.ref()
+	if isSHA(ref) {
+		return nil
+	}
+	sha, err := g.engine.commitSHA(ctx, g.source(), g.Owner, g.Repo, ref)
+	if err != nil {
+		return fmt.Errorf("failed to pin ref: %w", err)
+	}
+	g.name = ref
+	g.Ref = &github.RepositoryContentGetOptions{Ref: sha}
+
+	return nil
+}
+
+// resolveRef resolves the reference of the URL. If the URL has no reference,
+// it uses the default branch of the repository. Pull requests are resolved
+// to their head SHA, or to their merge ref. Version constraints, like ^1.4,
+// are resolved to the highest tag which satisfies them.
+//
+// If the reference may contain slashes, it splits the reference and the path
+// of the URL. It tries successively longer prefixes against the branches and
+// tags of the repository, and chooses the longest match. Full commit SHAs are
+// used as is. If nothing matches, the first segment is kept as the reference.
+func (g *GitHub) resolveRef(ctx context.Context) error {
+	if g.pull != 0 {
+		return g.resolvePull(ctx)
+	}
+	if g.ref() == "" {
+		branch, err := g.engine.defaultBranch(ctx, g.source(), g.Owner, g.Repo)
+		if err != nil {
+			return fmt.Errorf("failed to resolve ref: %w", err)
+		}
+		g.Ref = &github.RepositoryContentGetOptions{Ref: branch}
+		return nil
+	}
+	if isConstraint(g.ref()) {
+		return g.resolveVersion(ctx)
+	}
+
+	first, _, ambiguous := strings.Cut(g.refPath, "/")
+	if !ambiguous || isSHA(first) {
+		return nil
+	}
+
+	refs, err := g.engine.matchingRefs(ctx, g.source(), g.Owner, g.Repo, first)
+	if err != nil {
+		return fmt.Errorf("failed to resolve ref: %w", err)
+	}
+
+	ref, path := longestRef(g.refPath, refs)
+	if ref == "" {
+		return nil
+	}
+	if g.opts == nil || g.opts.Path == "" {
+		g.Path = path
+	}
+	if g.opts == nil || g.opts.Ref == "" {
+		g.Ref = &github.RepositoryContentGetOptions{Ref: ref}
+	}
 
 	return nil
 }
 
+// rawURL returns the raw download URL of the file at the given reference
+// on the host of the repository.
+func (g *GitHub) rawURL(ref, path string) string {
+	return g.source().rawURL(g.Owner, g.Repo, ref, path)
+}
+
+// token returns the token of the host of the repository, if any.
+func (g *GitHub) token() string {
+	if g.hosts == nil {
+		return token.Get()
+	}
+	return g.hosts.token(g.host)
+}
+
+// ref returns the reference name, if any.
+func (g *GitHub) ref() string {
+	if g.Ref == nil {
+		return ""
+	}
+	return g.Ref.Ref
+}
+
+// refName returns the reference which was pinned to the commit SHA of ref,
+// if any. Otherwise, it returns ref.
+func (g *GitHub) refName() string {
+	if g.name != "" {
+		return g.name
+	}
+	return g.ref()
+}
+
+// dest returns the local destination path of the download. The root of
+// the repository is saved into a directory named after the repository,
+// and the files of a pull request, a gist, a release or an artifact into
+// a directory named after it.
+func (g *GitHub) dest() string {
+	if g.artifact != nil {
+		return fmt.Sprintf("%s-%s", g.Repo, g.artifact.GetName())
+	}
+	if g.assets != nil {
+		return fmt.Sprintf("%s-%s", g.Repo, g.ref())
+	}
+	if g.gist != "" {
+		return g.gist
+	}
+	if g.pull != 0 {
+		return fmt.Sprintf("%s-pull-%d", g.Repo, g.pull)
+	}
+	if g.Path == "" {
+		return g.Repo
+	}
+	return filepath.ToSlash(filepath.Base(g.Path))
+}
+
+// target returns the base and the local path to save the file at the
+// remote path.
+func (g *GitHub) target(path string) (string, string) {
+	if g.pull == 0 && g.Path != "" {
+		return g.Path, path
+	}
+	base := g.dest()
+	return base, filepath.Join(base, g.side, path)
+}
+
 // download downloads the contents concurrently.
 func (g *GitHub) download(ctx context.Context) error {
 	wg := &sync.WaitGroup{}
@@ -76,8 +327,28 @@ func (g *GitHub) download(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, downloadLimit*time.Second)
 	defer cancel()
 
+	// Only the tree and the comparison list their files upfront.
+	if g.artifact != nil || g.assets != nil || g.gist != "" || g.pull != 0 {
+		if err := g.expect(nil); err != nil {
+			return err
+		}
+	}
+
 	wg.Add(1)
-	go g.contents(ctx, wg, g.Path, errCh)
+	switch {
+	case g.artifact != nil:
+		go g.collectArtifact(ctx, wg, errCh)
+	case g.assets != nil:
+		go g.collectRelease(ctx, wg, errCh)
+	case g.gist != "":
+		go g.collectGist(ctx, wg, errCh)
+	case g.pull != 0:
+		go g.collectPull(ctx, wg, errCh)
+	case g.since != "":
+		go g.collectChanges(ctx, wg, errCh)
+	default:
+		go g.collect(ctx, wg, errCh)
+	}
 
 	go func() {
 		defer func() {
@@ -101,12 +372,141 @@ func (g *GitHub) download(ctx context.Context) error {
 	return nil
 }
 
+// result returns the result of the run so far.
+func (g *GitHub) result() *DownloadResult {
+	return g.engine.stats.result()
+}
+
+// expect lists the files of g into the archive, if any, by their remote
+// paths and git modes. Nil paths mean that the files are not known upfront.
+func (g *GitHub) expect(paths map[string]string) error {
+	if g.engine.archive == nil {
+		return nil
+	}
+	if paths == nil {
+		return g.engine.archive.expect(nil)
+	}
+
+	entries := make(map[string]string, len(paths))
+	for path, mode := range paths {
+		p, err := exactPath(g.target(path))
+		if err != nil {
+			return err
+		}
+		entries[filepath.ToSlash(p)] = mode
+	}
+
+	return g.engine.archive.expect(entries)
+}
+
+// close finishes the run. If the downloads are written into an archive,
+// it writes the archive of the files if ok, and discards it otherwise.
+func (g *GitHub) close(files []*File, ok bool) error {
+	if g.engine.archive == nil {
+		return nil
+	}
+	if !ok {
+		return g.engine.archive.discard()
+	}
+	return g.engine.archive.close(files)
+}
+
+// collect downloads the files under the path concurrently. The tree of the
+// reference is listed once per run. If the listing is truncated, it collects
+// the contents per directory instead.
+func (g *GitHub) collect(ctx context.Context, wg *sync.WaitGroup, errCh chan error) {
+	defer wg.Done()
+
+	tree, err := g.engine.tree(ctx, g.source(), g.Owner, g.Repo, g.ref())
+	if err != nil {
+		errCh <- err
+		return
+	}
+
+	if tree.truncated {
+		if err := g.expect(nil); err != nil {
+			errCh <- err
+			return
+		}
+		wg.Add(1)
+		go g.contents(ctx, wg, g.Path, errCh)
+		return
+	}
+
+	entries := matchEntries(tree.entries, g.Path)
+	if len(entries) == 0 {
+		errCh <- fmt.Errorf("%w: %s", ErrNotFound, g.Path)
+		return
+	}
+	entries = g.filter(entries)
+
+	blobs := make(map[string]string)
+	for _, entry := range entries {
+		if entry.typ == entryBlob && entry.mode != modeSymlink {
+			blobs[entry.path] = entry.mode
+		}
+	}
+	if err := g.expect(blobs); err != nil {
+		errCh <- err
+		return
+	}
+	if err := g.prefetch(ctx, entries); err != nil {
+		errCh <- err
+		return
+	}
+
+	for _, entry := range entries {
+		switch {
+		case entry.typ == entryTree:
+			g.engine.stats.dir()
+		case entry.typ == entryCommit:
+			g.engine.stats.skip(typeSubmodule)
+		case entry.mode == modeSymlink:
+			g.engine.stats.skip(typeSymlink)
+		case entry.typ == entryBlob:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				url := g.rawURL(g.ref(), entry.path)
+				if err := g.fetch(ctx, url, entry.path, entry.sha, entry.mode); err != nil {
+					errCh <- err
+				}
+			}()
+		}
+	}
+}
+
+// prefetch gets the blobs of the entries in one request, if the host can.
+func (g *GitHub) prefetch(ctx context.Context, entries []*treeEntry) error {
+	p, ok := g.source().(prefetcher)
+	if !ok {
+		return nil
+	}
+	if err := g.engine.budget(); err != nil {
+		return err
+	}
+	resp, err := p.prefetch(ctx, g.Owner, g.Repo, entries)
+	g.engine.stats.reply(resp)
+
+	return err
+}
+
 // contents retrieves the contents of the GitHub directory path. It recursively
 // collects subdirectories, if any. It downloads files concurrently.
 func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string, errCh chan error) {
 	defer wg.Done()
 
-	fileContent, directoryContent, _, err := g.Client.GetContents(ctx, g.Owner, g.Repo, path, g.Ref)
+	if err := g.engine.budget(); err != nil {
+		errCh <- err
+		return
+	}
+	if err := g.engine.acquire(ctx); err != nil {
+		errCh <- err
+		return
+	}
+	fileContent, directoryContent, resp, err := g.Client.GetContents(ctx, g.Owner, g.Repo, path, g.Ref)
+	g.engine.release()
+	g.engine.stats.call(resp)
 	if err != nil {
 		errCh <- err
 		return
@@ -114,7 +514,7 @@ func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string,
 
 	// If the URL points to a file, only the file is downloaded.
 	if len(directoryContent) == 0 && fileContent != nil {
-		if err := g.getFile(fileContent.GetDownloadURL(), fileContent.GetPath()); err != nil {
+		if err := g.fetch(ctx, fileContent.GetDownloadURL(), fileContent.GetPath(), fileContent.GetSHA(), ""); err != nil {
 			errCh <- err
 			return
 		}
@@ -122,6 +522,7 @@ func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string,
 	}
 
 	// Collect all subcontents of subdirectories.
+	g.engine.stats.dir()
 	for _, content := range directoryContent {
 		wg.Add(1)
 		go func(content *github.RepositoryContent) {
@@ -129,7 +530,7 @@ func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string,
 			switch content.GetType() {
 			case "file":
 				// Download the file directly.
-				if err := g.getFile(content.GetDownloadURL(), content.GetPath()); err != nil {
+				if err := g.fetch(ctx, content.GetDownloadURL(), content.GetPath(), content.GetSHA(), ""); err != nil {
 					errCh <- err
 					return
 				}
@@ -137,62 +538,175 @@ func (g *GitHub) contents(ctx context.Context, wg *sync.WaitGroup, path string,
 				// Recursively get the files of the content.
 				wg.Add(1)
 				go g.contents(ctx, wg, content.GetPath(), errCh)
+			case typeSubmodule, typeSymlink:
+				g.engine.stats.skip(content.GetType())
 			}
 		}(content)
 	}
 }
 
+// fetch downloads the file from the given URL and records it with its
+// sha and git mode, if known. The file is skipped if it is filtered out.
+func (g *GitHub) fetch(ctx context.Context, url, path, sha, mode string) error {
+	if g.opts.filtered(path) {
+		g.engine.stats.skip(typeFiltered)
+		return nil
+	}
+	if err := g.engine.acquire(ctx); err != nil {
+		return err
+	}
+	defer g.engine.release()
+
+	f, err := g.getFile(ctx, url, path)
+	if err != nil {
+		g.engine.stats.fail()
+		return err
+	}
+	f.SHA = sha
+	f.Mode = mode
+	g.engine.stats.file(f)
+
+	return nil
+}
+
 // getFile retrieves a file from the given URL and saves it.
-func (g *GitHub) getFile(url, path string) error {
+func (g *GitHub) getFile(ctx context.Context, url, path string) (*File, error) {
 	if url == "" || path == "" {
+		return nil, ErrInvalidPathURL
+	}
+	fmt.Fprintln(g.opts.log(), "Downloading:", path)
+	start := time.Now()
+
+	resp, err := g.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Responses which are not read from the wire leave the length at zero,
+	// so it is trusted only if positive.
+	size := resp.ContentLength
+	if size <= 0 {
+		size = -1
+	}
+	return g.store(url, path, resp.Body, size, start)
+}
+
+// store saves the body of the file at the remote path, which was
+// downloaded from the URL since start. The size of the body is -1 if unknown.
+func (g *GitHub) store(url, path string, body io.Reader, size int64, start time.Time) (*File, error) {
+	base, local := g.target(path)
+	p, n, err := g.engine.save(base, local, body, size)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(g.opts.log(), "Saving:", p)
+
+	return &File{
+		LocalPath:  p,
+		RemotePath: path,
+		Size:       n,
+		URL:        url,
+		Duration:   time.Since(start),
+	}, nil
+}
+
+// cat writes the raw content of the file to w. It writes only the given
+// range of lines, if any.
+func (g *GitHub) cat(ctx context.Context, w io.Writer, lines lineRange) error {
+	ctx, cancel := context.WithTimeout(ctx, downloadLimit*time.Second)
+	defer cancel()
+
+	if g.gist != "" {
+		return g.catGist(ctx, w, lines)
+	}
+	url, resp, err := g.source().file(ctx, g.Owner, g.Repo, g.ref(), g.Path)
+	g.engine.stats.reply(resp)
+	if errors.Is(err, ErrNotFile) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to cat: %w", err)
+	}
+	if url == "" {
 		return ErrInvalidPathURL
 	}
-	fmt.Println("Downloading:", path)
 
-	resp, err := g.Client.Get(url)
+	raw, err := g.get(ctx, url)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
+	defer raw.Body.Close()
+
+	return copyLines(w, raw.Body, lines)
+}
+
+// get issues a GET to the specified URL. It retries on transient failures
+// and returns an error if the response status is not OK.
+func (g *GitHub) get(ctx context.Context, url string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	for attempt := 0; ; attempt++ {
+		resp, err = g.source().get(ctx, url)
+		transient := err != nil || resp.StatusCode >= http.StatusInternalServerError
+		if !transient || attempt == maxRetries {
+			break
+		}
+		if err == nil {
+			resp.Body.Close()
+		}
+		g.engine.stats.retry()
+		time.Sleep(time.Duration(attempt+1) * retryDelay)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrBadStatus, resp.Status)
+	}
 
-	return saveFile(g.Path, path, resp.Body)
+	return resp, nil
 }
 
 // status reports the status of the client, the remaining hourly
 // rate limit, and the time at which the current rate limit will reset.
 // This function does not reduce the rate limit. It can be used freely.
-func (g *GitHub) status(ctx context.Context) error {
+func (g *GitHub) status(ctx context.Context) (*StatusResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	if g.hosts != nil && g.hosts.err != nil {
+		return nil, g.hosts.err
+	}
 	rate, _, err := g.Client.RateLimit(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to check status: %w", err)
-	}
-
-	auth := "NOT Authorized"
-	if token.Get() != "" && rate.Core.Limit > baseRateLimit {
-		auth = "Authorized"
+		return nil, fmt.Errorf("failed to check status: %w", err)
 	}
 
-	reset := time.Until(rate.Core.Reset.Time).Minutes()
-	fmt.Printf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins \n", auth, rate.Core.Remaining, reset)
-
-	return nil
+	return &StatusResult{
+		Authorized: g.token() != "" && rate.GetCore().Limit > baseRateLimit,
+		RateLimits: rate,
+	}, nil
 }
 
-// auth reports the authenticated username, if applicable.
+// auth reports the authenticated user, if applicable.
 // This function reduces the rate limit for each request.
-func (g *GitHub) auth(ctx context.Context) error {
+func (g *GitHub) auth(ctx context.Context) (*AuthResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
+	if g.hosts != nil && g.hosts.err != nil {
+		return nil, g.hosts.err
+	}
 	u, _, err := g.Client.GetUser(ctx, "")
 	if err != nil {
-		return fmt.Errorf("failed to check auth: %w", err)
+		return nil, fmt.Errorf("failed to check auth: %w", err)
 	}
 
-	fmt.Printf("Authenticated as @%s \n", u.GetLogin())
-
-	return nil
+	return &AuthResult{
+		Login: u.GetLogin(),
+		ID:    u.GetID(),
+	}, nil
 }
@@ -1,13 +1,18 @@
 package gitty
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -23,6 +28,18 @@ var (
 	errMockGet       = errors.New("mock get error")
 	errMockContents  = errors.New("mock contents error")
 	errMockGetUser   = errors.New("mock getuser error")
+	errMockTree      = errors.New("mock tree error")
+	errMockRefs      = errors.New("mock refs error")
+	errMockCommit    = errors.New("mock commit error")
+	errMockRepo      = errors.New("mock repository error")
+	errMockPull      = errors.New("mock pull request error")
+	errMockCompare   = errors.New("mock compare error")
+	errMockCommits   = errors.New("mock commits error")
+	errMockGist      = errors.New("mock gist error")
+	errMockRelease   = errors.New("mock release error")
+	errMockAsset     = errors.New("mock asset error")
+	errMockRuns      = errors.New("mock workflow runs error")
+	errMockArtifact  = errors.New("mock artifact error")
 )
 
 type mockSuccess struct{}
@@ -31,9 +48,28 @@ type mockError struct{}
 
 type mockClient interface {
 	Get(url string) (resp *http.Response, err error)
+	Do(req *http.Request) (*http.Response, error)
 	GetContents(ctx context.Context, owner, repo, path string, opts *github.RepositoryContentGetOptions) (fileContent *github.RepositoryContent, directoryContent []*github.RepositoryContent, resp *github.Response, err error)
 	RateLimit(ctx context.Context) (*github.RateLimits, *github.Response, error)
 	GetUser(ctx context.Context, user string) (*github.User, *github.Response, error)
+	GetTree(ctx context.Context, owner string, repo string, sha string, recursive bool) (*github.Tree, *github.Response, error)
+	ListMatchingRefs(ctx context.Context, owner, repo string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error)
+	GetCommitSHA1(ctx context.Context, owner, repo, ref, lastSHA string) (string, *github.Response, error)
+	GetRepository(ctx context.Context, owner, repo string) (*github.Repository, *github.Response, error)
+	GetPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
+	ListPullRequestFiles(ctx context.Context, owner string, repo string, number int, opts *github.ListOptions) ([]*github.CommitFile, *github.Response, error)
+	CompareCommits(ctx context.Context, owner, repo string, base, head string, opts *github.ListOptions) (*github.CommitsComparison, *github.Response, error)
+	ListCommits(ctx context.Context, owner, repo string, opts *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error)
+	GetGist(ctx context.Context, id string) (*github.Gist, *github.Response, error)
+	GetGistRevision(ctx context.Context, id, sha string) (*github.Gist, *github.Response, error)
+	GetLatestRelease(ctx context.Context, owner, repo string) (*github.RepositoryRelease, *github.Response, error)
+	GetReleaseByTag(ctx context.Context, owner, repo, tag string) (*github.RepositoryRelease, *github.Response, error)
+	ListReleases(ctx context.Context, owner, repo string, opts *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error)
+	DownloadReleaseAsset(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error)
+	ListWorkflowRunsByFileName(ctx context.Context, owner, repo, workflowFileName string, opts *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error)
+	ListWorkflowRunArtifacts(ctx context.Context, owner, repo string, runID int64, opts *github.ListOptions) (*github.ArtifactList, *github.Response, error)
+	ListArtifacts(ctx context.Context, owner, repo string, opts *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error)
+	DownloadArtifact(ctx context.Context, owner, repo string, id int64) (io.ReadCloser, error)
 }
 
 func fakeRepository(c mockClient) Repository {
@@ -43,6 +79,7 @@ func fakeRepository(c mockClient) Repository {
 		Repo:   "",
 		Ref:    nil,
 		Path:   "",
+		engine: newEngine(0),
 	}
 }
 
@@ -54,10 +91,35 @@ func (m *mockSuccess) Get(_ string) (resp *http.Response, err error) {
 	return
 }
 
+func (m *mockSuccess) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	return m.Get(req.URL.String())
+}
+
 func (m *mockError) Get(_ string) (resp *http.Response, err error) {
 	return &http.Response{}, errMockGet
 }
 
+func (m *mockError) Do(req *http.Request) (*http.Response, error) {
+	return m.Get(req.URL.String())
+}
+
+// chdir changes the working directory to a temporary directory until the
+// end of the test, since single files are saved into the working
+// directory. The test must not be parallel.
+func chdir(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		require.NoError(t, os.Chdir(wd))
+	})
+}
+
 // ptr returns a pointer to the provided value.
 func ptr[T any](t T) *T {
 	return &t
@@ -65,7 +127,12 @@ func ptr[T any](t T) *T {
 
 type contextPathKey string
 
-const pathKey contextPathKey = "fakepath"
+const (
+	pathKey  contextPathKey = "fakepath"
+	treeKey  contextPathKey = "faketree"
+	refsKey  contextPathKey = "fakerefs"
+	filesKey contextPathKey = "fakefiles"
+)
 
 // contenstsData for testing Contents.
 func contentsData(firstPath, secondPath string) []*github.RepositoryContent {
@@ -100,8 +167,284 @@ const (
 	testFileOnly     = "testFileOnly"
 	testDownloadFail = "testDownloadFail"
 	testContentFail  = "testContentFail"
+	testCatFail      = "testCatFail"
+	testTreeFail     = "testTreeFail"
+	testRefsFail     = "testRefsFail"
+	testCommitFail   = "testCommitFail"
 )
 
+// Commit SHAs of the pull request.
+const (
+	testHeadSHA = "1111111111111111111111111111111111111111"
+	testBaseSHA = "2222222222222222222222222222222222222222"
+)
+
+// treeData for testing trees.
+func treeData(base, firstPath, secondPath string) []*github.TreeEntry {
+	return []*github.TreeEntry{
+		{Type: ptr(entryTree), Path: ptr(base)},
+		{Type: ptr(entryBlob), Path: ptr(firstPath), SHA: ptr("sha1"), Mode: ptr("100644")},
+		{Type: ptr(entryBlob), Path: ptr(secondPath), SHA: ptr("sha2"), Mode: ptr("100644")},
+		{Type: ptr(entryTree), Path: ptr(base + "/sub")},
+		{Type: ptr(entryCommit), Path: ptr(base + "/sub/module")},
+		{Type: ptr(entryBlob), Path: ptr(base + "/sub/link"), Mode: ptr(modeSymlink)},
+		{Type: ptr(entryBlob), Path: ptr("other/file.txt"), Mode: ptr("100644")},
+	}
+}
+
+// GetTree returns the tree entries of the context, if any. Otherwise,
+// it returns a truncated tree, so that the contents are collected per directory.
+func (m *mockSuccess) GetTree(ctx context.Context, _, _, _ string, _ bool) (*github.Tree, *github.Response, error) {
+	entries, ok := ctx.Value(treeKey).([]*github.TreeEntry)
+	if !ok {
+		return &github.Tree{Truncated: ptr(true)}, nil, nil
+	}
+	return &github.Tree{Entries: entries, Truncated: ptr(false)}, nil, nil
+}
+
+func (m *mockError) GetTree(_ context.Context, _, _, sha string, _ bool) (*github.Tree, *github.Response, error) {
+	if sha == commitData(testTreeFail) {
+		return nil, nil, errMockTree
+	}
+	return &github.Tree{Truncated: ptr(true)}, nil, nil
+}
+
+// ListMatchingRefs returns the references of the context that match the
+// given ref, if any.
+func (m *mockSuccess) ListMatchingRefs(ctx context.Context, _, _ string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error) {
+	refs, _ := ctx.Value(refsKey).([]string)
+	var matched []*github.Reference
+	for _, ref := range refs {
+		if strings.HasPrefix(ref, "refs/"+opts.Ref) {
+			matched = append(matched, &github.Reference{Ref: ptr(ref)})
+		}
+	}
+	return matched, nil, nil
+}
+
+// commitData returns the commit SHA of the reference for testing.
+func commitData(ref string) string {
+	sum := sha1.Sum([]byte(ref))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *mockSuccess) GetCommitSHA1(_ context.Context, _, _, ref, _ string) (string, *github.Response, error) {
+	return commitData(ref), nil, nil
+}
+
+// GetCommitSHA1 fails only for the testCommitFail reference, so the errors
+// of the other calls can be tested.
+func (m *mockError) GetCommitSHA1(_ context.Context, _, _, ref, _ string) (string, *github.Response, error) {
+	if ref == testCommitFail {
+		return "", nil, errMockCommit
+	}
+	return commitData(ref), nil, nil
+}
+
+func (m *mockError) ListMatchingRefs(_ context.Context, _, _ string, opts *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error) {
+	if strings.HasSuffix(opts.Ref, "/"+testRefsFail) {
+		return nil, nil, errMockRefs
+	}
+	return nil, nil, nil
+}
+
+func (m *mockSuccess) GetRepository(_ context.Context, _, _ string) (*github.Repository, *github.Response, error) {
+	return &github.Repository{DefaultBranch: ptr("main")}, nil, nil
+}
+
+func (m *mockError) GetRepository(_ context.Context, _, _ string) (*github.Repository, *github.Response, error) {
+	return nil, nil, errMockRepo
+}
+
+func (m *mockSuccess) GetPullRequest(_ context.Context, _, _ string, _ int) (*github.PullRequest, *github.Response, error) {
+	pr := &github.PullRequest{
+		Head: &github.PullRequestBranch{SHA: ptr(testHeadSHA)},
+		Base: &github.PullRequestBranch{SHA: ptr(testBaseSHA)},
+	}
+	return pr, nil, nil
+}
+
+func (m *mockError) GetPullRequest(_ context.Context, _, _ string, _ int) (*github.PullRequest, *github.Response, error) {
+	return nil, nil, errMockPull
+}
+
+// ListPullRequestFiles returns an added, a modified, a removed, and a renamed file.
+func (m *mockSuccess) ListPullRequestFiles(_ context.Context, _, _ string, _ int, _ *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	files := []*github.CommitFile{
+		{Filename: ptr("added.go"), Status: ptr(statusAdded), SHA: ptr("sha1")},
+		{Filename: ptr("dir/modified.go"), Status: ptr("modified"), SHA: ptr("sha2")},
+		{Filename: ptr("removed.go"), Status: ptr(statusRemoved)},
+		{Filename: ptr("renamed.go"), PreviousFilename: ptr("old.go"), Status: ptr("renamed"), SHA: ptr("sha3")},
+	}
+	return files, nil, nil
+}
+
+func (m *mockError) ListPullRequestFiles(_ context.Context, _, _ string, _ int, _ *github.ListOptions) ([]*github.CommitFile, *github.Response, error) {
+	return nil, nil, errMockPull
+}
+
+// CompareCommits returns the files of the context, if any.
+func (m *mockSuccess) CompareCommits(ctx context.Context, _, _, _, _ string, _ *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	files, _ := ctx.Value(filesKey).([]*github.CommitFile)
+	return &github.CommitsComparison{Files: files}, nil, nil
+}
+
+func (m *mockError) CompareCommits(_ context.Context, _, _, _, _ string, _ *github.ListOptions) (*github.CommitsComparison, *github.Response, error) {
+	return nil, nil, errMockCompare
+}
+
+func (m *mockSuccess) ListCommits(_ context.Context, _, _ string, _ *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return []*github.RepositoryCommit{{SHA: ptr("datesha")}}, nil, nil
+}
+
+func (m *mockError) ListCommits(_ context.Context, _, _ string, _ *github.CommitsListOptions) ([]*github.RepositoryCommit, *github.Response, error) {
+	return nil, nil, errMockCommits
+}
+
+// gistData returns a gist with a complete and a truncated file.
+func gistData() *github.Gist {
+	return &github.Gist{Files: map[github.GistFilename]github.GistFile{
+		"hello_world.go": {Content: ptr("test data"), Size: ptr(9), RawURL: ptr("https://gist.githubusercontent.com/raw/hello_world.go")},
+		"large.txt":      {Content: ptr("test"), Size: ptr(1 << 20), RawURL: ptr("https://gist.githubusercontent.com/raw/large.txt")},
+	}}
+}
+
+func (m *mockSuccess) GetGist(_ context.Context, _ string) (*github.Gist, *github.Response, error) {
+	return gistData(), nil, nil
+}
+
+func (m *mockError) GetGist(_ context.Context, _ string) (*github.Gist, *github.Response, error) {
+	return nil, nil, errMockGist
+}
+
+func (m *mockSuccess) GetGistRevision(_ context.Context, _, _ string) (*github.Gist, *github.Response, error) {
+	return gistData(), nil, nil
+}
+
+func (m *mockError) GetGistRevision(_ context.Context, _, _ string) (*github.Gist, *github.Response, error) {
+	return nil, nil, errMockGist
+}
+
+// releaseData returns a release with assets for two platforms and their
+// checksums.
+func releaseData(tag string) *github.RepositoryRelease {
+	return &github.RepositoryRelease{
+		TagName: ptr(tag),
+		Assets: []*github.ReleaseAsset{
+			{ID: ptr(int64(1)), Name: ptr("gitty_linux_amd64.tar.gz")},
+			{ID: ptr(int64(2)), Name: ptr("gitty_darwin_arm64.tar.gz")},
+			{ID: ptr(int64(3)), Name: ptr("checksums.txt")},
+		},
+	}
+}
+
+func (m *mockSuccess) GetLatestRelease(_ context.Context, _, _ string) (*github.RepositoryRelease, *github.Response, error) {
+	return releaseData("v1.0.0"), nil, nil
+}
+
+func (m *mockError) GetLatestRelease(_ context.Context, _, _ string) (*github.RepositoryRelease, *github.Response, error) {
+	return nil, nil, errMockRelease
+}
+
+func (m *mockSuccess) GetReleaseByTag(_ context.Context, _, _, tag string) (*github.RepositoryRelease, *github.Response, error) {
+	return releaseData(tag), nil, nil
+}
+
+func (m *mockError) GetReleaseByTag(_ context.Context, _, _, _ string) (*github.RepositoryRelease, *github.Response, error) {
+	return nil, nil, errMockRelease
+}
+
+// ListReleases lists a draft before the newest release.
+func (m *mockSuccess) ListReleases(_ context.Context, _, _ string, _ *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error) {
+	return []*github.RepositoryRelease{{Draft: ptr(true)}, releaseData("v1.1.0-rc.1"), releaseData("v1.0.0")}, nil, nil
+}
+
+func (m *mockError) ListReleases(_ context.Context, _, _ string, _ *github.ListOptions) ([]*github.RepositoryRelease, *github.Response, error) {
+	return nil, nil, errMockRelease
+}
+
+func (m *mockSuccess) DownloadReleaseAsset(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader([]byte("test data"))), nil
+}
+
+func (m *mockError) DownloadReleaseAsset(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return nil, errMockAsset
+}
+
+// artifactTime is the creation time of the oldest artifact.
+var artifactTime = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// artifactData for testing artifacts. It is created hours after artifactTime.
+func artifactData(id int64, name, branch string, hours int, expired bool) *github.Artifact {
+	return &github.Artifact{
+		ID:                 ptr(id),
+		Name:               ptr(name),
+		Expired:            ptr(expired),
+		CreatedAt:          &github.Timestamp{Time: artifactTime.Add(time.Duration(hours) * time.Hour)},
+		ArchiveDownloadURL: ptr(fmt.Sprintf("https://api.github.com/repos/owner/repo/actions/artifacts/%d/zip", id)),
+		WorkflowRun:        &github.ArtifactWorkflowRun{HeadBranch: ptr(branch), HeadSHA: ptr(fmt.Sprintf("sha%d", id))},
+	}
+}
+
+// artifactZip returns the zip archive of an artifact, with a directory
+// entry.
+func artifactZip() []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range []string{"coverage.out", "html/", "html/index.html", "html/style.css"} {
+		w, _ := zw.Create(name)
+		if !strings.HasSuffix(name, "/") {
+			_, _ = io.WriteString(w, name)
+		}
+	}
+	_ = zw.Close()
+	return buf.Bytes()
+}
+
+// ListWorkflowRunsByFileName lists a run without the coverage artifact
+// before an older run with it.
+func (m *mockSuccess) ListWorkflowRunsByFileName(_ context.Context, _, _, _ string, _ *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	return &github.WorkflowRuns{WorkflowRuns: []*github.WorkflowRun{{ID: ptr(int64(2))}, {ID: ptr(int64(1))}}}, nil, nil
+}
+
+func (m *mockError) ListWorkflowRunsByFileName(_ context.Context, _, _, _ string, _ *github.ListWorkflowRunsOptions) (*github.WorkflowRuns, *github.Response, error) {
+	return nil, nil, errMockRuns
+}
+
+func (m *mockSuccess) ListWorkflowRunArtifacts(_ context.Context, _, _ string, runID int64, _ *github.ListOptions) (*github.ArtifactList, *github.Response, error) {
+	if runID == 2 {
+		return &github.ArtifactList{Artifacts: []*github.Artifact{artifactData(21, "logs", "main", 3, false)}}, nil, nil
+	}
+	return &github.ArtifactList{Artifacts: []*github.Artifact{
+		artifactData(11, "coverage", "main", 0, false),
+		artifactData(12, "coverage", "main", 1, true),
+	}}, nil, nil
+}
+
+func (m *mockError) ListWorkflowRunArtifacts(_ context.Context, _, _ string, _ int64, _ *github.ListOptions) (*github.ArtifactList, *github.Response, error) {
+	return nil, nil, errMockArtifact
+}
+
+func (m *mockSuccess) ListArtifacts(_ context.Context, _, _ string, _ *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	return &github.ArtifactList{Artifacts: []*github.Artifact{
+		artifactData(31, "coverage", "feature", 2, false),
+		artifactData(21, "logs", "main", 3, false),
+		artifactData(11, "coverage", "main", 0, false),
+	}}, nil, nil
+}
+
+func (m *mockError) ListArtifacts(_ context.Context, _, _ string, _ *github.ListArtifactsOptions) (*github.ArtifactList, *github.Response, error) {
+	return nil, nil, errMockArtifact
+}
+
+func (m *mockSuccess) DownloadArtifact(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(artifactZip())), nil
+}
+
+func (m *mockError) DownloadArtifact(_ context.Context, _, _ string, _ int64) (io.ReadCloser, error) {
+	return nil, errMockArtifact
+}
+
 func (m *mockSuccess) GetContents(ctx context.Context, _, _, path string, _ *github.RepositoryContentGetOptions) (fileContent *github.RepositoryContent, directoryContent []*github.RepositoryContent, resp *github.Response, err error) {
 	fakeData, ok := ctx.Value(pathKey).([]*github.RepositoryContent)
 	if !ok {
@@ -125,6 +468,9 @@ func (m *mockError) GetContents(ctx context.Context, _, _, path string, _ *githu
 	if path == testContentFail {
 		return nil, testDownloadFailData(), nil, nil
 	}
+	if path == testCatFail {
+		return contentsData("tmp/file_0", "tmp/file_1")[0], nil, nil, nil
+	}
 	if ctx.Err() != nil {
 		if errors.Is(ctx.Err(), context.Canceled) {
 			return nil, nil, nil, context.Canceled
@@ -162,16 +508,16 @@ func (m *mockError) GetUser(_ context.Context, _ string) (*github.User, *github.
 
 func TestRepository(t *testing.T) {
 	t.Parallel()
-	c := github.NewClient(nil)
-	actual := repository(c)
+	c := &service{client: github.NewClient(nil)}
+	h := &hosts{def: hostGitHub, github: c}
+	actual := repository(h)
 	expected := &GitHub{
-		Client: &service{
-			client: c,
-		},
-		Owner: "",
-		Repo:  "",
-		Ref:   nil,
-		Path:  "",
+		Client: c,
+		Owner:  "",
+		Repo:   "",
+		Ref:    nil,
+		Path:   "",
+		hosts:  h,
 	}
 	assert.Equal(t, expected, actual)
 }
@@ -217,27 +563,71 @@ func TestExtract(t *testing.T) {
 			},
 			expectedErr: nil,
 		},
+		{
+			name: "valid repository url",
+			url:  "https://github.com/owner/repo",
+			expected: &GitHub{
+				Owner: "owner",
+				Repo:  "repo",
+				Ref:   nil,
+				Path:  "",
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "valid tree url",
+			url:  "github.com/owner/repo/tree/branch/",
+			expected: &GitHub{
+				Owner: "owner",
+				Repo:  "repo",
+				Ref:   &github.RepositoryContentGetOptions{Ref: "branch"},
+				Path:  "",
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "valid raw url",
+			url:  "https://raw.githubusercontent.com/owner/repo/branch/directory/file.txt",
+			expected: &GitHub{
+				Owner: "owner",
+				Repo:  "repo",
+				Ref:   &github.RepositoryContentGetOptions{Ref: "branch"},
+				Path:  "directory/file.txt",
+			},
+			expectedErr: nil,
+		},
+		{
+			name: "valid api url",
+			url:  "https://api.github.com/repos/owner/repo/contents/my%20directory?ref=feature/x",
+			expected: &GitHub{
+				Owner: "owner",
+				Repo:  "repo",
+				Ref:   &github.RepositoryContentGetOptions{Ref: "feature/x"},
+				Path:  "my directory",
+			},
+			expectedErr: nil,
+		},
 		{
 			name: "invalid https url",
-			url:  "https://gitlab.com/owner/repo/tree/branch/directory",
+			url:  "https://git.example.com/owner/repo/tree/branch/directory",
 			expected: &GitHub{
 				Owner: "",
 				Repo:  "",
 				Ref:   nil,
 				Path:  "",
 			},
-			expectedErr: ErrNotValidURL,
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
 		},
 		{
 			name: "invalid url",
-			url:  "gitlab.com/owner/repo/tree/branch/directory",
+			url:  "git.example.com/owner/repo/tree/branch/directory",
 			expected: &GitHub{
 				Owner: "",
 				Repo:  "",
 				Ref:   nil,
 				Path:  "",
 			},
-			expectedErr: ErrNotValidURL,
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
 		},
 	}
 
@@ -256,6 +646,234 @@ func TestExtract(t *testing.T) {
 	}
 }
 
+func TestExtractRef(t *testing.T) {
+	t.Parallel()
+	r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
+	require.True(t, ok)
+	err := r.configure(&Options{Ref: "v1.0.0"})
+	require.NoError(t, err)
+
+	err = r.extract("github.com/owner/repo/blob/main/file.go")
+	require.NoError(t, err)
+	assert.Equal(t, &github.RepositoryContentGetOptions{Ref: "v1.0.0"}, r.Ref)
+	assert.Equal(t, "file.go", r.Path)
+}
+
+func TestExtractClone(t *testing.T) {
+	t.Parallel()
+	r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
+	require.True(t, ok)
+	err := r.configure(&Options{Ref: "v1.0.0", Path: "/docs/"})
+	require.NoError(t, err)
+
+	err = r.extract("git@github.com:owner/repo.git")
+	require.NoError(t, err)
+	assert.Equal(t, "owner", r.Owner)
+	assert.Equal(t, "repo", r.Repo)
+	assert.Equal(t, &github.RepositoryContentGetOptions{Ref: "v1.0.0"}, r.Ref)
+	assert.Equal(t, "docs", r.Path)
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+	sha := strings.Repeat("a1", 20)
+	ctx := context.WithValue(context.Background(), refsKey, []string{
+		"refs/heads/feature",
+		"refs/heads/feature/new-api",
+		"refs/heads/featured",
+		"refs/tags/release/v1",
+	})
+
+	tests := []struct {
+		name         string
+		client       mockClient
+		url          string
+		opts         *Options
+		expectedRef  string
+		expectedPath string
+		expectedErr  error
+	}{
+		{
+			name:         "ref without slashes",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/main/docs",
+			expectedRef:  "main",
+			expectedPath: "docs",
+		},
+		{
+			name:         "longest branch",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/feature/new-api/docs",
+			expectedRef:  "feature/new-api",
+			expectedPath: "docs",
+		},
+		{
+			name:         "shorter branch",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/feature/other/docs",
+			expectedRef:  "feature",
+			expectedPath: "other/docs",
+		},
+		{
+			name:         "branch only",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/feature/new-api",
+			expectedRef:  "feature/new-api",
+			expectedPath: "",
+		},
+		{
+			name:         "tag",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/blob/release/v1/file.go",
+			expectedRef:  "release/v1",
+			expectedPath: "file.go",
+		},
+		{
+			name:         "full sha",
+			client:       &mockError{},
+			url:          "github.com/owner/repo/tree/" + sha + "/docs",
+			expectedRef:  sha,
+			expectedPath: "docs",
+		},
+		{
+			name:         "ref override",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/feature/new-api/docs",
+			opts:         &Options{Ref: "v2"},
+			expectedRef:  "v2",
+			expectedPath: "docs",
+		},
+		{
+			name:         "path override",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/feature/new-api/docs",
+			opts:         &Options{Path: "src"},
+			expectedRef:  "feature/new-api",
+			expectedPath: "src",
+		},
+		{
+			name:         "default branch",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo",
+			expectedRef:  "main",
+			expectedPath: "",
+		},
+		{
+			name:         "default branch override",
+			client:       &mockError{},
+			url:          "github.com/owner/repo",
+			opts:         &Options{Ref: "v2"},
+			expectedRef:  "v2",
+			expectedPath: "",
+		},
+		{
+			name:        "error default branch",
+			client:      &mockError{},
+			url:         "github.com/owner/repo",
+			expectedErr: fmt.Errorf("failed to resolve ref: %w", errMockRepo),
+		},
+		{
+			name:        "error refs",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/tree/" + testRefsFail + "/docs",
+			expectedErr: fmt.Errorf("failed to resolve ref: %w", errMockRefs),
+		},
+		{
+			name:        "error commit",
+			client:      &mockError{},
+			url:         "github.com/owner/repo/tree/" + testCommitFail,
+			expectedErr: fmt.Errorf("failed to pin ref: %w", errMockCommit),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r, ok := fakeRepository(test.client).(*GitHub)
+			require.True(t, ok)
+			err := r.configure(test.opts)
+			require.NoError(t, err)
+			err = r.extract(test.url)
+			require.NoError(t, err)
+
+			err = r.resolve(ctx)
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				// The reference is pinned to its commit SHA.
+				assert.Equal(t, test.expectedRef, r.refName())
+				if !isSHA(test.expectedRef) {
+					assert.Equal(t, commitData(test.expectedRef), r.ref())
+				}
+				assert.Equal(t, test.expectedPath, r.Path)
+			}
+		})
+	}
+}
+
+func TestClientCat(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		repo        Repository
+		path        string
+		lines       lineRange
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "success cat",
+			repo:     fakeRepository(&mockSuccess{}),
+			path:     testFileOnly,
+			expected: "test data",
+		},
+		{
+			name:     "success cat lines",
+			repo:     fakeRepository(&mockSuccess{}),
+			path:     testFileOnly,
+			lines:    lineRange{start: 1, end: 1},
+			expected: "test data",
+		},
+		{
+			name:        "error directory",
+			repo:        fakeRepository(&mockSuccess{}),
+			path:        "directory",
+			expectedErr: ErrNotFile,
+		},
+		{
+			name:        "error contents",
+			repo:        fakeRepository(&mockError{}),
+			path:        "directory",
+			expectedErr: fmt.Errorf("failed to cat: %w", errMockContents),
+		},
+		{
+			name:        "error download url",
+			repo:        fakeRepository(&mockError{}),
+			path:        testDownloadFail,
+			expectedErr: ErrInvalidPathURL,
+		},
+		{
+			name:        "error get",
+			repo:        fakeRepository(&mockError{}),
+			path:        testCatFail,
+			expectedErr: errMockGet,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			r, ok := test.repo.(*GitHub)
+			require.True(t, ok)
+			r.Path = test.path
+
+			var buf bytes.Buffer
+			err := r.cat(context.Background(), &buf, test.lines)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, buf.String())
+		})
+	}
+}
+
 func TestGetFile(t *testing.T) {
 	t.Parallel()
 	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
@@ -298,8 +916,270 @@ func TestGetFile(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := test.repo.getFile(test.url, test.path)
+			f, err := test.repo.getFile(context.Background(), test.url, test.path)
 			assert.Equal(t, test.expected, err)
+			if err == nil {
+				assert.Equal(t, test.path, f.RemotePath)
+				assert.Equal(t, test.url, f.URL)
+				assert.Equal(t, int64(len("test data")), f.Size)
+			}
+		})
+	}
+}
+
+// mockStatus responds with the given status codes in order.
+type mockStatus struct {
+	mockSuccess
+	mu    sync.Mutex
+	codes []int
+}
+
+func (m *mockStatus) Get(_ string) (resp *http.Response, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := m.codes[0]
+	if len(m.codes) > 1 {
+		m.codes = m.codes[1:]
+	}
+	resp = &http.Response{
+		StatusCode: code,
+		Status:     http.StatusText(code),
+		Body:       io.NopCloser(bytes.NewReader([]byte("test data"))),
+	}
+	return
+}
+
+func (m *mockStatus) Do(req *http.Request) (*http.Response, error) {
+	return m.Get(req.URL.String())
+}
+
+func TestGetRetry(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name            string
+		client          mockClient
+		expectedRetries int
+		expectedErr     error
+	}{
+		{
+			name:            "success",
+			client:          &mockStatus{codes: []int{http.StatusOK}},
+			expectedRetries: 0,
+			expectedErr:     nil,
+		},
+		{
+			name:            "success after retries",
+			client:          &mockStatus{codes: []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusOK}},
+			expectedRetries: 2,
+			expectedErr:     nil,
+		},
+		{
+			name:            "error server",
+			client:          &mockStatus{codes: []int{http.StatusInternalServerError}},
+			expectedRetries: maxRetries,
+			expectedErr:     fmt.Errorf("%w: %s", ErrBadStatus, http.StatusText(http.StatusInternalServerError)),
+		},
+		{
+			name:            "error not found",
+			client:          &mockStatus{codes: []int{http.StatusNotFound}},
+			expectedRetries: 0,
+			expectedErr:     fmt.Errorf("%w: %s", ErrBadStatus, http.StatusText(http.StatusNotFound)),
+		},
+		{
+			name:            "error get",
+			client:          &mockError{},
+			expectedRetries: maxRetries,
+			expectedErr:     errMockGet,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, engine: newEngine(0)}
+			resp, err := g.get(context.Background(), gofakeit.URL())
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				resp.Body.Close()
+			}
+			assert.Equal(t, test.expectedRetries, g.result().Summary.Retries)
+		})
+	}
+}
+
+func TestContentsSkipped(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), pathKey, []*github.RepositoryContent{
+		{Type: ptr(typeSubmodule), Path: ptr("module")},
+		{Type: ptr(typeSymlink), Path: ptr("link")},
+		{Type: ptr("dir"), Path: ptr("dir")},
+	})
+	g := &GitHub{Client: &mockSuccess{}, engine: newEngine(0)}
+
+	err := g.download(ctx)
+	require.NoError(t, err)
+	res := g.result()
+	assert.Empty(t, res.Files)
+	assert.Equal(t, 2, res.Summary.Directories)
+	// The truncated tree and two directories.
+	assert.Equal(t, 3, res.Summary.APICalls)
+	assert.Equal(t, Skipped{Submodules: 2, Symlinks: 2}, res.Summary.Skipped)
+}
+
+func TestCollect(t *testing.T) {
+	chdir(t)
+	fakeBase := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+	ctx := context.WithValue(context.Background(), treeKey, treeData(fakeBase, fakeBase+"/a.txt", fakeBase+"/b.txt"))
+
+	tests := []struct {
+		name        string
+		path        string
+		expected    *Summary
+		expectedErr error
+	}{
+		{
+			name: "success directory",
+			path: fakeBase,
+			expected: &Summary{
+				Directories:        2,
+				APICalls:           1,
+				RateLimitRemaining: -1,
+				Skipped:            Skipped{Submodules: 1, Symlinks: 1},
+			},
+		},
+		{
+			name: "success file",
+			path: fakeBase + "/a.txt",
+			expected: &Summary{
+				APICalls:           1,
+				RateLimitRemaining: -1,
+			},
+		},
+		{
+			name:        "error not found",
+			path:        "missing",
+			expectedErr: fmt.Errorf("%w: %s", ErrNotFound, "missing"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: &mockSuccess{}, Path: test.path, engine: newEngine(0)}
+			err := g.download(ctx)
+			if test.expectedErr != nil {
+				assert.Equal(t, fmt.Errorf("failed to download: %w", test.expectedErr), err)
+				return
+			}
+			require.NoError(t, err)
+			res := g.result()
+			assert.Equal(t, test.expected.Directories, res.Summary.Directories)
+			assert.Equal(t, test.expected.APICalls, res.Summary.APICalls)
+			assert.Equal(t, test.expected.Skipped, res.Summary.Skipped)
+			for _, f := range res.Files {
+				assert.Equal(t, rawURL(rawPrefix, "", "", "", f.RemotePath), f.URL)
+				assert.NotEmpty(t, f.SHA)
+			}
+		})
+	}
+}
+
+func TestFork(t *testing.T) {
+	t.Parallel()
+	r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
+	require.True(t, ok)
+	err := r.configure(&Options{Concurrency: 2})
+	require.NoError(t, err)
+
+	f, ok := r.fork().(*GitHub)
+	require.True(t, ok)
+	assert.Equal(t, r.Client, f.Client)
+	assert.Same(t, r.opts, f.opts)
+	assert.Same(t, r.engine, f.engine)
+	assert.Equal(t, 2, cap(f.engine.sem))
+}
+
+func TestConfigure(t *testing.T) {
+	t.Parallel()
+	r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
+	require.True(t, ok)
+
+	err := r.configure(nil)
+	require.NoError(t, err)
+	assert.Nil(t, r.engine.archive)
+
+	err = r.configure(&Options{Archive: "out.zip"})
+	require.NoError(t, err)
+	assert.Equal(t, formatZip, r.engine.archive.format)
+
+	err = r.configure(&Options{Archive: "out.rar"})
+	assert.Equal(t, ErrNotValidArchive, err)
+
+	err = r.configure(&Options{Exclude: []string{"["}})
+	assert.Equal(t, fmt.Errorf("%w: %q", ErrNotValidFilter, "["), err)
+}
+
+func TestClose(t *testing.T) {
+	t.Parallel()
+	name := filepath.Join(t.TempDir(), "out.zip")
+	tests := []struct {
+		name     string
+		opts     *Options
+		ok       bool
+		expected bool
+	}{
+		{
+			name:     "without archive",
+			opts:     nil,
+			ok:       true,
+			expected: false,
+		},
+		{
+			name:     "discard archive",
+			opts:     &Options{Archive: name},
+			ok:       false,
+			expected: false,
+		},
+		{
+			name:     "write archive",
+			opts:     &Options{Archive: name},
+			ok:       true,
+			expected: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, ok := fakeRepository(&mockSuccess{}).(*GitHub)
+			require.True(t, ok)
+			err := r.configure(test.opts)
+			require.NoError(t, err)
+
+			err = r.close(nil, test.ok)
+			require.NoError(t, err)
+			_, err = os.Stat(name)
+			assert.Equal(t, test.expected, err == nil)
+		})
+	}
+}
+
+func TestDest(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "", expected: "repo"},
+		{path: "docs", expected: "docs"},
+		{path: "path/to/docs", expected: "docs"},
+		{path: "path/to/file.txt", expected: "file.txt"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Repo: "repo", Path: test.path}
+			assert.Equal(t, test.expected, g.dest())
 		})
 	}
 }
@@ -444,7 +1324,6 @@ func TestContents(t *testing.T) {
 func TestClientStatus(t *testing.T) {
 	// Must be same as token const key.
 	tokenKey := "GH_TOKEN"
-	// This test also checks print outputs.
 	tests := []struct {
 		name        string
 		repo        Repository
@@ -456,13 +1335,13 @@ func TestClientStatus(t *testing.T) {
 			name:        "success with authorized",
 			repo:        fakeRepository(&mockSuccess{}),
 			auth:        true,
-			expected:    fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins \n", "Authorized", 50, float64(60)),
+			expected:    fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins ", "Authorized", 50, float64(60)),
 			expectedErr: nil,
 		},
 		{
 			name:        "success with not authorized",
 			repo:        fakeRepository(&mockSuccess{}),
-			expected:    fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins \n", "NOT Authorized", 50, float64(60)),
+			expected:    fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins ", "NOT Authorized", 50, float64(60)),
 			expectedErr: nil,
 		},
 		{
@@ -477,26 +1356,17 @@ func TestClientStatus(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			if test.auth {
 				t.Setenv(tokenKey, gofakeit.LoremIpsumWord())
-				t.Cleanup(func() {
-					err := os.Unsetenv(tokenKey)
-					require.NoError(t, err)
-				})
+			} else {
+				t.Setenv(tokenKey, "")
 			}
-			old := os.Stdout
-			r, w, _ := os.Pipe()
-			os.Stdout = w
 
-			err := test.repo.status(context.Background())
+			res, err := test.repo.status(context.Background())
 			assert.Equal(t, test.expectedErr, err)
-
-			w.Close()
-			os.Stdout = old
-
-			var buf bytes.Buffer
-			_, err = io.Copy(&buf, r)
-			require.NoError(t, err)
-			actual := buf.String()
-			assert.Equal(t, test.expected, actual)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, test.auth, res.Authorized)
+			assert.Equal(t, test.expected, res.String())
 		})
 	}
 }
@@ -524,7 +1394,7 @@ func TestClientAuth(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			err := test.repo.auth(context.Background())
+			_, err := test.repo.auth(context.Background())
 			assert.Equal(t, test.expected, err)
 		})
 	}
@@ -0,0 +1,127 @@
+package gitty
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Headers of the REST API responses, which report the next page and the
+// rate limit, if any.
+const (
+	nextPageHeader  = "X-Next-Page"
+	limitHeader     = "RateLimit-Limit"
+	remainingHeader = "RateLimit-Remaining"
+)
+
+var ErrBadLink = errors.New("link outside the host")
+
+// authorizationHeader is the header of the credentials of the hosts which
+// use basic or bearer authentication.
+const authorizationHeader = "Authorization"
+
+// rest represents the REST API of a host other than GitHub. The token, if
+// any, is sent in the given header with the given prefix.
+type rest struct {
+	// base is the base URL of the host, like https://gitlab.com, and api is
+	// the path of its API, like /api/v4/.
+	base   string
+	api    string
+	header string
+	prefix string
+	token  string
+	client *http.Client
+}
+
+// call calls the endpoint of the API, relative to its path, and decodes
+// the JSON response into v, if it is not nil. The reply reports the next
+// page and the rate limit, if the API does.
+func (r *rest) call(ctx context.Context, method, endpoint string, query url.Values, v any) (*reply, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.base+r.api+endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawQuery = query.Encode()
+
+	return r.send(req, endpoint, v)
+}
+
+// follow calls the link of the API, like the link of the next page of a
+// list, as is, and decodes the JSON response into v. The link must be on
+// the host, which the token is sent to.
+func (r *rest) follow(ctx context.Context, link string, v any) (*reply, error) {
+	if !strings.HasPrefix(link, r.base+"/") {
+		return nil, fmt.Errorf("%w: %s", ErrBadLink, link)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.send(req, strings.TrimPrefix(link, r.base+r.api), v)
+}
+
+// send sends the request to the endpoint, and decodes the JSON response
+// into v, if it is not nil.
+func (r *rest) send(req *http.Request, endpoint string, v any) (*reply, error) {
+	hr, err := r.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer hr.Body.Close()
+
+	resp := &reply{status: hr.StatusCode, next: cursor(hr.Header.Get(nextPageHeader))}
+	resp.limit, _ = strconv.Atoi(hr.Header.Get(limitHeader))
+	resp.remaining, _ = strconv.Atoi(hr.Header.Get(remainingHeader))
+	if hr.StatusCode != http.StatusOK {
+		return resp, fmt.Errorf("%w: %s %s", ErrBadStatus, hr.Status, endpoint)
+	}
+	if v == nil {
+		return resp, nil
+	}
+	if err := json.NewDecoder(hr.Body).Decode(v); err != nil {
+		return resp, fmt.Errorf("failed to decode %s: %w", endpoint, err)
+	}
+
+	return resp, nil
+}
+
+// raw issues a GET to the URL of a raw file.
+func (r *rest) raw(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.do(req)
+}
+
+// do sends the request with the token, if any.
+func (r *rest) do(req *http.Request) (*http.Response, error) {
+	if r.token != "" {
+		req.Header.Set(r.header, r.prefix+r.token)
+	}
+	return r.client.Do(req)
+}
+
+// pathEscape escapes the segments of the path, and keeps the slashes.
+func pathEscape(path string) string {
+	u := &url.URL{Path: path}
+	return u.EscapedPath()
+}
+
+// credentials returns the prefix and the value of the Authorization header
+// of the token. A username and a password, like an app password, separated
+// by a colon, use basic authentication. Other tokens, like HTTP access
+// tokens, are bearer tokens.
+func credentials(tok string) (string, string) {
+	if strings.Contains(tok, ":") {
+		return "Basic ", base64.StdEncoding.EncodeToString([]byte(tok))
+	}
+	return "Bearer ", tok
+}
@@ -0,0 +1,30 @@
+package gitty
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentials(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name           string
+		token          string
+		expectedPrefix string
+		expectedToken  string
+	}{
+		{name: "app password", token: "user:secret", expectedPrefix: "Basic ", expectedToken: "dXNlcjpzZWNyZXQ="},
+		{name: "access token", token: "secret", expectedPrefix: "Bearer ", expectedToken: "secret"},
+		{name: "no token", token: "", expectedPrefix: "Bearer ", expectedToken: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			prefix, tok := credentials(test.token)
+			assert.Equal(t, test.expectedPrefix, prefix)
+			assert.Equal(t, test.expectedToken, tok)
+		})
+	}
+}
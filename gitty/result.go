@@ -0,0 +1,236 @@
+package gitty
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// StatusResult represents the status of the client and its rate limits.
+type StatusResult struct {
+	Authorized bool               `json:"authorized"`
+	RateLimits *github.RateLimits `json:"rate_limits"`
+}
+
+// String returns the human-readable status.
+func (s *StatusResult) String() string {
+	auth := "NOT Authorized"
+	if s.Authorized {
+		auth = "Authorized"
+	}
+
+	core := s.RateLimits.GetCore()
+	reset := time.Until(core.Reset.Time).Minutes()
+	return fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins ", auth, core.Remaining, reset)
+}
+
+// AuthResult represents the authenticated user.
+type AuthResult struct {
+	Login string `json:"login"`
+	ID    int64  `json:"id"`
+}
+
+// String returns the human-readable authenticated user.
+func (a *AuthResult) String() string {
+	return fmt.Sprintf("Authenticated as @%s ", a.Login)
+}
+
+// Install represents an executable installed from a release asset.
+type Install struct {
+	// Host is the host of the repository, like github.com or
+	// ghe.example.com. It is empty in the records of older installs, which
+	// are on the default host.
+	Host  string `json:"host,omitempty"`
+	Repo  string `json:"repo"`
+	Tag   string `json:"tag"`
+	Asset string `json:"asset"`
+	// Pattern is the glob which selected the asset, if any.
+	Pattern     string    `json:"pattern,omitempty"`
+	Path        string    `json:"path"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+// String returns the human-readable install.
+func (i *Install) String() string {
+	return fmt.Sprintf("Installed %s %s: %s", i.Repo, i.Tag, i.Path)
+}
+
+// InstalledResult represents the recorded installs.
+type InstalledResult struct {
+	Installs []*Install `json:"installs"`
+}
+
+// String returns the human-readable list of installs.
+func (r *InstalledResult) String() string {
+	if len(r.Installs) == 0 {
+		return "No installs"
+	}
+
+	lines := make([]string, 0, len(r.Installs))
+	for _, i := range r.Installs {
+		lines = append(lines, fmt.Sprintf("%s %s: %s", i.Repo, i.Tag, i.Path))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Upgrade represents an install upgraded from the previous tag.
+type Upgrade struct {
+	From string `json:"from"`
+	*Install
+}
+
+// UpgradeResult represents the upgraded installs, and the ones which were
+// already at the latest release.
+type UpgradeResult struct {
+	Upgraded []*Upgrade `json:"upgraded"`
+	UpToDate []*Install `json:"up_to_date"`
+}
+
+// String returns the human-readable upgrades.
+func (r *UpgradeResult) String() string {
+	lines := make([]string, 0, len(r.Upgraded)+len(r.UpToDate))
+	for _, u := range r.Upgraded {
+		lines = append(lines, fmt.Sprintf("Upgraded %s %s -> %s: %s", u.Repo, u.From, u.Tag, u.Path))
+	}
+	for _, i := range r.UpToDate {
+		lines = append(lines, fmt.Sprintf("Up to date %s %s", i.Repo, i.Tag))
+	}
+	if len(lines) == 0 {
+		return "No installs"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// File represents a downloaded file.
+type File struct {
+	LocalPath  string        `json:"local_path"`
+	RemotePath string        `json:"remote_path"`
+	Size       int64         `json:"size"`
+	SHA        string        `json:"sha"`
+	SHA256     string        `json:"sha256,omitempty"`
+	Mode       string        `json:"mode,omitempty"`
+	URL        string        `json:"url"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// slowestFiles represents the number of the slowest files in the summary.
+const slowestFiles = 5
+
+// Skipped represents the number of contents skipped by type.
+type Skipped struct {
+	Submodules int `json:"submodules"`
+	Symlinks   int `json:"symlinks"`
+	Filtered   int `json:"filtered"`
+}
+
+// Summary represents the details of a download. RateLimitRemaining is -1
+// if the API did not report it.
+type Summary struct {
+	Directories        int     `json:"directories"`
+	APICalls           int     `json:"api_calls"`
+	RateLimitRemaining int     `json:"rate_limit_remaining"`
+	Skipped            Skipped `json:"skipped"`
+	Retries            int     `json:"retries"`
+	Failures           int     `json:"failures"`
+	Throughput         float64 `json:"throughput_bytes_per_sec"`
+	Slowest            []*File `json:"slowest"`
+}
+
+// DownloadResult represents the result of a download. Refs holds the
+// resolved reference of each URL, and Commits the commit SHA it was
+// downloaded at. Deleted holds the local paths of the files deleted since
+// the given ref.
+type DownloadResult struct {
+	URLs       []string      `json:"urls"`
+	Refs       []string      `json:"refs"`
+	Commits    []string      `json:"commits,omitempty"`
+	Files      []*File       `json:"files"`
+	Deleted    []string      `json:"deleted"`
+	TotalFiles int           `json:"total_files"`
+	TotalBytes int64         `json:"total_bytes"`
+	Duration   time.Duration `json:"duration_ns"`
+	Summary    *Summary      `json:"summary"`
+}
+
+// summarize sorts the files and calculates the totals, the throughput,
+// and the slowest files for the given duration.
+func (d *DownloadResult) summarize(duration time.Duration) {
+	sort.Slice(d.Files, func(i, j int) bool {
+		return d.Files[i].RemotePath < d.Files[j].RemotePath
+	})
+	sort.Strings(d.Deleted)
+
+	d.Duration = duration
+	d.TotalFiles = len(d.Files)
+	d.TotalBytes = 0
+	for _, f := range d.Files {
+		d.TotalBytes += f.Size
+	}
+
+	if d.Summary == nil {
+		d.Summary = &Summary{RateLimitRemaining: -1}
+	}
+	if duration > 0 {
+		d.Summary.Throughput = float64(d.TotalBytes) / duration.Seconds()
+	}
+
+	slowest := append([]*File{}, d.Files...)
+	sort.SliceStable(slowest, func(i, j int) bool {
+		return slowest[i].Duration > slowest[j].Duration
+	})
+	d.Summary.Slowest = slowest[:min(len(slowest), slowestFiles)]
+}
+
+// String returns the human-readable download summary.
+func (d *DownloadResult) String() string {
+	s := d.Summary
+	if s == nil {
+		s = &Summary{RateLimitRemaining: -1}
+	}
+
+	remaining := "unknown"
+	if s.RateLimitRemaining >= 0 {
+		remaining = fmt.Sprint(s.RateLimitRemaining)
+	}
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "Download Completed")
+	fmt.Fprintf(&b, "Files: %d | Directories: %d | Bytes: %s\n", d.TotalFiles, s.Directories, formatBytes(d.TotalBytes))
+	fmt.Fprintf(&b, "API calls: %d | Remaining rate limit: %s\n", s.APICalls, remaining)
+	fmt.Fprintf(&b, "Skipped: %d submodules, %d symlinks, %d filtered\n", s.Skipped.Submodules, s.Skipped.Symlinks, s.Skipped.Filtered)
+	fmt.Fprintf(&b, "Retries: %d | Failures: %d\n", s.Retries, s.Failures)
+	fmt.Fprintf(&b, "Duration: %v | Throughput: %s/s", d.Duration, formatBytes(int64(s.Throughput)))
+	if len(d.Deleted) > 0 {
+		fmt.Fprint(&b, "\nDeleted files:")
+		for _, p := range d.Deleted {
+			fmt.Fprintf(&b, "\n  %s", p)
+		}
+	}
+	if len(s.Slowest) > 0 {
+		fmt.Fprint(&b, "\nSlowest files:")
+		for _, f := range s.Slowest {
+			fmt.Fprintf(&b, "\n  %s (%v)", f.RemotePath, f.Duration)
+		}
+	}
+
+	return b.String()
+}
+
+// formatBytes returns the human-readable size of n bytes.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
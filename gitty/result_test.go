@@ -0,0 +1,226 @@
+package gitty
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusResultString(t *testing.T) {
+	t.Parallel()
+	rate := &github.RateLimits{
+		Core: &github.Rate{
+			Limit:     5000,
+			Remaining: 42,
+			Reset:     github.Timestamp{Time: time.Now().Add(30 * time.Minute)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		status   *StatusResult
+		expected string
+	}{
+		{
+			name:     "authorized",
+			status:   &StatusResult{Authorized: true, RateLimits: rate},
+			expected: fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins ", "Authorized", 42, float64(30)),
+		},
+		{
+			name:     "not authorized",
+			status:   &StatusResult{RateLimits: rate},
+			expected: fmt.Sprintf("Status: %v | Remaining rate limit: %v | Reset in: %.0f mins ", "NOT Authorized", 42, float64(30)),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.status.String())
+		})
+	}
+}
+
+func TestAuthResultString(t *testing.T) {
+	t.Parallel()
+	a := &AuthResult{Login: "octocat", ID: 1}
+	assert.Equal(t, "Authenticated as @octocat ", a.String())
+}
+
+func TestDownloadResultSummarize(t *testing.T) {
+	t.Parallel()
+	files := make([]*File, 0, slowestFiles+2)
+	for i := range slowestFiles + 2 {
+		files = append(files, &File{
+			RemotePath: fmt.Sprintf("dir/%d.txt", slowestFiles+2-i),
+			Size:       512,
+			Duration:   time.Duration(i) * time.Second,
+		})
+	}
+	d := &DownloadResult{Files: files}
+
+	d.summarize(2 * time.Second)
+	assert.Equal(t, slowestFiles+2, d.TotalFiles)
+	assert.Equal(t, int64(512*(slowestFiles+2)), d.TotalBytes)
+	assert.Equal(t, "dir/1.txt", d.Files[0].RemotePath)
+	assert.InDelta(t, float64(256*(slowestFiles+2)), d.Summary.Throughput, 0.001)
+	assert.Len(t, d.Summary.Slowest, slowestFiles)
+	assert.Equal(t, time.Duration(slowestFiles+1)*time.Second, d.Summary.Slowest[0].Duration)
+	assert.Equal(t, -1, d.Summary.RateLimitRemaining)
+}
+
+func TestDownloadResultString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		result   *DownloadResult
+		expected string
+	}{
+		{
+			name:   "without summary",
+			result: &DownloadResult{Duration: 2 * time.Second},
+			expected: "Download Completed\n" +
+				"Files: 0 | Directories: 0 | Bytes: 0 B\n" +
+				"API calls: 0 | Remaining rate limit: unknown\n" +
+				"Skipped: 0 submodules, 0 symlinks, 0 filtered\n" +
+				"Retries: 0 | Failures: 0\n" +
+				"Duration: 2s | Throughput: 0 B/s",
+		},
+		{
+			name: "with summary",
+			result: &DownloadResult{
+				TotalFiles: 1,
+				TotalBytes: 2048,
+				Duration:   time.Second,
+				Summary: &Summary{
+					Directories:        1,
+					APICalls:           2,
+					RateLimitRemaining: 58,
+					Skipped:            Skipped{Submodules: 1, Symlinks: 2},
+					Retries:            3,
+					Failures:           0,
+					Throughput:         2048,
+					Slowest:            []*File{{RemotePath: "dir/a.txt", Duration: time.Second}},
+				},
+			},
+			expected: "Download Completed\n" +
+				"Files: 1 | Directories: 1 | Bytes: 2.0 KiB\n" +
+				"API calls: 2 | Remaining rate limit: 58\n" +
+				"Skipped: 1 submodules, 2 symlinks, 0 filtered\n" +
+				"Retries: 3 | Failures: 0\n" +
+				"Duration: 1s | Throughput: 2.0 KiB/s\n" +
+				"Slowest files:\n" +
+				"  dir/a.txt (1s)",
+		},
+		{
+			name:   "with deleted files",
+			result: &DownloadResult{Deleted: []string{"dir/a.txt", "dir/b.txt"}},
+			expected: "Download Completed\n" +
+				"Files: 0 | Directories: 0 | Bytes: 0 B\n" +
+				"API calls: 0 | Remaining rate limit: unknown\n" +
+				"Skipped: 0 submodules, 0 symlinks, 0 filtered\n" +
+				"Retries: 0 | Failures: 0\n" +
+				"Duration: 0s | Throughput: 0 B/s\n" +
+				"Deleted files:\n" +
+				"  dir/a.txt\n" +
+				"  dir/b.txt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.result.String())
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		n        int64
+		expected string
+	}{
+		{n: 0, expected: "0 B"},
+		{n: 1023, expected: "1023 B"},
+		{n: 1024, expected: "1.0 KiB"},
+		{n: 1536 * 1024, expected: "1.5 MiB"},
+		{n: 3 << 30, expected: "3.0 GiB"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, formatBytes(test.n))
+		})
+	}
+}
+
+func TestInstallString(t *testing.T) {
+	t.Parallel()
+	i := &Install{Repo: "owner/tool", Tag: "v1.0.0", Path: "bin/tool"}
+	assert.Equal(t, "Installed owner/tool v1.0.0: bin/tool", i.String())
+}
+
+func TestInstalledResultString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		result   *InstalledResult
+		expected string
+	}{
+		{
+			name: "installs",
+			result: &InstalledResult{Installs: []*Install{
+				{Repo: "owner/a", Tag: "v1.0.0", Path: "bin/a"},
+				{Repo: "owner/b", Tag: "v2.0.0", Path: "bin/b"},
+			}},
+			expected: "owner/a v1.0.0: bin/a\nowner/b v2.0.0: bin/b",
+		},
+		{
+			name:     "no installs",
+			result:   &InstalledResult{},
+			expected: "No installs",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.result.String())
+		})
+	}
+}
+
+func TestUpgradeResultString(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		result   *UpgradeResult
+		expected string
+	}{
+		{
+			name: "upgraded and up to date",
+			result: &UpgradeResult{
+				Upgraded: []*Upgrade{{From: "v1.0.0", Install: &Install{Repo: "owner/a", Tag: "v1.1.0", Path: "bin/a"}}},
+				UpToDate: []*Install{{Repo: "owner/b", Tag: "v2.0.0", Path: "bin/b"}},
+			},
+			expected: "Upgraded owner/a v1.0.0 -> v1.1.0: bin/a\nUp to date owner/b v2.0.0",
+		},
+		{
+			name:     "no installs",
+			result:   &UpgradeResult{},
+			expected: "No installs",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.result.String())
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// latestStable is the reference which picks the highest stable version.
+const latestStable = "latest-stable"
+
+var (
+	ErrNotValidConstraint = errors.New("version constraint is not valid")
+	ErrNoVersion          = errors.New("no tag satisfies the version constraint")
+)
+
+// version represents a semantic version, like v1.2.3-rc.1. Build metadata
+// is ignored.
+type version struct {
+	nums [3]int
+	pre  []string
+}
+
+// parseVersion parses a semantic version, with or without the v prefix.
+// The minor and patch numbers may be omitted, like v1.2, and default to 0.
+func parseVersion(s string) (version, bool) {
+	var v version
+	s = strings.TrimPrefix(s, "v")
+	s, _, _ = strings.Cut(s, "+")
+	s, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if pre == "" {
+			return v, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > len(v.nums) {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v.nums[i] = n
+	}
+
+	return v, true
+}
+
+// compare compares the versions by precedence, like semver does. It
+// returns -1, 0 or 1 if v is lower than, equal to, or higher than w. A
+// prerelease is lower than its release.
+func (v version) compare(w version) int {
+	for i := range v.nums {
+		if v.nums[i] != w.nums[i] {
+			return sign(v.nums[i] - w.nums[i])
+		}
+	}
+
+	switch {
+	case len(v.pre) == 0 && len(w.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(w.pre) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.pre) && i < len(w.pre); i++ {
+		if c := comparePre(v.pre[i], w.pre[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.pre) - len(w.pre))
+}
+
+// comparePre compares prerelease identifiers. Numeric identifiers are
+// compared numerically, and are lower than alphanumeric ones.
+func comparePre(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(x - y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// sign returns the sign of n.
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// constraint represents a range of versions, from min up to, but not
+// including, max. Any version at or above min matches if max is nil. Stable
+// constraints never match prereleases.
+type constraint struct {
+	min    version
+	max    *version
+	stable bool
+}
+
+// isConstraint reports whether the reference is a version constraint. Git
+// references can't contain ^ or ~, so they are never taken for one.
+func isConstraint(ref string) bool {
+	return strings.HasPrefix(ref, "^") || strings.HasPrefix(ref, "~") || ref == latestStable
+}
+
+// parseConstraint parses a version constraint:
+//
+//	^1.4     matches >=1.4.0 <2.0.0, and ^0.4 matches >=0.4.0 <0.5.0
+//	~2.0.3   matches >=2.0.3 <2.1.0, and ~2 matches >=2.0.0 <3.0.0
+//	latest-stable matches any stable version
+//
+// The versions may have the v prefix.
+func parseConstraint(s string) (*constraint, error) {
+	if s == latestStable {
+		return &constraint{stable: true}, nil
+	}
+	if s == "" {
+		return nil, fmt.Errorf("%w: %q", ErrNotValidConstraint, s)
+	}
+
+	op, rest := s[:1], s[1:]
+	v, ok := parseVersion(rest)
+	if !ok || len(v.pre) > 0 || (op != "^" && op != "~") {
+		return nil, fmt.Errorf("%w: %q", ErrNotValidConstraint, s)
+	}
+	// given is the number of the version parts given, like 2 for ^1.4.
+	given := strings.Count(strings.TrimPrefix(rest, "v"), ".") + 1
+
+	// bump is the index of the version part which is bumped for max.
+	bump := 0
+	switch {
+	case op == "~" && given > 1:
+		bump = 1
+	case op == "^":
+		// The first nonzero part is bumped, unless it was not given.
+		for bump < given-1 && v.nums[bump] == 0 {
+			bump++
+		}
+	}
+	upper := version{}
+	copy(upper.nums[:bump], v.nums[:bump])
+	upper.nums[bump] = v.nums[bump] + 1
+
+	return &constraint{min: v, max: &upper}, nil
+}
+
+// matches reports whether the version is in the range of the constraint.
+// Prereleases match only if prerelease is set.
+func (c *constraint) matches(v version, prerelease bool) bool {
+	if len(v.pre) > 0 && (c.stable || !prerelease) {
+		return false
+	}
+	if v.compare(c.min) < 0 {
+		return false
+	}
+	// Prereleases of max, like 2.0.0-rc.1 for ^1.4, are below it, but
+	// they are not in the range.
+	return c.max == nil || version{nums: v.nums}.compare(*c.max) < 0
+}
+
+// highestTag returns the tag with the highest version which satisfies the
+// constraint. Tags which are not semantic versions are skipped.
+func highestTag(tags []string, c *constraint, prerelease bool) (string, bool) {
+	var best string
+	var bestVersion version
+	for _, tag := range tags {
+		v, ok := parseVersion(tag)
+		if !ok || !c.matches(v, prerelease) {
+			continue
+		}
+		if best == "" || v.compare(bestVersion) > 0 {
+			best, bestVersion = tag, v
+		}
+	}
+	return best, best != ""
+}
+
+// resolveVersion resolves the version constraint of the reference to the
+// tag of the repository with the highest version which satisfies it.
+// Prereleases are skipped, unless opts.Prerelease is set.
+func (g *GitHub) resolveVersion(ctx context.Context) error {
+	c, err := parseConstraint(g.ref())
+	if err != nil {
+		return err
+	}
+
+	tags, err := g.engine.listTags(ctx, g.source(), g.Owner, g.Repo)
+	if err != nil {
+		return fmt.Errorf("failed to resolve version: %w", err)
+	}
+
+	prerelease := g.opts != nil && g.opts.Prerelease
+	tag, ok := highestTag(tags, c, prerelease)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNoVersion, g.ref())
+	}
+	g.Ref = &github.RepositoryContentGetOptions{Ref: tag}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package gitty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersion(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		s        string
+		expected version
+		ok       bool
+	}{
+		{name: "full", s: "1.2.3", expected: version{nums: [3]int{1, 2, 3}}, ok: true},
+		{name: "v prefix", s: "v1.2.3", expected: version{nums: [3]int{1, 2, 3}}, ok: true},
+		{name: "minor", s: "v1.2", expected: version{nums: [3]int{1, 2, 0}}, ok: true},
+		{name: "major", s: "2", expected: version{nums: [3]int{2, 0, 0}}, ok: true},
+		{name: "prerelease", s: "v1.0.0-rc.1", expected: version{nums: [3]int{1, 0, 0}, pre: []string{"rc", "1"}}, ok: true},
+		{name: "build", s: "1.0.0+20240131", expected: version{nums: [3]int{1, 0, 0}}, ok: true},
+		{name: "error name", s: "release-1"},
+		{name: "error empty prerelease", s: "1.0.0-"},
+		{name: "error too many parts", s: "1.2.3.4"},
+		{name: "error empty part", s: "1..3"},
+		{name: "error negative", s: "v-1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			v, ok := parseVersion(test.s)
+			assert.Equal(t, test.ok, ok)
+			if ok {
+				assert.Equal(t, test.expected, v)
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	t.Parallel()
+	// The versions are in ascending order of precedence.
+	ordered := []string{
+		"0.9.9",
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.10.0",
+		"2.0.0",
+	}
+
+	for i, a := range ordered {
+		for j, b := range ordered {
+			v, ok := parseVersion(a)
+			require.True(t, ok)
+			w, ok := parseVersion(b)
+			require.True(t, ok)
+			assert.Equal(t, sign(i-j), v.compare(w), "%s <=> %s", a, b)
+		}
+	}
+}
+
+func TestParseConstraint(t *testing.T) {
+	t.Parallel()
+	upper := func(nums ...int) *version {
+		return &version{nums: [3]int(nums)}
+	}
+	tests := []struct {
+		name        string
+		s           string
+		expected    *constraint
+		expectedErr error
+	}{
+		{name: "caret", s: "^1.4", expected: &constraint{min: version{nums: [3]int{1, 4, 0}}, max: upper(2, 0, 0)}},
+		{name: "caret with v", s: "^v1.4.2", expected: &constraint{min: version{nums: [3]int{1, 4, 2}}, max: upper(2, 0, 0)}},
+		{name: "caret zero major", s: "^0.4", expected: &constraint{min: version{nums: [3]int{0, 4, 0}}, max: upper(0, 5, 0)}},
+		{name: "caret zero minor", s: "^0.0.3", expected: &constraint{min: version{nums: [3]int{0, 0, 3}}, max: upper(0, 0, 4)}},
+		{name: "caret zero", s: "^0", expected: &constraint{min: version{}, max: upper(1, 0, 0)}},
+		{name: "caret zero partial", s: "^0.0", expected: &constraint{min: version{}, max: upper(0, 1, 0)}},
+		{name: "tilde", s: "~2.0.3", expected: &constraint{min: version{nums: [3]int{2, 0, 3}}, max: upper(2, 1, 0)}},
+		{name: "tilde minor", s: "~2.1", expected: &constraint{min: version{nums: [3]int{2, 1, 0}}, max: upper(2, 2, 0)}},
+		{name: "tilde major", s: "~2", expected: &constraint{min: version{nums: [3]int{2, 0, 0}}, max: upper(3, 0, 0)}},
+		{name: "latest stable", s: latestStable, expected: &constraint{stable: true}},
+		{name: "error version", s: "^one", expectedErr: fmt.Errorf("%w: %q", ErrNotValidConstraint, "^one")},
+		{name: "error prerelease", s: "~1.0.0-rc.1", expectedErr: fmt.Errorf("%w: %q", ErrNotValidConstraint, "~1.0.0-rc.1")},
+		{name: "error operator", s: "1.4", expectedErr: fmt.Errorf("%w: %q", ErrNotValidConstraint, "1.4")},
+		{name: "error empty", s: "", expectedErr: fmt.Errorf("%w: %q", ErrNotValidConstraint, "")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := parseConstraint(test.s)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, c)
+		})
+	}
+}
+
+func TestHighestTag(t *testing.T) {
+	t.Parallel()
+	tags := []string{
+		"v1.3.9",
+		"v1.4.0",
+		"v1.4.2",
+		"v1.5.0-rc.1",
+		"1.10.1",
+		"v1.10.2-rc.1",
+		"v2.0.0-beta.1",
+		"v2.0.3",
+		"v2.0.10",
+		"v2.1.0",
+		"nightly",
+		"release/v3",
+	}
+
+	tests := []struct {
+		name       string
+		constraint string
+		prerelease bool
+		expected   string
+		ok         bool
+	}{
+		{name: "caret", constraint: "^1.4", expected: "1.10.1", ok: true},
+		{name: "tilde", constraint: "~2.0.3", expected: "v2.0.10", ok: true},
+		{name: "tilde minor", constraint: "~1.4", expected: "v1.4.2", ok: true},
+		{name: "latest stable", constraint: latestStable, expected: "v2.1.0", ok: true},
+		{name: "latest stable with prerelease", constraint: latestStable, prerelease: true, expected: "v2.1.0", ok: true},
+		{name: "prerelease", constraint: "^1.4", prerelease: true, expected: "v1.10.2-rc.1", ok: true},
+		{name: "prerelease of max", constraint: "~1.4", prerelease: true, expected: "v1.4.2", ok: true},
+		{name: "prerelease of min", constraint: "~2.0", prerelease: true, expected: "v2.0.10", ok: true},
+		{name: "none", constraint: "^3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c, err := parseConstraint(test.constraint)
+			require.NoError(t, err)
+			tag, ok := highestTag(tags, c, test.prerelease)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, tag)
+		})
+	}
+}
+
+// mockTagsError fails to list the tags.
+type mockTagsError struct {
+	mockSuccess
+}
+
+var errMockTags = errors.New("failed to list tags")
+
+func (m *mockTagsError) ListMatchingRefs(_ context.Context, _, _ string, _ *github.ReferenceListOptions) ([]*github.Reference, *github.Response, error) {
+	return nil, nil, errMockTags
+}
+
+func TestResolveVersion(t *testing.T) {
+	t.Parallel()
+	ctx := context.WithValue(context.Background(), refsKey, []string{
+		"refs/heads/main",
+		"refs/heads/v9.0.0",
+		"refs/tags/v1.3.0",
+		"refs/tags/v1.4.1",
+		"refs/tags/v1.5.0-rc.1",
+		"refs/tags/v2.0.0",
+	})
+
+	tests := []struct {
+		name         string
+		client       mockClient
+		url          string
+		opts         *Options
+		expectedRef  string
+		expectedPath string
+		expectedErr  error
+	}{
+		{
+			name:         "url",
+			client:       &mockSuccess{},
+			url:          "github.com/owner/repo/tree/^1.3/docs",
+			expectedRef:  "v1.4.1",
+			expectedPath: "docs",
+		},
+		{
+			name:         "spec",
+			client:       &mockSuccess{},
+			url:          "owner/repo@~1.3:docs",
+			expectedRef:  "v1.3.0",
+			expectedPath: "docs",
+		},
+		{
+			name:        "latest stable",
+			client:      &mockSuccess{},
+			url:         "owner/repo#latest-stable",
+			opts:        &Options{Prerelease: true},
+			expectedRef: "v2.0.0",
+		},
+		{
+			name:        "prerelease",
+			client:      &mockSuccess{},
+			url:         "owner/repo",
+			opts:        &Options{Ref: "^1.3", Prerelease: true},
+			expectedRef: "v1.5.0-rc.1",
+		},
+		{
+			name:        "error constraint",
+			client:      &mockSuccess{},
+			url:         "owner/repo@^latest",
+			expectedErr: fmt.Errorf("%w: %q", ErrNotValidConstraint, "^latest"),
+		},
+		{
+			name:        "error no version",
+			client:      &mockSuccess{},
+			url:         "owner/repo@^3",
+			expectedErr: fmt.Errorf("%w: %s", ErrNoVersion, "^3"),
+		},
+		{
+			name:        "error tags",
+			client:      &mockTagsError{},
+			url:         "owner/repo@^1",
+			expectedErr: fmt.Errorf("failed to resolve version: %w", errMockTags),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			g := &GitHub{Client: test.client, opts: test.opts, engine: newEngine(0)}
+			err := g.extract(test.url)
+			require.NoError(t, err)
+
+			err = g.resolve(ctx)
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				return
+			}
+			// The chosen tag is pinned to its commit.
+			assert.Equal(t, test.expectedRef, g.refName())
+			assert.Equal(t, commitData(test.expectedRef), g.ref())
+			assert.Equal(t, test.expectedPath, g.Path)
+		})
+	}
+}
@@ -0,0 +1,576 @@
+package gitty
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/worlpaker/gitty/gitty/token"
+)
+
+// Service, headers and content types of the git smart HTTP protocol.
+const (
+	uploadPack     = "git-upload-pack"
+	protocolHeader = "Git-Protocol"
+	protocolV2     = "version=2"
+	requestType    = "application/x-git-upload-pack-request"
+	resultType     = "application/x-git-upload-pack-result"
+)
+
+// Lines of the git protocol v2 responses.
+const (
+	serviceLine  = "# service=" + uploadPack
+	versionLine  = "version 2"
+	fetchCap     = "fetch="
+	packfileLine = "packfile"
+)
+
+// Attributes of the references listed by ls-refs.
+const (
+	symrefAttr = "symref-target:"
+	peeledAttr = "peeled:"
+)
+
+// Features of the fetch command which the downloads use.
+const (
+	filterFeature  = "filter"
+	shallowFeature = "shallow"
+)
+
+// modeTree is the mode of the tree entries of GitHub, which is written
+// without its leading zero in git trees.
+const modeTree = "040000"
+
+var ErrNoProtocolV2 = errors.New("git server must support protocol v2 with filters")
+
+// smartProvider represents a plain git server, which is used through the
+// git protocol v2 over smart HTTP, without any host API. The repository is
+// at the path of the owner and the repository on the host. The trees are
+// fetched without their blobs, and the blobs of the listed files are
+// fetched together then. Other files are fetched with their own blob.
+type smartProvider struct {
+	rest
+	name string
+
+	mu sync.Mutex
+	// fetches are the features of the fetch command of the repositories,
+	// keyed by their URLs.
+	fetches map[string]*lookup
+	// trees are the entries of the trees of the commits, keyed by the URL of
+	// the repository and the commit, and then by their paths.
+	trees map[string]map[string]*treeEntry
+	// blobs are the prefetched blobs, keyed by their SHAs, until they are
+	// served.
+	blobs map[string][]byte
+}
+
+// Ensure smartProvider implements the provider and the prefetcher
+// interfaces.
+var (
+	_ provider   = (*smartProvider)(nil)
+	_ prefetcher = (*smartProvider)(nil)
+)
+
+// newSmart creates the provider of the git server at the base URL,
+// authenticated with the token of the host, if any.
+func newSmart(name, base string) *smartProvider {
+	prefix, tok := credentials(token.ForGit(name))
+	return &smartProvider{
+		rest: rest{
+			base:   base,
+			header: authorizationHeader,
+			prefix: prefix,
+			token:  tok,
+			client: http.DefaultClient,
+		},
+		name:    name,
+		fetches: make(map[string]*lookup),
+		trees:   make(map[string]map[string]*treeEntry),
+		blobs:   make(map[string][]byte),
+	}
+}
+
+// gitRef represents a reference listed by ls-refs. The target of a symbolic
+// reference and the commit of an annotated tag are listed if they were asked
+// for.
+type gitRef struct {
+	name   string
+	oid    string
+	target string
+	peeled string
+}
+
+func (p *smartProvider) host() string {
+	return p.name
+}
+
+// defaultBranch returns the branch which HEAD points to.
+func (p *smartProvider) defaultBranch(ctx context.Context, owner, repo string) (string, *reply, error) {
+	refs, resp, err := p.lsRefs(ctx, p.remote(owner, repo), "symrefs", "ref-prefix HEAD")
+	if err != nil {
+		return "", resp, err
+	}
+	for _, ref := range refs {
+		if ref.name == "HEAD" && ref.target != "" {
+			return strings.TrimPrefix(ref.target, "refs/heads/"), resp, nil
+		}
+	}
+	return "", resp, fmt.Errorf("%w: HEAD", ErrNoRef)
+}
+
+// refs lists the references of the kind that start with the given name.
+// They are not paged.
+func (p *smartProvider) refs(ctx context.Context, owner, repo, kind, name string, _ cursor) ([]string, *reply, error) {
+	prefix := "refs/" + kind + "/"
+	refs, resp, err := p.lsRefs(ctx, p.remote(owner, repo), "ref-prefix "+prefix+name)
+	if err != nil {
+		return nil, resp, err
+	}
+	var names []string
+	for _, ref := range refs {
+		if n, ok := strings.CutPrefix(ref.name, prefix); ok && strings.HasPrefix(n, name) {
+			names = append(names, n)
+		}
+	}
+
+	return names, resp, nil
+}
+
+// commitSHA returns the commit of the branch or the tag, which is peeled
+// if it is an annotated tag. Full commit SHAs are returned as is.
+func (p *smartProvider) commitSHA(ctx context.Context, owner, repo, ref string) (string, *reply, error) {
+	return p.commit(ctx, p.remote(owner, repo), ref)
+}
+
+// tree fetches the commit and its trees, without their blobs. It is not
+// paged.
+func (p *smartProvider) tree(ctx context.Context, owner, repo, ref string, _ cursor) (*fileTree, *reply, error) {
+	remote := p.remote(owner, repo)
+	commit, resp, err := p.commit(ctx, remote, ref)
+	if err != nil {
+		return nil, resp, err
+	}
+	entries, resp, err := p.listTree(ctx, remote, commit)
+	if err != nil {
+		return nil, resp, err
+	}
+	return &fileTree{entries: entries}, resp, nil
+}
+
+// file looks the path up in the tree of the reference.
+func (p *smartProvider) file(ctx context.Context, owner, repo, ref, path string) (string, *reply, error) {
+	index, resp, err := p.index(ctx, p.remote(owner, repo), ref)
+	if err != nil {
+		return "", resp, err
+	}
+	entry, ok := index[path]
+	if !ok {
+		return "", resp, fmt.Errorf("%w: %s", ErrNotFound, path)
+	}
+	if entry.typ != entryBlob {
+		return "", resp, ErrNotFile
+	}
+	return p.rawURL(owner, repo, ref, path), resp, nil
+}
+
+// rawURL returns the URL of the repository, whose fragment is the reference
+// and the path, separated by a colon, like the revisions of git. Reference
+// names can't have colons.
+func (p *smartProvider) rawURL(owner, repo, ref, path string) string {
+	return p.remote(owner, repo) + "#" + (&url.URL{Fragment: ref + ":" + path}).EscapedFragment()
+}
+
+// prefetch fetches the blobs of the files of the entries in one request.
+// The symbolic links are not downloaded, so their blobs are not fetched.
+func (p *smartProvider) prefetch(ctx context.Context, owner, repo string, entries []*treeEntry) (*reply, error) {
+	var wants []string
+	seen := make(map[string]bool)
+	p.mu.Lock()
+	for _, entry := range entries {
+		_, cached := p.blobs[entry.sha]
+		if entry.typ != entryBlob || entry.mode == modeSymlink || cached || seen[entry.sha] {
+			continue
+		}
+		seen[entry.sha] = true
+		wants = append(wants, "want "+entry.sha)
+	}
+	p.mu.Unlock()
+	if len(wants) == 0 {
+		return nil, nil
+	}
+
+	objects, resp, err := p.fetch(ctx, p.remote(owner, repo), wants...)
+	if err != nil {
+		return resp, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for sha := range seen {
+		blob, ok := objects[sha]
+		if !ok {
+			return resp, fmt.Errorf("%w: missing blob %s", ErrBadPack, sha)
+		}
+		p.blobs[sha] = blob.data
+	}
+
+	return resp, nil
+}
+
+// get serves the blob of the file at the URL of rawURL if it was
+// prefetched, and fetches it otherwise. It responds with 404 Not Found if
+// the path is not a file.
+func (p *smartProvider) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	remote, revision, _ := strings.Cut(rawURL, "#")
+	revision, err := url.PathUnescape(revision)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPathURL, err)
+	}
+	ref, path, ok := strings.Cut(revision, ":")
+	if !ok {
+		return nil, ErrInvalidPathURL
+	}
+
+	index, _, err := p.index(ctx, remote, ref)
+	if err != nil {
+		return nil, err
+	}
+	entry, ok := index[path]
+	if !ok || entry.typ != entryBlob {
+		return &http.Response{
+			Status:     "404 Not Found",
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	}
+
+	data, err := p.blob(ctx, remote, entry.sha)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+	}, nil
+}
+
+// blob returns the prefetched blob, which is dropped then, or fetches it.
+func (p *smartProvider) blob(ctx context.Context, remote, sha string) ([]byte, error) {
+	p.mu.Lock()
+	data, ok := p.blobs[sha]
+	delete(p.blobs, sha)
+	p.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+
+	objects, _, err := p.fetch(ctx, remote, "want "+sha)
+	if err != nil {
+		return nil, err
+	}
+	blob, ok := objects[sha]
+	if !ok {
+		return nil, fmt.Errorf("%w: missing blob %s", ErrBadPack, sha)
+	}
+	return blob.data, nil
+}
+
+// remote returns the URL of the repository.
+func (p *smartProvider) remote(owner, repo string) string {
+	return p.base + "/" + pathEscape(strings.Trim(owner+"/"+repo, "/"))
+}
+
+// commit returns the commit of the reference of the repository.
+func (p *smartProvider) commit(ctx context.Context, remote, ref string) (string, *reply, error) {
+	if isSHA(ref) {
+		return ref, nil, nil
+	}
+	names := []string{"refs/heads/" + ref, "refs/tags/" + ref}
+	refs, resp, err := p.lsRefs(ctx, remote, "peel", "ref-prefix "+names[0], "ref-prefix "+names[1])
+	if err != nil {
+		return "", resp, err
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(refs, func(r gitRef) bool { return r.name == name })
+		if i < 0 {
+			continue
+		}
+		if refs[i].peeled != "" {
+			return refs[i].peeled, resp, nil
+		}
+		return refs[i].oid, resp, nil
+	}
+	return "", resp, fmt.Errorf("%w: %s", ErrNoRef, ref)
+}
+
+// index returns the entries of the tree of the reference, keyed by their
+// paths. The tree is fetched if it wasn't yet.
+func (p *smartProvider) index(ctx context.Context, remote, ref string) (map[string]*treeEntry, *reply, error) {
+	commit, resp, err := p.commit(ctx, remote, ref)
+	if err != nil {
+		return nil, resp, err
+	}
+	p.mu.Lock()
+	index, ok := p.trees[remote+"@"+commit]
+	p.mu.Unlock()
+	if ok {
+		return index, resp, nil
+	}
+
+	if _, resp, err = p.listTree(ctx, remote, commit); err != nil {
+		return nil, resp, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.trees[remote+"@"+commit], resp, nil
+}
+
+// listTree fetches the commit and its trees without their blobs, and lists
+// the entries of its tree recursively. Only the commit itself is fetched if
+// the server supports shallow fetches.
+func (p *smartProvider) listTree(ctx context.Context, remote, commit string) ([]*treeEntry, *reply, error) {
+	features, err := p.features(ctx, remote)
+	if err != nil {
+		return nil, nil, err
+	}
+	args := []string{"filter blob:none"}
+	if slices.Contains(features, shallowFeature) {
+		args = append(args, "deepen 1")
+	}
+	objects, resp, err := p.fetch(ctx, remote, append(args, "want "+commit)...)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	c, ok := objects[commit]
+	if !ok || c.kind != objCommit {
+		return nil, resp, fmt.Errorf("%w: missing commit %s", ErrBadPack, commit)
+	}
+	tree, err := commitTree(c.data)
+	if err != nil {
+		return nil, resp, err
+	}
+	var entries []*treeEntry
+	if err := walkTree(objects, tree, "", &entries); err != nil {
+		return nil, resp, err
+	}
+
+	index := make(map[string]*treeEntry, len(entries))
+	for _, entry := range entries {
+		index[entry.path] = entry
+	}
+	p.mu.Lock()
+	p.trees[remote+"@"+commit] = index
+	p.mu.Unlock()
+
+	return entries, resp, nil
+}
+
+// walkTree appends the entries of the tree and of its subtrees, whose paths
+// are under the prefix. The submodules are not walked.
+func walkTree(objects map[string]*object, sha, prefix string, entries *[]*treeEntry) error {
+	o, ok := objects[sha]
+	if !ok || o.kind != objTree {
+		return fmt.Errorf("%w: missing tree %s", ErrBadPack, sha)
+	}
+	items, err := parseTree(o.data)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		entry := &treeEntry{
+			path: prefix + item.name,
+			mode: item.mode,
+			typ:  entryBlob,
+			sha:  item.sha,
+		}
+		*entries = append(*entries, entry)
+		switch item.mode {
+		case modeDir:
+			entry.mode, entry.typ = modeTree, entryTree
+			if err := walkTree(objects, item.sha, entry.path+"/", entries); err != nil {
+				return err
+			}
+		case modeSubmodule:
+			entry.typ = entryCommit
+		}
+	}
+
+	return nil
+}
+
+// features returns the features of the fetch command of the repository.
+// The capabilities are advertised once per repository. The server must
+// support the protocol v2 and the filters.
+func (p *smartProvider) features(ctx context.Context, remote string) ([]string, error) {
+	p.mu.Lock()
+	l, ok := p.fetches[remote]
+	if !ok {
+		l = &lookup{}
+		p.fetches[remote] = l
+	}
+	p.mu.Unlock()
+
+	l.once.Do(func() {
+		l.names, l.err = p.advertise(ctx, remote)
+	})
+	forget(&p.mu, p.fetches, remote, l, l.err)
+
+	return l.names, l.err
+}
+
+// advertise gets the capabilities of the repository, and returns the
+// features of its fetch command.
+func (p *smartProvider) advertise(ctx context.Context, remote string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, remote+"/info/refs?service="+uploadPack, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(protocolHeader, protocolV2)
+	hr, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer hr.Body.Close()
+	if hr.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s %s", ErrBadStatus, hr.Status, remote)
+	}
+
+	body := bufio.NewReader(hr.Body)
+	caps, err := readLines(body)
+	if err != nil {
+		return nil, err
+	}
+	// The service line is in a block of its own.
+	if len(caps) > 0 && caps[0] == serviceLine {
+		if caps, err = readLines(body); err != nil {
+			return nil, err
+		}
+	}
+	if len(caps) == 0 || caps[0] != versionLine {
+		return nil, fmt.Errorf("%w: %s", ErrNoProtocolV2, remote)
+	}
+	for _, c := range caps[1:] {
+		if features, ok := strings.CutPrefix(c, fetchCap); ok && slices.Contains(strings.Fields(features), filterFeature) {
+			return strings.Fields(features), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNoProtocolV2, remote)
+}
+
+// lsRefs lists the references of the repository with the arguments of the
+// ls-refs command.
+func (p *smartProvider) lsRefs(ctx context.Context, remote string, args ...string) ([]gitRef, *reply, error) {
+	if _, err := p.features(ctx, remote); err != nil {
+		return nil, nil, err
+	}
+	body, resp, err := p.command(ctx, remote, "ls-refs", args)
+	if err != nil {
+		return nil, resp, err
+	}
+	defer body.Close()
+
+	lines, err := readLines(bufio.NewReader(body))
+	if err != nil {
+		return nil, resp, err
+	}
+	refs := make([]gitRef, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, resp, fmt.Errorf("%w: ref %q", ErrBadPacket, line)
+		}
+		ref := gitRef{oid: fields[0], name: fields[1]}
+		for _, attr := range fields[2:] {
+			if target, ok := strings.CutPrefix(attr, symrefAttr); ok {
+				ref.target = target
+			}
+			if peeled, ok := strings.CutPrefix(attr, peeledAttr); ok {
+				ref.peeled = peeled
+			}
+		}
+		refs = append(refs, ref)
+	}
+
+	return refs, resp, nil
+}
+
+// fetch fetches the objects with the arguments of the fetch command, and
+// reads them from the packfile of the response.
+func (p *smartProvider) fetch(ctx context.Context, remote string, args ...string) (map[string]*object, *reply, error) {
+	if _, err := p.features(ctx, remote); err != nil {
+		return nil, nil, err
+	}
+	args = append([]string{"no-progress", "ofs-delta"}, args...)
+	body, resp, err := p.command(ctx, remote, "fetch", append(args, "done"))
+	if err != nil {
+		return nil, resp, err
+	}
+	defer body.Close()
+
+	// The packfile section follows the other sections, like the shallow
+	// info.
+	r := bufio.NewReader(body)
+	for {
+		payload, kind, err := readPkt(r)
+		if err != nil {
+			return nil, resp, err
+		}
+		if payload == nil && kind == pktFlush {
+			return nil, resp, fmt.Errorf("%w: missing packfile", ErrBadPacket)
+		}
+		if strings.TrimSuffix(string(payload), "\n") == packfileLine {
+			break
+		}
+	}
+	objects, err := readPack(&sideband{r: r})
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return objects, resp, nil
+}
+
+// command sends the command of the protocol v2 with its arguments, and
+// returns the body of the response, which the caller must close.
+func (p *smartProvider) command(ctx context.Context, remote, command string, args []string) (io.ReadCloser, *reply, error) {
+	var w pktWriter
+	w.line("command=%s", command)
+	w.special(pktDelim)
+	for _, arg := range args {
+		w.line("%s", arg)
+	}
+	w.special(pktFlush)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, remote+"/"+uploadPack, bytes.NewReader(w.Bytes()))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", requestType)
+	req.Header.Set("Accept", resultType)
+	req.Header.Set(protocolHeader, protocolV2)
+	hr, err := p.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := &reply{status: hr.StatusCode}
+	if hr.StatusCode != http.StatusOK {
+		hr.Body.Close()
+		return nil, resp, fmt.Errorf("%w: %s %s", ErrBadStatus, hr.Status, command)
+	}
+
+	return hr.Body, resp, nil
+}
@@ -0,0 +1,337 @@
+package gitty
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// git runs the git command in the directory, and returns its output.
+func git(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"GIT_AUTHOR_NAME=gitty", "GIT_AUTHOR_EMAIL=gitty@example.com",
+		"GIT_COMMITTER_NAME=gitty", "GIT_COMMITTER_EMAIL=gitty@example.com",
+	)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// gitServer returns a stand-in of a git server, which is git http-backend
+// serving the group/repo.git repository. Its main and feature/x branches
+// and its annotated v1.0.0 tag have the docs directory. The test is skipped
+// if git is not installed. The requests must have the credentials.
+func gitServer(t *testing.T, user, password string) *httptest.Server {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	backend := filepath.Join(git(t, ".", "--exec-path"), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git http-backend is not installed")
+	}
+
+	work := t.TempDir()
+	git(t, work, "init", "--quiet", "--initial-branch=main")
+	files := map[string]string{
+		"docs/a.md":       "# A\n",
+		"docs/guide/b.md": strings.Repeat("# B\n", 100),
+		"README.md":       "# Repo\n",
+		"run.sh":          "#!/bin/sh\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(work, filepath.Dir(name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(work, name), []byte(content), 0o644))
+	}
+	require.NoError(t, os.Chmod(filepath.Join(work, "run.sh"), 0o755))
+	require.NoError(t, os.Symlink("a.md", filepath.Join(work, "docs", "link")))
+	git(t, work, "add", ".")
+	git(t, work, "commit", "--quiet", "--message=main")
+	git(t, work, "tag", "--annotate", "--message=v1.0.0", "v1.0.0")
+	git(t, work, "checkout", "--quiet", "-b", "feature/x")
+	require.NoError(t, os.WriteFile(filepath.Join(work, "docs", "guide", "b.md"), []byte(strings.Repeat("# B\n", 101)), 0o644))
+	git(t, work, "commit", "--quiet", "--all", "--message=feature")
+	git(t, work, "checkout", "--quiet", "main")
+
+	root := t.TempDir()
+	bare := filepath.Join(root, "group", "repo.git")
+	git(t, root, "clone", "--quiet", "--bare", work, bare)
+	git(t, bare, "config", "uploadpack.allowFilter", "true")
+
+	handler := &cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + root, "GIT_HTTP_EXPORT_ALL=1", "GIT_CONFIG_NOSYSTEM=1", "HOME=" + root},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, ok := r.BasicAuth(); !ok || u != user || p != password {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestSmart returns the provider of the stand-in with the credentials.
+func newTestSmart(srv *httptest.Server, tok string) *smartProvider {
+	p := newSmart("git.example.com", srv.URL)
+	p.prefix, p.token = credentials(tok)
+	return p
+}
+
+func TestSmartProvider(t *testing.T) {
+	t.Parallel()
+	srv := gitServer(t, "user", "secret")
+	p := newTestSmart(srv, "user:secret")
+	ctx := context.Background()
+	assert.Equal(t, "git.example.com", p.host())
+
+	branch, _, err := p.defaultBranch(ctx, "group", "repo.git")
+	require.NoError(t, err)
+	assert.Equal(t, "main", branch)
+
+	names, _, err := p.refs(ctx, "group", "repo.git", "heads", "feature", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"feature/x"}, names)
+
+	names, resp, err := p.refs(ctx, "group", "repo.git", "tags", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1.0.0"}, names)
+	assert.Empty(t, resp.next)
+
+	main, _, err := p.commitSHA(ctx, "group", "repo.git", "main")
+	require.NoError(t, err)
+	assert.True(t, isSHA(main))
+	tag, _, err := p.commitSHA(ctx, "group", "repo.git", "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, main, tag)
+	sha, _, err := p.commitSHA(ctx, "group", "repo.git", main)
+	require.NoError(t, err)
+	assert.Equal(t, main, sha)
+
+	feature, _, err := p.commitSHA(ctx, "group", "repo.git", "feature/x")
+	require.NoError(t, err)
+	tree, resp, err := p.tree(ctx, "group", "repo.git", feature, "")
+	require.NoError(t, err)
+	assert.Empty(t, resp.next)
+	types := make(map[string]string)
+	modes := make(map[string]string)
+	for _, entry := range tree.entries {
+		types[entry.path] = entry.typ
+		modes[entry.path] = entry.mode
+	}
+	assert.Equal(t, map[string]string{
+		"README.md":       entryBlob,
+		"docs":            entryTree,
+		"docs/a.md":       entryBlob,
+		"docs/guide":      entryTree,
+		"docs/guide/b.md": entryBlob,
+		"docs/link":       entryBlob,
+		"run.sh":          entryBlob,
+	}, types)
+	assert.Equal(t, modeTree, modes["docs"])
+	assert.Equal(t, modeSymlink, modes["docs/link"])
+	assert.Equal(t, modeExecutable, modes["run.sh"])
+
+	url, _, err := p.file(ctx, "group", "repo.git", feature, "docs/guide/b.md")
+	require.NoError(t, err)
+	assert.Equal(t, srv.URL+"/group/repo.git#"+feature+":docs/guide/b.md", url)
+	raw, err := p.get(ctx, url)
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("# B\n", 101), string(body))
+
+	_, _, err = p.file(ctx, "group", "repo.git", feature, "docs")
+	assert.Equal(t, ErrNotFile, err)
+	_, _, err = p.file(ctx, "group", "repo.git", feature, "missing.md")
+	assert.Equal(t, fmt.Errorf("%w: missing.md", ErrNotFound), err)
+
+	missing, err := p.get(ctx, p.rawURL("group", "repo.git", "main", "missing.md"))
+	require.NoError(t, err)
+	defer missing.Body.Close()
+	assert.Equal(t, http.StatusNotFound, missing.StatusCode)
+}
+
+// countingTransport counts the requests which it sends.
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestSmartProviderPrefetch(t *testing.T) {
+	t.Parallel()
+	srv := gitServer(t, "user", "secret")
+	p := newTestSmart(srv, "user:secret")
+	c := &countingTransport{}
+	p.client = &http.Client{Transport: c}
+	ctx := context.Background()
+
+	commit, _, err := p.commitSHA(ctx, "group", "repo.git", "main")
+	require.NoError(t, err)
+	tree, _, err := p.tree(ctx, "group", "repo.git", commit, "")
+	require.NoError(t, err)
+
+	before := c.requests.Load()
+	_, err = p.prefetch(ctx, "group", "repo.git", tree.entries)
+	require.NoError(t, err)
+	assert.Equal(t, before+1, c.requests.Load())
+	// The blobs are fetched already.
+	_, err = p.prefetch(ctx, "group", "repo.git", tree.entries)
+	require.NoError(t, err)
+	assert.Equal(t, before+1, c.requests.Load())
+
+	for _, path := range []string{"README.md", "docs/a.md", "docs/guide/b.md", "run.sh"} {
+		raw, err := p.get(ctx, p.rawURL("group", "repo.git", commit, path))
+		require.NoError(t, err)
+		body, err := io.ReadAll(raw.Body)
+		raw.Body.Close()
+		require.NoError(t, err)
+		assert.NotEmpty(t, body)
+	}
+	assert.Equal(t, before+1, c.requests.Load())
+	assert.Empty(t, p.blobs)
+
+	// The served blobs are fetched again.
+	raw, err := p.get(ctx, p.rawURL("group", "repo.git", commit, "docs/a.md"))
+	require.NoError(t, err)
+	defer raw.Body.Close()
+	body, err := io.ReadAll(raw.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "# A\n", string(body))
+	assert.Equal(t, before+2, c.requests.Load())
+}
+
+func TestSmartProviderError(t *testing.T) {
+	t.Parallel()
+	srv := gitServer(t, "user", "secret")
+	ctx := context.Background()
+
+	p := newTestSmart(srv, "user:wrong")
+	_, _, err := p.defaultBranch(ctx, "group", "repo.git")
+	assert.Equal(t, fmt.Errorf("%w: 401 Unauthorized %s/group/repo.git", ErrBadStatus, srv.URL), err)
+
+	p = newTestSmart(srv, "user:secret")
+	_, _, err = p.commitSHA(ctx, "group", "repo.git", "missing")
+	assert.Equal(t, fmt.Errorf("%w: missing", ErrNoRef), err)
+	_, _, err = p.tree(ctx, "group", "other.git", "main", "")
+	assert.ErrorIs(t, err, ErrBadStatus)
+	_, err = p.get(ctx, srv.URL+"/group/repo.git")
+	assert.Equal(t, ErrInvalidPathURL, err)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	p = newTestSmart(srv, "user:secret")
+	_, err = p.get(canceled, p.rawURL("group", "repo.git", "main", "docs/a.md"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestSmartProviderNoFilter(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		var pw pktWriter
+		pw.line("version 2")
+		pw.line("ls-refs")
+		pw.line("fetch=shallow")
+		pw.special(pktFlush)
+		w.Write(pw.Bytes())
+	}))
+	t.Cleanup(srv.Close)
+
+	p := newTestSmart(srv, "")
+	_, _, err := p.defaultBranch(context.Background(), "group", "repo.git")
+	assert.Equal(t, fmt.Errorf("%w: %s/group/repo.git", ErrNoProtocolV2, srv.URL), err)
+}
+
+// setSmart configures the stand-in as a git server, and returns its name.
+func setSmart(t *testing.T, srv *httptest.Server) string {
+	t.Helper()
+	name := strings.TrimPrefix(srv.URL, "http://")
+	t.Setenv(tokenVar("GIT_TOKEN", name), "user:secret")
+	t.Setenv(hostKey, "")
+	t.Setenv(hostsKey, "")
+	t.Setenv(gitHostsKey, srv.URL)
+	return name
+}
+
+func TestDownloadSmart(t *testing.T) {
+	srv := gitServer(t, "user", "secret")
+	name := setSmart(t, srv)
+
+	tests := []struct {
+		name        string
+		url         string
+		ref         string
+		expectedRef string
+		expectedB   string
+	}{
+		{name: "default branch", url: srv.URL + "/group/repo.git/docs", expectedRef: "main", expectedB: strings.Repeat("# B\n", 100)},
+		{name: "ref in the fragment", url: name + "/group/repo.git/docs#feature/x", expectedRef: "feature/x", expectedB: strings.Repeat("# B\n", 101)},
+		{name: "version constraint", url: srv.URL + "/group/repo.git/docs", ref: "^1.0", expectedRef: "v1.0.0", expectedB: strings.Repeat("# B\n", 100)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := New()
+			archive := filepath.Join(t.TempDir(), "docs.zip")
+			opts := &Options{Log: io.Discard, Ref: test.ref, Archive: archive}
+
+			res, err := g.Download(context.Background(), []string{test.url}, opts)
+			require.NoError(t, err)
+			assert.Len(t, res.Files, 2)
+			assert.Equal(t, []string{test.expectedRef}, res.Refs)
+			assert.Equal(t, 1, res.Summary.Skipped.Symlinks)
+
+			z, err := zip.OpenReader(archive)
+			require.NoError(t, err)
+			defer z.Close()
+			f, err := z.Open("docs/guide/b.md")
+			require.NoError(t, err)
+			defer f.Close()
+			b, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedB, string(b))
+		})
+	}
+}
+
+func TestCatSmart(t *testing.T) {
+	srv := gitServer(t, "user", "secret")
+	setSmart(t, srv)
+
+	var buf bytes.Buffer
+	err := New().Cat(context.Background(), &buf, srv.URL+"/group/repo.git/docs/a.md", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "# A\n", buf.String())
+
+	err = New().Cat(context.Background(), io.Discard, srv.URL+"/group/repo.git/docs#main", nil)
+	assert.Equal(t, ErrNotFile, err)
+
+	_, err = New().Release(context.Background(), srv.URL+"/group/repo.git", &Options{Log: io.Discard})
+	assert.ErrorIs(t, err, ErrNotSupported)
+}
@@ -0,0 +1,687 @@
+package gitty
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Hosts of the supported URLs.
+const (
+	hostGitHub    = "github.com"
+	hostWWW       = "www.github.com"
+	hostRaw       = "raw.githubusercontent.com"
+	hostAPI       = "api.github.com"
+	hostGist      = "gist.github.com"
+	hostGitLab    = "gitlab.com"
+	hostCodeberg  = "codeberg.org"
+	hostBitbucket = "bitbucket.org"
+)
+
+// rawSubdomain is the subdomain of the raw URLs of a GitHub Enterprise
+// Server with subdomain isolation.
+const rawSubdomain = "raw."
+
+// ghPrefix is the optional prefix of compact specs.
+const ghPrefix = "gh:"
+
+// gitSuffix is the optional suffix of the repository in clone URLs.
+const gitSuffix = ".git"
+
+// gitlabSeparator separates the project path from the rest of the path in
+// GitLab URLs, since the project may be in nested groups.
+const gitlabSeparator = "-"
+
+// Prefixes of the qualified references of the at query of Bitbucket Server
+// URLs.
+var serverRefPrefixes = []string{"refs/heads/", "refs/tags/"}
+
+// Expected formats of the supported URLs and specs, used in error messages.
+const (
+	webFormat       = "github.com/owner/repo[/tree/ref[/path]], github.com/owner/repo/pull/number[/files] or github.com/owner/repo/compare/base...head"
+	rawFormat       = "raw.githubusercontent.com/owner/repo/ref/path"
+	apiFormat       = "api.github.com/repos/owner/repo/contents[/path][?ref=ref]"
+	cloneFormat     = "git@github.com:owner/repo.git or ssh://git@github.com/owner/repo.git"
+	gistFormat      = "gist.github.com/user/id[/revision][#file-name]"
+	hostFormat      = "host/owner/repo[/tree/ref[/path]], host/raw/owner/repo/ref/path, host/api/v3/repos/owner/repo/contents[/path] or host/gist/user/id"
+	gitlabFormat    = "gitlab.com/group[/subgroup...]/project[/-/tree/ref[/path]] or git@gitlab.com:group/project.git"
+	giteaFormat     = "codeberg.org/owner/repo[/src/branch/ref[/path]], codeberg.org/owner/repo/src/commit/sha[/path] or git@codeberg.org:owner/repo.git"
+	bitbucketFormat = "bitbucket.org/workspace/repo[/src/ref[/path]] or git@bitbucket.org:workspace/repo.git"
+	serverFormat    = "host/projects/PROJECT/repos/repo[/browse[/path]][?at=ref], host/users/user/repos/repo[/browse[/path]] or host/scm/project/repo.git"
+	smartFormat     = "host/path/to/repo[.git[/path]][#ref]"
+	compactFormat   = "[gh:]owner/repo[/path][#ref] or [gh:]owner/repo@ref[:path]"
+)
+
+var (
+	ErrNotValidURL    = errors.New("url must be a github.com, raw.githubusercontent.com, api.github.com, gist.github.com, gitlab.com, codeberg.org, bitbucket.org, or configured GitHub Enterprise Server, GitLab, Gitea, Bitbucket Server or git server url")
+	ErrNotValidFormat = errors.New("url format is not valid")
+)
+
+// spec represents the source of the contents: the repository, the reference,
+// and the path in it.
+type spec struct {
+	// host is the host, like github.com, a GitHub Enterprise Server, or
+	// a GitLab, Gitea or Bitbucket instance. It is empty for compact specs,
+	// which use the default host. The owner of a GitLab project is its group,
+	// with its subgroups, and the owner of a Bitbucket Server repository is
+	// its project. The owner of a repository of a git server is the path
+	// before it, which may be empty.
+	host  string
+	owner string
+	repo  string
+	// ref is the reference, if it is known. It is empty if the source
+	// points to the default branch.
+	ref string
+	// refPath is the reference and the path, which are ambiguous if the
+	// reference contains slashes. If it is set, ref and path are not.
+	refPath string
+	path    string
+	// pull is the number of the pull request, if the source points to one.
+	pull int
+	// since is the base of a comparison, if the source points to one.
+	// The head of the comparison is the ref.
+	since string
+	// gist is the ID of the gist, if the source points to one. Its revision
+	// is the ref, and file is the anchor of a single file in it, if any.
+	gist string
+	file string
+}
+
+// parseSpec parses the source of the contents. It is either a GitHub URL,
+// a git clone URL, or a compact spec like owner/repo/path. The first segment
+// of a URL is a host, which an owner name can't be. URLs of the known
+// GitHub Enterprise Server hosts and GitLab, Gitea and Bitbucket instances, named
+// like ghe.example.com, are accepted too.
+func parseSpec(s string, h *hosts) (*spec, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, ghPrefix) {
+		return parseCompact(s)
+	}
+	first, _, _ := strings.Cut(s, "/")
+	if strings.Contains(s, "://") || strings.Contains(first, ".") || slices.Contains(h.known(), strings.ToLower(first)) {
+		return parseURL(s, h)
+	}
+	return parseCompact(s)
+}
+
+// parseCompact parses a compact spec. Compact specs follow the grammar:
+//
+//	spec = [ "gh:" ] owner "/" repo [ "/" path ] [ "#" ref ]
+//	     | [ "gh:" ] owner "/" repo "@" ref [ ":" path ]
+//
+// For example: owner/repo, owner/repo/path/to/dir, owner/repo/path#ref,
+// owner/repo@v1.2.3:path, and gh:owner/repo/path. Without a ref, the
+// default branch is used. The ref may contain slashes.
+func parseCompact(s string) (*spec, error) {
+	s = strings.TrimPrefix(s, ghPrefix)
+	s, hashRef, hasHash := strings.Cut(s, "#")
+	s, atRef, hasAt := strings.Cut(s, "@")
+	if hasHash && hasAt {
+		return nil, formatError("ref given with both @ and #", compactFormat)
+	}
+
+	segments := strings.Split(strings.Trim(s, "/"), "/")
+	sp, err := parseRepo(segments, compactFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case hasAt:
+		if len(segments) > 2 {
+			return nil, formatError(fmt.Sprintf("unexpected path %q before @", strings.Join(segments[2:], "/")), compactFormat)
+		}
+		sp.ref, sp.path, _ = strings.Cut(atRef, ":")
+		if sp.ref == "" {
+			return nil, formatError("missing ref after @", compactFormat)
+		}
+	case hasHash:
+		if hashRef == "" {
+			return nil, formatError("missing ref after #", compactFormat)
+		}
+		sp.ref = hashRef
+		fallthrough
+	default:
+		sp.path = strings.Join(segments[2:], "/")
+	}
+	sp.path = strings.Trim(sp.path, "/")
+
+	return sp, nil
+}
+
+// parseURL parses a GitHub web, raw or API contents URL, a GitLab, Gitea or
+// Bitbucket URL, or a git clone URL. The scheme and the www subdomain are
+// optional. The path may be percent-encoded. Query strings and fragments are ignored, except
+// for the ref of API contents URLs and the file of gist URLs. Clone URLs
+// may be SSH, scp-like, or HTTPS.
+func parseURL(s string, h *hosts) (*spec, error) {
+	u, err := splitURL(s, h.known())
+	if err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if name, ok := knownHost(u, h.gitlabNames()); ok {
+		sp, err := parseGitLab(u, segments)
+		if err != nil {
+			return nil, err
+		}
+		sp.host = name
+		return sp, nil
+	}
+	if name, ok := knownHost(u, h.giteaNames()); ok {
+		sp, err := parseGitea(u, segments)
+		if err != nil {
+			return nil, err
+		}
+		sp.host = name
+		return sp, nil
+	}
+	if name, ok := knownHost(u, h.bitbucketNames()); ok {
+		parse := parseServer
+		if name == hostBitbucket {
+			parse = parseBitbucket
+		}
+		sp, err := parse(u, segments)
+		if err != nil {
+			return nil, err
+		}
+		sp.host = name
+		return sp, nil
+	}
+	if name, ok := knownHost(u, h.gitNames()); ok {
+		sp, err := parseSmart(u, segments)
+		if err != nil {
+			return nil, err
+		}
+		sp.host = name
+		return sp, nil
+	}
+	if name, ok := enterpriseHost(u, h.names()); ok {
+		sp, err := parseEnterprise(u, segments)
+		if err != nil {
+			return nil, err
+		}
+		sp.host = name
+		return sp, nil
+	}
+
+	sp, err := parseGitHub(u, segments)
+	if err != nil {
+		return nil, err
+	}
+	sp.host = hostGitHub
+
+	return sp, nil
+}
+
+// splitURL parses the URL. The scheme defaults to https, or to ssh for the
+// scp-like syntax. The port of a known host is not taken for it.
+func splitURL(s string, known []string) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	first, _, _ := strings.Cut(s, "/")
+	if !strings.Contains(s, "://") {
+		// The scp-like syntax is [user@]host:path.
+		if host, path, ok := strings.Cut(s, ":"); ok && !strings.Contains(host, "/") && !slices.Contains(known, strings.ToLower(first)) {
+			s = "ssh://" + host + "/" + path
+		} else {
+			s = "https://" + s
+		}
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotValidURL, err)
+	}
+
+	return u, nil
+}
+
+// parseGitHub parses a URL of github.com, or of its raw, API and gist
+// hosts.
+func parseGitHub(u *url.URL, segments []string) (*spec, error) {
+	host := strings.ToLower(u.Hostname())
+	switch u.Scheme {
+	case "https", "http":
+	case "ssh":
+		if host != hostGitHub {
+			return nil, fmt.Errorf("%w: unknown host %q", ErrNotValidURL, u.Host)
+		}
+		return parseClone(segments)
+	default:
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	switch host {
+	case hostGitHub, hostWWW:
+		return parseWeb(segments)
+	case hostRaw:
+		return parseRaw(segments)
+	case hostAPI:
+		return parseAPI(segments, u.Query().Get("ref"))
+	case hostGist:
+		return parseGist(segments, u.Fragment)
+	default:
+		return nil, fmt.Errorf("%w: unknown host %q", ErrNotValidURL, u.Host)
+	}
+}
+
+// enterpriseHost returns the name of the GitHub Enterprise Server host of
+// the URL, if it is one of the given hosts. The raw subdomain of a host
+// and the SSH clone URLs, which may use another port, match it too.
+func enterpriseHost(u *url.URL, enterprise []string) (string, bool) {
+	host := strings.ToLower(u.Host)
+	for _, name := range enterprise {
+		switch {
+		case host == name, host == rawSubdomain+name:
+			return name, true
+		case u.Scheme == "ssh" && strings.ToLower(u.Hostname()) == hostname(name):
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// knownHost returns the name of the host of the URL, if it is one of the
+// given hosts. The SSH clone URLs, which may use another port, match it
+// too.
+func knownHost(u *url.URL, names []string) (string, bool) {
+	host := strings.ToLower(u.Host)
+	for _, name := range names {
+		if host == name || (u.Scheme == "ssh" && strings.ToLower(u.Hostname()) == hostname(name)) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// hostname returns the host name without the port.
+func hostname(host string) string {
+	return (&url.URL{Host: host}).Hostname()
+}
+
+// parseEnterprise parses a URL of a GitHub Enterprise Server. Its web URLs
+// are like the ones of github.com, and its raw, API and gist URLs are under
+// the raw, api/v3 and gist paths of the host. Raw URLs may also be on the
+// raw subdomain of the host.
+func parseEnterprise(u *url.URL, segments []string) (*spec, error) {
+	switch {
+	case u.Scheme == "ssh":
+		return parseClone(segments)
+	case u.Scheme != "https" && u.Scheme != "http":
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	case strings.HasPrefix(strings.ToLower(u.Host), rawSubdomain):
+		return parseRaw(segments)
+	}
+
+	switch segments[0] {
+	case "raw":
+		return parseRaw(segments[1:])
+	case "api":
+		if len(segments) < 2 || segments[1] != "v3" {
+			return nil, formatError("missing \"v3\" after \"api\"", hostFormat)
+		}
+		return parseAPI(segments[2:], u.Query().Get("ref"))
+	case "gist":
+		return parseGist(segments[1:], u.Fragment)
+	}
+	return parseWeb(segments)
+}
+
+// parseGitLab parses a URL of a GitLab instance. The project path, which
+// may have nested groups, ends before the "-" segment, which is followed
+// by tree, blob or raw, the reference, and the path. Clone URLs have only
+// the project path.
+func parseGitLab(u *url.URL, segments []string) (*spec, error) {
+	switch u.Scheme {
+	case "https", "http", "ssh":
+	default:
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	project := segments
+	var rest []string
+	if i := slices.Index(segments, gitlabSeparator); i >= 0 {
+		project, rest = segments[:i], segments[i+1:]
+	}
+	if len(project) < 2 || slices.Contains(project, "") {
+		return nil, formatError("missing group or project", gitlabFormat)
+	}
+	repo := strings.TrimSuffix(project[len(project)-1], gitSuffix)
+	if repo == "" {
+		return nil, formatError("missing group or project", gitlabFormat)
+	}
+	s := &spec{owner: strings.Join(project[:len(project)-1], "/"), repo: repo}
+	if rest == nil {
+		return s, nil
+	}
+
+	if u.Scheme == "ssh" {
+		return nil, formatError(fmt.Sprintf("unexpected path %q after the project", strings.Join(segments[len(project):], "/")), gitlabFormat)
+	}
+	if len(rest) == 0 {
+		return nil, formatError(`missing segment after "-"`, gitlabFormat)
+	}
+	switch rest[0] {
+	case "tree", "blob", "raw":
+	default:
+		return nil, formatError(fmt.Sprintf("unknown segment %q after \"-\"", rest[0]), gitlabFormat)
+	}
+	if len(rest) == 1 || rest[1] == "" {
+		return nil, formatError(fmt.Sprintf("missing ref after %q", rest[0]), gitlabFormat)
+	}
+	s.refPath = strings.Join(rest[1:], "/")
+
+	return s, nil
+}
+
+// parseGitea parses a URL of a Gitea instance. The src, raw and media
+// segments after the repository are followed by the kind of the
+// reference, branch, tag or commit, the reference, and the path. The kind
+// may be missing in older URLs.
+func parseGitea(u *url.URL, segments []string) (*spec, error) {
+	switch u.Scheme {
+	case "https", "http":
+	case "ssh":
+		return parseClone(segments)
+	default:
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	s, err := parseRepo(segments, giteaFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 2 {
+		return s, nil
+	}
+
+	switch segments[2] {
+	case "src", "raw", "media":
+	default:
+		return nil, formatError(fmt.Sprintf("unknown segment %q after the repository", segments[2]), giteaFormat)
+	}
+	rest := segments[3:]
+	if len(rest) > 0 && (rest[0] == "branch" || rest[0] == "tag" || rest[0] == "commit") {
+		if rest[0] == "commit" && len(rest) > 1 {
+			s.ref = rest[1]
+			s.path = strings.Join(rest[2:], "/")
+			return s, nil
+		}
+		rest = rest[1:]
+	}
+	if len(rest) == 0 || rest[0] == "" {
+		return nil, formatError(fmt.Sprintf("missing ref after %q", segments[2]), giteaFormat)
+	}
+	s.refPath = strings.Join(rest, "/")
+
+	return s, nil
+}
+
+// parseBitbucket parses a URL of bitbucket.org. The src and raw segments
+// after the repository are followed by the reference and the path.
+func parseBitbucket(u *url.URL, segments []string) (*spec, error) {
+	switch u.Scheme {
+	case "https", "http":
+	case "ssh":
+		return parseClone(segments)
+	default:
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	s, err := parseRepo(segments, bitbucketFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 2 {
+		return s, nil
+	}
+
+	switch segments[2] {
+	case "src", "raw":
+	default:
+		return nil, formatError(fmt.Sprintf("unknown segment %q after the repository", segments[2]), bitbucketFormat)
+	}
+	if len(segments) == 3 || segments[3] == "" {
+		return nil, formatError(fmt.Sprintf("missing ref after %q", segments[2]), bitbucketFormat)
+	}
+	s.refPath = strings.Join(segments[3:], "/")
+
+	return s, nil
+}
+
+// parseServer parses a URL of a Bitbucket Server. The repositories are
+// under projects, or under users, whose owner is the user name after
+// a tilde. The browse and raw segments after the repository are followed
+// by the path, and the reference is in the at query, which may be
+// qualified like refs/heads/main. Clone URLs are under the scm path.
+func parseServer(u *url.URL, segments []string) (*spec, error) {
+	switch u.Scheme {
+	case "https", "http":
+	case "ssh":
+		return parseClone(segments)
+	default:
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	if segments[0] == "scm" {
+		s, err := parseRepo(segments[1:], serverFormat)
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) > 3 {
+			return nil, formatError(fmt.Sprintf("unexpected path %q after the repository", strings.Join(segments[3:], "/")), serverFormat)
+		}
+		return s, nil
+	}
+
+	if len(segments) < 4 || segments[1] == "" || segments[2] != "repos" || segments[3] == "" {
+		return nil, formatError("missing project or repository", serverFormat)
+	}
+	s := &spec{owner: segments[1], repo: segments[3]}
+	switch segments[0] {
+	case "projects":
+	case "users":
+		s.owner = "~" + s.owner
+	default:
+		return nil, formatError(fmt.Sprintf("unknown segment %q, expected \"projects\" or \"users\"", segments[0]), serverFormat)
+	}
+
+	rest := segments[4:]
+	if len(rest) > 0 {
+		switch rest[0] {
+		case "browse", "raw":
+		default:
+			return nil, formatError(fmt.Sprintf("unknown segment %q after the repository", rest[0]), serverFormat)
+		}
+		s.path = strings.Join(rest[1:], "/")
+	}
+	s.ref = u.Query().Get("at")
+	for _, prefix := range serverRefPrefixes {
+		if ref, ok := strings.CutPrefix(s.ref, prefix); ok {
+			s.ref = ref
+			break
+		}
+	}
+
+	return s, nil
+}
+
+// parseSmart parses the URL of a repository of a git server, which is used
+// through smart HTTP. The repository ends at the first segment with the .git
+// suffix, which is kept, and is followed by the path. Without the suffix,
+// the whole path is the repository. The reference is the fragment.
+func parseSmart(u *url.URL, segments []string) (*spec, error) {
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return nil, fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, u.Scheme)
+	}
+
+	repo, path := segments, []string(nil)
+	if i := slices.IndexFunc(segments, func(s string) bool { return strings.HasSuffix(s, gitSuffix) }); i >= 0 {
+		repo, path = segments[:i+1], segments[i+1:]
+	}
+	if slices.Contains(repo, "") || repo[len(repo)-1] == gitSuffix {
+		return nil, formatError("missing repository", smartFormat)
+	}
+
+	return &spec{
+		owner: strings.Join(repo[:len(repo)-1], "/"),
+		repo:  repo[len(repo)-1],
+		ref:   u.Fragment,
+		path:  strings.Join(path, "/"),
+	}, nil
+}
+
+// parseWeb parses the path segments of a github.com URL.
+func parseWeb(segments []string) (*spec, error) {
+	s, err := parseRepo(segments, webFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 2 {
+		return s, nil
+	}
+
+	switch segments[2] {
+	case "tree", "blob", "raw":
+	case "pull":
+		return parsePull(s, segments[3:])
+	case "compare":
+		return parseCompare(s, segments[3:])
+	default:
+		return nil, formatError(fmt.Sprintf("unknown segment %q after the repository", segments[2]), webFormat)
+	}
+	if len(segments) == 3 {
+		return nil, formatError(fmt.Sprintf("missing ref after %q", segments[2]), webFormat)
+	}
+	s.refPath = strings.Join(segments[3:], "/")
+
+	return s, nil
+}
+
+// parsePull parses the path segments after "pull" in a pull request URL.
+func parsePull(s *spec, segments []string) (*spec, error) {
+	if len(segments) == 0 {
+		return nil, formatError(`missing number after "pull"`, webFormat)
+	}
+	n, err := strconv.Atoi(segments[0])
+	if err != nil || n < 1 {
+		return nil, formatError(fmt.Sprintf("pull request number %q is not valid", segments[0]), webFormat)
+	}
+	if len(segments) > 2 || (len(segments) == 2 && segments[1] != "files") {
+		return nil, formatError(fmt.Sprintf("unexpected path %q after the pull request", strings.Join(segments[1:], "/")), webFormat)
+	}
+	s.pull = n
+
+	return s, nil
+}
+
+// parseCompare parses the path segments after "compare" in a compare URL.
+// The refs are separated by three dots, or by two.
+func parseCompare(s *spec, segments []string) (*spec, error) {
+	basehead := strings.Join(segments, "/")
+	base, head, ok := strings.Cut(basehead, "...")
+	if !ok {
+		base, head, ok = strings.Cut(basehead, "..")
+	}
+	if !ok || base == "" || head == "" {
+		return nil, formatError(fmt.Sprintf("range %q is not valid", basehead), webFormat)
+	}
+	s.since = base
+	s.ref = head
+
+	return s, nil
+}
+
+// parseClone parses the path segments of an SSH clone URL.
+func parseClone(segments []string) (*spec, error) {
+	s, err := parseRepo(segments, cloneFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 2 {
+		return nil, formatError(fmt.Sprintf("unexpected path %q after the repository", strings.Join(segments[2:], "/")), cloneFormat)
+	}
+	return s, nil
+}
+
+// parseRaw parses the path segments of a raw.githubusercontent.com URL.
+// The reference may be qualified like refs/heads/main.
+func parseRaw(segments []string) (*spec, error) {
+	s, err := parseRepo(segments, rawFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := segments[2:]
+	if len(rest) > 2 && rest[0] == "refs" && (rest[1] == "heads" || rest[1] == "tags") {
+		rest = rest[2:]
+	}
+	if len(rest) < 2 || rest[0] == "" {
+		return nil, formatError("missing ref or path after the repository", rawFormat)
+	}
+	s.refPath = strings.Join(rest, "/")
+
+	return s, nil
+}
+
+// parseAPI parses the path segments and the ref of an api.github.com
+// contents URL.
+func parseAPI(segments []string, ref string) (*spec, error) {
+	if segments[0] != "repos" {
+		return nil, formatError(fmt.Sprintf("unknown segment %q, expected \"repos\"", segments[0]), apiFormat)
+	}
+
+	s, err := parseRepo(segments[1:], apiFormat)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) < 4 || segments[3] != "contents" {
+		return nil, formatError("missing \"contents\" after the repository", apiFormat)
+	}
+	s.ref = ref
+	s.path = strings.Join(segments[4:], "/")
+
+	return s, nil
+}
+
+// parseGist parses the path segments and the fragment of a gist.github.com
+// URL.
+func parseGist(segments []string, fragment string) (*spec, error) {
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return nil, formatError("missing user or gist id", gistFormat)
+	}
+	if len(segments) > 3 {
+		return nil, formatError(fmt.Sprintf("unexpected path %q after the revision", strings.Join(segments[3:], "/")), gistFormat)
+	}
+
+	s := &spec{owner: segments[0], gist: segments[1], file: fragment}
+	if len(segments) == 3 {
+		s.ref = segments[2]
+	}
+
+	return s, nil
+}
+
+// parseRepo parses the owner and the repository from the first two path
+// segments. The .git suffix of the repository is removed, since repository
+// names can't end with it.
+func parseRepo(segments []string, want string) (*spec, error) {
+	if len(segments) < 2 || segments[0] == "" || segments[1] == "" {
+		return nil, formatError("missing owner or repository", want)
+	}
+	repo := strings.TrimSuffix(segments[1], gitSuffix)
+	if repo == "" {
+		return nil, formatError("missing owner or repository", want)
+	}
+	return &spec{owner: segments[0], repo: repo}, nil
+}
+
+// formatError returns ErrNotValidFormat with the part of the URL that was
+// not understood, and the expected format.
+func formatError(detail, want string) error {
+	return fmt.Errorf("%w: %s, want %s", ErrNotValidFormat, detail, want)
+}
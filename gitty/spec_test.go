@@ -0,0 +1,711 @@
+package gitty
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSpec(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		spec        string
+		hosts       *hosts
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "url",
+			spec:     "github.com/owner/repo/tree/main/dir",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "main/dir"},
+		},
+		{
+			name:     "scp-like clone url",
+			spec:     "git@github.com:owner/repo.git",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "ssh clone url",
+			spec:     "ssh://git@github.com/owner/repo.git",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "https clone url",
+			spec:     "https://github.com/owner/repo.git",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:        "ssh clone url with unknown host",
+			spec:        "git@git.example.com:owner/repo.git",
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
+		},
+		{
+			name:        "ssh clone url with path",
+			spec:        "ssh://git@github.com/owner/repo.git/docs",
+			expectedErr: formatError(`unexpected path "docs" after the repository`, cloneFormat),
+		},
+		{
+			name:        "ssh clone url missing repository",
+			spec:        "git@github.com:owner/.git",
+			expectedErr: formatError("missing owner or repository", cloneFormat),
+		},
+		{
+			name:     "repository",
+			spec:     "owner/repo",
+			expected: &spec{owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "path",
+			spec:     " owner/repo/path/to/dir/ ",
+			expected: &spec{owner: "owner", repo: "repo", path: "path/to/dir"},
+		},
+		{
+			name:     "path with hash ref",
+			spec:     "owner/repo/path#feature/x",
+			expected: &spec{owner: "owner", repo: "repo", ref: "feature/x", path: "path"},
+		},
+		{
+			name:     "hash ref",
+			spec:     "owner/my.repo#v1",
+			expected: &spec{owner: "owner", repo: "my.repo", ref: "v1"},
+		},
+		{
+			name:     "at ref with path",
+			spec:     "owner/repo@v1.2.3:path/to/file.go",
+			expected: &spec{owner: "owner", repo: "repo", ref: "v1.2.3", path: "path/to/file.go"},
+		},
+		{
+			name:     "at ref",
+			spec:     "owner/repo@feature/x",
+			expected: &spec{owner: "owner", repo: "repo", ref: "feature/x"},
+		},
+		{
+			name:     "gh prefix",
+			spec:     "gh:owner/repo/path",
+			expected: &spec{owner: "owner", repo: "repo", path: "path"},
+		},
+		{
+			name:        "missing repository",
+			spec:        "owner",
+			expectedErr: formatError("missing owner or repository", compactFormat),
+		},
+		{
+			name:        "both refs",
+			spec:        "owner/repo@v1#v2",
+			expectedErr: formatError("ref given with both @ and #", compactFormat),
+		},
+		{
+			name:        "path before at",
+			spec:        "owner/repo/dir@v1",
+			expectedErr: formatError(`unexpected path "dir" before @`, compactFormat),
+		},
+		{
+			name:        "missing at ref",
+			spec:        "owner/repo@:dir",
+			expectedErr: formatError("missing ref after @", compactFormat),
+		},
+		{
+			name:        "missing hash ref",
+			spec:        "owner/repo/dir#",
+			expectedErr: formatError("missing ref after #", compactFormat),
+		},
+		{
+			name:        "https url of an unconfigured host",
+			spec:        "https://gitlab.com/owner/repo/tree/branch/directory",
+			hosts:       &hosts{},
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "gitlab.com"),
+		},
+		{
+			name:        "url of an unconfigured host",
+			spec:        "gitlab.com/owner/repo/tree/branch/directory",
+			hosts:       &hosts{},
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "gitlab.com"),
+		},
+		{
+			name:     "https repository url",
+			spec:     "https://github.com/owner/repo",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "repository url",
+			spec:     "github.com/owner/repo",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:        "https url without tree",
+			spec:        "https://github.com/owner/repo/directory",
+			expectedErr: formatError(`unknown segment "directory" after the repository`, webFormat),
+		},
+		{
+			name:        "url without tree",
+			spec:        "github.com/owner/repo/directory",
+			expectedErr: formatError(`unknown segment "directory" after the repository`, webFormat),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSpec(test.spec, test.hosts)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestParseURL(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		url         string
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "web url with https://github.com/",
+			url:      "https://github.com/owner/repo/tree/branch/directory",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/directory"},
+		},
+		{
+			name:     "web url with github.com/",
+			url:      "github.com/owner/repo/tree/branch/directory1/directory2",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/directory1/directory2"},
+		},
+		{
+			name:     "web url with http:// and www",
+			url:      "http://www.github.com/owner/repo/blob/branch/file.txt",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/file.txt"},
+		},
+		{
+			name:     "web url with query and fragment",
+			url:      "https://github.com/owner/repo/blob/branch/file.md?plain=1#L10",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/file.md"},
+		},
+		{
+			name:     "web url with encoded path",
+			url:      "https://github.com/owner/repo/blob/branch/my%20dir/%C3%BCber%231.txt",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/my dir/über#1.txt"},
+		},
+		{
+			name:     "repository url",
+			url:      "github.com/owner/repo/",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "tree url",
+			url:      "github.com/owner/repo/tree/branch",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch"},
+		},
+		{
+			name:     "pull request url",
+			url:      "https://github.com/owner/repo/pull/123",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", pull: 123},
+		},
+		{
+			name:     "pull request files url",
+			url:      "github.com/owner/repo/pull/123/files",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", pull: 123},
+		},
+		{
+			name:        "pull request missing number",
+			url:         "github.com/owner/repo/pull",
+			expectedErr: formatError(`missing number after "pull"`, webFormat),
+		},
+		{
+			name:        "pull request number",
+			url:         "github.com/owner/repo/pull/abc",
+			expectedErr: formatError(`pull request number "abc" is not valid`, webFormat),
+		},
+		{
+			name:        "pull request unexpected path",
+			url:         "github.com/owner/repo/pull/1/commits",
+			expectedErr: formatError(`unexpected path "commits" after the pull request`, webFormat),
+		},
+		{
+			name:     "gist url",
+			url:      "https://gist.github.com/user/abc123",
+			expected: &spec{host: hostGitHub, owner: "user", gist: "abc123"},
+		},
+		{
+			name:     "gist url with revision and file",
+			url:      "gist.github.com/user/abc123/def456#file-hello-go",
+			expected: &spec{host: hostGitHub, owner: "user", gist: "abc123", ref: "def456", file: "file-hello-go"},
+		},
+		{
+			name:        "gist url missing id",
+			url:         "gist.github.com/user",
+			expectedErr: formatError("missing user or gist id", gistFormat),
+		},
+		{
+			name:        "gist url with extra path",
+			url:         "gist.github.com/user/abc123/def456/raw",
+			expectedErr: formatError(`unexpected path "raw" after the revision`, gistFormat),
+		},
+		{
+			name:     "compare url",
+			url:      "github.com/owner/repo/compare/v1.0...feature/x",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", since: "v1.0", ref: "feature/x"},
+		},
+		{
+			name:     "compare url with two dots",
+			url:      "github.com/owner/repo/compare/v1.0..v1.1",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", since: "v1.0", ref: "v1.1"},
+		},
+		{
+			name:        "compare url missing head",
+			url:         "github.com/owner/repo/compare/v1.0",
+			expectedErr: formatError(`range "v1.0" is not valid`, webFormat),
+		},
+		{
+			name:     "raw url",
+			url:      "https://raw.githubusercontent.com/owner/repo/branch/dir/file.txt",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "branch/dir/file.txt"},
+		},
+		{
+			name:     "raw url with qualified ref",
+			url:      "raw.githubusercontent.com/owner/repo/refs/heads/feature/x/file.txt?token=abc",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", refPath: "feature/x/file.txt"},
+		},
+		{
+			name:     "api url",
+			url:      "https://api.github.com/repos/owner/repo/contents/dir/file.txt?ref=feature/x",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo", ref: "feature/x", path: "dir/file.txt"},
+		},
+		{
+			name:     "api url without ref",
+			url:      "api.github.com/repos/owner/repo/contents",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:        "unknown host",
+			url:         "https://git.example.com/owner/repo/tree/branch/directory",
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "git.example.com"),
+		},
+		{
+			name:        "unknown scheme",
+			url:         "ftp://github.com/owner/repo",
+			expectedErr: fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, "ftp"),
+		},
+		{
+			name:        "missing repository",
+			url:         "github.com/owner",
+			expectedErr: formatError("missing owner or repository", webFormat),
+		},
+		{
+			name:        "unknown segment",
+			url:         "github.com/owner/repo/directory",
+			expectedErr: formatError(`unknown segment "directory" after the repository`, webFormat),
+		},
+		{
+			name:        "missing ref",
+			url:         "github.com/owner/repo/tree/",
+			expectedErr: formatError(`missing ref after "tree"`, webFormat),
+		},
+		{
+			name:        "raw url missing path",
+			url:         "raw.githubusercontent.com/owner/repo/branch",
+			expectedErr: formatError("missing ref or path after the repository", rawFormat),
+		},
+		{
+			name:        "api url missing repos",
+			url:         "api.github.com/users/owner",
+			expectedErr: formatError(`unknown segment "users", expected "repos"`, apiFormat),
+		},
+		{
+			name:        "api url missing contents",
+			url:         "api.github.com/repos/owner/repo/git/trees/main",
+			expectedErr: formatError(`missing "contents" after the repository`, apiFormat),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseURL(test.url, nil)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestParseURLEscape(t *testing.T) {
+	t.Parallel()
+	_, err := parseURL("github.com/owner/repo/blob/main/%zz", nil)
+	assert.ErrorIs(t, err, ErrNotValidURL)
+}
+
+func TestParseEnterpriseURL(t *testing.T) {
+	t.Parallel()
+	h := &hosts{enterprise: map[string]*enterprise{"ghe.example.com": {}, "127.0.0.1:8080": {}}}
+	tests := []struct {
+		name        string
+		url         string
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "web url",
+			url:      "https://ghe.example.com/owner/repo/tree/main/dir",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo", refPath: "main/dir"},
+		},
+		{
+			name:     "web url without scheme",
+			url:      "GHE.example.com/owner/repo",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "web url with port",
+			url:      "127.0.0.1:8080/owner/repo/blob/main/file.txt",
+			expected: &spec{host: "127.0.0.1:8080", owner: "owner", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "pull request url",
+			url:      "https://ghe.example.com/owner/repo/pull/7",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo", pull: 7},
+		},
+		{
+			name:     "raw url",
+			url:      "https://ghe.example.com/raw/owner/repo/main/file.txt",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "raw subdomain url",
+			url:      "https://raw.ghe.example.com/owner/repo/main/file.txt",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "api url",
+			url:      "https://ghe.example.com/api/v3/repos/owner/repo/contents/dir?ref=v1",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo", ref: "v1", path: "dir"},
+		},
+		{
+			name:     "gist url",
+			url:      "https://ghe.example.com/gist/user/abc123",
+			expected: &spec{host: "ghe.example.com", owner: "user", gist: "abc123"},
+		},
+		{
+			name:     "scp-like clone url",
+			url:      "git@ghe.example.com:owner/repo.git",
+			expected: &spec{host: "ghe.example.com", owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "ssh clone url with port",
+			url:      "ssh://git@127.0.0.1:2222/owner/repo.git",
+			expected: &spec{host: "127.0.0.1:8080", owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "github.com url",
+			url:      "github.com/owner/repo",
+			expected: &spec{host: hostGitHub, owner: "owner", repo: "repo"},
+		},
+		{
+			name:        "api url without version",
+			url:         "https://ghe.example.com/api/repos/owner/repo/contents",
+			expectedErr: formatError(`missing "v3" after "api"`, hostFormat),
+		},
+		{
+			name:        "unknown host",
+			url:         "https://other.example.com/owner/repo",
+			expectedErr: fmt.Errorf("%w: unknown host %q", ErrNotValidURL, "other.example.com"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSpec(test.url, h)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestParseGitLabURL(t *testing.T) {
+	t.Parallel()
+	h := &hosts{gitlab: map[string]*gitlabProvider{hostGitLab: {}, "gitlab.example.com:8443": {}}}
+	tests := []struct {
+		name        string
+		url         string
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "project url",
+			url:      "https://gitlab.com/group/project",
+			expected: &spec{host: hostGitLab, owner: "group", repo: "project"},
+		},
+		{
+			name:     "nested groups",
+			url:      "gitlab.com/group/subgroup/project/-/tree/main/docs",
+			expected: &spec{host: hostGitLab, owner: "group/subgroup", repo: "project", refPath: "main/docs"},
+		},
+		{
+			name:     "blob url",
+			url:      "https://gitlab.com/group/project/-/blob/feature/x/README.md?ref_type=heads",
+			expected: &spec{host: hostGitLab, owner: "group", repo: "project", refPath: "feature/x/README.md"},
+		},
+		{
+			name:     "raw url",
+			url:      "https://gitlab.com/group/project/-/raw/v1.0.0/file.txt",
+			expected: &spec{host: hostGitLab, owner: "group", repo: "project", refPath: "v1.0.0/file.txt"},
+		},
+		{
+			name:     "https clone url",
+			url:      "https://gitlab.com/group/subgroup/project.git",
+			expected: &spec{host: hostGitLab, owner: "group/subgroup", repo: "project"},
+		},
+		{
+			name:     "scp-like clone url",
+			url:      "git@gitlab.com:group/subgroup/project.git",
+			expected: &spec{host: hostGitLab, owner: "group/subgroup", repo: "project"},
+		},
+		{
+			name:     "self-managed url with port",
+			url:      "https://gitlab.example.com:8443/group/project/-/tree/main",
+			expected: &spec{host: "gitlab.example.com:8443", owner: "group", repo: "project", refPath: "main"},
+		},
+		{
+			name:     "self-managed ssh clone url",
+			url:      "ssh://git@gitlab.example.com:2222/group/project.git",
+			expected: &spec{host: "gitlab.example.com:8443", owner: "group", repo: "project"},
+		},
+		{
+			name:        "missing project",
+			url:         "gitlab.com/group",
+			expectedErr: formatError("missing group or project", gitlabFormat),
+		},
+		{
+			name:        "missing project before separator",
+			url:         "gitlab.com/group/-/tree/main",
+			expectedErr: formatError("missing group or project", gitlabFormat),
+		},
+		{
+			name:        "missing segment",
+			url:         "gitlab.com/group/project/-",
+			expectedErr: formatError(`missing segment after "-"`, gitlabFormat),
+		},
+		{
+			name:        "unknown segment",
+			url:         "gitlab.com/group/project/-/issues/1",
+			expectedErr: formatError(`unknown segment "issues" after "-"`, gitlabFormat),
+		},
+		{
+			name:        "missing ref",
+			url:         "gitlab.com/group/project/-/tree",
+			expectedErr: formatError(`missing ref after "tree"`, gitlabFormat),
+		},
+		{
+			name:        "path after clone url",
+			url:         "ssh://git@gitlab.com/group/project/-/tree/main",
+			expectedErr: formatError(`unexpected path "-/tree/main" after the project`, gitlabFormat),
+		},
+		{
+			name:        "unknown scheme",
+			url:         "ftp://gitlab.com/group/project",
+			expectedErr: fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, "ftp"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSpec(test.url, h)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestParseGiteaURL(t *testing.T) {
+	t.Parallel()
+	h := &hosts{gitea: map[string]*giteaProvider{hostCodeberg: {}, "git.example.com": {}}}
+	tests := []struct {
+		name        string
+		url         string
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "repository url",
+			url:      "https://codeberg.org/owner/repo",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo"},
+		},
+		{
+			name:     "branch url",
+			url:      "codeberg.org/owner/repo/src/branch/feature/x/docs",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo", refPath: "feature/x/docs"},
+		},
+		{
+			name:     "tag url",
+			url:      "https://codeberg.org/owner/repo/src/tag/v1.0.0/README.md",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo", refPath: "v1.0.0/README.md"},
+		},
+		{
+			name:     "commit url",
+			url:      "https://codeberg.org/owner/repo/src/commit/" + testHeadSHA + "/docs",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo", ref: testHeadSHA, path: "docs"},
+		},
+		{
+			name:     "raw url",
+			url:      "https://codeberg.org/owner/repo/raw/branch/main/file.txt",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "url without kind",
+			url:      "https://codeberg.org/owner/repo/src/main/file.txt",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "configured host",
+			url:      "https://git.example.com/owner/repo/src/branch/main",
+			expected: &spec{host: "git.example.com", owner: "owner", repo: "repo", refPath: "main"},
+		},
+		{
+			name:     "scp-like clone url",
+			url:      "git@codeberg.org:owner/repo.git",
+			expected: &spec{host: hostCodeberg, owner: "owner", repo: "repo"},
+		},
+		{
+			name:        "unknown segment",
+			url:         "codeberg.org/owner/repo/issues/1",
+			expectedErr: formatError(`unknown segment "issues" after the repository`, giteaFormat),
+		},
+		{
+			name:        "missing ref",
+			url:         "codeberg.org/owner/repo/src/branch",
+			expectedErr: formatError(`missing ref after "src"`, giteaFormat),
+		},
+		{
+			name:        "missing repository",
+			url:         "codeberg.org/owner",
+			expectedErr: formatError("missing owner or repository", giteaFormat),
+		},
+		{
+			name:        "unknown scheme",
+			url:         "ftp://codeberg.org/owner/repo",
+			expectedErr: fmt.Errorf("%w: unknown scheme %q", ErrNotValidURL, "ftp"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSpec(test.url, h)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
+
+func TestParseBitbucketURL(t *testing.T) {
+	t.Parallel()
+	h := &hosts{bitbucket: map[string]provider{hostBitbucket: nil, "stash.example.com": nil}}
+	tests := []struct {
+		name        string
+		url         string
+		expected    *spec
+		expectedErr error
+	}{
+		{
+			name:     "repository url",
+			url:      "https://bitbucket.org/workspace/repo",
+			expected: &spec{host: hostBitbucket, owner: "workspace", repo: "repo"},
+		},
+		{
+			name:     "source url",
+			url:      "bitbucket.org/workspace/repo/src/feature/x/docs/",
+			expected: &spec{host: hostBitbucket, owner: "workspace", repo: "repo", refPath: "feature/x/docs"},
+		},
+		{
+			name:     "raw url",
+			url:      "https://bitbucket.org/workspace/repo/raw/main/file.txt",
+			expected: &spec{host: hostBitbucket, owner: "workspace", repo: "repo", refPath: "main/file.txt"},
+		},
+		{
+			name:     "scp-like clone url",
+			url:      "git@bitbucket.org:workspace/repo.git",
+			expected: &spec{host: hostBitbucket, owner: "workspace", repo: "repo"},
+		},
+		{
+			name:     "https clone url with user",
+			url:      "https://user@bitbucket.org/workspace/repo.git",
+			expected: &spec{host: hostBitbucket, owner: "workspace", repo: "repo"},
+		},
+		{
+			name:     "server repository url",
+			url:      "https://stash.example.com/projects/PROJ/repos/repo",
+			expected: &spec{host: "stash.example.com", owner: "PROJ", repo: "repo"},
+		},
+		{
+			name:     "server browse url",
+			url:      "https://stash.example.com/projects/PROJ/repos/repo/browse/docs?at=refs%2Fheads%2Ffeature%2Fx",
+			expected: &spec{host: "stash.example.com", owner: "PROJ", repo: "repo", ref: "feature/x", path: "docs"},
+		},
+		{
+			name:     "server tag url",
+			url:      "stash.example.com/projects/PROJ/repos/repo/raw/README.md?at=refs/tags/v1.0.0",
+			expected: &spec{host: "stash.example.com", owner: "PROJ", repo: "repo", ref: "v1.0.0", path: "README.md"},
+		},
+		{
+			name:     "server commit url",
+			url:      "https://stash.example.com/projects/PROJ/repos/repo/browse?at=" + testHeadSHA,
+			expected: &spec{host: "stash.example.com", owner: "PROJ", repo: "repo", ref: testHeadSHA},
+		},
+		{
+			name:     "server personal repository url",
+			url:      "https://stash.example.com/users/jdoe/repos/repo/browse/docs",
+			expected: &spec{host: "stash.example.com", owner: "~jdoe", repo: "repo", path: "docs"},
+		},
+		{
+			name:     "server https clone url",
+			url:      "https://stash.example.com/scm/proj/repo.git",
+			expected: &spec{host: "stash.example.com", owner: "proj", repo: "repo"},
+		},
+		{
+			name:     "server ssh clone url",
+			url:      "ssh://git@stash.example.com:7999/proj/repo.git",
+			expected: &spec{host: "stash.example.com", owner: "proj", repo: "repo"},
+		},
+		{
+			name:        "unknown segment",
+			url:         "bitbucket.org/workspace/repo/pull-requests/1",
+			expectedErr: formatError(`unknown segment "pull-requests" after the repository`, bitbucketFormat),
+		},
+		{
+			name:        "missing ref",
+			url:         "bitbucket.org/workspace/repo/src",
+			expectedErr: formatError(`missing ref after "src"`, bitbucketFormat),
+		},
+		{
+			name:        "server missing repository",
+			url:         "https://stash.example.com/projects/PROJ",
+			expectedErr: formatError("missing project or repository", serverFormat),
+		},
+		{
+			name:        "server unknown segment",
+			url:         "https://stash.example.com/dashboard/PROJ/repos/repo",
+			expectedErr: formatError(`unknown segment "dashboard", expected "projects" or "users"`, serverFormat),
+		},
+		{
+			name:        "server unknown segment after the repository",
+			url:         "https://stash.example.com/projects/PROJ/repos/repo/commits",
+			expectedErr: formatError(`unknown segment "commits" after the repository`, serverFormat),
+		},
+		{
+			name:        "server clone url with path",
+			url:         "https://stash.example.com/scm/proj/repo.git/docs",
+			expectedErr: formatError(`unexpected path "docs" after the repository`, serverFormat),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := parseSpec(test.url, h)
+			assert.Equal(t, test.expectedErr, err)
+			assert.Equal(t, test.expected, s)
+		})
+	}
+}
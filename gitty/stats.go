@@ -0,0 +1,135 @@
+package gitty
+
+import (
+	"sync"
+
+	"github.com/google/go-github/v70/github"
+)
+
+// Content types which are not downloaded.
+const (
+	typeSubmodule = "submodule"
+	typeSymlink   = "symlink"
+	typeFiltered  = "filtered"
+)
+
+// stats collects the counters of a download. It is safe for concurrent use.
+type stats struct {
+	mu         sync.Mutex
+	files      []*File
+	deleted    []string
+	dirs       int
+	calls      int
+	remaining  int
+	submodules int
+	symlinks   int
+	filtered   int
+	retries    int
+	failures   int
+}
+
+// reset clears the counters.
+func (s *stats) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files, s.deleted = nil, nil
+	s.dirs, s.calls = 0, 0
+	s.submodules, s.symlinks, s.filtered = 0, 0, 0
+	s.retries, s.failures = 0, 0
+	s.remaining = -1
+}
+
+// call counts a call of the GitHub client and records the remaining rate
+// limit, if reported.
+func (s *stats) call(resp *github.Response) {
+	s.reply(githubReply(resp))
+}
+
+// reply counts an API call and records the remaining rate limit, if
+// reported.
+func (s *stats) reply(r *reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if r != nil && r.limit > 0 {
+		s.remaining = r.remaining
+	}
+}
+
+// dir counts a fetched directory.
+func (s *stats) dir() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirs++
+}
+
+// file records a downloaded file.
+func (s *stats) file(f *File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, f)
+}
+
+// skip counts a skipped content of the given type.
+func (s *stats) skip(typ string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch typ {
+	case typeSubmodule:
+		s.submodules++
+	case typeSymlink:
+		s.symlinks++
+	default:
+		s.filtered++
+	}
+}
+
+// delete records a deleted file.
+func (s *stats) delete(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deleted = append(s.deleted, path)
+}
+
+// retry counts a retried request.
+func (s *stats) retry() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retries++
+}
+
+// fail counts a failed file.
+func (s *stats) fail() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures++
+}
+
+// result returns a snapshot of the counters as a download result.
+func (s *stats) result() *DownloadResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &DownloadResult{
+		Files:   append([]*File{}, s.files...),
+		Deleted: append([]string{}, s.deleted...),
+		Summary: &Summary{
+			Directories:        s.dirs,
+			APICalls:           s.calls,
+			RateLimitRemaining: s.remaining,
+			Skipped: Skipped{
+				Submodules: s.submodules,
+				Symlinks:   s.symlinks,
+				Filtered:   s.filtered,
+			},
+			Retries:  s.retries,
+			Failures: s.failures,
+		},
+	}
+}
+
+// exhausted reports whether the API reported no remaining rate limit.
+func (s *stats) exhausted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remaining == 0
+}
@@ -0,0 +1,59 @@
+package gitty
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+	s := &stats{}
+	s.reset()
+
+	wg := &sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.call(nil)
+			s.dir()
+			s.file(&File{})
+			s.skip(typeSubmodule)
+			s.skip(typeSymlink)
+			s.skip(typeFiltered)
+			s.retry()
+			s.fail()
+			s.delete("deleted.txt")
+		}()
+	}
+	wg.Wait()
+
+	res := s.result()
+	assert.Len(t, res.Files, 10)
+	assert.Len(t, res.Deleted, 10)
+	assert.Equal(t, &Summary{
+		Directories:        10,
+		APICalls:           10,
+		RateLimitRemaining: -1,
+		Skipped: Skipped{
+			Submodules: 10,
+			Symlinks:   10,
+			Filtered:   10,
+		},
+		Retries:  10,
+		Failures: 10,
+	}, res.Summary)
+
+	s.call(&github.Response{Rate: github.Rate{Limit: 60, Remaining: 42}})
+	assert.Equal(t, 42, s.result().Summary.RateLimitRemaining)
+
+	s.reset()
+	assert.Equal(t, &DownloadResult{
+		Files:   []*File{},
+		Deleted: []string{},
+		Summary: &Summary{RateLimitRemaining: -1},
+	}, s.result())
+}
@@ -2,17 +2,100 @@ package token
 
 import (
 	"os"
+	"strings"
+	"unicode"
 
 	"github.com/worlpaker/gitty/gitty/token/script"
 )
 
 const key = "GH_TOKEN"
 
+// enterpriseKey is the token of the GitHub Enterprise Server hosts which
+// have no token of their own.
+const enterpriseKey = "GH_ENTERPRISE_TOKEN"
+
+// githubHost is the host which uses the GH_TOKEN.
+const githubHost = "github.com"
+
+// Tokens of the GitLab, Gitea, Bitbucket and plain git hosts which have no
+// token of their own.
+const (
+	gitlabKey    = "GITLAB_TOKEN"
+	giteaKey     = "GITEA_TOKEN"
+	bitbucketKey = "BITBUCKET_TOKEN"
+	gitKey       = "GIT_TOKEN"
+)
+
 // Get retrieves the GitHub token from the environment variable.
 func Get() string {
 	return os.Getenv(key)
 }
 
+// ForHost retrieves the token of the GitHub host from the environment
+// variables. github.com uses GH_TOKEN. Other hosts use their own variable,
+// like GH_TOKEN_GHE_EXAMPLE_COM for ghe.example.com, or GH_ENTERPRISE_TOKEN.
+func ForHost(host string) string {
+	if host == "" || host == githubHost {
+		return Get()
+	}
+	if t := os.Getenv(hostKey(key, host)); t != "" {
+		return t
+	}
+	return os.Getenv(enterpriseKey)
+}
+
+// ForGitLab retrieves the token of the GitLab host from the environment
+// variables. Each host may have its own variable, like
+// GITLAB_TOKEN_GITLAB_EXAMPLE_COM for gitlab.example.com. Otherwise, it
+// uses GITLAB_TOKEN.
+func ForGitLab(host string) string {
+	return forHost(gitlabKey, host)
+}
+
+// ForGitea retrieves the token of the Gitea host, like Forgejo or
+// codeberg.org, from the environment variables, like ForGitLab does. It
+// uses GITEA_TOKEN_CODEBERG_ORG for codeberg.org, or GITEA_TOKEN.
+func ForGitea(host string) string {
+	return forHost(giteaKey, host)
+}
+
+// ForBitbucket retrieves the token of the Bitbucket host, bitbucket.org or
+// a Bitbucket Server, from the environment variables, like ForGitLab does.
+// It is an HTTP access token, or a username and an app password separated
+// by a colon.
+func ForBitbucket(host string) string {
+	return forHost(bitbucketKey, host)
+}
+
+// ForGit retrieves the token of the plain git host from the environment
+// variables, like ForGitLab does. It is a username and a password separated
+// by a colon, or a bearer token.
+func ForGit(host string) string {
+	return forHost(gitKey, host)
+}
+
+// forHost retrieves the token of the host from its own variable, or from
+// the variable of all the hosts, which is the prefix.
+func forHost(prefix, host string) string {
+	if t := os.Getenv(hostKey(prefix, host)); t != "" {
+		return t
+	}
+	return os.Getenv(prefix)
+}
+
+// hostKey returns the name of the environment variable of the token of the
+// host, which starts with the prefix. Letters are upper-cased, and other
+// characters are replaced by underscores.
+func hostKey(prefix, host string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, host)
+	return prefix + "_" + name
+}
+
 // Set sets the provided GitHub token in the environment variable.
 func Set(token string) error {
 	return script.Run(key, token)
@@ -35,3 +35,56 @@ func TestToken(t *testing.T) {
 	}
 	require.NoError(t, err)
 }
+
+func TestHostKey(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "GH_TOKEN_GHE_EXAMPLE_COM", hostKey(key, "ghe.example.com"))
+	require.Equal(t, "GH_TOKEN_GHE_EXAMPLE_COM_8443", hostKey(key, "GHE.example.com:8443"))
+	require.Equal(t, "GITLAB_TOKEN_GITLAB_EXAMPLE_COM_8443", hostKey(gitlabKey, "gitlab.example.com:8443"))
+}
+
+func TestForHost(t *testing.T) {
+	t.Setenv(key, "github")
+	t.Setenv(enterpriseKey, "enterprise")
+	t.Setenv("GH_TOKEN_GHE_EXAMPLE_COM", "ghe")
+
+	require.Equal(t, "github", ForHost(""))
+	require.Equal(t, "github", ForHost(githubHost))
+	require.Equal(t, "ghe", ForHost("ghe.example.com"))
+	require.Equal(t, "enterprise", ForHost("other.example.com"))
+}
+
+func TestForGitLab(t *testing.T) {
+	t.Setenv(gitlabKey, "gitlab")
+	t.Setenv("GITLAB_TOKEN_GITLAB_EXAMPLE_COM", "self-managed")
+
+	require.Equal(t, "gitlab", ForGitLab("gitlab.com"))
+	require.Equal(t, "self-managed", ForGitLab("gitlab.example.com"))
+}
+
+func TestForGitea(t *testing.T) {
+	t.Setenv(giteaKey, "gitea")
+	t.Setenv(hostKey(giteaKey, "codeberg.org"), "codeberg")
+
+	require.Equal(t, "GITEA_TOKEN_CODEBERG_ORG", hostKey(giteaKey, "codeberg.org"))
+	require.Equal(t, "codeberg", ForGitea("codeberg.org"))
+	require.Equal(t, "gitea", ForGitea("git.example.com"))
+}
+
+func TestForBitbucket(t *testing.T) {
+	t.Setenv(bitbucketKey, "bitbucket")
+	t.Setenv(hostKey(bitbucketKey, "bitbucket.org"), "user:app-password")
+
+	require.Equal(t, "BITBUCKET_TOKEN_BITBUCKET_ORG", hostKey(bitbucketKey, "bitbucket.org"))
+	require.Equal(t, "user:app-password", ForBitbucket("bitbucket.org"))
+	require.Equal(t, "bitbucket", ForBitbucket("stash.example.com"))
+}
+
+func TestForGit(t *testing.T) {
+	t.Setenv(gitKey, "git")
+	t.Setenv(hostKey(gitKey, "git.example.com"), "user:password")
+
+	require.Equal(t, "GIT_TOKEN_GIT_EXAMPLE_COM", hostKey(gitKey, "git.example.com"))
+	require.Equal(t, "user:password", ForGit("git.example.com"))
+	require.Equal(t, "git", ForGit("cgit.example.com"))
+}
@@ -0,0 +1,227 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// exeSuffix is the suffix of Windows executables.
+const exeSuffix = ".exe"
+
+var ErrNoExecutable = errors.New("no executable found in the release asset")
+
+// entry represents a regular file in an archive.
+type entry struct {
+	name string
+	mode os.FileMode
+}
+
+// unpack extracts the executable from the release asset in the given format
+// and writes it into dir. An executable named after name is preferred in
+// archives, otherwise the only executable is used. A binary asset is written
+// as name. It returns the path of the executable.
+func unpack(f *os.File, format, name, goos, dir string) (string, error) {
+	exe := name
+	if goos == "windows" {
+		exe = strings.TrimSuffix(name, exeSuffix) + exeSuffix
+	}
+
+	switch format {
+	case formatBinary:
+		return writeExecutable(dir, exe, f)
+	case formatGz:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return "", err
+		}
+		defer zr.Close()
+		return writeExecutable(dir, exe, zr)
+	case formatZip:
+		return unpackZip(f, exe, goos, dir)
+	default:
+		return unpackTar(f, format, exe, goos, dir)
+	}
+}
+
+// unpackZip extracts the executable from the zip archive.
+func unpackZip(f *os.File, exe, goos, dir string) (string, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		return "", err
+	}
+
+	var entries []entry
+	for _, zf := range zr.File {
+		if zf.Mode().IsRegular() {
+			entries = append(entries, entry{name: zf.Name, mode: zf.Mode()})
+		}
+	}
+	chosen, err := chooseExecutable(entries, exe, goos)
+	if err != nil {
+		return "", err
+	}
+
+	rc, err := zr.Open(chosen)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	return writeExecutable(dir, path.Base(chosen), rc)
+}
+
+// unpackTar extracts the executable from the tar archive. The archive is
+// read twice: to choose the executable, and to extract it.
+func unpackTar(f *os.File, format, exe, goos, dir string) (string, error) {
+	var entries []entry
+	err := walkTar(f, format, func(h *tar.Header, _ io.Reader) (bool, error) {
+		if h.Typeflag == tar.TypeReg {
+			entries = append(entries, entry{name: h.Name, mode: h.FileInfo().Mode()})
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	chosen, err := chooseExecutable(entries, exe, goos)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	var p string
+	err = walkTar(f, format, func(h *tar.Header, r io.Reader) (bool, error) {
+		if h.Typeflag != tar.TypeReg || h.Name != chosen {
+			return false, nil
+		}
+		var errWrite error
+		p, errWrite = writeExecutable(dir, path.Base(chosen), r)
+		return true, errWrite
+	})
+
+	return p, err
+}
+
+// walkTar calls fn for each entry of the tar archive until fn returns true
+// or an error.
+func walkTar(f io.Reader, format string, fn func(h *tar.Header, r io.Reader) (bool, error)) error {
+	var r io.Reader
+	switch format {
+	case formatTarGz:
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case formatTarZst:
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case formatTarBz2:
+		r = bzip2.NewReader(f)
+	case formatTar:
+		r = f
+	default:
+		return fmt.Errorf("%w: unknown format %q", ErrNoExecutable, format)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		done, err := fn(h, tr)
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// chooseExecutable chooses the entry named exe, with the shortest path if
+// there are several. Otherwise, it chooses the only executable entry. On
+// Windows, executables are the .exe files.
+func chooseExecutable(entries []entry, exe, goos string) (string, error) {
+	var named, executables []string
+	for _, e := range entries {
+		if path.Base(e.name) == exe {
+			named = append(named, e.name)
+		}
+		if (goos == "windows" && strings.HasSuffix(e.name, exeSuffix)) || (goos != "windows" && e.mode&0o111 != 0) {
+			executables = append(executables, e.name)
+		}
+	}
+
+	switch {
+	case len(named) > 0:
+		chosen := named[0]
+		for _, n := range named[1:] {
+			if len(n) < len(chosen) {
+				chosen = n
+			}
+		}
+		return chosen, nil
+	case len(executables) == 1:
+		return executables[0], nil
+	case len(executables) == 0:
+		return "", fmt.Errorf("%w: want %s", ErrNoExecutable, exe)
+	default:
+		return "", fmt.Errorf("%w: want %s, found %s", ErrNoExecutable, exe, strings.Join(executables, ", "))
+	}
+}
+
+// writeExecutable writes the executable into dir. It replaces the existing
+// executable, if any, only after it is fully written.
+func writeExecutable(dir, name string, r io.Reader) (string, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, "."+name+"-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Chmod(0o755); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	p := filepath.Join(dir, name)
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", err
+	}
+
+	return p, nil
+}
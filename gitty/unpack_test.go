@@ -0,0 +1,266 @@
+package gitty
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tarData returns a tar archive of the entries, which map names to modes.
+// The content of each entry is its name.
+func tarData(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: int64(e.mode), Size: int64(len(e.name)), Typeflag: tar.TypeReg})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(e.name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+// zipData returns a zip archive of the entries. The content of each entry
+// is its name.
+func zipData(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name}
+		h.SetMode(e.mode)
+		w, err := zw.CreateHeader(h)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(e.name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+// compress compresses the data with the writer.
+func compress(t *testing.T, data []byte, newWriter func(w io.Writer) io.WriteCloser) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func gzipWriter(w io.Writer) io.WriteCloser {
+	return gzip.NewWriter(w)
+}
+
+func zstdWriter(w io.Writer) io.WriteCloser {
+	zw, _ := zstd.NewWriter(w)
+	return zw
+}
+
+func TestUnpack(t *testing.T) {
+	t.Parallel()
+	entries := []entry{
+		{name: "tool_1.0.0/README.md", mode: 0o644},
+		{name: "tool_1.0.0/tool", mode: 0o755},
+	}
+	single := []entry{
+		{name: "LICENSE", mode: 0o644},
+		{name: "bin/other", mode: 0o755},
+	}
+	several := []entry{
+		{name: "a", mode: 0o755},
+		{name: "b", mode: 0o755},
+	}
+
+	tests := []struct {
+		name            string
+		data            []byte
+		format          string
+		goos            string
+		expectedName    string
+		expectedContent string
+		expectedErr     error
+	}{
+		{
+			name:            "tar.gz",
+			data:            compress(t, tarData(t, entries), gzipWriter),
+			format:          formatTarGz,
+			goos:            "linux",
+			expectedName:    "tool",
+			expectedContent: "tool_1.0.0/tool",
+		},
+		{
+			name:            "tar.zst",
+			data:            compress(t, tarData(t, entries), zstdWriter),
+			format:          formatTarZst,
+			goos:            "linux",
+			expectedName:    "tool",
+			expectedContent: "tool_1.0.0/tool",
+		},
+		{
+			name:            "tar with the only executable",
+			data:            tarData(t, single),
+			format:          formatTar,
+			goos:            "linux",
+			expectedName:    "other",
+			expectedContent: "bin/other",
+		},
+		{
+			name:            "zip",
+			data:            zipData(t, entries),
+			format:          formatZip,
+			goos:            "linux",
+			expectedName:    "tool",
+			expectedContent: "tool_1.0.0/tool",
+		},
+		{
+			name:            "zip on windows",
+			data:            zipData(t, []entry{{name: "tool.exe", mode: 0o644}, {name: "README.md", mode: 0o644}}),
+			format:          formatZip,
+			goos:            "windows",
+			expectedName:    "tool.exe",
+			expectedContent: "tool.exe",
+		},
+		{
+			name:            "gz",
+			data:            compress(t, []byte("binary"), gzipWriter),
+			format:          formatGz,
+			goos:            "linux",
+			expectedName:    "tool",
+			expectedContent: "binary",
+		},
+		{
+			name:            "binary",
+			data:            []byte("binary"),
+			format:          formatBinary,
+			goos:            "linux",
+			expectedName:    "tool",
+			expectedContent: "binary",
+		},
+		{
+			name:            "binary on windows",
+			data:            []byte("binary"),
+			format:          formatBinary,
+			goos:            "windows",
+			expectedName:    "tool.exe",
+			expectedContent: "binary",
+		},
+		{
+			name:        "error several executables",
+			data:        tarData(t, several),
+			format:      formatTar,
+			goos:        "linux",
+			expectedErr: fmt.Errorf("%w: want %s, found %s", ErrNoExecutable, "tool", "a, b"),
+		},
+		{
+			name:        "error no executable",
+			data:        zipData(t, []entry{{name: "README.md", mode: 0o644}}),
+			format:      formatZip,
+			goos:        "linux",
+			expectedErr: fmt.Errorf("%w: want %s", ErrNoExecutable, "tool"),
+		},
+		{
+			name:        "error gzip",
+			data:        []byte("not a gzip stream"),
+			format:      formatGz,
+			goos:        "linux",
+			expectedErr: gzip.ErrHeader,
+		},
+		{
+			name:        "error tar.gz",
+			data:        []byte("not a gzip stream"),
+			format:      formatTarGz,
+			goos:        "linux",
+			expectedErr: gzip.ErrHeader,
+		},
+		{
+			name:        "error zip",
+			data:        []byte("not zip"),
+			format:      formatZip,
+			goos:        "linux",
+			expectedErr: zip.ErrFormat,
+		},
+		{
+			name:        "error format",
+			data:        []byte("data"),
+			format:      "rar",
+			goos:        "linux",
+			expectedErr: fmt.Errorf("%w: unknown format %q", ErrNoExecutable, "rar"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			name := filepath.Join(dir, "asset")
+			err := os.WriteFile(name, test.data, 0o600)
+			require.NoError(t, err)
+			f, err := os.Open(name)
+			require.NoError(t, err)
+			defer f.Close()
+
+			bin := filepath.Join(dir, "bin")
+			p, err := unpack(f, test.format, "tool", test.goos, bin)
+			assert.Equal(t, test.expectedErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, filepath.Join(bin, test.expectedName), p)
+			b, err := os.ReadFile(p)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedContent, string(b))
+
+			info, err := os.Stat(p)
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o755), info.Mode().Perm())
+
+			// Only the executable is left in the bin directory.
+			files, err := os.ReadDir(bin)
+			require.NoError(t, err)
+			assert.Len(t, files, 1)
+		})
+	}
+}
+
+func TestChooseExecutable(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		entries  []entry
+		expected string
+	}{
+		{
+			name:     "shortest named",
+			entries:  []entry{{name: "tool/completions/tool", mode: 0o644}, {name: "tool/tool", mode: 0o755}},
+			expected: "tool/tool",
+		},
+		{
+			name:     "named without executable bit",
+			entries:  []entry{{name: "tool", mode: 0o644}, {name: "helper", mode: 0o755}},
+			expected: "tool",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			chosen, err := chooseExecutable(test.entries, "tool", "linux")
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, chosen)
+		})
+	}
+}
@@ -0,0 +1,344 @@
+package gitty
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Verification modes of the release assets.
+const (
+	// verifyAuto verifies the assets which have checksums.
+	verifyAuto = "auto"
+	// verifyRequired fails if an asset has no checksum.
+	verifyRequired = "required"
+	// verifyOff skips the verification.
+	verifyOff = "off"
+)
+
+// Suffixes of the checksum files and their signatures.
+const (
+	sha256Suffix   = ".sha256"
+	cosignSuffix   = ".sig"
+	minisignSuffix = ".minisig"
+)
+
+// maxChecksumSize represents the maximum size of a checksum or signature file.
+const maxChecksumSize = 1 << 20
+
+// Minisign key and signature attributes. The keys and the legacy signatures
+// are pure Ed25519, and the signatures of minisign 0.10 and later are over
+// the BLAKE2b-512 prehash of the file.
+const (
+	minisignAlg      = "Ed"
+	minisignHashAlg  = "ED"
+	minisignKeySize  = 2 + 8 + ed25519.PublicKeySize
+	minisignSigSize  = 2 + 8 + ed25519.SignatureSize
+	trustedComment   = "trusted comment: "
+	untrustedComment = "untrusted comment: "
+)
+
+var (
+	ErrNotValidVerify   = errors.New("verify mode must be one of auto, required or off")
+	ErrNoChecksum       = errors.New("no checksum found for the release asset")
+	ErrChecksumMismatch = errors.New("checksum of the release asset does not match")
+	ErrNotValidKey      = errors.New("public key must be a minisign key or a PEM encoded ECDSA or Ed25519 key")
+	ErrNoSignature      = errors.New("no signature found for the checksum file")
+	ErrBadSignature     = errors.New("signature of the checksum file is not valid")
+)
+
+// publicKey verifies the detached signatures of the checksum files.
+type publicKey interface {
+	// suffix returns the suffix of the signature file of a checksum file.
+	suffix() string
+	// verify verifies the signature file of the message.
+	verify(msg, sig []byte) error
+}
+
+// minisignKey represents a minisign Ed25519 public key.
+type minisignKey struct {
+	id  []byte
+	key ed25519.PublicKey
+}
+
+// pemKey represents a PEM encoded ECDSA or Ed25519 public key, which signs
+// like cosign: the signature file holds the base64 signature.
+type pemKey struct {
+	key any
+}
+
+// verifyMode returns the verification mode of the release assets.
+func (o *Options) verifyMode() (string, error) {
+	if o == nil || o.Verify == "" {
+		return verifyAuto, nil
+	}
+	switch o.Verify {
+	case verifyAuto, verifyRequired, verifyOff:
+		return o.Verify, nil
+	default:
+		return "", ErrNotValidVerify
+	}
+}
+
+// loadChecksums downloads the checksum files of the release, and verifies
+// their signatures if opts.PublicKey is set. It records the checksums of
+// the assets to verify them as they are downloaded. A public key makes the
+// verification required in auto mode, since the assets without a signed
+// checksum would be trusted otherwise.
+func (g *GitHub) loadChecksums(ctx context.Context, assets []*releaseAsset) error {
+	mode, err := g.opts.verifyMode()
+	if err != nil {
+		return err
+	}
+	g.verify = mode
+	if mode == verifyOff {
+		return nil
+	}
+
+	var key publicKey
+	if g.opts != nil && g.opts.PublicKey != "" {
+		if key, err = parsePublicKey(g.opts.PublicKey); err != nil {
+			return err
+		}
+		mode = verifyRequired
+		g.verify = mode
+	}
+
+	byName := make(map[string]*releaseAsset, len(assets))
+	for _, asset := range assets {
+		byName[asset.name] = asset
+	}
+
+	g.sums = make(map[string]string)
+	for _, asset := range assets {
+		if !isChecksumFile(asset.name) {
+			continue
+		}
+		data, err := g.readAsset(ctx, asset)
+		if err != nil {
+			return fmt.Errorf("failed to load checksums: %w", err)
+		}
+		if key != nil {
+			sig, ok := byName[asset.name+key.suffix()]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrNoSignature, asset.name)
+			}
+			sigData, err := g.readAsset(ctx, sig)
+			if err != nil {
+				return fmt.Errorf("failed to load checksums: %w", err)
+			}
+			if err := key.verify(data, sigData); err != nil {
+				return fmt.Errorf("%w: %s", err, asset.name)
+			}
+		}
+		for name, sum := range parseChecksums(asset.name, data) {
+			g.sums[name] = sum
+		}
+	}
+	if len(g.sums) == 0 && mode == verifyRequired {
+		return fmt.Errorf("%w: no checksum file in the release", ErrNoChecksum)
+	}
+
+	return nil
+}
+
+// readAsset reads the small release asset into memory.
+func (g *GitHub) readAsset(ctx context.Context, asset *releaseAsset) ([]byte, error) {
+	if err := g.engine.budget(); err != nil {
+		return nil, err
+	}
+	rc, err := g.openAsset(ctx, asset)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(io.LimitReader(rc, maxChecksumSize))
+}
+
+// verifyAsset compares the SHA-256 checksum of the downloaded asset with
+// the one in the checksum files. The checksum files and their signatures
+// are not verified.
+func (g *GitHub) verifyAsset(name, sum string) error {
+	if g.verify == verifyOff || isChecksumFile(name) || isSignatureFile(name) {
+		return nil
+	}
+
+	want, ok := g.sums[name]
+	switch {
+	case !ok && g.verify == verifyRequired:
+		return fmt.Errorf("%w: %s", ErrNoChecksum, name)
+	case !ok:
+		return nil
+	case want != sum:
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, name)
+	}
+	fmt.Fprintln(g.opts.log(), "Verified:", name)
+
+	return nil
+}
+
+// discard removes the file which failed the verification from the disk.
+// Archives are discarded as a whole when the run fails.
+func (g *GitHub) discard(f *File) {
+	if g.engine.archive != nil {
+		return
+	}
+	if err := os.Remove(f.LocalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		fmt.Fprintln(g.opts.log(), "Failed to remove:", f.LocalPath)
+	}
+}
+
+// isChecksumFile reports whether the release asset holds SHA-256 checksums,
+// like SHA256SUMS, checksums.txt, gitty_1.0.0_checksums.txt or
+// gitty.tar.gz.sha256.
+func isChecksumFile(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "sha256sums" || strings.HasSuffix(lower, "checksums.txt") || strings.HasSuffix(lower, sha256Suffix)
+}
+
+// isSignatureFile reports whether the release asset is a detached signature.
+func isSignatureFile(name string) bool {
+	return strings.HasSuffix(name, cosignSuffix) || strings.HasSuffix(name, minisignSuffix)
+}
+
+// parseChecksums parses the lines of the checksum file like "<sha256>  name"
+// or "<sha256> *name". A .sha256 file may hold only the checksum of the file
+// it is named after.
+func parseChecksums(file string, data []byte) map[string]string {
+	sums := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !isSHA256(fields[0]) {
+			continue
+		}
+		sum := strings.ToLower(fields[0])
+		switch {
+		case len(fields) > 1:
+			name := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+			sums[name] = sum
+		case strings.HasSuffix(file, sha256Suffix):
+			sums[strings.TrimSuffix(file, sha256Suffix)] = sum
+		}
+	}
+
+	return sums
+}
+
+// isSHA256 reports whether s is a hex encoded SHA-256 checksum.
+func isSHA256(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+// parsePublicKey parses a PEM encoded ECDSA or Ed25519 public key, or a
+// minisign public key, which may be the content of its .pub file.
+func parsePublicKey(s string) (publicKey, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNotValidKey, err)
+		}
+		switch key.(type) {
+		case *ecdsa.PublicKey, ed25519.PublicKey:
+			return &pemKey{key: key}, nil
+		default:
+			return nil, ErrNotValidKey
+		}
+	}
+
+	var line string
+	for _, l := range strings.Split(strings.TrimSpace(s), "\n") {
+		if !strings.HasPrefix(l, untrustedComment) {
+			line = strings.TrimSpace(l)
+		}
+	}
+	b, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(b) != minisignKeySize || string(b[:2]) != minisignAlg {
+		return nil, ErrNotValidKey
+	}
+
+	return &minisignKey{id: b[2:10], key: ed25519.PublicKey(b[10:])}, nil
+}
+
+// suffix returns the suffix of minisign signature files.
+func (k *minisignKey) suffix() string {
+	return minisignSuffix
+}
+
+// verify verifies the minisign signature file, which holds an untrusted
+// comment, the signature, a trusted comment, and the global signature over
+// the signature and the trusted comment. The signature is over the message
+// or over its prehash.
+func (k *minisignKey) verify(msg, sig []byte) error {
+	lines := strings.Split(strings.TrimSpace(string(sig)), "\n")
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], trustedComment) {
+		return ErrBadSignature
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(b) != minisignSigSize || !bytes.Equal(b[2:10], k.id) {
+		return ErrBadSignature
+	}
+	switch string(b[:2]) {
+	case minisignAlg:
+	case minisignHashAlg:
+		sum := blake2b512(msg)
+		msg = sum[:]
+	default:
+		return ErrBadSignature
+	}
+	if !ed25519.Verify(k.key, msg, b[10:]) {
+		return ErrBadSignature
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return ErrBadSignature
+	}
+	comment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), trustedComment)
+	if !ed25519.Verify(k.key, append(b[10:], comment...), global) {
+		return ErrBadSignature
+	}
+
+	return nil
+}
+
+// suffix returns the suffix of cosign signature files.
+func (k *pemKey) suffix() string {
+	return cosignSuffix
+}
+
+// verify verifies the base64 signature. ECDSA signatures are over the
+// SHA-256 digest of the message.
+func (k *pemKey) verify(msg, sig []byte) error {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil {
+		return ErrBadSignature
+	}
+
+	var ok bool
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		ok = ecdsa.VerifyASN1(key, digest[:], b)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(key, msg, b)
+	}
+	if !ok {
+		return ErrBadSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,447 @@
+package gitty
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/google/go-github/v70/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// releaseServer serves fixture releases of the repositories of owner with
+// the given assets. The latest release is v1.0.0. The asset API redirects
+// to the content of the asset, like GitHub.
+func releaseServer(t *testing.T, assets map[string][]byte) *github.Client {
+	t.Helper()
+	names := make([]string, 0, len(assets))
+	for name := range assets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	release := func(w http.ResponseWriter, tag string) {
+		rel := &github.RepositoryRelease{TagName: ptr(tag)}
+		for i, name := range names {
+			rel.Assets = append(rel.Assets, &github.ReleaseAsset{ID: ptr(int64(i)), Name: ptr(name)})
+		}
+		err := json.NewEncoder(w).Encode(rel)
+		assert.NoError(t, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/owner/{repo}/releases/latest", func(w http.ResponseWriter, _ *http.Request) {
+		release(w, "v1.0.0")
+	})
+	mux.HandleFunc("GET /repos/owner/{repo}/releases/tags/{tag}", func(w http.ResponseWriter, r *http.Request) {
+		release(w, r.PathValue("tag"))
+	})
+	mux.HandleFunc("GET /repos/owner/{repo}/releases/assets/{id}", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		id, err := strconv.Atoi(r.PathValue("id"))
+		assert.NoError(t, err)
+		http.Redirect(w, r, "/download/"+names[id], http.StatusFound)
+	})
+	mux.HandleFunc("GET /download/{name}", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write(assets[r.PathValue("name")])
+		assert.NoError(t, err)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	c := github.NewClient(nil)
+	c.BaseURL, _ = url.Parse(srv.URL + "/")
+
+	return c
+}
+
+// Minisign key and prehashed signature of minisignSums, in the format of
+// minisign 0.10 and later, which signs the BLAKE2b-512 digest of the file.
+// The key is the one of the first test vector of RFC 8032.
+const (
+	minisignSums      = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  app_linux_amd64.tar.gz\n"
+	minisignHashedPub = `untrusted comment: minisign public key 81706F5E4D3C2B1A
+RWQaKzxNXm9wgddamAGCsQq31Uv+08lkBzoO4XLz2qYjJa8CGmj3B1Ea
+`
+	minisignHashedSig = `untrusted comment: signature from minisign secret key
+RUQaKzxNXm9wge1az129t3T4cF184X0qFSAN/NW678lG3MYIpLxkwWxMMVfNaLcNBaVL8exKYV52LJrZ5R2KB8xJpTpBAjlLZgw=
+trusted comment: timestamp:1700000000	file:SHA256SUMS	hashed
+hAJ9pKM8CbtDdJMf0iGOJm6QTGi+K39Dq+hf0BPt3nymk2NZIyxQLFlFsx4R7ApQNA6tAU4j3Jhm9x3wiqSsBg==
+`
+)
+
+// minisign returns the minisign public key and the signature file of msg.
+func minisign(t *testing.T, msg []byte) (string, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id := []byte("keyid123")
+
+	key := append(append([]byte(minisignAlg), id...), pub...)
+	sig := ed25519.Sign(priv, msg)
+	comment := "timestamp:1700000000"
+	global := ed25519.Sign(priv, append(append([]byte{}, sig...), comment...))
+
+	file := fmt.Sprintf("%sminisign signature\n%s\n%s%s\n%s\n",
+		untrustedComment,
+		base64.StdEncoding.EncodeToString(append(append([]byte(minisignAlg), id...), sig...)),
+		trustedComment, comment,
+		base64.StdEncoding.EncodeToString(global),
+	)
+	pubFile := untrustedComment + "minisign public key\n" + base64.StdEncoding.EncodeToString(key) + "\n"
+
+	return pubFile, []byte(file)
+}
+
+// cosign returns the PEM encoded ECDSA public key and the signature of msg.
+func cosign(t *testing.T, msg []byte) (string, []byte) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(msg)
+	sig, err := ecdsa.SignASN1(rand.Reader, priv, digest[:])
+	require.NoError(t, err)
+
+	key := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	return string(key), []byte(base64.StdEncoding.EncodeToString(sig))
+}
+
+func TestReleaseVerify(t *testing.T) {
+	t.Parallel()
+	binary := []byte("binary")
+	digest := sha256.Sum256(binary)
+	sums := []byte(hex.EncodeToString(digest[:]) + "  app_linux_amd64.tar.gz\n")
+	badSums := []byte(strings.Repeat("0", 64) + "  app_linux_amd64.tar.gz\n")
+	minisignKey, minisignSig := minisign(t, sums)
+	cosignKey, cosignSig := cosign(t, sums)
+	otherKey, _ := cosign(t, sums)
+
+	tests := []struct {
+		name        string
+		assets      map[string][]byte
+		opts        *Options
+		discarded   string
+		expectedSum string
+		expectedErr error
+	}{
+		{
+			name:        "checksums",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": sums},
+			opts:        &Options{},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "sha256 file",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "app_linux_amd64.tar.gz.sha256": []byte(hex.EncodeToString(digest[:]))},
+			opts:        &Options{Verify: verifyRequired},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "minisign signature",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "SHA256SUMS": sums, "SHA256SUMS.minisig": minisignSig},
+			opts:        &Options{Verify: verifyRequired, PublicKey: minisignKey},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "cosign signature",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": sums, "checksums.txt.sig": cosignSig},
+			opts:        &Options{PublicKey: cosignKey},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "without checksums",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary},
+			opts:        &Options{},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "verification off",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": badSums},
+			opts:        &Options{Verify: verifyOff},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "error checksum mismatch",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": badSums},
+			opts:        &Options{},
+			expectedErr: fmt.Errorf("failed to download: %w", fmt.Errorf("%w: %s", ErrChecksumMismatch, "app_linux_amd64.tar.gz")),
+		},
+		{
+			name:        "error required checksums",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary},
+			opts:        &Options{Verify: verifyRequired},
+			expectedErr: fmt.Errorf("%w: no checksum file in the release", ErrNoChecksum),
+		},
+		{
+			name:        "error required checksum",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "other.tar.gz": binary, "checksums.txt": sums},
+			opts:        &Options{Verify: verifyRequired, Asset: "other.tar.gz"},
+			discarded:   "other.tar.gz",
+			expectedErr: fmt.Errorf("failed to download: %w", fmt.Errorf("%w: %s", ErrNoChecksum, "other.tar.gz")),
+		},
+		{
+			name:        "error unsigned checksum with key",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "other.tar.gz": binary, "checksums.txt": sums, "checksums.txt.sig": cosignSig},
+			opts:        &Options{PublicKey: cosignKey, Asset: "other.tar.gz"},
+			discarded:   "other.tar.gz",
+			expectedErr: fmt.Errorf("failed to download: %w", fmt.Errorf("%w: %s", ErrNoChecksum, "other.tar.gz")),
+		},
+		{
+			name:        "unsigned checksum with key and verification off",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": badSums},
+			opts:        &Options{Verify: verifyOff, PublicKey: cosignKey},
+			expectedSum: hex.EncodeToString(digest[:]),
+		},
+		{
+			name:        "error missing signature",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": sums},
+			opts:        &Options{PublicKey: cosignKey},
+			expectedErr: fmt.Errorf("%w: %s", ErrNoSignature, "checksums.txt"),
+		},
+		{
+			name:        "error wrong key",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "checksums.txt": sums, "checksums.txt.sig": cosignSig},
+			opts:        &Options{PublicKey: otherKey},
+			expectedErr: fmt.Errorf("%w: %s", ErrBadSignature, "checksums.txt"),
+		},
+		{
+			name:        "error tampered checksums",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary, "SHA256SUMS": badSums, "SHA256SUMS.minisig": minisignSig},
+			opts:        &Options{PublicKey: minisignKey},
+			expectedErr: fmt.Errorf("%w: %s", ErrBadSignature, "SHA256SUMS"),
+		},
+		{
+			name:        "error key",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary},
+			opts:        &Options{PublicKey: "not a key"},
+			expectedErr: ErrNotValidKey,
+		},
+		{
+			name:        "error verify mode",
+			assets:      map[string][]byte{"app_linux_amd64.tar.gz": binary},
+			opts:        &Options{Verify: "sometimes"},
+			expectedErr: ErrNotValidVerify,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fakeRepo := fmt.Sprintf("%s_%d", gofakeit.LoremIpsumWord(), gofakeit.Int())
+			fakeDest := fakeRepo + "-v1.0.0"
+			t.Cleanup(func() {
+				err := os.RemoveAll(fakeDest)
+				require.NoError(t, err)
+			})
+
+			g := &GitHub{Client: &service{client: releaseServer(t, test.assets)}}
+			test.opts.Log = io.Discard
+			if test.opts.Asset == "" {
+				test.opts.Asset = "*.tar.gz"
+			}
+			err := g.configure(test.opts)
+			require.NoError(t, err)
+			err = g.extract("owner/" + fakeRepo)
+			require.NoError(t, err)
+			err = g.resolveRelease(context.Background())
+			if err == nil {
+				err = g.download(context.Background())
+			}
+			assert.Equal(t, test.expectedErr, err)
+
+			if test.expectedSum == "" {
+				// Assets which fail the verification are not kept.
+				discarded := test.discarded
+				if discarded == "" {
+					discarded = "app_linux_amd64.tar.gz"
+				}
+				_, errStat := os.Stat(filepath.Join(fakeDest, discarded))
+				assert.True(t, os.IsNotExist(errStat))
+				return
+			}
+			_, err = os.Stat(filepath.Join(fakeDest, "app_linux_amd64.tar.gz"))
+			require.NoError(t, err)
+			files := g.result().Files
+			require.NotEmpty(t, files)
+			for _, f := range files {
+				if f.RemotePath == "app_linux_amd64.tar.gz" {
+					assert.Equal(t, test.expectedSum, f.SHA256)
+				}
+			}
+		})
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	t.Parallel()
+	sum := strings.Repeat("ab", 32)
+	tests := []struct {
+		name     string
+		file     string
+		data     string
+		expected map[string]string
+	}{
+		{
+			name:     "text and binary modes",
+			file:     "checksums.txt",
+			data:     sum + "  a.tar.gz\n" + strings.ToUpper(sum) + " *./b.zip\n\n# comment\n",
+			expected: map[string]string{"a.tar.gz": sum, "b.zip": sum},
+		},
+		{
+			name:     "single checksum",
+			file:     "a.tar.gz.sha256",
+			data:     sum + "\n",
+			expected: map[string]string{"a.tar.gz": sum},
+		},
+		{
+			name:     "not a checksum",
+			file:     "checksums.txt",
+			data:     "abc  a.tar.gz\n",
+			expected: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, parseChecksums(test.file, []byte(test.data)))
+		})
+	}
+}
+
+func TestIsChecksumFile(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		file     string
+		expected bool
+	}{
+		{name: "sha256sums", file: "SHA256SUMS", expected: true},
+		{name: "checksums", file: "checksums.txt", expected: true},
+		{name: "goreleaser checksums", file: "gitty_1.0.0_checksums.txt", expected: true},
+		{name: "sha256 file", file: "gitty.tar.gz.sha256", expected: true},
+		{name: "asset", file: "gitty.tar.gz", expected: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, isChecksumFile(test.file))
+		})
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	t.Parallel()
+	msg := []byte("message")
+	minisignKey, _ := minisign(t, msg)
+	cosignKey, _ := cosign(t, msg)
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	ed25519Key := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	prehashed := base64.StdEncoding.EncodeToString(append([]byte("ED"), make([]byte, minisignKeySize-2)...))
+
+	tests := []struct {
+		name           string
+		key            string
+		expectedSuffix string
+		expectedErr    error
+	}{
+		{name: "minisign file", key: minisignKey, expectedSuffix: minisignSuffix},
+		{name: "minisign key", key: strings.Split(minisignKey, "\n")[1], expectedSuffix: minisignSuffix},
+		{name: "ecdsa", key: cosignKey, expectedSuffix: cosignSuffix},
+		{name: "ed25519", key: ed25519Key, expectedSuffix: cosignSuffix},
+		{name: "error minisign algorithm", key: prehashed, expectedErr: ErrNotValidKey},
+		{name: "error not base64", key: "RW!", expectedErr: ErrNotValidKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			key, err := parsePublicKey(test.key)
+			assert.Equal(t, test.expectedErr, err)
+			if err == nil {
+				assert.Equal(t, test.expectedSuffix, key.suffix())
+			}
+		})
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	t.Parallel()
+	msg := []byte("message")
+	minisignPub, minisignSig := minisign(t, msg)
+	minisignKey, err := parsePublicKey(minisignPub)
+	require.NoError(t, err)
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	ed25519Key, err := parsePublicKey(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})))
+	require.NoError(t, err)
+	ed25519Sig := []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, msg)))
+
+	lines := strings.Split(string(minisignSig), "\n")
+	badComment := strings.Join([]string{lines[0], lines[1], trustedComment + "forged", lines[3]}, "\n")
+
+	hashedKey, err := parsePublicKey(minisignHashedPub)
+	require.NoError(t, err)
+	lines = strings.Split(minisignHashedSig, "\n")
+	b, err := base64.StdEncoding.DecodeString(lines[1])
+	require.NoError(t, err)
+	lines[1] = base64.StdEncoding.EncodeToString(append([]byte("Xx"), b[2:]...))
+	badAlgorithm := strings.Join(lines, "\n")
+
+	tests := []struct {
+		name     string
+		key      publicKey
+		msg      []byte
+		sig      []byte
+		expected error
+	}{
+		{name: "minisign", key: minisignKey, msg: msg, sig: minisignSig},
+		{name: "minisign prehashed", key: hashedKey, msg: []byte(minisignSums), sig: []byte(minisignHashedSig)},
+		{name: "ed25519", key: ed25519Key, msg: msg, sig: ed25519Sig},
+		{name: "error minisign message", key: minisignKey, msg: []byte("other"), sig: minisignSig, expected: ErrBadSignature},
+		{name: "error minisign trusted comment", key: minisignKey, msg: msg, sig: []byte(badComment), expected: ErrBadSignature},
+		{name: "error minisign format", key: minisignKey, msg: msg, sig: []byte("signature"), expected: ErrBadSignature},
+		{name: "error minisign prehashed message", key: hashedKey, msg: msg, sig: []byte(minisignHashedSig), expected: ErrBadSignature},
+		{name: "error minisign algorithm", key: hashedKey, msg: []byte(minisignSums), sig: []byte(badAlgorithm), expected: ErrBadSignature},
+		{name: "error ed25519 message", key: ed25519Key, msg: []byte("other"), sig: ed25519Sig, expected: ErrBadSignature},
+		{name: "error not base64", key: ed25519Key, msg: msg, sig: []byte("!"), expected: ErrBadSignature},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.expected, test.key.verify(test.msg, test.sig))
+		})
+	}
+}
@@ -5,6 +5,7 @@ go 1.23.0
 require (
 	github.com/brianvoe/gofakeit/v7 v7.2.1
 	github.com/google/go-github/v70 v70.0.0
+	github.com/klauspost/compress v1.18.0
 	github.com/spf13/cobra v1.9.1
 	github.com/stretchr/testify v1.10.0
 )